@@ -0,0 +1,134 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/suipic/backend/config"
+	"github.com/suipic/backend/services"
+)
+
+func main() {
+	var action string
+	var albumID int
+	var photographerID int
+	var path string
+	var renditions bool
+	flag.StringVar(&action, "action", "export", "Bundle action: export or import")
+	flag.IntVar(&albumID, "album", 0, "Album ID to export")
+	flag.IntVar(&photographerID, "photographer", 0, "Photographer ID to export, or to own imported albums")
+	flag.StringVar(&path, "path", "", "Bundle location: a directory, or a file ending in .zip")
+	flag.BoolVar(&renditions, "renditions", false, "Include thumbnails in the export")
+	flag.Parse()
+
+	if path == "" {
+		log.Fatal("-path is required")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	dbService, err := services.NewDatabaseService(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database service: %v", err)
+	}
+	defer dbService.Close()
+
+	storageService, err := services.NewStorageService(&cfg.MinIO)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage service: %v", err)
+	}
+
+	esService, err := services.NewElasticsearchService(&cfg.Elasticsearch)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize elasticsearch service: %v", err)
+		esService = nil
+	}
+
+	albumService := services.NewAlbumService(dbService.GetDB())
+	systemSettingsService := services.NewSystemSettingsService(dbService.GetSystemSettingsRepo())
+	photoService := services.NewPhotoService(dbService.GetPhotoRepo(), storageService, esService, albumService, dbService.GetCommentRepo(), systemSettingsService, nil)
+	exportService := services.NewExportService(albumService, photoService, storageService, dbService.GetPhotoRepo(), dbService.GetCommentRepo(), dbService.GetUserRepo())
+
+	ctx := context.Background()
+
+	switch action {
+	case "export":
+		if (albumID == 0) == (photographerID == 0) {
+			log.Fatal("Specify exactly one of -album or -photographer")
+		}
+
+		bundle, closeFile := openBundleWriter(path)
+		opts := services.ExportOptions{IncludeRenditions: renditions}
+
+		var manifest *services.ExportManifest
+		if albumID != 0 {
+			manifest, err = exportService.ExportAlbum(ctx, albumID, opts, bundle)
+		} else {
+			manifest, err = exportService.ExportPhotographer(ctx, photographerID, opts, bundle)
+		}
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		if err := bundle.Close(); err != nil {
+			log.Fatalf("Failed to finalize bundle: %v", err)
+		}
+		closeFile()
+
+		log.Printf("Exported %d album(s) to %s", len(manifest.Albums), path)
+	case "import":
+		if photographerID == 0 {
+			log.Fatal("-photographer is required for import")
+		}
+
+		bundle, closeFile := openBundleReader(path)
+		defer closeFile()
+
+		result, err := exportService.Import(ctx, bundle, photographerID)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+
+		log.Printf("Imported %d album(s), %d photo(s), %d comment(s)", len(result.Albums), result.PhotoCount, result.CommentCount)
+		if len(result.SkippedUsers) > 0 {
+			log.Printf("Users not found on this instance, skipped: %s", strings.Join(result.SkippedUsers, ", "))
+		}
+	default:
+		log.Fatalf("Unknown action: %s (use 'export' or 'import')", action)
+	}
+}
+
+func openBundleWriter(path string) (services.BundleWriter, func()) {
+	if strings.HasSuffix(path, ".zip") {
+		file, err := os.Create(path)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", path, err)
+		}
+		return services.NewZipBundleWriter(file), func() { file.Close() }
+	}
+
+	bundle, err := services.NewDirBundleWriter(path)
+	if err != nil {
+		log.Fatalf("Failed to prepare %s: %v", path, err)
+	}
+	return bundle, func() {}
+}
+
+func openBundleReader(path string) (fs.FS, func()) {
+	if strings.HasSuffix(path, ".zip") {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", path, err)
+		}
+		return archive, func() { archive.Close() }
+	}
+
+	return os.DirFS(path), func() {}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -17,11 +18,22 @@ type Config struct {
 	JWT           JWTConfig
 	CORS          CORSConfig
 	Admin         AdminConfig
+	Image         ImageConfig
+	Geocoder      GeocoderConfig
+	Mail          MailConfig
+	MFA           MFAConfig
+	OIDC          OIDCConfig
+	Captcha       CaptchaConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Env  string
+	Port        string
+	Env         string
+	ProxyHeader string
+	// TrustedProxies lists the addresses or CIDR ranges whose ProxyHeader
+	// is believed. When empty, the header is believed from anyone.
+	TrustedProxies []string
+	PublicURL      string
 }
 
 type DatabaseConfig struct {
@@ -48,14 +60,83 @@ type MinIOConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string
-	Expiry string
+	Secret           string
+	SigningKey       string
+	VerificationKeys []string
+	Expiry           string
+	RefreshExpiry    string
+}
+
+// DefaultJWTSecret is the JWT_SECRET used when none is configured. It is
+// public, so production refuses to start with it.
+const DefaultJWTSecret = "your-secret-key-change-this-in-production"
+
+// placeholderSecrets are DefaultJWTSecret and the stand-ins from the example
+// compose files, none of which may be used in production.
+var placeholderSecrets = []string{
+	DefaultJWTSecret,
+	"change-this-in-production-use-strong-secret",
+	"changeme",
 }
 
 type CORSConfig struct {
 	Origins []string
 }
 
+type ImageConfig struct {
+	SigningSecret string
+}
+
+type GeocoderConfig struct {
+	CitiesPath    string
+	Admin1Path    string
+	MaxDistanceKm float64
+}
+
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPTLS      string
+	FileDir      string
+}
+
+type MFAConfig struct {
+	Issuer        string
+	EncryptionKey string
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+}
+
+// OIDCProviderConfig describes one OpenID Connect identity provider users can
+// sign in with. Role mapping looks at RoleClaim in the ID token; a user whose
+// claim contains one of AdminValues becomes an admin, one of
+// PhotographerValues a photographer.
+type OIDCProviderConfig struct {
+	ID                 string
+	Name               string
+	Issuer             string
+	ClientID           string
+	ClientSecret       string
+	Scopes             []string
+	RedirectURL        string
+	RoleClaim          string
+	AdminValues        []string
+	PhotographerValues []string
+	AllowSignup        bool
+}
+
+type CaptchaConfig struct {
+	Provider string
+	SiteKey  string
+	Secret   string
+}
+
 type AdminConfig struct {
 	Email    string
 	Password string
@@ -71,8 +152,11 @@ func Load() (*Config, error) {
 
 	config := &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "3000"),
-			Env:  getEnv("ENV", "development"),
+			Port:           getEnv("PORT", "3000"),
+			Env:            getEnv("ENV", "development"),
+			ProxyHeader:    getEnv("PROXY_HEADER", ""),
+			TrustedProxies: getListEnv("TRUSTED_PROXIES", nil),
+			PublicURL:      strings.TrimRight(getEnv("PUBLIC_URL", "http://localhost:5173"), "/"),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -95,8 +179,11 @@ func Load() (*Config, error) {
 			Bucket:    getEnv("MINIO_BUCKET", "suipic"),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
-			Expiry: getEnv("JWT_EXPIRY", "24h"),
+			Secret:           getEnv("JWT_SECRET", DefaultJWTSecret),
+			SigningKey:       getEnv("JWT_SIGNING_KEY", ""),
+			VerificationKeys: getListEnv("JWT_VERIFICATION_KEYS", nil),
+			Expiry:           getEnv("JWT_EXPIRY", "15m"),
+			RefreshExpiry:    getEnv("JWT_REFRESH_EXPIRY", "720h"),
 		},
 		CORS: CORSConfig{
 			Origins: strings.Split(getEnv("CORS_ORIGINS", "http://localhost:5173,http://localhost:3001"), ","),
@@ -106,11 +193,54 @@ func Load() (*Config, error) {
 			Password: getEnv("ADMIN_PASSWORD", ""),
 			Username: getEnv("ADMIN_USERNAME", ""),
 		},
+		Image: ImageConfig{
+			SigningSecret: getEnv("IMAGE_SIGNING_SECRET", ""),
+		},
+		Geocoder: GeocoderConfig{
+			CitiesPath:    getEnv("GEOCODER_CITIES_PATH", ""),
+			Admin1Path:    getEnv("GEOCODER_ADMIN1_PATH", ""),
+			MaxDistanceKm: getFloatEnv("GEOCODER_MAX_DISTANCE_KM", 100),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "Suipic <no-reply@suipic.local>"),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			SMTPTLS:      getEnv("SMTP_TLS", "starttls"),
+			FileDir:      getEnv("MAIL_FILE_DIR", "./mail"),
+		},
+		MFA: MFAConfig{
+			Issuer:        getEnv("MFA_ISSUER", "Suipic"),
+			EncryptionKey: getEnv("MFA_ENCRYPTION_KEY", ""),
+		},
+		OIDC: OIDCConfig{
+			Providers: loadOIDCProviders(),
+		},
+		Captcha: CaptchaConfig{
+			Provider: getEnv("CAPTCHA_PROVIDER", ""),
+			SiteKey:  getEnv("CAPTCHA_SITE_KEY", ""),
+			Secret:   getEnv("CAPTCHA_SECRET", ""),
+		},
+	}
+
+	if config.Server.Env == "production" && config.UsesPlaceholderSecret() {
+		return nil, fmt.Errorf("JWT_SECRET is set to a placeholder; generate one with `openssl rand -base64 32` before running in production")
+	}
+	if config.Server.Env == "production" && config.MFA.EncryptionKey == "" {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY is not set; generate one with `openssl rand -base64 32` before running in production")
 	}
 
 	return config, nil
 }
 
+// UsesPlaceholderSecret reports whether a publicly known JWT_SECRET is used
+// to sign tokens, and so to derive the internal and image signing keys.
+func (c *Config) UsesPlaceholderSecret() bool {
+	return c.JWT.SigningKey == "" && slices.Contains(placeholderSecrets, c.JWT.Secret)
+}
+
 func (c *DatabaseConfig) ConnectionString() string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
@@ -118,6 +248,30 @@ func (c *DatabaseConfig) ConnectionString() string {
 	)
 }
 
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS. Each one is
+// configured through variables named after its ID, e.g. OIDC_STUDIO_ISSUER
+// for a provider called "studio".
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, id := range getListEnv("OIDC_PROVIDERS", nil) {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			ID:                 id,
+			Name:               getEnv(prefix+"NAME", id),
+			Issuer:             strings.TrimRight(getEnv(prefix+"ISSUER", ""), "/"),
+			ClientID:           getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:       getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:             getListEnv(prefix+"SCOPES", []string{"openid", "email", "profile"}),
+			RedirectURL:        getEnv(prefix+"REDIRECT_URL", ""),
+			RoleClaim:          getEnv(prefix+"ROLE_CLAIM", ""),
+			AdminValues:        getListEnv(prefix+"ADMIN_VALUES", nil),
+			PhotographerValues: getListEnv(prefix+"PHOTOGRAPHER_VALUES", nil),
+			AllowSignup:        getBoolEnv(prefix+"ALLOW_SIGNUP", true),
+		})
+	}
+	return providers
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -135,3 +289,29 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		floatValue, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return defaultValue
+		}
+		return floatValue
+	}
+	return defaultValue
+}
+
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_photos_original_filename;
+
+ALTER TABLE photos DROP COLUMN IF EXISTS original_filename;
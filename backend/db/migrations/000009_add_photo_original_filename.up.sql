@@ -0,0 +1,3 @@
+ALTER TABLE photos ADD COLUMN original_filename VARCHAR(500);
+
+CREATE INDEX idx_photos_original_filename ON photos(album_id, LOWER(original_filename));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_photos_keywords;
+
+ALTER TABLE photos DROP COLUMN IF EXISTS caption;
+ALTER TABLE photos DROP COLUMN IF EXISTS keywords;
@@ -0,0 +1,4 @@
+ALTER TABLE photos ADD COLUMN keywords TEXT[];
+ALTER TABLE photos ADD COLUMN caption TEXT;
+
+CREATE INDEX idx_photos_keywords ON photos USING GIN (keywords);
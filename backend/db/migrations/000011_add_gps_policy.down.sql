@@ -0,0 +1,3 @@
+DELETE FROM settings WHERE key IN ('gps_retain', 'gps_expose', 'gps_index', 'gps_strip');
+
+ALTER TABLE albums DROP COLUMN IF EXISTS gps_policy;
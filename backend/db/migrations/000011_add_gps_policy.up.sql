@@ -0,0 +1,9 @@
+ALTER TABLE albums ADD COLUMN gps_policy JSONB;
+
+INSERT INTO settings (key, value, updated_at)
+VALUES
+    ('gps_retain', 'true', NOW()),
+    ('gps_expose', 'true', NOW()),
+    ('gps_index', 'true', NOW()),
+    ('gps_strip', 'true', NOW())
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_photos_place;
+
+ALTER TABLE photos DROP COLUMN IF EXISTS city;
+ALTER TABLE photos DROP COLUMN IF EXISTS region;
+ALTER TABLE photos DROP COLUMN IF EXISTS country;
+ALTER TABLE photos DROP COLUMN IF EXISTS country_code;
@@ -0,0 +1,6 @@
+ALTER TABLE photos ADD COLUMN country_code VARCHAR(2);
+ALTER TABLE photos ADD COLUMN country VARCHAR(255);
+ALTER TABLE photos ADD COLUMN region VARCHAR(255);
+ALTER TABLE photos ADD COLUMN city VARCHAR(255);
+
+CREATE INDEX idx_photos_place ON photos(album_id, country_code, city);
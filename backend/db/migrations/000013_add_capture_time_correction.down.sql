@@ -0,0 +1,3 @@
+ALTER TABLE photos DROP COLUMN IF EXISTS time_shift;
+
+ALTER TABLE albums DROP COLUMN IF EXISTS timezone;
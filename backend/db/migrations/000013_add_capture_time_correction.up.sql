@@ -0,0 +1,3 @@
+ALTER TABLE albums ADD COLUMN timezone VARCHAR(64);
+
+ALTER TABLE photos ADD COLUMN time_shift INTEGER NOT NULL DEFAULT 0;
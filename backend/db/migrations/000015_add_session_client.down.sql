@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_sessions_user_active;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS device;
+ALTER TABLE sessions DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE sessions DROP COLUMN IF EXISTS ip_address;
@@ -0,0 +1,5 @@
+ALTER TABLE sessions ADD COLUMN ip_address VARCHAR(45);
+ALTER TABLE sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN device VARCHAR(255);
+
+CREATE INDEX idx_sessions_user_active ON sessions(user_id, last_used_at DESC) WHERE revoked_at IS NULL;
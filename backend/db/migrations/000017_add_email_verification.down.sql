@@ -0,0 +1,3 @@
+DELETE FROM settings WHERE key = 'require_email_verification';
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
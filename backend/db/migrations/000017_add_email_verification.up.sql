@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Accounts that existed before verification was introduced are trusted.
+UPDATE users SET email_verified_at = created_at;
+
+INSERT INTO settings (key, value, updated_at)
+VALUES ('require_email_verification', 'false', NOW())
+ON CONFLICT (key) DO NOTHING;
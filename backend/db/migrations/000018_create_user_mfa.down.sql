@@ -0,0 +1,4 @@
+DELETE FROM settings WHERE key = 'require_mfa_privileged';
+
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
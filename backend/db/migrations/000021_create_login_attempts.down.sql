@@ -0,0 +1,10 @@
+DELETE FROM settings WHERE key IN (
+    'login_max_failures_account',
+    'login_max_failures_ip',
+    'login_failure_window_minutes',
+    'login_lockout_minutes',
+    'login_backoff_base_seconds'
+);
+
+DROP TABLE IF EXISTS login_throttles;
+DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,31 @@
+CREATE TABLE login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    identifier VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_attempts_user ON login_attempts(user_id, created_at DESC);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip_address, created_at DESC);
+CREATE INDEX idx_login_attempts_created ON login_attempts(created_at DESC);
+
+CREATE TABLE login_throttles (
+    kind VARCHAR(16) NOT NULL,
+    subject VARCHAR(64) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (kind, subject)
+);
+
+INSERT INTO settings (key, value, updated_at) VALUES
+    ('login_max_failures_account', '5', NOW()),
+    ('login_max_failures_ip', '20', NOW()),
+    ('login_failure_window_minutes', '15', NOW()),
+    ('login_lockout_minutes', '15', NOW()),
+    ('login_backoff_base_seconds', '1', NOW())
+ON CONFLICT (key) DO NOTHING;
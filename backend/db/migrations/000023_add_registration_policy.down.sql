@@ -0,0 +1,4 @@
+DELETE FROM settings WHERE key IN (
+    'registration_mode',
+    'registration_allowed_domains'
+);
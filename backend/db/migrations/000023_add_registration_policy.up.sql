@@ -0,0 +1,4 @@
+INSERT INTO settings (key, value, updated_at) VALUES
+    ('registration_mode', 'clients', NOW()),
+    ('registration_allowed_domains', '', NOW())
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,18 @@
+CREATE TABLE client_invites (
+    id SERIAL PRIMARY KEY,
+    photographer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    friendly_name VARCHAR(255) NOT NULL DEFAULT '',
+    album_ids INTEGER[] NOT NULL DEFAULT '{}',
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    accepted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_client_invites_photographer ON client_invites(photographer_id);
+CREATE UNIQUE INDEX idx_client_invites_open ON client_invites(photographer_id, LOWER(email))
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
@@ -0,0 +1,29 @@
+CREATE TABLE share_links (
+    id SERIAL PRIMARY KEY,
+    album_id INTEGER NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
+    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    token_prefix VARCHAR(16) NOT NULL,
+    photo_ids INTEGER[],
+    password_hash VARCHAR(255),
+    mode VARCHAR(10) NOT NULL DEFAULT 'view' CHECK (mode IN ('view', 'pick')),
+    allow_download BOOLEAN NOT NULL DEFAULT false,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_share_links_album ON share_links(album_id);
+
+CREATE TABLE share_link_accesses (
+    id BIGSERIAL PRIMARY KEY,
+    share_link_id INTEGER NOT NULL REFERENCES share_links(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL,
+    photo_id INTEGER REFERENCES photos(id) ON DELETE SET NULL,
+    ip_address VARCHAR(64),
+    user_agent VARCHAR(512),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_share_link_accesses_link ON share_link_accesses(share_link_id, created_at DESC);
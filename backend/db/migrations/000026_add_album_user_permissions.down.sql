@@ -0,0 +1,5 @@
+ALTER TABLE album_users
+    DROP COLUMN IF EXISTS can_share,
+    DROP COLUMN IF EXISTS can_download,
+    DROP COLUMN IF EXISTS can_rate,
+    DROP COLUMN IF EXISTS can_comment;
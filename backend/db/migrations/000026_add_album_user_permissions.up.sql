@@ -0,0 +1,7 @@
+-- Being assigned to an album lets a user view it; these flags control what
+-- else they may do there. Existing assignments keep what they could do before.
+ALTER TABLE album_users
+    ADD COLUMN can_comment BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN can_rate BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN can_download BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN can_share BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_photos_uploaded_by;
+ALTER TABLE photos DROP COLUMN IF EXISTS uploaded_by;
+DROP TABLE IF EXISTS album_collaborators;
@@ -0,0 +1,16 @@
+CREATE TABLE album_collaborators (
+    id SERIAL PRIMARY KEY,
+    album_id INTEGER NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT unique_album_collaborator UNIQUE (album_id, user_id)
+);
+
+CREATE INDEX idx_album_collaborators_user_id ON album_collaborators(user_id);
+
+-- Photos uploaded before collaborators existed were all uploaded by the
+-- album's photographer, which is what a NULL uploader stands for.
+ALTER TABLE photos ADD COLUMN uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_photos_uploaded_by ON photos(uploaded_by);
@@ -0,0 +1,157 @@
+// Package geocoder resolves coordinates to the nearest known city without
+// calling out to an external service.
+package geocoder
+
+import (
+	"math"
+	"strings"
+
+	"github.com/suipic/backend/config"
+)
+
+const earthRadiusKm = 6371.0
+
+// Place is where a photo was taken, at city granularity.
+type Place struct {
+	CountryCode string
+	Country     string
+	Region      string
+	City        string
+}
+
+// Label renders the place as "City, Region, Country", leaving out empty
+// parts and a region that merely repeats the city.
+func (p *Place) Label() string {
+	parts := make([]string, 0, 3)
+	if p.City != "" {
+		parts = append(parts, p.City)
+	}
+	if p.Region != "" && !strings.EqualFold(p.Region, p.City) {
+		parts = append(parts, p.Region)
+	}
+	if p.Country != "" {
+		parts = append(parts, p.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+type city struct {
+	name        string
+	region      string
+	countryCode string
+	lat         float64
+	lon         float64
+}
+
+type cell struct {
+	lat int
+	lon int
+}
+
+type Geocoder struct {
+	cities      []city
+	grid        map[cell][]int
+	countries   map[string]string
+	maxDistance float64
+}
+
+// New loads the cities dataset, falling back to the bundled one when no path
+// is configured, and indexes it for nearest-city lookups.
+func New(cfg *config.GeocoderConfig) (*Geocoder, error) {
+	cities, err := loadCities(cfg.CitiesPath, cfg.Admin1Path)
+	if err != nil {
+		return nil, err
+	}
+
+	countries, err := loadCountries()
+	if err != nil {
+		return nil, err
+	}
+
+	maxDistance := cfg.MaxDistanceKm
+	if maxDistance <= 0 {
+		maxDistance = 100
+	}
+
+	g := &Geocoder{
+		cities:      cities,
+		grid:        make(map[cell][]int),
+		countries:   countries,
+		maxDistance: maxDistance,
+	}
+	for i, c := range cities {
+		key := cellFor(c.lat, c.lon)
+		g.grid[key] = append(g.grid[key], i)
+	}
+
+	return g, nil
+}
+
+// Len returns the number of cities loaded.
+func (g *Geocoder) Len() int {
+	return len(g.cities)
+}
+
+// Lookup returns the city nearest to the given coordinates, or nil when none
+// lies within the configured maximum distance.
+func (g *Geocoder) Lookup(lat, lon float64) *Place {
+	if g == nil || math.IsNaN(lat) || math.IsNaN(lon) || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil
+	}
+
+	// Cells are one degree square; widen the longitude span towards the poles
+	// where a degree covers less ground.
+	latSpan := int(math.Ceil(g.maxDistance / 111.0))
+	lonSpan := 180
+	if cos := math.Cos(lat * math.Pi / 180); cos > 0.01 {
+		lonSpan = min(int(math.Ceil(g.maxDistance/(111.0*cos))), 180)
+	}
+
+	origin := cellFor(lat, lon)
+	best := -1
+	bestDistance := g.maxDistance
+	for dLat := -latSpan; dLat <= latSpan; dLat++ {
+		for dLon := -lonSpan; dLon <= lonSpan; dLon++ {
+			key := cell{lat: origin.lat + dLat, lon: wrapLongitude(origin.lon + dLon)}
+			for _, i := range g.grid[key] {
+				if distance := haversine(lat, lon, g.cities[i].lat, g.cities[i].lon); distance <= bestDistance {
+					best = i
+					bestDistance = distance
+				}
+			}
+		}
+	}
+
+	if best < 0 {
+		return nil
+	}
+
+	c := g.cities[best]
+	country := g.countries[c.countryCode]
+	if country == "" {
+		country = c.countryCode
+	}
+	return &Place{
+		CountryCode: c.countryCode,
+		Country:     country,
+		Region:      c.region,
+		City:        c.name,
+	}
+}
+
+func cellFor(lat, lon float64) cell {
+	return cell{lat: int(math.Floor(lat)), lon: wrapLongitude(int(math.Floor(lon)))}
+}
+
+func wrapLongitude(lon int) int {
+	return ((lon+180)%360+360)%360 - 180
+}
+
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
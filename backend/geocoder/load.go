@@ -0,0 +1,151 @@
+package geocoder
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//go:embed data/cities.tsv data/countries.tsv
+var bundled embed.FS
+
+// GeoNames dumps (cities500.txt, cities1000.txt, ...) have 19 columns; the
+// bundled file has six.
+const (
+	geonamesColumns = 15
+
+	geonamesName        = 1
+	geonamesLatitude    = 4
+	geonamesLongitude   = 5
+	geonamesCountryCode = 8
+	geonamesAdmin1      = 10
+)
+
+// loadCities reads either the bundled dataset or, when path is set, a file in
+// the same format or a GeoNames cities dump. For GeoNames dumps the region
+// comes from admin1Path (admin1CodesASCII.txt) when one is given.
+func loadCities(path, admin1Path string) ([]city, error) {
+	var regions map[string]string
+	if admin1Path != "" {
+		var err error
+		regions, err = loadAdmin1(admin1Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var r io.Reader
+	if path == "" {
+		data, err := bundled.ReadFile("data/cities.tsv")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundled cities: %w", err)
+		}
+		r = bytes.NewReader(data)
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cities file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var cities []city
+	err := eachRecord(r, func(fields []string) {
+		if c, ok := parseCity(fields, regions); ok {
+			cities = append(cities, c)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cities: %w", err)
+	}
+	if len(cities) == 0 {
+		return nil, fmt.Errorf("no cities found in dataset")
+	}
+
+	return cities, nil
+}
+
+func parseCity(fields []string, regions map[string]string) (city, bool) {
+	var c city
+	var lat, lon string
+
+	if len(fields) >= geonamesColumns {
+		c.name = fields[geonamesName]
+		c.countryCode = fields[geonamesCountryCode]
+		c.region = regions[c.countryCode+"."+fields[geonamesAdmin1]]
+		lat, lon = fields[geonamesLatitude], fields[geonamesLongitude]
+	} else if len(fields) >= 5 {
+		c.name = fields[0]
+		c.region = fields[1]
+		c.countryCode = fields[2]
+		lat, lon = fields[3], fields[4]
+	} else {
+		return city{}, false
+	}
+
+	var err error
+	if c.lat, err = strconv.ParseFloat(lat, 64); err != nil {
+		return city{}, false
+	}
+	if c.lon, err = strconv.ParseFloat(lon, 64); err != nil {
+		return city{}, false
+	}
+	c.countryCode = strings.ToUpper(c.countryCode)
+
+	return c, c.name != ""
+}
+
+func loadAdmin1(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open admin1 codes file: %w", err)
+	}
+	defer f.Close()
+
+	regions := make(map[string]string)
+	err = eachRecord(f, func(fields []string) {
+		if len(fields) >= 2 {
+			regions[fields[0]] = fields[1]
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin1 codes: %w", err)
+	}
+	return regions, nil
+}
+
+func loadCountries() (map[string]string, error) {
+	data, err := bundled.ReadFile("data/countries.tsv")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundled countries: %w", err)
+	}
+
+	countries := make(map[string]string)
+	err = eachRecord(bytes.NewReader(data), func(fields []string) {
+		if len(fields) >= 2 {
+			countries[fields[0]] = fields[1]
+		}
+	})
+	return countries, err
+}
+
+// eachRecord calls fn with the tab-separated fields of every non-empty,
+// non-comment line.
+func eachRecord(r io.Reader, fn func(fields []string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fn(strings.Split(line, "\t"))
+	}
+	return scanner.Err()
+}
@@ -3,6 +3,9 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
+	"maps"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/suipic/backend/models"
@@ -13,13 +16,15 @@ type AdminHandler struct {
 	authService           *services.AuthService
 	dbService             *services.DatabaseService
 	systemSettingsService *services.SystemSettingsService
+	auditService          *services.AuditService
 }
 
-func NewAdminHandler(authService *services.AuthService, dbService *services.DatabaseService, systemSettingsService *services.SystemSettingsService) *AdminHandler {
+func NewAdminHandler(authService *services.AuthService, dbService *services.DatabaseService, systemSettingsService *services.SystemSettingsService, auditService *services.AuditService) *AdminHandler {
 	return &AdminHandler{
 		authService:           authService,
 		dbService:             dbService,
 		systemSettingsService: systemSettingsService,
+		auditService:          auditService,
 	}
 }
 
@@ -53,6 +58,14 @@ func (h *AdminHandler) CreatePhotographer(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
+	entry := auditEntry(c, models.AuditUserCreate, models.AuditTargetUser, user.ID)
+	entry.After = models.AuditValues{"username": user.Username, "email": user.Email, "role": user.Role}
+	h.auditService.Record(c.Context(), entry)
+
+	if err := h.authService.SendVerificationEmail(c.Context(), user); err != nil {
+		fmt.Printf("Warning: failed to send verification email to user %d: %v\n", user.ID, err)
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(CreatePhotographerResponse{
 		User:     user,
 		Password: password,
@@ -104,10 +117,23 @@ func (h *AdminHandler) UpdateSetting(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
 	}
 
+	var before models.AuditValues
+	if settings, err := h.systemSettingsService.GetAllSettings(c.Context()); err == nil {
+		if value, ok := settings[key]; ok {
+			before = models.AuditValues{"value": value}
+		}
+	}
+
 	if err := h.systemSettingsService.UpdateSetting(c.Context(), key, req.Value); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to update setting")
 	}
 
+	entry := auditEntry(c, models.AuditSettingUpdate, models.AuditTargetSetting, 0)
+	entry.TargetID = key
+	entry.Before = before
+	entry.After = models.AuditValues{"value": req.Value}
+	h.auditService.Record(c.Context(), entry)
+
 	return c.JSON(fiber.Map{
 		"message": "setting updated successfully",
 		"key":     key,
@@ -115,6 +141,50 @@ func (h *AdminHandler) UpdateSetting(c *fiber.Ctx) error {
 	})
 }
 
+func (h *AdminHandler) ListUserSessions(c *fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user ID")
+	}
+
+	sessions, err := h.authService.ListSessions(c.Context(), userID, "")
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list sessions")
+	}
+
+	return c.JSON(sessions)
+}
+
+// RevokeUserSessions signs a user out of every device, for example when
+// their account was shared or compromised.
+func (h *AdminHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user ID")
+	}
+
+	user, err := h.authService.GetUserByID(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
+	}
+	if user == nil {
+		return fiber.NewError(fiber.StatusNotFound, "user not found")
+	}
+
+	revoked, err := h.authService.RevokeAllSessions(c.Context(), user.ID, "", services.SessionRevokedAdmin)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke sessions")
+	}
+
+	entry := auditEntry(c, models.AuditSessionsRevoke, models.AuditTargetUser, user.ID)
+	entry.After = models.AuditValues{"revoked": revoked}
+	h.auditService.Record(c.Context(), entry)
+
+	return c.JSON(fiber.Map{
+		"revoked": revoked,
+	})
+}
+
 func generateRandomPassword(length int) (string, error) {
 	bytes := make([]byte, length)
 	if _, err := rand.Read(bytes); err != nil {
@@ -122,3 +192,406 @@ func generateRandomPassword(length int) (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(bytes)[:length], nil
 }
+
+// ResetUserMFA removes a user's second factor so they can sign in with their
+// password alone, for when they lost both their authenticator and their
+// recovery codes.
+func (h *AdminHandler) ResetUserMFA(c *fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user ID")
+	}
+
+	user, err := h.authService.GetUserByID(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
+	}
+	if user == nil {
+		return fiber.NewError(fiber.StatusNotFound, "user not found")
+	}
+
+	if err := h.authService.ResetMFA(c.Context(), user.ID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to reset two-factor authentication")
+	}
+
+	h.auditService.Record(c.Context(), auditEntry(c, models.AuditUserMFAReset, models.AuditTargetUser, user.ID))
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// UnlockUser lifts a lockout caused by failed logins.
+func (h *AdminHandler) UnlockUser(c *fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user ID")
+	}
+
+	user, err := h.authService.GetUserByID(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
+	}
+	if user == nil {
+		return fiber.NewError(fiber.StatusNotFound, "user not found")
+	}
+
+	unlocked, err := h.authService.UnlockUser(c.Context(), user.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to unlock user")
+	}
+	if unlocked {
+		h.auditService.Record(c.Context(), auditEntry(c, models.AuditUserUnlock, models.AuditTargetUser, user.ID))
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AdminHandler) UnlockIP(c *fiber.Ctx) error {
+	unlocked, err := h.authService.UnlockIP(c.Context(), c.Params("ip"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to unlock IP address")
+	}
+	if !unlocked {
+		return fiber.NewError(fiber.StatusNotFound, "IP address has no failed logins")
+	}
+
+	entry := auditEntry(c, models.AuditIPUnlock, models.AuditTargetIP, 0)
+	entry.TargetID = c.Params("ip")
+	h.auditService.Record(c.Context(), entry)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListLockouts returns the accounts and IP addresses locked out right now.
+func (h *AdminHandler) ListLockouts(c *fiber.Ctx) error {
+	lockouts, err := h.authService.ListLockouts(c.Context())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list lockouts")
+	}
+
+	return c.JSON(lockouts)
+}
+
+// ListLoginAttempts returns the login audit log, newest first, optionally
+// for one user or IP address.
+func (h *AdminHandler) ListLoginAttempts(c *fiber.Ctx) error {
+	filter := models.LoginAttemptFilter{
+		UserID:    int64(c.QueryInt("userId", 0)),
+		IPAddress: c.Query("ip"),
+		Limit:     c.QueryInt("limit", 100),
+	}
+	if filter.Limit < 1 || filter.Limit > 1000 {
+		return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 1000")
+	}
+
+	attempts, err := h.authService.ListLoginAttempts(c.Context(), filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list login attempts")
+	}
+
+	return c.JSON(attempts)
+}
+
+// adminTargetUser loads the user named by the :id route parameter.
+func (h *AdminHandler) adminTargetUser(c *fiber.Ctx) (*models.User, error) {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid user ID")
+	}
+
+	user, err := h.authService.GetUserByID(userID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
+	}
+	if user == nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "user not found")
+	}
+	return user, nil
+}
+
+// ListUsers returns a page of users, optionally filtered by role, status
+// and a search on username, email and friendly name.
+func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
+	filter := models.UserFilter{
+		Role:   models.UserRole(c.Query("role")),
+		Query:  c.Query("q"),
+		Status: c.Query("status"),
+		Limit:  c.QueryInt("limit", 50),
+		Offset: c.QueryInt("offset", 0),
+	}
+	switch filter.Role {
+	case "", models.RoleAdmin, models.RolePhotographer, models.RoleClient:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "invalid role")
+	}
+	switch filter.Status {
+	case "", models.UserStatusActive, models.UserStatusSuspended:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "status must be active or suspended")
+	}
+	if filter.Limit < 1 || filter.Limit > 200 {
+		return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 200")
+	}
+	if filter.Offset < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "offset must not be negative")
+	}
+
+	users, total, err := h.authService.ListUsers(c.Context(), filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list users")
+	}
+
+	return c.JSON(fiber.Map{
+		"users":  users,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+func (h *AdminHandler) GetUser(c *fiber.Ctx) error {
+	user, err := h.adminTargetUser(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(user)
+}
+
+type CreateUserRequest struct {
+	Email        string          `json:"email"`
+	Username     string          `json:"username"`
+	FriendlyName string          `json:"friendlyName"`
+	Password     string          `json:"password"`
+	Role         models.UserRole `json:"role"`
+}
+
+// CreateUser creates an account of any role. Without a password one is
+// generated and returned once, as for photographers.
+func (h *AdminHandler) CreateUser(c *fiber.Ctx) error {
+	var req CreateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Email == "" || req.Username == "" || req.Role == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "email, username and role are required")
+	}
+
+	password := req.Password
+	if password == "" {
+		var err error
+		password, err = generateRandomPassword(16)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to generate password")
+		}
+	}
+
+	user, err := h.authService.CreateUser(req.Email, req.Username, password, req.FriendlyName, req.Role)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	entry := auditEntry(c, models.AuditUserCreate, models.AuditTargetUser, user.ID)
+	entry.After = models.AuditValues{"username": user.Username, "email": user.Email, "role": user.Role}
+	h.auditService.Record(c.Context(), entry)
+
+	if err := h.authService.SendVerificationEmail(c.Context(), user); err != nil {
+		fmt.Printf("Warning: failed to send verification email to user %d: %v\n", user.ID, err)
+	}
+
+	response := CreatePhotographerResponse{User: user}
+	if req.Password == "" {
+		response.Password = password
+	}
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+type UpdateUserRequest struct {
+	Email        *string          `json:"email"`
+	Username     *string          `json:"username"`
+	FriendlyName *string          `json:"friendlyName"`
+	Role         *models.UserRole `json:"role"`
+}
+
+func (h *AdminHandler) UpdateUser(c *fiber.Ctx) error {
+	user, err := h.adminTargetUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	changes := services.UserChanges{
+		Email:        req.Email,
+		Username:     req.Username,
+		FriendlyName: req.FriendlyName,
+		Role:         req.Role,
+	}
+	before := userChangeValues(user, changes)
+	if err := h.authService.UpdateUserAsAdmin(c.Context(), c.Locals("user_id").(int64), user, changes); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if after := userChangeValues(user, changes); !maps.Equal(before, after) {
+		entry := auditEntry(c, models.AuditUserUpdate, models.AuditTargetUser, user.ID)
+		entry.Before = before
+		entry.After = after
+		h.auditService.Record(c.Context(), entry)
+	}
+
+	return c.JSON(user)
+}
+
+// userChangeValues returns the user's current values of the fields changes
+// touches, for the audit log.
+func userChangeValues(user *models.User, changes services.UserChanges) models.AuditValues {
+	values := models.AuditValues{}
+	if changes.Email != nil {
+		values["email"] = user.Email
+	}
+	if changes.Username != nil {
+		values["username"] = user.Username
+	}
+	if changes.FriendlyName != nil {
+		values["friendlyName"] = user.FriendlyName
+	}
+	if changes.Role != nil {
+		values["role"] = user.Role
+	}
+	return values
+}
+
+// DeleteUser removes an account. Photographers who still own albums are
+// only deleted with ?force=true, which deletes the albums too.
+func (h *AdminHandler) DeleteUser(c *fiber.Ctx) error {
+	user, err := h.adminTargetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.authService.DeleteUser(c.Context(), c.Locals("user_id").(int64), user, c.QueryBool("force", false)); err != nil {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+
+	entry := auditEntry(c, models.AuditUserDelete, models.AuditTargetUser, user.ID)
+	entry.Before = models.AuditValues{"username": user.Username, "email": user.Email, "role": user.Role}
+	h.auditService.Record(c.Context(), entry)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SuspendUser stops a user from signing in or using the API until they
+// are unsuspended, and signs them out everywhere.
+func (h *AdminHandler) SuspendUser(c *fiber.Ctx) error {
+	user, err := h.adminTargetUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req SuspendUserRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+
+	wasSuspended := user.Suspended()
+	if err := h.authService.SuspendUser(c.Context(), c.Locals("user_id").(int64), user, req.Reason); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	entry := auditEntry(c, models.AuditUserSuspend, models.AuditTargetUser, user.ID)
+	entry.Before = models.AuditValues{"suspended": wasSuspended}
+	entry.After = models.AuditValues{"suspended": true, "reason": req.Reason}
+	h.auditService.Record(c.Context(), entry)
+
+	return c.JSON(user)
+}
+
+func (h *AdminHandler) UnsuspendUser(c *fiber.Ctx) error {
+	user, err := h.adminTargetUser(c)
+	if err != nil {
+		return err
+	}
+
+	wasSuspended := user.Suspended()
+	if err := h.authService.UnsuspendUser(c.Context(), user); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to unsuspend user")
+	}
+
+	if wasSuspended {
+		entry := auditEntry(c, models.AuditUserUnsuspend, models.AuditTargetUser, user.ID)
+		entry.Before = models.AuditValues{"suspended": true}
+		entry.After = models.AuditValues{"suspended": false}
+		h.auditService.Record(c.Context(), entry)
+	}
+
+	return c.JSON(user)
+}
+
+type SetUserPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// SetUserPassword replaces a user's password. Without one in the body a
+// password is generated and returned once.
+func (h *AdminHandler) SetUserPassword(c *fiber.Ctx) error {
+	user, err := h.adminTargetUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req SetUserPasswordRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+
+	password := req.Password
+	if password == "" {
+		password, err = generateRandomPassword(16)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to generate password")
+		}
+	}
+
+	if err := h.authService.SetUserPassword(c.Context(), user, password); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	entry := auditEntry(c, models.AuditUserPasswordSet, models.AuditTargetUser, user.ID)
+	entry.After = models.AuditValues{"generated": req.Password == ""}
+	h.auditService.Record(c.Context(), entry)
+
+	if req.Password != "" {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	return c.JSON(fiber.Map{
+		"password": password,
+	})
+}
+
+// ForcePasswordReset signs a user out, revokes their API tokens and makes
+// them choose a new password through an emailed link before signing in.
+func (h *AdminHandler) ForcePasswordReset(c *fiber.Ctx) error {
+	user, err := h.adminTargetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.authService.ForcePasswordReset(c.Context(), user); err != nil {
+		fmt.Printf("Warning: failed to force password reset of user %d: %v\n", user.ID, err)
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to force password reset")
+	}
+
+	h.auditService.Record(c.Context(), auditEntry(c, models.AuditUserPasswordReset, models.AuditTargetUser, user.ID))
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
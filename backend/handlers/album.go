@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,34 +14,146 @@ import (
 
 type AlbumHandler struct {
 	albumService *services.AlbumService
+	photoService *services.PhotoService
+	authService  *services.AuthService
+	auditService *services.AuditService
 }
 
-func NewAlbumHandler(albumService *services.AlbumService) *AlbumHandler {
+func NewAlbumHandler(albumService *services.AlbumService, photoService *services.PhotoService, authService *services.AuthService, auditService *services.AuditService) *AlbumHandler {
 	return &AlbumHandler{
 		albumService: albumService,
+		photoService: photoService,
+		authService:  authService,
+		auditService: auditService,
 	}
 }
 
 type CreateAlbumRequest struct {
-	Title            string                 `json:"title"`
-	DateTaken        *string                `json:"dateTaken"`
-	Description      *string                `json:"description"`
-	Location         *string                `json:"location"`
-	CustomFields     map[string]interface{} `json:"customFields"`
-	ThumbnailPhotoID *int                   `json:"thumbnailPhotoId"`
+	Title            string                    `json:"title"`
+	DateTaken        *string                   `json:"dateTaken"`
+	Description      *string                   `json:"description"`
+	Location         *string                   `json:"location"`
+	Timezone         *string                   `json:"timezone"`
+	CustomFields     map[string]interface{}    `json:"customFields"`
+	GPSPolicy        *models.GPSPolicyOverride `json:"gpsPolicy"`
+	ThumbnailPhotoID *int                      `json:"thumbnailPhotoId"`
 }
 
 type UpdateAlbumRequest struct {
-	Title            string                 `json:"title"`
-	DateTaken        *string                `json:"dateTaken"`
-	Description      *string                `json:"description"`
-	Location         *string                `json:"location"`
-	CustomFields     map[string]interface{} `json:"customFields"`
-	ThumbnailPhotoID *int                   `json:"thumbnailPhotoId"`
+	Title            string                    `json:"title"`
+	DateTaken        *string                   `json:"dateTaken"`
+	Description      *string                   `json:"description"`
+	Location         *string                   `json:"location"`
+	Timezone         *string                   `json:"timezone"`
+	CustomFields     map[string]interface{}    `json:"customFields"`
+	GPSPolicy        *models.GPSPolicyOverride `json:"gpsPolicy"`
+	ThumbnailPhotoID *int                      `json:"thumbnailPhotoId"`
 }
 
+// maxCaptureTimeShift bounds ShiftCaptureTime. No camera clock is off by
+// more, and larger values would overflow time.Duration.
+const maxCaptureTimeShift = 366 * 24 * 60 * 60
+
+type ShiftCaptureTimeRequest struct {
+	Make         string `json:"make"`
+	Model        string `json:"model"`
+	SerialNumber string `json:"serialNumber"`
+	ShiftSeconds int    `json:"shiftSeconds"`
+}
+
+// AssignUsersRequest replaces an album's users. Users listed in userIds get
+// the default permissions; entries in users can set them explicitly, and
+// flags they leave out keep their defaults.
 type AssignUsersRequest struct {
-	UserIDs []int `json:"userIds"`
+	UserIDs []int                 `json:"userIds"`
+	Users   []AlbumUserAssignment `json:"users"`
+}
+
+type AlbumUserAssignment struct {
+	UserID      int   `json:"userId"`
+	CanComment  *bool `json:"canComment,omitempty"`
+	CanRate     *bool `json:"canRate,omitempty"`
+	CanDownload *bool `json:"canDownload,omitempty"`
+	CanShare    *bool `json:"canShare,omitempty"`
+}
+
+// albumUsers turns the request into assignments, one per user.
+func (r *AssignUsersRequest) albumUsers(albumID int) []*models.AlbumUser {
+	byUser := make(map[int]*models.AlbumUser)
+	var albumUsers []*models.AlbumUser
+	assign := func(userID int) *models.AlbumUser {
+		if albumUser, ok := byUser[userID]; ok {
+			return albumUser
+		}
+		albumUser := models.NewAlbumUser(albumID, userID)
+		byUser[userID] = albumUser
+		albumUsers = append(albumUsers, albumUser)
+		return albumUser
+	}
+
+	for _, userID := range r.UserIDs {
+		assign(userID)
+	}
+	for _, user := range r.Users {
+		albumUser := assign(user.UserID)
+		if user.CanComment != nil {
+			albumUser.CanComment = *user.CanComment
+		}
+		if user.CanRate != nil {
+			albumUser.CanRate = *user.CanRate
+		}
+		if user.CanDownload != nil {
+			albumUser.CanDownload = *user.CanDownload
+		}
+		if user.CanShare != nil {
+			albumUser.CanShare = *user.CanShare
+		}
+	}
+	return albumUsers
+}
+
+// albumPermissionDenied names what an album permission allows, for error
+// messages.
+var albumPermissionDenied = map[models.AlbumPermission]string{
+	models.AlbumPermissionView:     "access denied to this album",
+	models.AlbumPermissionComment:  "you are not allowed to comment in this album",
+	models.AlbumPermissionRate:     "you are not allowed to rate or pick photos in this album",
+	models.AlbumPermissionDownload: "you are not allowed to download originals from this album",
+	models.AlbumPermissionShare:    "you are not allowed to share this album",
+}
+
+// requireAlbumPermission fails with 403 unless the current user has the
+// permission in the album.
+func requireAlbumPermission(c *fiber.Ctx, albumService *services.AlbumService, albumID int, permission models.AlbumPermission) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	allowed, err := albumService.HasAlbumPermission(c.Context(), int(userID), role, albumID, permission)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if !allowed {
+		return fiber.NewError(fiber.StatusForbidden, albumPermissionDenied[permission])
+	}
+	return nil
+}
+
+// requireManageAlbum fails with 403 and message unless the user may manage
+// the album: admins, its photographer and the owners and managers of its
+// organization may.
+func requireManageAlbum(c *fiber.Ctx, albumService *services.AlbumService, userID int64, role models.UserRole, album *models.Album, message string) error {
+	allowed, err := albumService.CanManageAlbum(c.Context(), int(userID), role, album)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if !allowed {
+		return fiber.NewError(fiber.StatusForbidden, message)
+	}
+	return nil
 }
 
 func (h *AlbumHandler) CreateAlbum(c *fiber.Ctx) error {
@@ -70,6 +185,17 @@ func (h *AlbumHandler) CreateAlbum(c *fiber.Ctx) error {
 		PhotographerID:   int(userID),
 	}
 
+	if req.GPSPolicy != nil {
+		album.GPSPolicy = *req.GPSPolicy
+	}
+
+	if req.Timezone != nil && *req.Timezone != "" {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid timezone")
+		}
+		album.Timezone = req.Timezone
+	}
+
 	if req.DateTaken != nil && *req.DateTaken != "" {
 		dateTaken, err := parseDateTime(*req.DateTaken)
 		if err != nil {
@@ -79,6 +205,9 @@ func (h *AlbumHandler) CreateAlbum(c *fiber.Ctx) error {
 	}
 
 	if err := h.albumService.CreateAlbum(c.Context(), album); err != nil {
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to create album: "+err.Error())
 	}
 
@@ -109,8 +238,7 @@ func (h *AlbumHandler) GetAlbums(c *fiber.Ctx) error {
 
 		albums, err = h.albumService.ListAlbums(c.Context(), &photographerID, nil)
 	} else if role == models.RolePhotographer {
-		photographerID := int(userID)
-		albums, err = h.albumService.ListAlbums(c.Context(), &photographerID, nil)
+		albums, err = h.albumService.ListAlbumsForPhotographer(c.Context(), int(userID))
 	} else if role == models.RoleClient {
 		userIDInt := int(userID)
 		albums, err = h.albumService.ListAlbums(c.Context(), nil, &userIDInt)
@@ -156,6 +284,15 @@ func (h *AlbumHandler) GetAlbum(c *fiber.Ctx) error {
 		}
 	}
 
+	if (album.Location == nil || *album.Location == "") && h.photoService.GPSVisibleTo(c.Context(), album, int(userID), role) {
+		suggestion, err := h.photoService.SuggestAlbumLocation(c.Context(), albumID)
+		if err != nil {
+			fmt.Printf("Warning: failed to suggest album location: %v\n", err)
+		} else if suggestion != "" {
+			album.SuggestedLocation = &suggestion
+		}
+	}
+
 	return c.JSON(album)
 }
 
@@ -180,8 +317,8 @@ func (h *AlbumHandler) UpdateAlbum(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "album not found")
 	}
 
-	if role != models.RoleAdmin && existingAlbum.PhotographerID != int(userID) {
-		return fiber.NewError(fiber.StatusForbidden, "you can only update your own albums")
+	if err := requireManageAlbum(c, h.albumService, userID, role, existingAlbum, "you can only update your own albums"); err != nil {
+		return err
 	}
 
 	var req UpdateAlbumRequest
@@ -199,6 +336,31 @@ func (h *AlbumHandler) UpdateAlbum(c *fiber.Ctx) error {
 	existingAlbum.CustomFields = req.CustomFields
 	existingAlbum.ThumbnailPhotoID = req.ThumbnailPhotoID
 
+	gpsPolicyChanged := false
+	if req.GPSPolicy != nil && !req.GPSPolicy.Equal(existingAlbum.GPSPolicy) {
+		existingAlbum.GPSPolicy = *req.GPSPolicy
+		gpsPolicyChanged = true
+	}
+
+	// A missing timezone leaves it unchanged; an empty one clears it.
+	timezoneChanged := false
+	if req.Timezone != nil {
+		currentTimezone := ""
+		if existingAlbum.Timezone != nil {
+			currentTimezone = *existingAlbum.Timezone
+		}
+		if *req.Timezone != currentTimezone {
+			if *req.Timezone == "" {
+				existingAlbum.Timezone = nil
+			} else if _, err := time.LoadLocation(*req.Timezone); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid timezone")
+			} else {
+				existingAlbum.Timezone = req.Timezone
+			}
+			timezoneChanged = true
+		}
+	}
+
 	if req.DateTaken != nil && *req.DateTaken != "" {
 		dateTaken, err := parseDateTime(*req.DateTaken)
 		if err != nil {
@@ -213,9 +375,119 @@ func (h *AlbumHandler) UpdateAlbum(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to update album: "+err.Error())
 	}
 
+	if gpsPolicyChanged {
+		if err := h.photoService.ApplyGPSPolicy(c.Context(), existingAlbum); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to apply GPS policy: "+err.Error())
+		}
+	}
+
+	if timezoneChanged {
+		if err := h.photoService.RecomputeCaptureTimes(c.Context(), existingAlbum); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update capture times: "+err.Error())
+		}
+	}
+
 	return c.JSON(existingAlbum)
 }
 
+func (h *AlbumHandler) ListCameraBodies(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	albumID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	album, err := h.albumService.GetAlbumByID(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
+	}
+	if album == nil {
+		return fiber.NewError(fiber.StatusNotFound, "album not found")
+	}
+
+	if err := requireManageAlbum(c, h.albumService, userID, role, album, "you can only manage your own albums"); err != nil {
+		return err
+	}
+
+	bodies, err := h.photoService.ListCameraBodies(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list cameras: "+err.Error())
+	}
+
+	return c.JSON(bodies)
+}
+
+// ShiftCaptureTime corrects the clock of one camera body across an album,
+// e.g. a second shooter whose camera was an hour behind.
+func (h *AlbumHandler) ShiftCaptureTime(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	albumID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	var req ShiftCaptureTimeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.ShiftSeconds == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "shiftSeconds is required")
+	}
+	if req.ShiftSeconds < -maxCaptureTimeShift || req.ShiftSeconds > maxCaptureTimeShift {
+		return fiber.NewError(fiber.StatusBadRequest, "shiftSeconds must be within a year")
+	}
+
+	album, err := h.albumService.GetAlbumByID(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
+	}
+	if album == nil {
+		return fiber.NewError(fiber.StatusNotFound, "album not found")
+	}
+
+	if err := requireManageAlbum(c, h.albumService, userID, role, album, "you can only manage your own albums"); err != nil {
+		return err
+	}
+
+	body := services.CameraBody{
+		Make:         strings.TrimSpace(req.Make),
+		Model:        strings.TrimSpace(req.Model),
+		SerialNumber: strings.TrimSpace(req.SerialNumber),
+	}
+	updated, err := h.photoService.ShiftCaptureTime(c.Context(), album, body, time.Duration(req.ShiftSeconds)*time.Second)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to shift capture times: "+err.Error())
+	}
+
+	entry := albumAuditEntry(c, models.AuditAlbumCaptureShift, album.ID)
+	entry.After = models.AuditValues{
+		"make":         body.Make,
+		"model":        body.Model,
+		"serialNumber": body.SerialNumber,
+		"shiftSeconds": req.ShiftSeconds,
+		"updated":      updated,
+	}
+	h.auditService.Record(c.Context(), entry)
+
+	return c.JSON(fiber.Map{
+		"message": "capture times shifted successfully",
+		"updated": updated,
+	})
+}
+
 func (h *AlbumHandler) DeleteAlbum(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(int64)
 	if !ok {
@@ -237,14 +509,18 @@ func (h *AlbumHandler) DeleteAlbum(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "album not found")
 	}
 
-	if role != models.RoleAdmin && existingAlbum.PhotographerID != int(userID) {
-		return fiber.NewError(fiber.StatusForbidden, "you can only delete your own albums")
+	if err := requireManageAlbum(c, h.albumService, userID, role, existingAlbum, "you can only delete your own albums"); err != nil {
+		return err
 	}
 
 	if err := h.albumService.DeleteAlbum(c.Context(), albumID); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete album: "+err.Error())
 	}
 
+	entry := albumAuditEntry(c, models.AuditAlbumDelete, albumID)
+	entry.Before = models.AuditValues{"title": existingAlbum.Title, "photographerId": existingAlbum.PhotographerID}
+	h.auditService.Record(c.Context(), entry)
+
 	return c.JSON(fiber.Map{
 		"message": "album deleted successfully",
 	})
@@ -271,8 +547,8 @@ func (h *AlbumHandler) AssignUsers(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "album not found")
 	}
 
-	if role != models.RoleAdmin && existingAlbum.PhotographerID != int(userID) {
-		return fiber.NewError(fiber.StatusForbidden, "you can only assign users to your own albums")
+	if err := requireManageAlbum(c, h.albumService, userID, role, existingAlbum, "you can only assign users to your own albums"); err != nil {
+		return err
 	}
 
 	var req AssignUsersRequest
@@ -280,14 +556,25 @@ func (h *AlbumHandler) AssignUsers(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
 	}
 
-	if len(req.UserIDs) == 0 {
-		return fiber.NewError(fiber.StatusBadRequest, "userIds is required")
+	if len(req.UserIDs) == 0 && len(req.Users) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "userIds or users is required")
+	}
+
+	previous, err := h.albumService.GetAlbumUsers(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get album users: "+err.Error())
 	}
 
-	if err := h.albumService.AssignUsersToAlbum(c.Context(), albumID, req.UserIDs); err != nil {
+	albumUsers := req.albumUsers(albumID)
+	if err := h.albumService.AssignUsersToAlbum(c.Context(), albumID, albumUsers); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to assign users: "+err.Error())
 	}
 
+	entry := albumAuditEntry(c, models.AuditAlbumUsersAssign, albumID)
+	entry.Before = models.AuditValues{"users": previous}
+	entry.After = models.AuditValues{"users": albumUsers}
+	h.auditService.Record(c.Context(), entry)
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "users assigned successfully",
 	})
@@ -314,8 +601,8 @@ func (h *AlbumHandler) GetAlbumUsers(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "album not found")
 	}
 
-	if role != models.RoleAdmin && existingAlbum.PhotographerID != int(userID) {
-		return fiber.NewError(fiber.StatusForbidden, "you can only view users for your own albums")
+	if err := requireManageAlbum(c, h.albumService, userID, role, existingAlbum, "you can only view users for your own albums"); err != nil {
+		return err
 	}
 
 	albumUsers, err := h.albumService.GetAlbumUsers(c.Context(), albumID)
@@ -326,6 +613,133 @@ func (h *AlbumHandler) GetAlbumUsers(c *fiber.Ctx) error {
 	return c.JSON(albumUsers)
 }
 
+type AddCollaboratorRequest struct {
+	UserID int64 `json:"userId"`
+}
+
+// leadAlbum returns the album from the :id parameter, checking that the
+// current user is its photographer or an admin.
+func (h *AlbumHandler) leadAlbum(c *fiber.Ctx) (*models.Album, error) {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	albumID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	album, err := h.albumService.GetAlbumByID(c.Context(), albumID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
+	}
+	if album == nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "album not found")
+	}
+
+	if err := requireManageAlbum(c, h.albumService, userID, role, album, "only the album's photographer can manage its collaborators"); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// AddCollaborator lets another photographer, such as a second shooter,
+// upload to the album.
+func (h *AlbumHandler) AddCollaborator(c *fiber.Ctx) error {
+	album, err := h.leadAlbum(c)
+	if err != nil {
+		return err
+	}
+
+	userID, _ := c.Locals("user_id").(int64)
+
+	var req AddCollaboratorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.UserID == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "userId is required")
+	}
+
+	user, err := h.authService.GetUserByID(req.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
+	}
+	if user == nil {
+		return fiber.NewError(fiber.StatusNotFound, "user not found")
+	}
+
+	collaborator, err := h.albumService.AddCollaborator(c.Context(), album, user, int(userID))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	entry := albumAuditEntry(c, models.AuditCollaboratorAdd, album.ID)
+	entry.After = models.AuditValues{"userId": user.ID, "username": user.Username}
+	h.auditService.Record(c.Context(), entry)
+
+	return c.Status(fiber.StatusCreated).JSON(collaborator)
+}
+
+func (h *AlbumHandler) GetCollaborators(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	albumID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	canUpload, err := h.albumService.CanUploadToAlbum(c.Context(), int(userID), role, albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if !canUpload {
+		return fiber.NewError(fiber.StatusForbidden, "access denied to this album")
+	}
+
+	collaborators, err := h.albumService.GetAlbumCollaborators(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get album collaborators: "+err.Error())
+	}
+
+	return c.JSON(collaborators)
+}
+
+func (h *AlbumHandler) RemoveCollaborator(c *fiber.Ctx) error {
+	album, err := h.leadAlbum(c)
+	if err != nil {
+		return err
+	}
+
+	collaboratorID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user id")
+	}
+
+	removed, err := h.albumService.RemoveCollaborator(c.Context(), album.ID, collaboratorID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to remove collaborator")
+	}
+	if !removed {
+		return fiber.NewError(fiber.StatusNotFound, "collaborator not found")
+	}
+
+	entry := albumAuditEntry(c, models.AuditCollaboratorRemove, album.ID)
+	entry.Before = models.AuditValues{"userId": collaboratorID}
+	h.auditService.Record(c.Context(), entry)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
 func parseDateTime(dateStr string) (time.Time, error) {
 	formats := []string{
 		time.RFC3339,
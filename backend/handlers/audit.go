@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/services"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// auditEntry starts an audit entry for the current request, attributed to
+// the signed-in user or, for guests, to their share link.
+func auditEntry(c *fiber.Ctx, action models.AuditAction, targetType string, targetID int64) *models.AuditEntry {
+	entry := &models.AuditEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   strconv.FormatInt(targetID, 10),
+	}
+	if userID, ok := c.Locals("user_id").(int64); ok {
+		entry.ActorID = &userID
+	} else if link, ok := c.Locals("share_link").(*models.ShareLink); ok {
+		entry.ShareLinkID = &link.ID
+		entry.AlbumID = &link.AlbumID
+	}
+	if ip := c.IP(); ip != "" {
+		entry.IPAddress = &ip
+	}
+	if userAgent := c.Get(fiber.HeaderUserAgent); userAgent != "" {
+		entry.UserAgent = &userAgent
+	}
+	return entry
+}
+
+// albumAuditEntry starts an audit entry about an album.
+func albumAuditEntry(c *fiber.Ctx, action models.AuditAction, albumID int) *models.AuditEntry {
+	entry := auditEntry(c, action, models.AuditTargetAlbum, int64(albumID))
+	entry.AlbumID = &albumID
+	return entry
+}
+
+// photoAuditEntry starts an audit entry about a photo in its album.
+func photoAuditEntry(c *fiber.Ctx, action models.AuditAction, photo *models.Photo) *models.AuditEntry {
+	entry := auditEntry(c, action, models.AuditTargetPhoto, int64(photo.ID))
+	entry.AlbumID = &photo.AlbumID
+	return entry
+}
+
+// auditPhotoState records a pick or reject, if the state changed.
+func auditPhotoState(c *fiber.Ctx, auditService *services.AuditService, photo *models.Photo, previous models.PickRejectState) {
+	if photo.PickRejectState == previous {
+		return
+	}
+	entry := photoAuditEntry(c, models.AuditPhotoState, photo)
+	entry.Before = models.AuditValues{"state": previous}
+	entry.After = models.AuditValues{"state": photo.PickRejectState}
+	auditService.Record(c.Context(), entry)
+}
+
+// auditPhotoStars records a rating, if it changed.
+func auditPhotoStars(c *fiber.Ctx, auditService *services.AuditService, photo *models.Photo, previous int) {
+	if photo.Stars == previous {
+		return
+	}
+	entry := photoAuditEntry(c, models.AuditPhotoStars, photo)
+	entry.Before = models.AuditValues{"stars": previous}
+	entry.After = models.AuditValues{"stars": photo.Stars}
+	auditService.Record(c.Context(), entry)
+}
+
+// auditFilter reads the filter from ?userId=, ?albumId=, ?action=, ?from=,
+// ?to=, ?limit= and ?offset=. Times are RFC 3339.
+func auditFilter(c *fiber.Ctx) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Action: models.AuditAction(c.Query("action")),
+		Limit:  c.QueryInt("limit", 0),
+		Offset: c.QueryInt("offset", 0),
+	}
+
+	if v := c.Query("userId"); v != "" {
+		userID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, "invalid userId")
+		}
+		filter.ActorID = userID
+	}
+	if v := c.Query("albumId"); v != "" {
+		albumID, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, "invalid albumId")
+		}
+		filter.AlbumID = albumID
+	}
+	for param, dest := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid %s, expected RFC 3339", param))
+		}
+		*dest = &t
+	}
+
+	return filter, nil
+}
+
+// ListAuditLog returns a page of audit entries, newest first.
+func (h *AuditHandler) ListAuditLog(c *fiber.Ctx) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := h.auditService.List(c.Context(), filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list audit log")
+	}
+
+	return c.JSON(entries)
+}
+
+// ExportAuditLog downloads every audit entry matching the filter as CSV.
+func (h *AuditHandler) ExportAuditLog(c *fiber.Ctx) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := h.auditService.ExportCSV(c.Context(), filter, &buf); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to export audit log")
+	}
+
+	filename := "audit-log-" + time.Now().UTC().Format("20060102-150405") + ".csv"
+	c.Set("Content-Type", "text/csv; charset=utf-8")
+	c.Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	return c.Send(buf.Bytes())
+}
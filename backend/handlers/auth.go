@@ -1,26 +1,49 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/captcha"
+	"github.com/suipic/backend/middleware"
 	"github.com/suipic/backend/models"
 	"github.com/suipic/backend/services"
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
+	authService    *services.AuthService
+	storageService *services.StorageService
+	auditService   *services.AuditService
+	captcha        captcha.Verifier
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+// NewAuthHandler creates the handler. captchaVerifier may be nil, in which
+// case open registration is not challenged.
+func NewAuthHandler(authService *services.AuthService, storageService *services.StorageService, auditService *services.AuditService, captchaVerifier captcha.Verifier) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:    authService,
+		storageService: storageService,
+		auditService:   auditService,
+		captcha:        captchaVerifier,
 	}
 }
 
 type RegisterRequest struct {
-	Email    string          `json:"email"`
-	Username string          `json:"username"`
-	Password string          `json:"password"`
-	Role     models.UserRole `json:"role"`
+	Email        string          `json:"email"`
+	Username     string          `json:"username"`
+	Password     string          `json:"password"`
+	Role         models.UserRole `json:"role"`
+	CaptchaToken string          `json:"captchaToken"`
+}
+
+type RegistrationInfoResponse struct {
+	Mode            string `json:"mode"`
+	CaptchaProvider string `json:"captchaProvider,omitempty"`
+	CaptchaSiteKey  string `json:"captchaSiteKey,omitempty"`
 }
 
 type LoginRequest struct {
@@ -30,12 +53,47 @@ type LoginRequest struct {
 }
 
 type RefreshRequest struct {
-	Token string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
 }
 
 type AuthResponse struct {
-	User  *models.User `json:"user"`
-	Token string       `json:"token"`
+	User         *models.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refreshToken"`
+	ExpiresIn    int          `json:"expiresIn"`
+}
+
+func newAuthResponse(user *models.User, tokens *services.TokenPair) AuthResponse {
+	return AuthResponse{
+		User:         user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+}
+
+// JWKS publishes the public keys access tokens are signed with, so other
+// services can verify them; they should also require the
+// services.AccessTokenAudience aud claim. It is empty while tokens are
+// signed with JWT_SECRET.
+func (h *AuthHandler) JWKS(c *fiber.Ctx) error {
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.JSON(fiber.Map{
+		"keys": h.authService.PublicKeys(),
+	})
+}
+
+// RegistrationInfo tells the sign-up page whether registration is possible
+// and which captcha widget to show.
+func (h *AuthHandler) RegistrationInfo(c *fiber.Ctx) error {
+	response := RegistrationInfoResponse{
+		Mode: h.authService.RegistrationPolicy(c.Context()).Mode,
+	}
+	if response.Mode == services.RegistrationOpen && h.captcha != nil {
+		response.CaptchaProvider = h.captcha.Provider()
+		response.CaptchaSiteKey = h.captcha.SiteKey()
+	}
+	return c.JSON(response)
 }
 
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
@@ -56,20 +114,42 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "cannot register as admin")
 	}
 
+	policy := h.authService.RegistrationPolicy(c.Context())
+	if err := policy.Check(req.Email, req.Role); err != nil {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+
+	if policy.Mode == services.RegistrationOpen && h.captcha != nil {
+		if err := h.captcha.Verify(c.Context(), req.CaptchaToken, c.IP()); err != nil {
+			if !errors.Is(err, captcha.ErrFailed) {
+				fmt.Printf("Warning: failed to verify captcha: %v\n", err)
+			}
+			return fiber.NewError(fiber.StatusBadRequest, captcha.ErrFailed.Error())
+		}
+	}
+
 	user, err := h.authService.Register(req.Email, req.Username, req.Password, req.Role)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	token, err := h.authService.GenerateToken(user)
+	if err := h.authService.SendVerificationEmail(c.Context(), user); err != nil {
+		fmt.Printf("Warning: failed to send verification email to user %d: %v\n", user.ID, err)
+	}
+
+	if h.authService.EmailVerificationRequired(c.Context()) {
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"user":    user,
+			"message": "check your email to confirm your address before signing in",
+		})
+	}
+
+	result, err := h.authService.BeginSession(c.Context(), user, middleware.RequestClient(c))
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to generate token")
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(AuthResponse{
-		User:  user,
-		Token: token,
-	})
+	return loginResponse(c, fiber.StatusCreated, result)
 }
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
@@ -86,15 +166,52 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "username or email is required")
 	}
 
-	user, token, err := h.authService.LoginWithUsernameOrEmail(req.Username, req.Email, req.Password)
+	result, err := h.authService.LoginWithUsernameOrEmail(req.Username, req.Email, req.Password, middleware.RequestClient(c))
 	if err != nil {
-		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		return loginError(c, err)
 	}
 
-	return c.JSON(AuthResponse{
-		User:  user,
-		Token: token,
-	})
+	return loginResponse(c, fiber.StatusOK, result)
+}
+
+// loginError answers a failed login with 401, with 403 when the account may
+// not sign in at the moment, or with 429 and Retry-After while failed
+// attempts have the account or IP address backing off.
+func loginError(c *fiber.Ctx, err error) error {
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.Seconds()))
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	}
+	if errors.Is(err, services.ErrAccountSuspended) || errors.Is(err, services.ErrPasswordResetRequired) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+}
+
+// reauthError answers for an action that failed while asking a signed-in
+// user for their password again: like a login when it was throttled, as a
+// bad request otherwise.
+func reauthError(c *fiber.Ctx, err error) error {
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		return loginError(c, err)
+	}
+	return fiber.NewError(fiber.StatusBadRequest, err.Error())
+}
+
+// loginResponse answers with the token pair, or with the challenge the
+// client has to complete at /auth/mfa when a second factor is needed.
+func loginResponse(c *fiber.Ctx, status int, result *services.LoginResult) error {
+	if result.Tokens == nil {
+		return c.Status(status).JSON(MFAChallengeResponse{
+			MFARequired:           !result.MFAEnrollmentRequired,
+			MFAEnrollmentRequired: result.MFAEnrollmentRequired,
+			MFAToken:              result.MFAToken,
+		})
+	}
+
+	return c.Status(status).JSON(newAuthResponse(result.User, result.Tokens))
 }
 
 func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
@@ -103,57 +220,469 @@ func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
 	}
 
+	if req.RefreshToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "refreshToken is required")
+	}
+
+	user, tokens, err := h.authService.RefreshSession(c.Context(), req.RefreshToken, middleware.RequestClient(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid or expired refresh token")
+	}
+
+	return c.JSON(newAuthResponse(user, tokens))
+}
+
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	sessionID, ok := c.Locals("session_id").(string)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	if err := h.authService.RevokeSession(c.Context(), sessionID, services.SessionRevokedLogout); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to log out")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "logged out successfully",
+	})
+}
+
+func (h *AuthHandler) Me(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(user)
+}
+
+type UpdateMeRequest struct {
+	FriendlyName *string `json:"friendlyName"`
+}
+
+func (h *AuthHandler) UpdateMe(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateMeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.FriendlyName != nil {
+		if err := h.authService.UpdateFriendlyName(c.Context(), user, *req.FriendlyName); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+
+	return c.JSON(user)
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "currentPassword and newPassword are required")
+	}
+
+	sessionID, _ := c.Locals("session_id").(string)
+	if err := h.authService.ChangePassword(c.Context(), user, sessionID, req.CurrentPassword, req.NewPassword, middleware.RequestClient(c)); err != nil {
+		return reauthError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "password changed successfully",
+	})
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (h *AuthHandler) ChangeEmail(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req ChangeEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Email == "" || req.Password == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "email and password are required")
+	}
+
+	if err := h.authService.RequestEmailChange(c.Context(), user, req.Password, req.Email, middleware.RequestClient(c)); err != nil {
+		return reauthError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "confirmation sent to the new email address",
+	})
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token"`
+}
+
+func (h *AuthHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req ConfirmEmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
 	if req.Token == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "token is required")
 	}
 
-	claims, err := h.authService.ValidateToken(req.Token)
+	user, err := h.authService.ConfirmEmailChange(c.Context(), req.Token)
 	if err != nil {
-		return fiber.NewError(fiber.StatusUnauthorized, "invalid or expired token")
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(user)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Email == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "email is required")
 	}
 
-	user, err := h.authService.GetUserByID(claims.UserID)
+	if err := h.authService.RequestPasswordReset(c.Context(), req.Email); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to request password reset")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "if an account uses this address, a reset link has been sent to it",
+	})
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Token == "" || req.Password == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "token and password are required")
+	}
+
+	if err := h.authService.ResetPassword(c.Context(), req.Token, req.Password); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "password has been reset",
+	})
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "token is required")
+	}
+
+	user, err := h.authService.VerifyEmail(c.Context(), req.Token)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(user)
+}
+
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "email address is already verified")
+	}
+
+	if err := h.authService.SendVerificationEmail(c.Context(), user); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to send verification email")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "verification email sent",
+	})
+}
+
+func (h *AuthHandler) UploadAvatar(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "avatar file is required")
+	}
+	if file.Size > services.MaxAvatarFileSize {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("avatar must be at most %d MB", services.MaxAvatarFileSize>>20))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to open file")
+	}
+	defer src.Close()
+
+	avatarID, err := h.storageService.UploadAvatar(c.Context(), src)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	previousAvatarID := user.AvatarID
+	user.AvatarID = &avatarID
+	if err := h.authService.UpdateUser(c.Context(), user); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to update avatar")
+	}
+
+	if previousAvatarID != nil {
+		if err := h.storageService.DeleteAvatar(c.Context(), *previousAvatarID); err != nil {
+			fmt.Printf("Warning: failed to delete old avatar %s: %v\n", *previousAvatarID, err)
+		}
+	}
+
+	return c.JSON(user)
+}
+
+func (h *AuthHandler) DeleteAvatar(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if user.AvatarID == nil {
+		return c.JSON(user)
+	}
+
+	avatarID := *user.AvatarID
+	user.AvatarID = nil
+	if err := h.authService.UpdateUser(c.Context(), user); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to remove avatar")
+	}
+
+	if err := h.storageService.DeleteAvatar(c.Context(), avatarID); err != nil {
+		fmt.Printf("Warning: failed to delete avatar %s: %v\n", avatarID, err)
+	}
+
+	return c.JSON(user)
+}
+
+// GetUserAvatar serves any user's avatar to signed-in users, since avatars
+// are shown next to comments.
+func (h *AuthHandler) GetUserAvatar(c *fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user ID")
+	}
+
+	user, err := h.authService.GetUserByID(userID)
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
 	}
+	if user == nil || user.AvatarID == nil {
+		return fiber.NewError(fiber.StatusNotFound, "avatar not found")
+	}
+
+	object, _, err := h.storageService.DownloadAvatar(c.Context(), *user.AvatarID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "avatar not found")
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to read avatar")
+	}
 
+	c.Set("Content-Type", "image/webp")
+	c.Set("Cache-Control", "private, max-age=3600")
+	return c.Send(data)
+}
+
+func (h *AuthHandler) currentUser(c *fiber.Ctx) (*models.User, error) {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	user, err := h.authService.GetProfile(c.Context(), userID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
+	}
 	if user == nil {
-		return fiber.NewError(fiber.StatusUnauthorized, "user not found")
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not found")
 	}
+	return user, nil
+}
 
-	newToken, err := h.authService.GenerateToken(user)
+func (h *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+	sessionID, _ := c.Locals("session_id").(string)
+
+	sessions, err := h.authService.ListSessions(c.Context(), userID, sessionID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "failed to generate token")
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list sessions")
 	}
 
-	return c.JSON(AuthResponse{
-		User:  user,
-		Token: newToken,
-	})
+	return c.JSON(sessions)
 }
 
-func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	session, err := h.authService.GetSession(c.Context(), c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve session")
+	}
+	if session == nil || session.UserID != userID {
+		return fiber.NewError(fiber.StatusNotFound, "session not found")
+	}
+
+	if err := h.authService.RevokeSession(c.Context(), session.ID, services.SessionRevokedByUser); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke session")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RevokeOtherSessions signs the user out of every device except the one
+// making the request.
+func (h *AuthHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+	sessionID, _ := c.Locals("session_id").(string)
+
+	revoked, err := h.authService.RevokeAllSessions(c.Context(), userID, sessionID, services.SessionRevokedByUser)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke sessions")
+	}
+
 	return c.JSON(fiber.Map{
-		"message": "logged out successfully",
+		"revoked": revoked,
 	})
 }
 
-func (h *AuthHandler) Me(c *fiber.Ctx) error {
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+func (h *AuthHandler) ListAPITokens(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(int64)
 	if !ok {
 		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
 	}
 
-	email, _ := c.Locals("user_email").(string)
-	username, _ := c.Locals("user_username").(string)
-	role, _ := c.Locals("user_role").(models.UserRole)
+	tokens, err := h.authService.ListAPITokens(c.Context(), userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list tokens")
+	}
+
+	return c.JSON(tokens)
+}
+
+// CreateAPIToken issues a personal access token. The token itself is only
+// part of this response.
+func (h *AuthHandler) CreateAPIToken(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
 
-	user := &models.User{
-		ID:       userID,
-		Email:    email,
-		Username: username,
-		Role:     role,
+	var req CreateAPITokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	if req.ExpiresInDays < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "expiresInDays must not be negative")
 	}
 
-	return c.JSON(user)
+	token, err := h.authService.CreateAPIToken(c.Context(), user, req.Name, req.Scopes, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	entry := auditEntry(c, models.AuditAPITokenCreate, models.AuditTargetAPIToken, int64(token.ID))
+	entry.After = models.AuditValues{"name": token.Name, "scopes": token.Scopes, "expiresAt": token.ExpiresAt}
+	h.auditService.Record(c.Context(), entry)
+
+	return c.Status(fiber.StatusCreated).JSON(token)
+}
+
+func (h *AuthHandler) RevokeAPIToken(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	tokenID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid token ID")
+	}
+
+	revoked, err := h.authService.RevokeAPIToken(c.Context(), userID, tokenID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke token")
+	}
+	if !revoked {
+		return fiber.NewError(fiber.StatusNotFound, "token not found")
+	}
+
+	h.auditService.Record(c.Context(), auditEntry(c, models.AuditAPITokenRevoke, models.AuditTargetAPIToken, int64(tokenID)))
+
+	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/services"
+)
+
+type ExportHandler struct {
+	exportService *services.ExportService
+	auditService  *services.AuditService
+}
+
+func NewExportHandler(exportService *services.ExportService, auditService *services.AuditService) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+		auditService:  auditService,
+	}
+}
+
+func (h *ExportHandler) ExportAlbum(c *fiber.Ctx) error {
+	albumID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	opts := services.ExportOptions{
+		IncludeRenditions: c.QueryBool("renditions", false),
+	}
+
+	entry := albumAuditEntry(c, models.AuditAlbumExport, albumID)
+	entry.After = models.AuditValues{"renditions": opts.IncludeRenditions}
+	h.auditService.Record(c.Context(), entry)
+
+	return streamBundle(c, fmt.Sprintf("suipic-album-%d.zip", albumID), func(ctx context.Context, bundle services.BundleWriter) error {
+		_, err := h.exportService.ExportAlbum(ctx, albumID, opts, bundle)
+		return err
+	})
+}
+
+func (h *ExportHandler) ExportPhotographer(c *fiber.Ctx) error {
+	photographerID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid photographer id")
+	}
+
+	opts := services.ExportOptions{
+		IncludeRenditions: c.QueryBool("renditions", false),
+	}
+
+	entry := auditEntry(c, models.AuditPhotographerExport, models.AuditTargetUser, int64(photographerID))
+	entry.After = models.AuditValues{"renditions": opts.IncludeRenditions}
+	h.auditService.Record(c.Context(), entry)
+
+	return streamBundle(c, fmt.Sprintf("suipic-photographer-%d.zip", photographerID), func(ctx context.Context, bundle services.BundleWriter) error {
+		_, err := h.exportService.ExportPhotographer(ctx, photographerID, opts, bundle)
+		return err
+	})
+}
+
+// Import recreates the albums of an uploaded bundle. Uploads are subject to
+// the server's body limit, Fiber's default of 4 MB, so this only suits small
+// bundles; larger ones are imported with cmd/export on the server.
+func (h *ExportHandler) Import(c *fiber.Ctx) error {
+	photographerID, err := strconv.Atoi(c.FormValue("photographerId"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "photographerId is required")
+	}
+
+	file, err := c.FormFile("bundle")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "bundle file is required")
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to open file")
+	}
+	defer src.Close()
+
+	archive, err := zip.NewReader(src, file.Size)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "bundle must be a zip archive")
+	}
+
+	result, err := h.exportService.Import(c.Context(), archive, photographerID)
+	if errors.Is(err, services.ErrInvalidBundle) {
+		return fiber.NewError(fiber.StatusBadRequest, "failed to import bundle: "+err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to import bundle: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+// streamBundle sends the ZIP archive write fills as it is being built, so
+// that large exports are never held in memory. The response has started by
+// the time write runs, so a failure part way through can only be logged; the
+// client is left with a truncated archive.
+func streamBundle(c *fiber.Ctx, filename string, write func(ctx context.Context, bundle services.BundleWriter) error) error {
+	c.Set("Content-Type", "application/zip")
+	c.Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context is not to be used once the handler returned.
+		bundle := services.NewZipBundleWriter(w)
+		if err := write(context.Background(), bundle); err != nil {
+			fmt.Printf("Warning: failed to export %s: %v\n", filename, err)
+			return
+		}
+		if err := bundle.Close(); err != nil {
+			fmt.Printf("Warning: failed to finalize %s: %v\n", filename, err)
+		}
+	})
+	return nil
+}
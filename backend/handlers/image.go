@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/services"
+)
+
+const maxSignedImageExpiry = 30 * 24 * time.Hour
+
+type ImageHandler struct {
+	imageService *services.ImageService
+	photoService *services.PhotoService
+	albumService *services.AlbumService
+}
+
+func NewImageHandler(imageService *services.ImageService, photoService *services.PhotoService, albumService *services.AlbumService) *ImageHandler {
+	return &ImageHandler{
+		imageService: imageService,
+		photoService: photoService,
+		albumService: albumService,
+	}
+}
+
+func parseTransformOptions(c *fiber.Ctx) services.TransformOptions {
+	opts := services.TransformOptions{
+		Width:   c.QueryInt("w"),
+		Height:  c.QueryInt("h"),
+		Fit:     c.Query("fit"),
+		Format:  c.Query("format"),
+		Quality: c.QueryInt("q"),
+	}
+	opts.Normalize()
+	return opts
+}
+
+func (h *ImageHandler) GetImage(c *fiber.Ctx) error {
+	photoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid photo id")
+	}
+
+	opts := parseTransformOptions(c)
+
+	photo, err := h.photoService.GetPhotoByID(c.Context(), photoID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get photo: "+err.Error())
+	}
+	if photo == nil {
+		return fiber.NewError(fiber.StatusNotFound, "photo not found")
+	}
+
+	signed := false
+	if sig := c.Query("sig"); sig != "" {
+		expires, err := strconv.ParseInt(c.Query("exp"), 10, 64)
+		if err != nil || !h.imageService.VerifySignature(photoID, opts, expires, sig) {
+			return fiber.NewError(fiber.StatusForbidden, "invalid or expired signature")
+		}
+		signed = true
+	} else if link, ok := c.Locals("share_link").(*models.ShareLink); ok {
+		if !link.Includes(photo) {
+			return fiber.NewError(fiber.StatusForbidden, "access denied to this photo")
+		}
+	} else {
+		userID, ok := c.Locals("user_id").(int64)
+		if !ok {
+			return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+		}
+
+		role, _ := c.Locals("user_role").(models.UserRole)
+		if role != models.RoleAdmin {
+			canAccess, err := h.albumService.CanUserAccessAlbum(c.Context(), int(userID), photo.AlbumID)
+			if err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+			}
+			if !canAccess {
+				return fiber.NewError(fiber.StatusForbidden, "access denied to this photo")
+			}
+		}
+	}
+
+	if err := opts.Validate(signed); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	data, err := h.imageService.Transform(c.Context(), photo.Filename, opts)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to transform image: "+err.Error())
+	}
+
+	c.Set("Content-Type", opts.ContentType())
+	if signed {
+		c.Set("Cache-Control", "public, max-age=86400")
+	} else {
+		c.Set("Cache-Control", "private, max-age=86400")
+	}
+
+	return c.Send(data)
+}
+
+func (h *ImageHandler) SignImageURL(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	photoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid photo id")
+	}
+
+	photo, err := h.photoService.GetPhotoByID(c.Context(), photoID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get photo: "+err.Error())
+	}
+	if photo == nil {
+		return fiber.NewError(fiber.StatusNotFound, "photo not found")
+	}
+
+	album, err := h.albumService.GetAlbumByID(c.Context(), photo.AlbumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
+	}
+	if album == nil {
+		return fiber.NewError(fiber.StatusNotFound, "album not found")
+	}
+
+	if err := requireManageAlbum(c, h.albumService, userID, role, album, "you can only sign image URLs for your own albums"); err != nil {
+		return err
+	}
+
+	opts := parseTransformOptions(c)
+	if err := opts.Validate(true); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	expiresIn := time.Duration(c.QueryInt("expiresIn", 3600)) * time.Second
+	if expiresIn <= 0 || expiresIn > maxSignedImageExpiry {
+		return fiber.NewError(fiber.StatusBadRequest, "expiresIn must be between 1 second and 30 days")
+	}
+	expiresAt := time.Now().Add(expiresIn)
+
+	query := h.imageService.SignQuery(photoID, opts, expiresAt)
+
+	return c.JSON(fiber.Map{
+		"url":        fmt.Sprintf("/api/photos/%d/image?%s", photoID, query.Encode()),
+		"expires_at": expiresAt.UTC(),
+	})
+}
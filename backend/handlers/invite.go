@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/middleware"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/services"
+)
+
+type InviteHandler struct {
+	inviteService *services.InviteService
+	authService   *services.AuthService
+}
+
+func NewInviteHandler(inviteService *services.InviteService, authService *services.AuthService) *InviteHandler {
+	return &InviteHandler{
+		inviteService: inviteService,
+		authService:   authService,
+	}
+}
+
+type CreateInviteRequest struct {
+	Email        string  `json:"email"`
+	FriendlyName string  `json:"friendlyName,omitempty"`
+	AlbumIDs     []int64 `json:"albumIds,omitempty"`
+}
+
+type InviteTokenRequest struct {
+	Token string `json:"token"`
+}
+
+type AcceptInviteRequest struct {
+	Token        string `json:"token"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	FriendlyName string `json:"friendlyName,omitempty"`
+}
+
+func (h *InviteHandler) currentUser(c *fiber.Ctx) (*models.User, error) {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	user, err := h.authService.GetUserByID(userID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
+	}
+	if user == nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not found")
+	}
+	return user, nil
+}
+
+// CreateInvite emails a client a link to join, optionally with albums they
+// are assigned to once they accept.
+func (h *InviteHandler) CreateInvite(c *fiber.Ctx) error {
+	inviter, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateInviteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Email == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "email is required")
+	}
+
+	invite, err := h.inviteService.CreateInvite(c.Context(), inviter, req.Email, req.FriendlyName, req.AlbumIDs)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(invite)
+}
+
+// ListInvites returns the photographer's invites that are still pending,
+// including expired ones that can be resent.
+func (h *InviteHandler) ListInvites(c *fiber.Ctx) error {
+	photographerID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	invites, err := h.inviteService.ListInvites(c.Context(), photographerID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list invites")
+	}
+
+	return c.JSON(invites)
+}
+
+func (h *InviteHandler) ResendInvite(c *fiber.Ctx) error {
+	inviter, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	inviteID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid invite ID")
+	}
+
+	invite, err := h.inviteService.ResendInvite(c.Context(), inviter, inviteID)
+	if err != nil {
+		fmt.Printf("Warning: failed to resend invite %d: %v\n", inviteID, err)
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to resend invite")
+	}
+	if invite == nil {
+		return fiber.NewError(fiber.StatusNotFound, "invite not found")
+	}
+
+	return c.JSON(invite)
+}
+
+func (h *InviteHandler) RevokeInvite(c *fiber.Ctx) error {
+	photographerID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	inviteID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid invite ID")
+	}
+
+	revoked, err := h.inviteService.RevokeInvite(c.Context(), photographerID, inviteID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke invite")
+	}
+	if !revoked {
+		return fiber.NewError(fiber.StatusNotFound, "invite not found")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// PreviewInvite tells the invite page who sent the invite and whether the
+// address already has an account to sign in with.
+func (h *InviteHandler) PreviewInvite(c *fiber.Ctx) error {
+	var req InviteTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "token is required")
+	}
+
+	preview, err := h.inviteService.PreviewInvite(c.Context(), req.Token)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(preview)
+}
+
+// AcceptInvite creates the invited client's account and signs them in.
+func (h *InviteHandler) AcceptInvite(c *fiber.Ctx) error {
+	var req AcceptInviteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Token == "" || req.Username == "" || req.Password == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "token, username, and password are required")
+	}
+
+	result, err := h.inviteService.AcceptInvite(c.Context(), req.Token, req.Username, req.Password, req.FriendlyName, middleware.RequestClient(c))
+	if errors.Is(err, services.ErrInviteAccountExists) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return loginResponse(c, fiber.StatusCreated, result)
+}
+
+// LinkInvite accepts an invite with the account the client is signed in to.
+func (h *InviteHandler) LinkInvite(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req InviteTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "token is required")
+	}
+
+	err = h.inviteService.LinkInvite(c.Context(), req.Token, user)
+	if errors.Is(err, services.ErrInviteWrongAccount) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/middleware"
+	"github.com/suipic/backend/services"
+)
+
+// MFAChallengeResponse is returned by login instead of tokens when the user
+// has to present a second factor, or set one up first.
+type MFAChallengeResponse struct {
+	MFARequired           bool   `json:"mfaRequired"`
+	MFAEnrollmentRequired bool   `json:"mfaEnrollmentRequired"`
+	MFAToken              string `json:"mfaToken"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfaToken"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type MFAEnrollmentResponse struct {
+	AuthResponse
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// VerifyMFA completes a login with a code from the user's authenticator or
+// one of their recovery codes.
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var req MFAVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.MFAToken == "" || req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "mfaToken and code are required")
+	}
+
+	user, tokens, err := h.authService.CompleteMFALogin(c.Context(), req.MFAToken, req.Code, middleware.RequestClient(c))
+	if err != nil {
+		return loginError(c, err)
+	}
+
+	return c.JSON(newAuthResponse(user, tokens))
+}
+
+// BeginMFAEnrollment starts TOTP setup for a user whose login is waiting on
+// it because their role requires two-factor authentication.
+func (h *AuthHandler) BeginMFAEnrollment(c *fiber.Ctx) error {
+	var req MFAEnrollRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.MFAToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "mfaToken is required")
+	}
+
+	enrollment, err := h.authService.BeginChallengeEnrollment(c.Context(), req.MFAToken)
+	if errors.Is(err, services.ErrMFANotConfigured) {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	return c.JSON(enrollment)
+}
+
+func (h *AuthHandler) CompleteMFAEnrollment(c *fiber.Ctx) error {
+	var req MFAVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.MFAToken == "" || req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "mfaToken and code are required")
+	}
+
+	user, tokens, recoveryCodes, err := h.authService.CompleteChallengeEnrollment(c.Context(), req.MFAToken, req.Code, middleware.RequestClient(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	return c.JSON(MFAEnrollmentResponse{
+		AuthResponse:  newAuthResponse(user, tokens),
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (h *AuthHandler) GetMFAStatus(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	status, err := h.authService.GetMFAStatus(c.Context(), user)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve two-factor status")
+	}
+
+	return c.JSON(status)
+}
+
+func (h *AuthHandler) SetupTOTP(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := h.authService.BeginTOTPEnrollment(c.Context(), user)
+	if errors.Is(err, services.ErrMFANotConfigured) {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(enrollment)
+}
+
+func (h *AuthHandler) EnableTOTP(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	code, err := parseMFACode(c)
+	if err != nil {
+		return err
+	}
+
+	recoveryCodes, err := h.authService.EnableTOTP(c.Context(), user, code)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+func (h *AuthHandler) DisableTOTP(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req DisableMFARequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Password == "" || req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "password and code are required")
+	}
+
+	if err := h.authService.DisableTOTP(c.Context(), user, req.Password, req.Code, middleware.RequestClient(c)); err != nil {
+		return reauthError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	code, err := parseMFACode(c)
+	if err != nil {
+		return err
+	}
+
+	recoveryCodes, err := h.authService.RegenerateRecoveryCodes(c.Context(), user, code)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+func parseMFACode(c *fiber.Ctx) (string, error) {
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return "", fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Code == "" {
+		return "", fiber.NewError(fiber.StatusBadRequest, "code is required")
+	}
+	return req.Code, nil
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/middleware"
+	"github.com/suipic/backend/services"
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+type OIDCStartResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+}
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+func (h *OIDCHandler) ListProviders(c *fiber.Ctx) error {
+	return c.JSON(h.oidcService.ListProviders())
+}
+
+// Start returns the identity provider URL to send the browser to. The caller
+// has to keep the state and check that the callback carries the same one.
+func (h *OIDCHandler) Start(c *fiber.Ctx) error {
+	authURL, state, err := h.oidcService.StartLogin(c.Context(), c.Params("provider"))
+	if err != nil {
+		fmt.Printf("Warning: failed to start oidc login with %s: %v\n", c.Params("provider"), err)
+		return fiber.NewError(fiber.StatusBadGateway, "failed to start sign-in with identity provider")
+	}
+
+	return c.JSON(OIDCStartResponse{
+		AuthorizationURL: authURL,
+		State:            state,
+	})
+}
+
+func (h *OIDCHandler) Callback(c *fiber.Ctx) error {
+	var req OIDCCallbackRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Code == "" || req.State == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "code and state are required")
+	}
+
+	result, err := h.oidcService.CompleteLogin(c.Context(), c.Params("provider"), req.Code, req.State, middleware.RequestClient(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	return loginResponse(c, fiber.StatusOK, result)
+}
+
+func (h *OIDCHandler) ListIdentities(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	identities, err := h.oidcService.ListIdentities(c.Context(), userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list linked accounts")
+	}
+
+	return c.JSON(identities)
+}
+
+func (h *OIDCHandler) UnlinkIdentity(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	identityID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid identity ID")
+	}
+
+	found, err := h.oidcService.UnlinkIdentity(c.Context(), userID, identityID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to unlink account")
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound, "linked account not found")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/services"
+)
+
+type OrganizationHandler struct {
+	orgService   *services.OrganizationService
+	authService  *services.AuthService
+	auditService *services.AuditService
+}
+
+func NewOrganizationHandler(orgService *services.OrganizationService, authService *services.AuthService, auditService *services.AuditService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService:   orgService,
+		authService:  authService,
+		auditService: auditService,
+	}
+}
+
+// auditMembership records a change to a user's place in an organization.
+func (h *OrganizationHandler) auditMembership(c *fiber.Ctx, action models.AuditAction, userID int64, organizationID int, before, after models.OrganizationRole) {
+	entry := auditEntry(c, action, models.AuditTargetUser, userID)
+	if before != "" {
+		entry.Before = models.AuditValues{"organizationId": organizationID, "role": before}
+	}
+	if after != "" {
+		entry.After = models.AuditValues{"organizationId": organizationID, "role": after}
+	}
+	h.auditService.Record(c.Context(), entry)
+}
+
+type OrganizationNameRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateOrganizationRequest creates an organization. MoveAlbums brings the
+// owner's existing albums into it; otherwise only new albums are.
+type CreateOrganizationRequest struct {
+	Name       string `json:"name"`
+	MoveAlbums bool   `json:"moveAlbums"`
+}
+
+type AcceptOrganizationInviteRequest struct {
+	MoveAlbums bool `json:"moveAlbums"`
+}
+
+type OrganizationMemberRequest struct {
+	UserID int64                   `json:"userId"`
+	Role   models.OrganizationRole `json:"role"`
+}
+
+type OrganizationQuotasRequest struct {
+	MaxMembers *int `json:"maxMembers"`
+	MaxAlbums  *int `json:"maxAlbums"`
+	MaxPhotos  *int `json:"maxPhotos"`
+}
+
+// organizationError maps organization service errors to HTTP errors.
+func organizationError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotInOrganization):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrOrganizationDenied), errors.Is(err, services.ErrQuotaExceeded):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return fiber.NewError(fiber.StatusBadRequest, err.Error())
+}
+
+// membership returns the current user's organization membership.
+func (h *OrganizationHandler) membership(c *fiber.Ctx) (*models.OrganizationMember, error) {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	member, err := h.orgService.GetMembership(c.Context(), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotInOrganization) {
+			return nil, organizationError(err)
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to get organization membership: "+err.Error())
+	}
+	return member, nil
+}
+
+// CreateOrganization makes the photographer the owner of a new
+// organization.
+func (h *OrganizationHandler) CreateOrganization(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	var req CreateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	user, err := h.authService.GetUserByID(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to retrieve user")
+	}
+	if user == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not found")
+	}
+
+	org, err := h.orgService.CreateOrganization(c.Context(), user, req.Name, req.MoveAlbums)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(org)
+}
+
+func (h *OrganizationHandler) GetOrganization(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	details, err := h.orgService.GetOrganizationDetails(c.Context(), member)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get organization: "+err.Error())
+	}
+
+	return c.JSON(details)
+}
+
+func (h *OrganizationHandler) UpdateOrganization(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	var req OrganizationNameRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	org, err := h.orgService.RenameOrganization(c.Context(), member, req.Name)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return c.JSON(org)
+}
+
+// InviteMember invites a photographer, who joins once they accept.
+func (h *OrganizationHandler) InviteMember(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	var req OrganizationMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	if req.UserID == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "userId is required")
+	}
+	if req.Role == "" {
+		req.Role = models.OrgRolePhotographer
+	}
+
+	invite, err := h.orgService.InviteMember(c.Context(), member, req.UserID, req.Role)
+	if err != nil {
+		return organizationError(err)
+	}
+	h.auditMembership(c, models.AuditOrgInvite, invite.UserID, invite.OrganizationID, "", invite.Role)
+
+	return c.Status(fiber.StatusCreated).JSON(invite)
+}
+
+func (h *OrganizationHandler) ListInvites(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	invites, err := h.orgService.ListInvites(c.Context(), member)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return c.JSON(invites)
+}
+
+func (h *OrganizationHandler) RevokeInvite(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	inviteID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid invite id")
+	}
+
+	invite, err := h.orgService.RevokeInvite(c.Context(), member, inviteID)
+	if err != nil {
+		return organizationError(err)
+	}
+	h.auditMembership(c, models.AuditOrgInviteRevoke, invite.UserID, invite.OrganizationID, invite.Role, "")
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListReceivedInvites lists the invites waiting for the current user.
+func (h *OrganizationHandler) ListReceivedInvites(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	invites, err := h.orgService.ListReceivedInvites(c.Context(), userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list organization invites")
+	}
+
+	return c.JSON(invites)
+}
+
+// AcceptInvite joins the inviting organization, bringing the user's
+// existing albums along only when they ask to.
+func (h *OrganizationHandler) AcceptInvite(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	inviteID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid invite id")
+	}
+
+	var req AcceptOrganizationInviteRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+
+	member, err := h.orgService.AcceptInvite(c.Context(), userID, inviteID, req.MoveAlbums)
+	if err != nil {
+		return organizationError(err)
+	}
+	h.auditMembership(c, models.AuditOrgInviteAccept, member.UserID, member.OrganizationID, "", member.Role)
+
+	return c.JSON(member)
+}
+
+func (h *OrganizationHandler) DeclineInvite(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	inviteID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid invite id")
+	}
+
+	if err := h.orgService.DeclineInvite(c.Context(), userID, inviteID); err != nil {
+		return organizationError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// MoveAlbums moves the current member's own albums into the organization.
+func (h *OrganizationHandler) MoveAlbums(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.orgService.MoveAlbumsIntoOrganization(c.Context(), member); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to move albums: "+err.Error())
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *OrganizationHandler) UpdateMember(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := strconv.ParseInt(c.Params("userId"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user id")
+	}
+
+	var req OrganizationMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	before, err := h.orgService.UpdateMemberRole(c.Context(), member, userID, req.Role)
+	if err != nil {
+		return organizationError(err)
+	}
+	if before.Role != req.Role {
+		h.auditMembership(c, models.AuditOrgMemberRole, userID, before.OrganizationID, before.Role, req.Role)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RemoveMember removes a member; members may also remove themselves to
+// leave the organization.
+func (h *OrganizationHandler) RemoveMember(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := strconv.ParseInt(c.Params("userId"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user id")
+	}
+
+	removed, err := h.orgService.RemoveMember(c.Context(), member, userID)
+	if err != nil {
+		return organizationError(err)
+	}
+	h.auditMembership(c, models.AuditOrgMemberRemove, userID, removed.OrganizationID, removed.Role, "")
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListClients lists the clients of every photographer in the organization.
+func (h *OrganizationHandler) ListClients(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	clients, err := h.orgService.ListClients(c.Context(), member)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get clients")
+	}
+
+	response := make([]ClientResponse, 0, len(clients))
+	for _, client := range clients {
+		response = append(response, ClientResponse{
+			ID:           client.ID,
+			Username:     client.Username,
+			Email:        client.Email,
+			FriendlyName: client.FriendlyName,
+			Role:         string(client.Role),
+			CreatedAt:    client.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+func (h *OrganizationHandler) GetSettings(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	settings, err := h.orgService.GetSettings(c.Context(), member)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get organization settings")
+	}
+
+	return c.JSON(settings)
+}
+
+func (h *OrganizationHandler) UpdateSettings(c *fiber.Ctx) error {
+	member, err := h.membership(c)
+	if err != nil {
+		return err
+	}
+
+	var req map[string]string
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if err := h.orgService.UpdateSettings(c.Context(), member, req); err != nil {
+		return organizationError(err)
+	}
+
+	settings, err := h.orgService.GetSettings(c.Context(), member)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get organization settings")
+	}
+
+	return c.JSON(settings)
+}
+
+func (h *OrganizationHandler) ListOrganizations(c *fiber.Ctx) error {
+	orgs, err := h.orgService.ListOrganizations(c.Context())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list organizations")
+	}
+	if orgs == nil {
+		orgs = []*models.Organization{}
+	}
+
+	return c.JSON(orgs)
+}
+
+// SetQuotas replaces an organization's quotas; omitted quotas are lifted.
+func (h *OrganizationHandler) SetQuotas(c *fiber.Ctx) error {
+	orgID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid organization id")
+	}
+
+	var req OrganizationQuotasRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	org, err := h.orgService.SetQuotas(c.Context(), orgID, req.MaxMembers, req.MaxAlbums, req.MaxPhotos)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if org == nil {
+		return fiber.NewError(fiber.StatusNotFound, "organization not found")
+	}
+
+	return c.JSON(org)
+}
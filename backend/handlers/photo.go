@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"io"
 	"strconv"
 	"time"
@@ -16,22 +17,28 @@ type PhotoHandler struct {
 	albumService   *services.AlbumService
 	commentService *services.CommentService
 	esService      *services.ElasticsearchService
+	shareService   *services.ShareService
+	auditService   *services.AuditService
 }
 
-func NewPhotoHandler(storageService *services.StorageService, photoService *services.PhotoService, albumService *services.AlbumService, commentService *services.CommentService, esService *services.ElasticsearchService) *PhotoHandler {
+func NewPhotoHandler(storageService *services.StorageService, photoService *services.PhotoService, albumService *services.AlbumService, commentService *services.CommentService, esService *services.ElasticsearchService, shareService *services.ShareService, auditService *services.AuditService) *PhotoHandler {
 	return &PhotoHandler{
 		storageService: storageService,
 		photoService:   photoService,
 		albumService:   albumService,
 		commentService: commentService,
 		esService:      esService,
+		shareService:   shareService,
+		auditService:   auditService,
 	}
 }
 
 type UpdatePhotoRequest struct {
-	Title           *string `json:"title"`
-	PickRejectState *string `json:"pickRejectState"`
-	Stars           *int    `json:"stars"`
+	Title           *string   `json:"title"`
+	Caption         *string   `json:"caption"`
+	Keywords        *[]string `json:"keywords"`
+	PickRejectState *string   `json:"pickRejectState"`
+	Stars           *int      `json:"stars"`
 }
 
 func (h *PhotoHandler) CreatePhoto(c *fiber.Ctx) error {
@@ -55,8 +62,12 @@ func (h *PhotoHandler) CreatePhoto(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "album not found")
 	}
 
-	if role != models.RoleAdmin && album.PhotographerID != int(userID) {
-		return fiber.NewError(fiber.StatusForbidden, "you can only upload photos to your own albums")
+	canUpload, err := h.albumService.CanUploadToAlbum(c.Context(), int(userID), role, albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if !canUpload {
+		return fiber.NewError(fiber.StatusForbidden, "you can only upload photos to albums you own or collaborate on")
 	}
 
 	file, err := c.FormFile("photo")
@@ -78,12 +89,16 @@ func (h *PhotoHandler) CreatePhoto(c *fiber.Ctx) error {
 	photo, err := h.photoService.CreatePhoto(
 		c.Context(),
 		albumID,
+		userID,
 		file.Filename,
 		src,
 		file.Size,
 		contentType,
 	)
 	if err != nil {
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to create photo: "+err.Error())
 	}
 
@@ -91,6 +106,51 @@ func (h *PhotoHandler) CreatePhoto(c *fiber.Ctx) error {
 }
 
 func (h *PhotoHandler) GetPhotosByAlbum(c *fiber.Ctx) error {
+	if link, ok := c.Locals("share_link").(*models.ShareLink); ok {
+		return h.sharedAlbumPhotos(c, link)
+	}
+
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	albumID, err := strconv.Atoi(c.Params("albumId"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	album, err := h.albumService.GetAlbumByID(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
+	}
+	if album == nil {
+		return fiber.NewError(fiber.StatusNotFound, "album not found")
+	}
+
+	if role != models.RoleAdmin {
+		canAccess, err := h.albumService.CanUserAccessAlbum(c.Context(), int(userID), albumID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+		if !canAccess {
+			return fiber.NewError(fiber.StatusForbidden, "access denied to this album")
+		}
+	}
+
+	photos, err := h.photoService.GetPhotosByAlbum(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get photos: "+err.Error())
+	}
+
+	return c.JSON(h.photoService.RedactPhotosForViewer(c.Context(), photos, int(userID), role))
+}
+
+// GetAlbumMap returns the album's geotagged photos as a GeoJSON
+// FeatureCollection, clustered when a zoom level is given.
+func (h *PhotoHandler) GetAlbumMap(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(int64)
 	if !ok {
 		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
@@ -103,6 +163,19 @@ func (h *PhotoHandler) GetPhotosByAlbum(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
 	}
 
+	zoom, err := parseMapZoom(c)
+	if err != nil {
+		return err
+	}
+
+	var bbox *services.BoundingBox
+	if bboxStr := c.Query("bbox"); bboxStr != "" {
+		bbox, err = services.ParseBoundingBox(bboxStr)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+
 	album, err := h.albumService.GetAlbumByID(c.Context(), albumID)
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
@@ -126,10 +199,19 @@ func (h *PhotoHandler) GetPhotosByAlbum(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to get photos: "+err.Error())
 	}
 
-	return c.JSON(photos)
+	photos = h.photoService.RedactPhotosForViewer(c.Context(), photos, int(userID), role)
+	return c.JSON(services.BuildPhotoMap(photos, zoom, bbox))
 }
 
 func (h *PhotoHandler) GetPhoto(c *fiber.Ctx) error {
+	if link, ok := c.Locals("share_link").(*models.ShareLink); ok {
+		photo, err := h.sharedPhoto(c, link)
+		if err != nil {
+			return err
+		}
+		return c.JSON(h.photoService.RedactPhotoForViewer(c.Context(), photo, 0, ""))
+	}
+
 	userID, ok := c.Locals("user_id").(int64)
 	if !ok {
 		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
@@ -160,7 +242,7 @@ func (h *PhotoHandler) GetPhoto(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.JSON(photo)
+	return c.JSON(h.photoService.RedactPhotoForViewer(c.Context(), photo, int(userID), role))
 }
 
 func (h *PhotoHandler) UpdatePhoto(c *fiber.Ctx) error {
@@ -207,10 +289,39 @@ func (h *PhotoHandler) UpdatePhoto(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
 	}
 
+	// Titles, captions and keywords belong to the album's content, so only
+	// those who may add photos to it may edit them; assigned users may at
+	// most rate.
+	if req.Title != nil || req.Caption != nil || req.Keywords != nil {
+		canEdit, err := h.albumService.CanUploadToAlbum(c.Context(), int(userID), role, photo.AlbumID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+		if !canEdit {
+			return fiber.NewError(fiber.StatusForbidden, "you can only edit photos in albums you own or collaborate on")
+		}
+	}
+
+	if req.PickRejectState != nil || req.Stars != nil {
+		if err := requireAlbumPermission(c, h.albumService, photo.AlbumID, models.AlbumPermissionRate); err != nil {
+			return err
+		}
+	}
+
+	previousState, previousStars := photo.PickRejectState, photo.Stars
+
 	if req.Title != nil {
 		photo.Title = req.Title
 	}
 
+	if req.Caption != nil {
+		photo.Caption = req.Caption
+	}
+
+	if req.Keywords != nil {
+		photo.Keywords = *req.Keywords
+	}
+
 	if req.PickRejectState != nil {
 		switch *req.PickRejectState {
 		case "none", "pick", "reject":
@@ -231,7 +342,10 @@ func (h *PhotoHandler) UpdatePhoto(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to update photo: "+err.Error())
 	}
 
-	return c.JSON(photo)
+	auditPhotoState(c, h.auditService, photo, previousState)
+	auditPhotoStars(c, h.auditService, photo, previousStars)
+
+	return c.JSON(h.photoService.RedactPhotoForViewer(c.Context(), photo, int(userID), role))
 }
 
 func (h *PhotoHandler) DeletePhoto(c *fiber.Ctx) error {
@@ -263,14 +377,18 @@ func (h *PhotoHandler) DeletePhoto(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "album not found")
 	}
 
-	if role != models.RoleAdmin && album.PhotographerID != int(userID) {
-		return fiber.NewError(fiber.StatusForbidden, "you can only delete photos from your own albums")
+	if err := requireManageAlbum(c, h.albumService, userID, role, album, "you can only delete photos from your own albums"); err != nil {
+		return err
 	}
 
 	if err := h.photoService.DeletePhoto(c.Context(), photoID); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete photo: "+err.Error())
 	}
 
+	entry := photoAuditEntry(c, models.AuditPhotoDelete, photo)
+	entry.Before = models.AuditValues{"filename": photo.Filename, "originalFilename": photo.OriginalFilename}
+	h.auditService.Record(c.Context(), entry)
+
 	return c.JSON(fiber.Map{
 		"message": "photo deleted successfully",
 	})
@@ -313,6 +431,22 @@ func (h *PhotoHandler) UploadPhoto(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(result)
 }
 
+// requireDownload checks that the current user may download the original
+// stored under fileID, and returns its photo.
+func (h *PhotoHandler) requireDownload(c *fiber.Ctx, fileID string) (*models.Photo, error) {
+	photo, err := h.photoService.GetPhotoByFilename(c.Context(), fileID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to get photo: "+err.Error())
+	}
+	if photo == nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "photo not found")
+	}
+	if err := requireAlbumPermission(c, h.albumService, photo.AlbumID, models.AlbumPermissionDownload); err != nil {
+		return nil, err
+	}
+	return photo, nil
+}
+
 func (h *PhotoHandler) DownloadPhoto(c *fiber.Ctx) error {
 	fileID := c.Params("id")
 	if fileID == "" {
@@ -321,6 +455,11 @@ func (h *PhotoHandler) DownloadPhoto(c *fiber.Ctx) error {
 		})
 	}
 
+	photo, err := h.requireDownload(c, fileID)
+	if err != nil {
+		return err
+	}
+
 	object, info, err := h.storageService.DownloadPhoto(c.Context(), fileID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -339,6 +478,8 @@ func (h *PhotoHandler) DownloadPhoto(c *fiber.Ctx) error {
 		})
 	}
 
+	h.auditService.Record(c.Context(), photoAuditEntry(c, models.AuditPhotoDownload, photo))
+
 	return c.Send(data)
 }
 
@@ -379,6 +520,11 @@ func (h *PhotoHandler) GetPresignedURL(c *fiber.Ctx) error {
 		})
 	}
 
+	photo, err := h.requireDownload(c, fileID)
+	if err != nil {
+		return err
+	}
+
 	expires := 1 * time.Hour
 	presignedURL, err := h.storageService.GetPresignedDownloadURL(c.Context(), fileID, expires)
 	if err != nil {
@@ -387,6 +533,8 @@ func (h *PhotoHandler) GetPresignedURL(c *fiber.Ctx) error {
 		})
 	}
 
+	h.auditService.Record(c.Context(), photoAuditEntry(c, models.AuditPhotoDownload, photo))
+
 	return c.JSON(fiber.Map{
 		"url":        presignedURL,
 		"expires_in": int(expires.Seconds()),
@@ -420,6 +568,10 @@ type SetPhotoStateRequest struct {
 }
 
 func (h *PhotoHandler) SetPhotoState(c *fiber.Ctx) error {
+	if link, ok := c.Locals("share_link").(*models.ShareLink); ok {
+		return h.setSharedPhotoState(c, link)
+	}
+
 	userID, ok := c.Locals("user_id").(int64)
 	if !ok {
 		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
@@ -440,14 +592,8 @@ func (h *PhotoHandler) SetPhotoState(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "photo not found")
 	}
 
-	if role != models.RoleAdmin {
-		canAccess, err := h.albumService.CanUserAccessAlbum(c.Context(), int(userID), photo.AlbumID)
-		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
-		}
-		if !canAccess {
-			return fiber.NewError(fiber.StatusForbidden, "access denied to this photo")
-		}
+	if err := requireAlbumPermission(c, h.albumService, photo.AlbumID, models.AlbumPermissionRate); err != nil {
+		return err
 	}
 
 	var req SetPhotoStateRequest
@@ -455,6 +601,7 @@ func (h *PhotoHandler) SetPhotoState(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
 	}
 
+	previous := photo.PickRejectState
 	switch req.State {
 	case "none", "pick", "reject":
 		photo.PickRejectState = models.PickRejectState(req.State)
@@ -466,7 +613,9 @@ func (h *PhotoHandler) SetPhotoState(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to update photo: "+err.Error())
 	}
 
-	return c.JSON(photo)
+	auditPhotoState(c, h.auditService, photo, previous)
+
+	return c.JSON(h.photoService.RedactPhotoForViewer(c.Context(), photo, int(userID), role))
 }
 
 type SetPhotoStarsRequest struct {
@@ -494,14 +643,8 @@ func (h *PhotoHandler) SetPhotoStars(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "photo not found")
 	}
 
-	if role != models.RoleAdmin {
-		canAccess, err := h.albumService.CanUserAccessAlbum(c.Context(), int(userID), photo.AlbumID)
-		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
-		}
-		if !canAccess {
-			return fiber.NewError(fiber.StatusForbidden, "access denied to this photo")
-		}
+	if err := requireAlbumPermission(c, h.albumService, photo.AlbumID, models.AlbumPermissionRate); err != nil {
+		return err
 	}
 
 	var req SetPhotoStarsRequest
@@ -513,13 +656,16 @@ func (h *PhotoHandler) SetPhotoStars(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "stars must be between 0 and 5")
 	}
 
+	previous := photo.Stars
 	photo.Stars = req.Stars
 
 	if err := h.photoService.UpdatePhoto(c.Context(), photo); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to update photo: "+err.Error())
 	}
 
-	return c.JSON(photo)
+	auditPhotoStars(c, h.auditService, photo, previous)
+
+	return c.JSON(h.photoService.RedactPhotoForViewer(c.Context(), photo, int(userID), role))
 }
 
 type CreateCommentRequest struct {
@@ -533,8 +679,6 @@ func (h *PhotoHandler) CreateComment(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
 	}
 
-	role, _ := c.Locals("user_role").(models.UserRole)
-
 	photoID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid photo id")
@@ -548,14 +692,8 @@ func (h *PhotoHandler) CreateComment(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "photo not found")
 	}
 
-	if role != models.RoleAdmin {
-		canAccess, err := h.albumService.CanUserAccessAlbum(c.Context(), int(userID), photo.AlbumID)
-		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
-		}
-		if !canAccess {
-			return fiber.NewError(fiber.StatusForbidden, "access denied to this photo")
-		}
+	if err := requireAlbumPermission(c, h.albumService, photo.AlbumID, models.AlbumPermissionComment); err != nil {
+		return err
 	}
 
 	var req CreateCommentRequest
@@ -578,12 +716,8 @@ func (h *PhotoHandler) CreateComment(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to create comment: "+err.Error())
 	}
 
-	if h.esService != nil {
-		album, _ := h.albumService.GetAlbumByID(c.Context(), photo.AlbumID)
-		comments, _ := h.commentService.GetCommentsByPhoto(c.Context(), photoID)
-		if err := h.esService.IndexPhoto(c.Context(), photo, album, comments); err != nil {
-			println("Warning: failed to update photo index after comment:", err.Error())
-		}
+	if err := h.photoService.ReindexPhoto(c.Context(), photo); err != nil {
+		println("Warning: failed to update photo index after comment:", err.Error())
 	}
 
 	commentWithUser, err := h.commentService.GetCommentWithUser(c.Context(), comment.ID)
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/models"
 	"github.com/suipic/backend/services"
 )
 
@@ -27,6 +30,99 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusServiceUnavailable, "search service is not available")
 	}
 
+	filter, err := parseSearchFilter(c)
+	if err != nil {
+		return err
+	}
+
+	userID, _ := c.Locals("user_id").(int64)
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	if filter.BoundingBox != nil || filter.Near != nil || filter.Country != nil || filter.City != nil {
+		albumIDs, err := h.photoService.AlbumIDsWithVisibleGPS(c.Context(), int(userID), role)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to list albums: "+err.Error())
+		}
+		filter.AlbumIDs = albumIDs
+	}
+
+	result, err := h.esService.Search(c.Context(), filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "search failed: "+err.Error())
+	}
+
+	result.Photos = h.photoService.RedactPhotosForViewer(c.Context(), result.Photos, int(userID), role)
+
+	return c.JSON(result)
+}
+
+// SearchMap returns the photos matching a search as GeoJSON, limited to the
+// albums whose coordinates the user can see. Paging parameters are ignored; up to
+// services.MaxMapPhotos photos are placed on the map.
+func (h *SearchHandler) SearchMap(c *fiber.Ctx) error {
+	if h.esService == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "search service is not available")
+	}
+
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	filter, err := parseSearchFilter(c)
+	if err != nil {
+		return err
+	}
+
+	zoom, err := parseMapZoom(c)
+	if err != nil {
+		return err
+	}
+
+	albumIDs, err := h.photoService.AlbumIDsWithVisibleGPS(c.Context(), int(userID), role)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list albums: "+err.Error())
+	}
+	if albumIDs != nil && len(albumIDs) == 0 {
+		return c.JSON(services.BuildPhotoMap(nil, zoom, nil))
+	}
+
+	filter.AlbumIDs = albumIDs
+	filter.HasLocation = true
+	filter.Limit = services.MaxMapPhotos
+	filter.Offset = 0
+
+	result, err := h.esService.Search(c.Context(), filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "search failed: "+err.Error())
+	}
+
+	photos := h.photoService.RedactPhotosForViewer(c.Context(), result.Photos, int(userID), role)
+	return c.JSON(services.BuildPhotoMap(photos, zoom, filter.BoundingBox))
+}
+
+func (h *SearchHandler) BulkIndexAlbum(c *fiber.Ctx) error {
+	if h.esService == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "search service is not available")
+	}
+
+	albumID, err := strconv.Atoi(c.Params("albumId"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	if err := h.photoService.BulkIndexPhotosByAlbum(c.Context(), albumID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to bulk index album: "+err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "album photos indexed successfully",
+	})
+}
+
+func parseSearchFilter(c *fiber.Ctx) (*services.SearchFilter, error) {
 	query := c.Query("q", "")
 
 	filter := &services.SearchFilter{
@@ -35,10 +131,23 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 		Offset: 0,
 	}
 
+	if keyword := c.Query("keyword"); keyword != "" {
+		filter.Keyword = &keyword
+	}
+
+	if country := c.Query("country"); country != "" {
+		country = strings.ToUpper(country)
+		filter.Country = &country
+	}
+
+	if city := c.Query("city"); city != "" {
+		filter.City = &city
+	}
+
 	if albumIDStr := c.Query("album"); albumIDStr != "" {
 		albumID, err := strconv.Atoi(albumIDStr)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid album id")
 		}
 		filter.AlbumID = &albumID
 	}
@@ -46,7 +155,7 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 	if dateFromStr := c.Query("dateFrom"); dateFromStr != "" {
 		dateFrom, err := time.Parse(time.RFC3339, dateFromStr)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid dateFrom format, use RFC3339")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid dateFrom format, use RFC3339")
 		}
 		filter.DateFrom = &dateFrom
 	}
@@ -54,7 +163,7 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 	if dateToStr := c.Query("dateTo"); dateToStr != "" {
 		dateTo, err := time.Parse(time.RFC3339, dateToStr)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid dateTo format, use RFC3339")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid dateTo format, use RFC3339")
 		}
 		filter.DateTo = &dateTo
 	}
@@ -62,10 +171,10 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 	if minStarsStr := c.Query("minStars"); minStarsStr != "" {
 		minStars, err := strconv.Atoi(minStarsStr)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid minStars")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid minStars")
 		}
 		if minStars < 0 || minStars > 5 {
-			return fiber.NewError(fiber.StatusBadRequest, "minStars must be between 0 and 5")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "minStars must be between 0 and 5")
 		}
 		filter.MinStars = &minStars
 	}
@@ -73,17 +182,17 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 	if maxStarsStr := c.Query("maxStars"); maxStarsStr != "" {
 		maxStars, err := strconv.Atoi(maxStarsStr)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid maxStars")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid maxStars")
 		}
 		if maxStars < 0 || maxStars > 5 {
-			return fiber.NewError(fiber.StatusBadRequest, "maxStars must be between 0 and 5")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "maxStars must be between 0 and 5")
 		}
 		filter.MaxStars = &maxStars
 	}
 
 	if state := c.Query("state"); state != "" {
 		if state != "none" && state != "pick" && state != "reject" {
-			return fiber.NewError(fiber.StatusBadRequest, "state must be 'none', 'pick', or 'reject'")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "state must be 'none', 'pick', or 'reject'")
 		}
 		filter.State = &state
 	}
@@ -91,7 +200,7 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 	if limitStr := c.Query("limit"); limitStr != "" {
 		limit, err := strconv.Atoi(limitStr)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid limit")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid limit")
 		}
 		if limit > 0 && limit <= 1000 {
 			filter.Limit = limit
@@ -101,36 +210,69 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 	if offsetStr := c.Query("offset"); offsetStr != "" {
 		offset, err := strconv.Atoi(offsetStr)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid offset")
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid offset")
 		}
 		if offset >= 0 {
 			filter.Offset = offset
 		}
 	}
 
-	result, err := h.esService.Search(c.Context(), filter)
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "search failed: "+err.Error())
+	if bboxStr := c.Query("bbox"); bboxStr != "" {
+		bbox, err := services.ParseBoundingBox(bboxStr)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		filter.BoundingBox = bbox
 	}
 
-	return c.JSON(result)
+	if nearStr := c.Query("near"); nearStr != "" {
+		near, err := parseGeoDistance(nearStr, c.Query("radius"))
+		if err != nil {
+			return nil, err
+		}
+		filter.Near = near
+	}
+
+	return filter, nil
 }
 
-func (h *SearchHandler) BulkIndexAlbum(c *fiber.Ctx) error {
-	if h.esService == nil {
-		return fiber.NewError(fiber.StatusServiceUnavailable, "search service is not available")
+// parseGeoDistance reads "lat,lon" and a radius in kilometres, defaulting to
+// 10 km.
+func parseGeoDistance(near, radius string) (*services.GeoDistance, error) {
+	parts := strings.Split(near, ",")
+	if len(parts) != 2 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "near must be lat,lon")
 	}
-
-	albumID, err := strconv.Atoi(c.Params("albumId"))
-	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid near latitude")
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid near longitude")
 	}
 
-	if err := h.photoService.BulkIndexPhotosByAlbum(c.Context(), albumID); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "failed to bulk index album: "+err.Error())
+	distance := &services.GeoDistance{Lat: lat, Lon: lon, RadiusKm: 10}
+	if radius != "" {
+		radiusKm, err := strconv.ParseFloat(radius, 64)
+		if err != nil || radiusKm <= 0 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "radius must be a positive number of kilometres")
+		}
+		distance.RadiusKm = radiusKm
 	}
+	return distance, nil
+}
 
-	return c.JSON(fiber.Map{
-		"message": "album photos indexed successfully",
-	})
+// parseMapZoom reads the optional zoom level used for clustering. Without
+// one, every photo is returned as its own feature.
+func parseMapZoom(c *fiber.Ctx) (*int, error) {
+	zoomStr := c.Query("zoom")
+	if zoomStr == "" {
+		return nil, nil
+	}
+	zoom, err := strconv.Atoi(zoomStr)
+	if err != nil || zoom < 0 || zoom > services.MaxMapZoom {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("zoom must be between 0 and %d", services.MaxMapZoom))
+	}
+	return &zoom, nil
 }
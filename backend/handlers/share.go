@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/middleware"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/services"
+)
+
+type ShareHandler struct {
+	shareService   *services.ShareService
+	albumService   *services.AlbumService
+	photoService   *services.PhotoService
+	storageService *services.StorageService
+	auditService   *services.AuditService
+}
+
+func NewShareHandler(shareService *services.ShareService, albumService *services.AlbumService, photoService *services.PhotoService, storageService *services.StorageService, auditService *services.AuditService) *ShareHandler {
+	return &ShareHandler{
+		shareService:   shareService,
+		albumService:   albumService,
+		photoService:   photoService,
+		storageService: storageService,
+		auditService:   auditService,
+	}
+}
+
+type CreateShareLinkRequest struct {
+	Name          string     `json:"name,omitempty"`
+	PhotoIDs      []int64    `json:"photoIds,omitempty"`
+	Password      string     `json:"password,omitempty"`
+	Mode          string     `json:"mode,omitempty"`
+	AllowDownload bool       `json:"allowDownload"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+}
+
+type OpenShareLinkRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password,omitempty"`
+}
+
+// sharedAlbum returns the album from the :id parameter, checking that the
+// current user may share it. It also reports whether they may manage the
+// album; users who may only share see and revoke just the links they created.
+func (h *ShareHandler) sharedAlbum(c *fiber.Ctx) (*models.Album, bool, error) {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return nil, false, fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	albumID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, false, fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	album, err := h.albumService.GetAlbumByID(c.Context(), albumID)
+	if err != nil {
+		return nil, false, fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
+	}
+	if album == nil {
+		return nil, false, fiber.NewError(fiber.StatusNotFound, "album not found")
+	}
+
+	manager, err := h.albumService.CanManageAlbum(c.Context(), int(userID), role, album)
+	if err != nil {
+		return nil, false, fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if !manager {
+		if err := requireAlbumPermission(c, h.albumService, album.ID, models.AlbumPermissionShare); err != nil {
+			return nil, false, err
+		}
+	}
+	return album, manager, nil
+}
+
+// sharedLink returns the link from the :linkId parameter, failing with 404
+// unless it belongs to the album and the user manages the album or created
+// the link.
+func (h *ShareHandler) sharedLink(c *fiber.Ctx, album *models.Album, manager bool) (*models.ShareLink, error) {
+	linkID, err := strconv.Atoi(c.Params("linkId"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid share link ID")
+	}
+
+	link, err := h.shareService.GetShareLink(c.Context(), album.ID, linkID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to get share link")
+	}
+	userID, _ := c.Locals("user_id").(int64)
+	if link == nil || (!manager && link.CreatedBy != userID) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "share link not found")
+	}
+	return link, nil
+}
+
+// CreateShareLink creates a public link to the album, or to a selection of
+// its photos. Besides the photographer, assigned users allowed to share may
+// create links, but no link lets guests do more than its creator could. The
+// token is only ever returned here.
+func (h *ShareHandler) CreateShareLink(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	albumID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	album, err := h.albumService.GetAlbumByID(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
+	}
+	if album == nil {
+		return fiber.NewError(fiber.StatusNotFound, "album not found")
+	}
+
+	if err := requireAlbumPermission(c, h.albumService, album.ID, models.AlbumPermissionShare); err != nil {
+		return err
+	}
+
+	var req CreateShareLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if models.ShareMode(req.Mode) == models.ShareModePick {
+		if err := requireAlbumPermission(c, h.albumService, album.ID, models.AlbumPermissionRate); err != nil {
+			return err
+		}
+	}
+	if req.AllowDownload {
+		if err := requireAlbumPermission(c, h.albumService, album.ID, models.AlbumPermissionDownload); err != nil {
+			return err
+		}
+	}
+
+	link, err := h.shareService.CreateShareLink(c.Context(), userID, album.ID, services.ShareLinkOptions{
+		Name:          req.Name,
+		PhotoIDs:      req.PhotoIDs,
+		Password:      req.Password,
+		Mode:          models.ShareMode(req.Mode),
+		AllowDownload: req.AllowDownload,
+		ExpiresAt:     req.ExpiresAt,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	entry := albumAuditEntry(c, models.AuditShareLinkCreate, album.ID)
+	entry.TargetType = models.AuditTargetShareLink
+	entry.TargetID = strconv.Itoa(link.ID)
+	entry.After = models.AuditValues{
+		"name":          link.Name,
+		"mode":          link.Mode,
+		"photoIds":      link.PhotoIDs,
+		"password":      link.PasswordHash != nil,
+		"allowDownload": link.AllowDownload,
+		"expiresAt":     link.ExpiresAt,
+	}
+	h.auditService.Record(c.Context(), entry)
+
+	return c.Status(fiber.StatusCreated).JSON(link)
+}
+
+// ListShareLinks returns the album's links. Users who may share but not
+// manage the album only see their own.
+func (h *ShareHandler) ListShareLinks(c *fiber.Ctx) error {
+	album, manager, err := h.sharedAlbum(c)
+	if err != nil {
+		return err
+	}
+
+	links, err := h.shareService.ListShareLinks(c.Context(), album.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list share links")
+	}
+
+	if !manager {
+		userID, _ := c.Locals("user_id").(int64)
+		links = slices.DeleteFunc(links, func(link *models.ShareLink) bool {
+			return link.CreatedBy != userID
+		})
+	}
+
+	return c.JSON(links)
+}
+
+func (h *ShareHandler) RevokeShareLink(c *fiber.Ctx) error {
+	album, manager, err := h.sharedAlbum(c)
+	if err != nil {
+		return err
+	}
+
+	link, err := h.sharedLink(c, album, manager)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := h.shareService.RevokeShareLink(c.Context(), album.ID, link.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke share link")
+	}
+	if !revoked {
+		return fiber.NewError(fiber.StatusNotFound, "share link not found")
+	}
+
+	entry := albumAuditEntry(c, models.AuditShareLinkRevoke, album.ID)
+	entry.TargetType = models.AuditTargetShareLink
+	entry.TargetID = strconv.Itoa(link.ID)
+	h.auditService.Record(c.Context(), entry)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListShareLinkAccesses returns who opened a link and what they did with it.
+func (h *ShareHandler) ListShareLinkAccesses(c *fiber.Ctx) error {
+	album, manager, err := h.sharedAlbum(c)
+	if err != nil {
+		return err
+	}
+
+	link, err := h.sharedLink(c, album, manager)
+	if err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 || limit > 500 {
+		return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 500")
+	}
+
+	accesses, err := h.shareService.ListAccesses(c.Context(), album.ID, link.ID, limit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list share link accesses")
+	}
+	if accesses == nil {
+		return fiber.NewError(fiber.StatusNotFound, "share link not found")
+	}
+
+	return c.JSON(accesses)
+}
+
+// OpenShareLink exchanges a share link token, and its password when it has
+// one, for a guest token. Guests send it in the X-Share-Token header.
+func (h *ShareHandler) OpenShareLink(c *fiber.Ctx) error {
+	var req OpenShareLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "token is required")
+	}
+
+	session, err := h.shareService.OpenShareLink(c.Context(), req.Token, req.Password, middleware.RequestClient(c))
+	if errors.Is(err, services.ErrShareLinkInvalid) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return loginError(c, err)
+	}
+
+	return c.JSON(session)
+}
+
+// DownloadPhoto lets a guest download the original of a shared photo when
+// the link allows downloads.
+func (h *ShareHandler) DownloadPhoto(c *fiber.Ctx) error {
+	link, ok := c.Locals("share_link").(*models.ShareLink)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "missing share token")
+	}
+	if !link.AllowDownload {
+		return fiber.NewError(fiber.StatusForbidden, "this link does not allow downloads")
+	}
+
+	photoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid photo id")
+	}
+
+	photo, err := h.photoService.GetPhotoByID(c.Context(), photoID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get photo: "+err.Error())
+	}
+	if photo == nil || !link.Includes(photo) {
+		return fiber.NewError(fiber.StatusNotFound, "photo not found")
+	}
+
+	object, info, err := h.storageService.DownloadPhoto(c.Context(), photo.Filename)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "photo not found: "+err.Error())
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to read photo")
+	}
+
+	h.shareService.RecordAccess(c.Context(), link, models.ShareAccessDownload, &photo.ID, middleware.RequestClient(c))
+	h.auditService.Record(c.Context(), photoAuditEntry(c, models.AuditPhotoDownload, photo))
+
+	filename := info.Key
+	if photo.OriginalFilename != nil && *photo.OriginalFilename != "" {
+		filename = *photo.OriginalFilename
+	}
+	c.Set("Content-Type", info.ContentType)
+	c.Attachment(filename)
+
+	return c.Send(data)
+}
+
+// sharedAlbumPhotos answers GetPhotosByAlbum for a share link guest with the
+// photos the link shares.
+func (h *PhotoHandler) sharedAlbumPhotos(c *fiber.Ctx, link *models.ShareLink) error {
+	albumID, err := strconv.Atoi(c.Params("albumId"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+	if albumID != link.AlbumID {
+		return fiber.NewError(fiber.StatusForbidden, "access denied to this album")
+	}
+
+	photos, err := h.photoService.GetPhotosByAlbum(c.Context(), albumID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to get photos: "+err.Error())
+	}
+
+	shared := make([]*models.Photo, 0, len(photos))
+	for _, photo := range photos {
+		if link.Includes(photo) {
+			shared = append(shared, photo)
+		}
+	}
+
+	return c.JSON(h.photoService.RedactPhotosForViewer(c.Context(), shared, 0, ""))
+}
+
+// sharedPhoto returns the photo from the :id parameter if the guest's link
+// shares it.
+func (h *PhotoHandler) sharedPhoto(c *fiber.Ctx, link *models.ShareLink) (*models.Photo, error) {
+	photoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid photo id")
+	}
+
+	photo, err := h.photoService.GetPhotoByID(c.Context(), photoID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to get photo: "+err.Error())
+	}
+	if photo == nil || !link.Includes(photo) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "photo not found")
+	}
+	return photo, nil
+}
+
+// setSharedPhotoState lets a guest pick and reject photos through a link in
+// pick mode.
+func (h *PhotoHandler) setSharedPhotoState(c *fiber.Ctx, link *models.ShareLink) error {
+	if link.Mode != models.ShareModePick {
+		return fiber.NewError(fiber.StatusForbidden, "this link does not allow picking photos")
+	}
+
+	photo, err := h.sharedPhoto(c, link)
+	if err != nil {
+		return err
+	}
+
+	var req SetPhotoStateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	previous := photo.PickRejectState
+	switch req.State {
+	case "none", "pick", "reject":
+		photo.PickRejectState = models.PickRejectState(req.State)
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "invalid state, must be 'none', 'pick', or 'reject'")
+	}
+
+	if err := h.photoService.UpdatePhoto(c.Context(), photo); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to update photo: "+err.Error())
+	}
+
+	h.shareService.RecordAccess(c.Context(), link, models.ShareAccessPick, &photo.ID, middleware.RequestClient(c))
+	auditPhotoState(c, h.auditService, photo, previous)
+
+	return c.JSON(h.photoService.RedactPhotoForViewer(c.Context(), photo, 0, ""))
+}
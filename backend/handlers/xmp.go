@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/services"
+)
+
+// Sidecars are small text files; these bounds keep an upload, and zip
+// archives in particular, from expanding into more than the import needs.
+const (
+	maxSidecarSize      = 1 << 20
+	maxSidecarTotalSize = 64 << 20
+	maxSidecarFiles     = 10000
+)
+
+type XMPHandler struct {
+	xmpService   *services.XMPService
+	albumService *services.AlbumService
+	auditService *services.AuditService
+}
+
+func NewXMPHandler(xmpService *services.XMPService, albumService *services.AlbumService, auditService *services.AuditService) *XMPHandler {
+	return &XMPHandler{
+		xmpService:   xmpService,
+		albumService: albumService,
+		auditService: auditService,
+	}
+}
+
+func (h *XMPHandler) ownedAlbum(c *fiber.Ctx) (*models.Album, error) {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+
+	role, _ := c.Locals("user_role").(models.UserRole)
+
+	albumID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid album id")
+	}
+
+	album, err := h.albumService.GetAlbumByID(c.Context(), albumID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to get album: "+err.Error())
+	}
+	if album == nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "album not found")
+	}
+
+	if err := requireManageAlbum(c, h.albumService, userID, role, album, "you can only manage sidecars for your own albums"); err != nil {
+		return nil, err
+	}
+
+	return album, nil
+}
+
+func (h *XMPHandler) ExportSidecars(c *fiber.Ctx) error {
+	album, err := h.ownedAlbum(c)
+	if err != nil {
+		return err
+	}
+
+	return streamBundle(c, fmt.Sprintf("suipic-album-%d-xmp.zip", album.ID), func(ctx context.Context, bundle services.BundleWriter) error {
+		_, err := h.xmpService.ExportAlbum(ctx, album.ID, bundle)
+		return err
+	})
+}
+
+func (h *XMPHandler) ImportSidecars(c *fiber.Ctx) error {
+	album, err := h.ownedAlbum(c)
+	if err != nil {
+		return err
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "multipart form is required")
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "at least one .xmp or .zip file is required")
+	}
+
+	upload := &sidecarUpload{files: make(map[string][]byte)}
+	for _, file := range files {
+		if err := upload.read(file); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+
+	result, err := h.xmpService.ImportSidecars(c.Context(), album.ID, upload.files)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "failed to import sidecars: "+err.Error())
+	}
+
+	for _, change := range result.Changes {
+		auditPhotoState(c, h.auditService, change.Photo, change.PreviousState)
+		auditPhotoStars(c, h.auditService, change.Photo, change.PreviousStars)
+	}
+
+	return c.JSON(result)
+}
+
+// sidecarUpload collects the sidecars of an import request, keeping count of
+// how many there are and how much they add up to.
+type sidecarUpload struct {
+	files map[string][]byte
+	size  int64
+}
+
+func (u *sidecarUpload) read(file *multipart.FileHeader) error {
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s", file.Filename)
+	}
+	defer src.Close()
+
+	switch strings.ToLower(path.Ext(file.Filename)) {
+	case ".xmp":
+		return u.add(file.Filename, file.Size, src)
+	case ".zip":
+		archive, err := zip.NewReader(src, file.Size)
+		if err != nil {
+			return fmt.Errorf("%s is not a valid zip archive", file.Filename)
+		}
+		for _, entry := range archive.File {
+			if entry.FileInfo().IsDir() || strings.ToLower(path.Ext(entry.Name)) != ".xmp" {
+				continue
+			}
+			if entry.UncompressedSize64 > maxSidecarSize {
+				return fmt.Errorf("%s in %s is larger than %d bytes", entry.Name, file.Filename, maxSidecarSize)
+			}
+			rc, err := entry.Open()
+			if err != nil {
+				return fmt.Errorf("failed to open %s in %s", entry.Name, file.Filename)
+			}
+			err = u.add(entry.Name, int64(entry.UncompressedSize64), rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", file.Filename, err)
+			}
+		}
+	default:
+		return fmt.Errorf("%s must be an .xmp or .zip file", file.Filename)
+	}
+
+	return nil
+}
+
+// add reads one sidecar of the given declared size. The declared size is not
+// trusted; reading stops just past the limit either way.
+func (u *sidecarUpload) add(name string, size int64, r io.Reader) error {
+	if len(u.files) >= maxSidecarFiles {
+		return fmt.Errorf("too many sidecars, at most %d can be imported at once", maxSidecarFiles)
+	}
+	if size > maxSidecarSize {
+		return fmt.Errorf("%s is larger than %d bytes", name, maxSidecarSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxSidecarSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read %s", name)
+	}
+	if len(data) > maxSidecarSize {
+		return fmt.Errorf("%s is larger than %d bytes", name, maxSidecarSize)
+	}
+
+	u.size += int64(len(data))
+	if u.size > maxSidecarTotalSize {
+		return fmt.Errorf("sidecars add up to more than %d bytes", maxSidecarTotalSize)
+	}
+	u.files[name] = data
+	return nil
+}
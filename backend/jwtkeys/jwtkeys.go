@@ -0,0 +1,298 @@
+// Package jwtkeys holds the keys Suipic signs and verifies its JWTs with. It
+// signs with either an RS256 or EdDSA private key, or with the shared
+// JWT_SECRET as before, and accepts tokens from any of the configured
+// verification keys so that keys can be rotated without signing everyone out.
+// Tokens that only Suipic itself reads are signed with a separate internal
+// key that is never published.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/suipic/backend/config"
+)
+
+// Key is one signing or verification key. Private is nil for keys that are
+// only kept to verify tokens signed before a rotation.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+	secret  []byte
+}
+
+// internalKeyLabel separates the internal key from anything else derived
+// from the same secret.
+const internalKeyLabel = "suipic internal jwt"
+
+// KeySet is the signing key together with every key tokens are accepted
+// from, and the internal key.
+type KeySet struct {
+	signing  *Key
+	keys     map[string]*Key
+	material []byte
+	internal []byte
+}
+
+// JSONWebKey is a public key as published in the JWKS document.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// Load builds the key set from the configuration. Without JWT_SIGNING_KEY
+// tokens are signed with JWT_SECRET using HS256, which other services cannot
+// verify.
+func Load(cfg *config.JWTConfig) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*Key)}
+
+	if cfg.SigningKey == "" {
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("either JWT_SIGNING_KEY or JWT_SECRET must be set")
+		}
+		set.signing = &Key{Method: jwt.SigningMethodHS256, secret: []byte(cfg.Secret)}
+		set.keys[""] = set.signing
+	} else {
+		key, err := loadKey(cfg.SigningKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_SIGNING_KEY: %w", err)
+		}
+		if key.Private == nil {
+			return nil, fmt.Errorf("invalid JWT_SIGNING_KEY: a private key is required")
+		}
+		set.signing = key
+		set.keys[key.ID] = key
+	}
+
+	// The internal key is derived from the signing secret, so that it is the
+	// same on every instance and changes when the signing key is rotated.
+	set.material = []byte(cfg.Secret)
+	if set.signing.Private != nil {
+		var err error
+		set.material, err = x509.MarshalPKCS8PrivateKey(set.signing.Private)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_SIGNING_KEY: %w", err)
+		}
+	}
+	internal, err := set.DeriveKey(internalKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	set.internal = internal
+
+	for _, source := range cfg.VerificationKeys {
+		key, err := loadKey(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in JWT_VERIFICATION_KEYS: %w", err)
+		}
+		if _, ok := set.keys[key.ID]; !ok {
+			set.keys[key.ID] = key
+		}
+	}
+
+	return set, nil
+}
+
+// DeriveKey derives a 32 byte key from the signing secret for another use.
+// Each use needs its own label, so that a key leaking from one cannot be
+// used for another.
+func (s *KeySet) DeriveKey(label string) ([]byte, error) {
+	return hkdf.Key(sha256.New, s.material, nil, label, 32)
+}
+
+// Asymmetric reports whether tokens are signed with a private key, and can
+// therefore be verified by others through the JWKS document.
+func (s *KeySet) Asymmetric() bool {
+	return s.signing.Private != nil
+}
+
+// Sign signs claims with the signing key, naming it in the kid header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	if s.signing.ID != "" {
+		token.Header["kid"] = s.signing.ID
+	}
+	if s.signing.Private != nil {
+		return token.SignedString(s.signing.Private)
+	}
+	return token.SignedString(s.signing.secret)
+}
+
+// Keyfunc finds the key a token was signed with for jwt.Parse. The token's
+// algorithm has to be the one of that key, so a public key can never be used
+// as an HMAC secret.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	if key.secret != nil {
+		return key.secret, nil
+	}
+	return key.Public, nil
+}
+
+// SignInternal signs claims that only Suipic reads, such as two-factor
+// challenges and guest sessions, with the internal key. Other services
+// cannot verify these, nor be handed one in place of an access token.
+func (s *KeySet) SignInternal(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.internal)
+}
+
+// InternalKeyfunc is Keyfunc for tokens signed with SignInternal.
+func (s *KeySet) InternalKeyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return s.internal, nil
+}
+
+// JWKS returns the public verification keys. It is empty while tokens are
+// signed with the shared secret.
+func (s *KeySet) JWKS() []JSONWebKey {
+	keys := []JSONWebKey{}
+	if jwk, ok := s.signing.jwk(); ok {
+		keys = append(keys, jwk)
+	}
+	for _, key := range s.keys {
+		if key == s.signing {
+			continue
+		}
+		if jwk, ok := key.jwk(); ok {
+			keys = append(keys, jwk)
+		}
+	}
+	return keys
+}
+
+func (k *Key) jwk() (JSONWebKey, bool) {
+	jwk := JSONWebKey{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return jwk, false
+	}
+	return jwk, true
+}
+
+// loadKey reads a PEM encoded RSA or Ed25519 key, either inline or from a
+// file. Private keys also provide their public half, so a retired signing
+// key can be kept for verification as it is.
+func loadKey(source string) (*Key, error) {
+	data := []byte(source)
+	if !strings.HasPrefix(strings.TrimSpace(source), "-----BEGIN") {
+		var err error
+		data, err = os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.Private = signer
+		key.Public = signer.Public()
+	} else {
+		key.Public = parsed
+	}
+
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA keys must be at least 2048 bits")
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("only RSA and Ed25519 keys are supported")
+	}
+
+	key.ID, err = thumbprint(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint of the public key, used as its
+// kid so that the same key always gets the same ID.
+func thumbprint(key *Key) (string, error) {
+	jwk, _ := key.jwk()
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/suipic/backend/config"
+)
+
+// FileMailer writes each message to an .eml file instead of sending it, for
+// development and for setups without outgoing mail.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(cfg *config.MailConfig) (*FileMailer, error) {
+	if cfg.FileDir == "" {
+		return nil, fmt.Errorf("MAIL_FILE_DIR is required for the file mail driver")
+	}
+	if err := os.MkdirAll(cfg.FileDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileMailer{dir: cfg.FileDir, from: cfg.From}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	now := time.Now()
+	data, err := compose(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// LogMailer prints messages to standard output. Links in them are live
+// credentials, so it is only meant for local development.
+type LogMailer struct{}
+
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	fmt.Printf("Mail to %s: %s\n%s\n", msg.To, msg.Subject, msg.Text)
+	return nil
+}
@@ -0,0 +1,77 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+	TemplateEmailChange   = "email_change"
+	TemplateClientInvite  = "client_invite"
+)
+
+// TemplateData is what the bundled templates can refer to.
+type TemplateData struct {
+	AppName   string
+	Name      string
+	Inviter   string
+	Link      string
+	Action    string
+	ExpiresIn string
+}
+
+type messageTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = loadTemplates(TemplateVerifyEmail, TemplatePasswordReset, TemplateEmailChange, TemplateClientInvite)
+
+func loadTemplates(names ...string) map[string]messageTemplate {
+	loaded := make(map[string]messageTemplate, len(names))
+	for _, name := range names {
+		file := "templates/" + name + ".tmpl"
+		loaded[name] = messageTemplate{
+			text: texttemplate.Must(texttemplate.ParseFS(templateFiles, file)),
+			html: htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/layout.tmpl", file)),
+		}
+	}
+	return loaded
+}
+
+// Render fills in one of the bundled templates. The subject and plain text
+// body come from its "subject" and "text" blocks, the HTML body from its
+// "body" block wrapped in the shared layout.
+func Render(name, to string, data *TemplateData) (*Message, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, fmt.Errorf("failed to render text body: %w", err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "html", data); err != nil {
+		return nil, fmt.Errorf("failed to render HTML body: %w", err)
+	}
+
+	return &Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
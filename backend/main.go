@@ -11,8 +11,12 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/suipic/backend/captcha"
 	"github.com/suipic/backend/config"
+	"github.com/suipic/backend/geocoder"
 	"github.com/suipic/backend/handlers"
+	"github.com/suipic/backend/jwtkeys"
+	"github.com/suipic/backend/mailer"
 	"github.com/suipic/backend/middleware"
 	"github.com/suipic/backend/services"
 )
@@ -29,10 +33,40 @@ func main() {
 	}
 	defer dbService.Close()
 
+	accountMailer, err := mailer.New(&cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	captchaVerifier, err := captcha.New(&cfg.Captcha)
+	if err != nil {
+		log.Fatalf("Failed to initialize captcha: %v", err)
+	}
+
+	jwtKeys, err := jwtkeys.Load(&cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	if !jwtKeys.Asymmetric() {
+		log.Printf("JWT_SIGNING_KEY is not set, signing tokens with JWT_SECRET; other services cannot verify them")
+	}
+	if cfg.UsesPlaceholderSecret() {
+		log.Printf("Warning: JWT_SECRET is set to a placeholder, do not use this configuration in production")
+	}
+
+	if cfg.MFA.EncryptionKey == "" {
+		log.Printf("Warning: MFA_ENCRYPTION_KEY is not set, two-factor authentication cannot be enrolled")
+	}
+
 	authService, err := services.NewAuthService(
 		dbService,
-		cfg.JWT.Secret,
+		accountMailer,
+		cfg.Server.PublicURL,
+		jwtKeys,
 		cfg.JWT.Expiry,
+		cfg.JWT.RefreshExpiry,
+		cfg.MFA.Issuer,
+		cfg.MFA.EncryptionKey,
 		cfg.Admin.Email,
 		cfg.Admin.Password,
 		cfg.Admin.Username,
@@ -41,6 +75,11 @@ func main() {
 		log.Fatalf("Failed to initialize auth service: %v", err)
 	}
 
+	oidcService, err := services.NewOIDCService(&cfg.OIDC, cfg.Server.PublicURL, authService, dbService)
+	if err != nil {
+		log.Fatalf("Failed to initialize oidc service: %v", err)
+	}
+
 	storageService, err := services.NewStorageService(&cfg.MinIO)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage service: %v", err)
@@ -52,13 +91,39 @@ func main() {
 		esService = nil
 	}
 
+	placeGeocoder, err := geocoder.New(&cfg.Geocoder)
+	if err != nil {
+		log.Printf("Warning: Failed to load reverse geocoder, photos will not be geocoded: %v", err)
+		placeGeocoder = nil
+	} else {
+		log.Printf("Reverse geocoder loaded %d places", placeGeocoder.Len())
+	}
+
 	albumService := services.NewAlbumService(dbService.GetDB())
+	inviteService := services.NewInviteService(authService, albumService, dbService)
 	commentService := services.NewCommentService(dbService.GetCommentRepo(), dbService.GetUserRepo())
-	photoService := services.NewPhotoService(dbService.GetPhotoRepo(), storageService, esService, albumService, dbService.GetCommentRepo())
 	systemSettingsService := services.NewSystemSettingsService(dbService.GetSystemSettingsRepo())
+	photoService := services.NewPhotoService(dbService.GetPhotoRepo(), storageService, esService, albumService, dbService.GetCommentRepo(), systemSettingsService, placeGeocoder)
+	shareService := services.NewShareService(authService, albumService, photoService, dbService)
+	orgService := services.NewOrganizationService(albumService, dbService)
+	auditService := services.NewAuditService(dbService)
+	imageSigningKey := []byte(cfg.Image.SigningSecret)
+	if len(imageSigningKey) == 0 {
+		imageSigningKey, err = jwtKeys.DeriveKey(services.ImageSigningKeyLabel)
+		if err != nil {
+			log.Fatalf("Failed to derive image signing key: %v", err)
+		}
+	}
+	imageService := services.NewImageService(storageService, imageSigningKey)
+	xmpService := services.NewXMPService(photoService)
+	exportService := services.NewExportService(albumService, photoService, storageService, dbService.GetPhotoRepo(), dbService.GetCommentRepo(), dbService.GetUserRepo())
 
 	app := fiber.New(fiber.Config{
-		AppName: "Suipic API",
+		AppName:                 "Suipic API",
+		ProxyHeader:             cfg.Server.ProxyHeader,
+		EnableTrustedProxyCheck: len(cfg.Server.TrustedProxies) > 0,
+		TrustedProxies:          cfg.Server.TrustedProxies,
+		EnableIPValidation:      true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -76,11 +141,11 @@ func main() {
 	}))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: joinStrings(cfg.CORS.Origins, ","),
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Share-Token",
 		AllowMethods: "GET, POST, PUT, DELETE, PATCH, OPTIONS",
 	}))
 
-	setupRoutes(app, authService, storageService, dbService, albumService, photoService, commentService, esService, systemSettingsService)
+	setupRoutes(app, authService, oidcService, storageService, dbService, albumService, photoService, commentService, esService, systemSettingsService, exportService, imageService, xmpService, inviteService, shareService, orgService, auditService, captchaVerifier)
 
 	go func() {
 		addr := fmt.Sprintf(":%s", cfg.Server.Port)
@@ -101,14 +166,24 @@ func main() {
 	log.Println("Server exited")
 }
 
-func setupRoutes(app *fiber.App, authService *services.AuthService, storageService *services.StorageService, dbService *services.DatabaseService, albumService *services.AlbumService, photoService *services.PhotoService, commentService *services.CommentService, esService *services.ElasticsearchService, systemSettingsService *services.SystemSettingsService) {
-	authHandler := handlers.NewAuthHandler(authService)
-	photoHandler := handlers.NewPhotoHandler(storageService, photoService, albumService, commentService, esService)
-	albumHandler := handlers.NewAlbumHandler(albumService)
-	adminHandler := handlers.NewAdminHandler(authService, dbService, systemSettingsService)
+func setupRoutes(app *fiber.App, authService *services.AuthService, oidcService *services.OIDCService, storageService *services.StorageService, dbService *services.DatabaseService, albumService *services.AlbumService, photoService *services.PhotoService, commentService *services.CommentService, esService *services.ElasticsearchService, systemSettingsService *services.SystemSettingsService, exportService *services.ExportService, imageService *services.ImageService, xmpService *services.XMPService, inviteService *services.InviteService, shareService *services.ShareService, orgService *services.OrganizationService, auditService *services.AuditService, captchaVerifier captcha.Verifier) {
+	authHandler := handlers.NewAuthHandler(authService, storageService, auditService, captchaVerifier)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
+	photoHandler := handlers.NewPhotoHandler(storageService, photoService, albumService, commentService, esService, shareService, auditService)
+	albumHandler := handlers.NewAlbumHandler(albumService, photoService, authService, auditService)
+	adminHandler := handlers.NewAdminHandler(authService, dbService, systemSettingsService, auditService)
 	photographerHandler := handlers.NewPhotographerHandler(authService)
+	inviteHandler := handlers.NewInviteHandler(inviteService, authService)
+	orgHandler := handlers.NewOrganizationHandler(orgService, authService, auditService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	shareHandler := handlers.NewShareHandler(shareService, albumService, photoService, storageService, auditService)
 	searchHandler := handlers.NewSearchHandler(esService, photoService, albumService)
 	settingsHandler := handlers.NewSettingsHandler(systemSettingsService)
+	exportHandler := handlers.NewExportHandler(exportService, auditService)
+	imageHandler := handlers.NewImageHandler(imageService, photoService, albumService)
+	xmpHandler := handlers.NewXMPHandler(xmpService, albumService, auditService)
+
+	app.Get("/.well-known/jwks.json", authHandler.JWKS)
 
 	api := app.Group("/api")
 
@@ -118,18 +193,74 @@ func setupRoutes(app *fiber.App, authService *services.AuthService, storageServi
 	settings.Get("/public", settingsHandler.GetPublicSettings)
 
 	auth := api.Group("/auth")
+	auth.Get("/registration", authHandler.RegistrationInfo)
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
 	auth.Post("/refresh", authHandler.Refresh)
-	auth.Post("/logout", middleware.AuthRequired(authService), authHandler.Logout)
+	auth.Post("/logout", middleware.SessionRequired(authService), authHandler.Logout)
+	auth.Post("/mfa/verify", authHandler.VerifyMFA)
+	auth.Post("/mfa/enroll", authHandler.BeginMFAEnrollment)
+	auth.Post("/mfa/enroll/confirm", authHandler.CompleteMFAEnrollment)
+	auth.Get("/oidc/providers", oidcHandler.ListProviders)
+	auth.Post("/oidc/:provider/start", oidcHandler.Start)
+	auth.Post("/oidc/:provider/callback", oidcHandler.Callback)
+	auth.Post("/verify-email-change", authHandler.ConfirmEmailChange)
+	auth.Post("/verify-email", authHandler.VerifyEmail)
+	auth.Post("/resend-verification", middleware.SessionRequired(authService), authHandler.ResendVerification)
+	auth.Post("/forgot-password", authHandler.ForgotPassword)
+	auth.Post("/reset-password", authHandler.ResetPassword)
 	auth.Get("/me", middleware.AuthRequired(authService), authHandler.Me)
+	auth.Patch("/me", middleware.SessionRequired(authService), authHandler.UpdateMe)
+	auth.Post("/me/password", middleware.SessionRequired(authService), authHandler.ChangePassword)
+	auth.Post("/me/email", middleware.SessionRequired(authService), authHandler.ChangeEmail)
+	auth.Put("/me/avatar", middleware.SessionRequired(authService), authHandler.UploadAvatar)
+	auth.Delete("/me/avatar", middleware.SessionRequired(authService), authHandler.DeleteAvatar)
+	auth.Get("/me/mfa", middleware.SessionRequired(authService), authHandler.GetMFAStatus)
+	auth.Post("/me/mfa/totp", middleware.SessionRequired(authService), authHandler.SetupTOTP)
+	auth.Post("/me/mfa/totp/enable", middleware.SessionRequired(authService), authHandler.EnableTOTP)
+	auth.Post("/me/mfa/totp/disable", middleware.SessionRequired(authService), authHandler.DisableTOTP)
+	auth.Post("/me/mfa/recovery-codes", middleware.SessionRequired(authService), authHandler.RegenerateRecoveryCodes)
+	auth.Get("/me/identities", middleware.SessionRequired(authService), oidcHandler.ListIdentities)
+	auth.Delete("/me/identities/:id", middleware.SessionRequired(authService), oidcHandler.UnlinkIdentity)
+	auth.Get("/sessions", middleware.SessionRequired(authService), authHandler.ListSessions)
+	auth.Delete("/sessions", middleware.SessionRequired(authService), authHandler.RevokeOtherSessions)
+	auth.Delete("/sessions/:id", middleware.SessionRequired(authService), authHandler.RevokeSession)
+	auth.Get("/tokens", middleware.SessionRequired(authService), authHandler.ListAPITokens)
+	auth.Post("/tokens", middleware.SessionRequired(authService), authHandler.CreateAPIToken)
+	auth.Delete("/tokens/:id", middleware.SessionRequired(authService), authHandler.RevokeAPIToken)
+
+	users := api.Group("/users")
+	users.Get("/:id/avatar", middleware.AuthRequired(authService), authHandler.GetUserAvatar)
 
 	admin := api.Group("/admin")
 	admin.Post("/photographers", middleware.AdminOnly(authService), adminHandler.CreatePhotographer)
 	admin.Get("/photographers", middleware.AdminOnly(authService), adminHandler.ListPhotographers)
 	admin.Get("/settings", middleware.AdminOnly(authService), adminHandler.GetSettings)
 	admin.Get("/stats", middleware.AdminOnly(authService), adminHandler.GetStats)
+	admin.Get("/users", middleware.AdminOnly(authService), adminHandler.ListUsers)
+	admin.Post("/users", middleware.AdminOnly(authService), adminHandler.CreateUser)
+	admin.Get("/users/:id", middleware.AdminOnly(authService), adminHandler.GetUser)
+	admin.Patch("/users/:id", middleware.AdminOnly(authService), adminHandler.UpdateUser)
+	admin.Delete("/users/:id", middleware.AdminOnly(authService), adminHandler.DeleteUser)
+	admin.Post("/users/:id/suspend", middleware.AdminOnly(authService), adminHandler.SuspendUser)
+	admin.Post("/users/:id/unsuspend", middleware.AdminOnly(authService), adminHandler.UnsuspendUser)
+	admin.Put("/users/:id/password", middleware.AdminOnly(authService), adminHandler.SetUserPassword)
+	admin.Post("/users/:id/force-password-reset", middleware.AdminOnly(authService), adminHandler.ForcePasswordReset)
+	admin.Get("/users/:id/sessions", middleware.AdminOnly(authService), adminHandler.ListUserSessions)
+	admin.Delete("/users/:id/sessions", middleware.AdminOnly(authService), adminHandler.RevokeUserSessions)
+	admin.Delete("/users/:id/mfa", middleware.AdminOnly(authService), adminHandler.ResetUserMFA)
+	admin.Delete("/users/:id/lockout", middleware.AdminOnly(authService), adminHandler.UnlockUser)
+	admin.Get("/lockouts", middleware.AdminOnly(authService), adminHandler.ListLockouts)
+	admin.Delete("/lockouts/ip/:ip", middleware.AdminOnly(authService), adminHandler.UnlockIP)
+	admin.Get("/login-attempts", middleware.AdminOnly(authService), adminHandler.ListLoginAttempts)
+	admin.Get("/audit-log", middleware.AdminOnly(authService), auditHandler.ListAuditLog)
+	admin.Get("/audit-log/export", middleware.AdminOnly(authService), auditHandler.ExportAuditLog)
 	admin.Put("/settings/:key", middleware.AdminOnly(authService), adminHandler.UpdateSetting)
+	admin.Get("/export/albums/:id", middleware.AdminOnly(authService), exportHandler.ExportAlbum)
+	admin.Get("/export/photographers/:id", middleware.AdminOnly(authService), exportHandler.ExportPhotographer)
+	admin.Post("/import", middleware.AdminOnly(authService), exportHandler.Import)
+	admin.Get("/organizations", middleware.AdminOnly(authService), orgHandler.ListOrganizations)
+	admin.Put("/organizations/:id/quotas", middleware.AdminOnly(authService), orgHandler.SetQuotas)
 
 	albums := api.Group("/albums")
 	albums.Post("/", middleware.AuthRequired(authService), albumHandler.CreateAlbum)
@@ -139,17 +270,31 @@ func setupRoutes(app *fiber.App, authService *services.AuthService, storageServi
 	albums.Delete("/:id", middleware.AuthRequired(authService), albumHandler.DeleteAlbum)
 	albums.Post("/:id/users", middleware.AuthRequired(authService), albumHandler.AssignUsers)
 	albums.Get("/:id/users", middleware.AuthRequired(authService), albumHandler.GetAlbumUsers)
+	albums.Post("/:id/collaborators", middleware.AuthRequired(authService), albumHandler.AddCollaborator)
+	albums.Get("/:id/collaborators", middleware.AuthRequired(authService), albumHandler.GetCollaborators)
+	albums.Delete("/:id/collaborators/:userId", middleware.AuthRequired(authService), albumHandler.RemoveCollaborator)
+	albums.Get("/:id/cameras", middleware.AuthRequired(authService), albumHandler.ListCameraBodies)
+	albums.Post("/:id/time-shift", middleware.AuthRequired(authService), albumHandler.ShiftCaptureTime)
+	albums.Get("/:id/xmp", middleware.AuthRequired(authService), xmpHandler.ExportSidecars)
+	albums.Post("/:id/xmp", middleware.AuthRequired(authService), xmpHandler.ImportSidecars)
 	albums.Post("/:albumId/photos", middleware.AuthRequired(authService), photoHandler.CreatePhoto)
-	albums.Get("/:albumId/photos", middleware.AuthRequired(authService), photoHandler.GetPhotosByAlbum)
+	albums.Get("/:albumId/photos", middleware.AuthOrShare(authService, shareService), photoHandler.GetPhotosByAlbum)
+	albums.Get("/:albumId/map", middleware.AuthRequired(authService), photoHandler.GetAlbumMap)
+	albums.Post("/:id/share-links", middleware.AuthRequired(authService), shareHandler.CreateShareLink)
+	albums.Get("/:id/share-links", middleware.AuthRequired(authService), shareHandler.ListShareLinks)
+	albums.Delete("/:id/share-links/:linkId", middleware.AuthRequired(authService), shareHandler.RevokeShareLink)
+	albums.Get("/:id/share-links/:linkId/accesses", middleware.AuthRequired(authService), shareHandler.ListShareLinkAccesses)
 
 	photos := api.Group("/photos")
 	photos.Post("/", middleware.PhotographerOnly(authService), photoHandler.UploadPhoto)
-	photos.Get("/:id", middleware.AuthRequired(authService), photoHandler.GetPhoto)
+	photos.Get("/:id", middleware.AuthOrShare(authService, shareService), photoHandler.GetPhoto)
 	photos.Put("/:id", middleware.AuthRequired(authService), photoHandler.UpdatePhoto)
 	photos.Delete("/:id", middleware.AuthRequired(authService), photoHandler.DeletePhoto)
-	photos.Get("/:id/download", photoHandler.DownloadPhoto)
-	photos.Get("/:id/presigned", photoHandler.GetPresignedURL)
-	photos.Put("/:id/state", middleware.AuthRequired(authService), photoHandler.SetPhotoState)
+	photos.Get("/:id/download", middleware.AuthRequired(authService), photoHandler.DownloadPhoto)
+	photos.Get("/:id/presigned", middleware.AuthRequired(authService), photoHandler.GetPresignedURL)
+	photos.Get("/:id/image", middleware.OptionalAuthOrShare(authService, shareService), imageHandler.GetImage)
+	photos.Get("/:id/image/sign", middleware.AuthRequired(authService), imageHandler.SignImageURL)
+	photos.Put("/:id/state", middleware.AuthOrShare(authService, shareService), photoHandler.SetPhotoState)
 	photos.Put("/:id/stars", middleware.AuthRequired(authService), photoHandler.SetPhotoStars)
 	photos.Post("/:id/comments", middleware.AuthRequired(authService), photoHandler.CreateComment)
 	photos.Get("/:id/comments", middleware.AuthRequired(authService), photoHandler.GetComments)
@@ -162,8 +307,39 @@ func setupRoutes(app *fiber.App, authService *services.AuthService, storageServi
 	photographer.Post("/clients", middleware.PhotographerOnly(authService), photographerHandler.CreateOrLinkClient)
 	photographer.Get("/clients", middleware.PhotographerOnly(authService), photographerHandler.ListClients)
 	photographer.Get("/clients/search", middleware.PhotographerOnly(authService), photographerHandler.SearchClients)
+	photographer.Post("/invites", middleware.PhotographerOnly(authService), inviteHandler.CreateInvite)
+	photographer.Get("/invites", middleware.PhotographerOnly(authService), inviteHandler.ListInvites)
+	photographer.Post("/invites/:id/resend", middleware.PhotographerOnly(authService), inviteHandler.ResendInvite)
+	photographer.Delete("/invites/:id", middleware.PhotographerOnly(authService), inviteHandler.RevokeInvite)
+
+	organization := api.Group("/organization")
+	organization.Post("/", middleware.PhotographerOnly(authService), orgHandler.CreateOrganization)
+	organization.Get("/", middleware.PhotographerOnly(authService), orgHandler.GetOrganization)
+	organization.Put("/", middleware.PhotographerOnly(authService), orgHandler.UpdateOrganization)
+	organization.Post("/invites", middleware.PhotographerOnly(authService), orgHandler.InviteMember)
+	organization.Get("/invites", middleware.PhotographerOnly(authService), orgHandler.ListInvites)
+	organization.Delete("/invites/:id", middleware.PhotographerOnly(authService), orgHandler.RevokeInvite)
+	organization.Get("/invites/received", middleware.PhotographerOnly(authService), orgHandler.ListReceivedInvites)
+	organization.Post("/invites/:id/accept", middleware.PhotographerOnly(authService), orgHandler.AcceptInvite)
+	organization.Post("/invites/:id/decline", middleware.PhotographerOnly(authService), orgHandler.DeclineInvite)
+	organization.Post("/albums/move", middleware.PhotographerOnly(authService), orgHandler.MoveAlbums)
+	organization.Put("/members/:userId", middleware.PhotographerOnly(authService), orgHandler.UpdateMember)
+	organization.Delete("/members/:userId", middleware.PhotographerOnly(authService), orgHandler.RemoveMember)
+	organization.Get("/clients", middleware.PhotographerOnly(authService), orgHandler.ListClients)
+	organization.Get("/settings", middleware.PhotographerOnly(authService), orgHandler.GetSettings)
+	organization.Put("/settings", middleware.PhotographerOnly(authService), orgHandler.UpdateSettings)
+
+	invites := api.Group("/invites")
+	invites.Post("/preview", inviteHandler.PreviewInvite)
+	invites.Post("/accept", inviteHandler.AcceptInvite)
+	invites.Post("/link", middleware.SessionRequired(authService), inviteHandler.LinkInvite)
+
+	share := api.Group("/share")
+	share.Post("/open", shareHandler.OpenShareLink)
+	share.Get("/photos/:id/download", middleware.ShareGuest(shareService), shareHandler.DownloadPhoto)
 
 	api.Get("/search", middleware.AuthRequired(authService), searchHandler.Search)
+	api.Get("/search/map", middleware.AuthRequired(authService), searchHandler.SearchMap)
 	api.Post("/albums/:albumId/index", middleware.AuthRequired(authService), searchHandler.BulkIndexAlbum)
 }
 
@@ -0,0 +1,282 @@
+package metadata
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+const (
+	maxExifValues = 16
+	maxTIFFDirs   = 64
+
+	exifIFDPointerTag    = 0x8769
+	interopIFDPointerTag = 0xA005
+)
+
+// goexif only knows the EXIF 2.2 tag set; these are the 2.3+ Exif sub-IFD
+// tags we care about (time zone offsets, lens and body identification).
+var exif23Fields = map[uint16]exif.FieldName{
+	0x8830: "SensitivityType",
+	0x8832: "RecommendedExposureIndex",
+	0x9010: "OffsetTime",
+	0x9011: "OffsetTimeOriginal",
+	0x9012: "OffsetTimeDigitized",
+	0xA430: "CameraOwnerName",
+	0xA431: "BodySerialNumber",
+	0xA432: "LensSpecification",
+	0xA435: "LensSerialNumber",
+}
+
+// Binary blobs and IFD bookkeeping that mean nothing outside the file.
+var skippedExifFields = map[exif.FieldName]bool{
+	exif.MakerNote:                        true,
+	exif.ExifIFDPointer:                   true,
+	exif.GPSInfoIFDPointer:                true,
+	exif.InteroperabilityIFDPointer:       true,
+	exif.ThumbJPEGInterchangeFormat:       true,
+	exif.ThumbJPEGInterchangeFormatLength: true,
+	exif.ComponentsConfiguration:          true,
+	exif.FileSource:                       true,
+	exif.SceneType:                        true,
+	exif.CFAPattern:                       true,
+	exif.DeviceSettingDescription:         true,
+	exif.SpatialFrequencyResponse:         true,
+	exif.OECF:                             true,
+}
+
+// Keys the rest of the application has always used for these fields.
+var renamedExifFields = map[exif.FieldName]string{
+	exif.ISOSpeedRatings: "ISO",
+	exif.ImageLength:     "ImageHeight",
+}
+
+type exif23Parser struct{}
+
+func (exif23Parser) Parse(x *exif.Exif) error {
+	pointer, err := x.Get(exif.ExifIFDPointer)
+	if err != nil {
+		return nil
+	}
+	offset, err := pointer.Int64(0)
+	if err != nil {
+		return nil
+	}
+
+	r := bytes.NewReader(x.Raw)
+	if _, err := r.Seek(offset, 0); err != nil {
+		return nil
+	}
+	dir, _, err := tiff.DecodeDir(r, x.Tiff.Order)
+	if err != nil {
+		return nil
+	}
+	x.LoadTags(dir, exif23Fields, false)
+	return nil
+}
+
+func init() {
+	exif.RegisterParsers(exif23Parser{})
+}
+
+type exifWalker map[string]interface{}
+
+func (w exifWalker) Walk(name exif.FieldName, tag *tiff.Tag) error {
+	if skippedExifFields[name] || strings.HasPrefix(string(name), exif.UnknownPrefix) {
+		return nil
+	}
+
+	value, ok := exifValue(name, tag)
+	if !ok {
+		return nil
+	}
+
+	key := string(name)
+	if renamed, ok := renamedExifFields[name]; ok {
+		key = renamed
+	}
+	w[key] = value
+	return nil
+}
+
+// parseExif decodes every tag goexif can reach in IFD0, IFD1 and the Exif, GPS
+// and Interoperability sub-IFDs into plain JSON-friendly values.
+func parseExif(data []byte) (map[string]interface{}, *exif.Exif) {
+	if block := findExif(data); block != nil {
+		data = block[len(exifSignature):]
+	}
+	if !checkTIFF(data) {
+		return nil, nil
+	}
+
+	x, err := exif.Decode(bytes.NewReader(data))
+	if x == nil {
+		return nil, nil
+	}
+	if err != nil && exif.IsCriticalError(err) {
+		return nil, nil
+	}
+
+	fields := exifWalker{}
+	x.Walk(fields)
+
+	if _, ok := fields["ImageWidth"]; !ok {
+		if width, ok := fields[string(exif.PixelXDimension)]; ok {
+			fields["ImageWidth"] = width
+		}
+	}
+	if _, ok := fields["ImageHeight"]; !ok {
+		if height, ok := fields[string(exif.PixelYDimension)]; ok {
+			fields["ImageHeight"] = height
+		}
+	}
+
+	return fields, x
+}
+
+// checkTIFF walks the IFDs goexif would decode and reports whether it can be
+// trusted with them. goexif multiplies tag counts in 32 bits, so a large count
+// wraps around and it allocates gigabytes for a tag that is a few bytes long,
+// and it follows IFD chains that loop back on themselves forever.
+func checkTIFF(tiff []byte) bool {
+	order := tiffByteOrder(tiff)
+	if order == nil {
+		return false
+	}
+
+	visited := make(map[uint32]bool)
+	var checkDir func(offset uint32) (next uint32, ok bool)
+	checkDir = func(offset uint32) (uint32, bool) {
+		visited[offset] = true
+		if uint64(offset)+2 > uint64(len(tiff)) {
+			return 0, true
+		}
+		count := int(order.Uint16(tiff[offset : offset+2]))
+		for i := 0; i < count; i++ {
+			entry := int(offset) + 2 + i*12
+			if entry+12 > len(tiff) {
+				return 0, true
+			}
+			size := uint64(tiffTypeSizes[order.Uint16(tiff[entry+2:entry+4])]) * uint64(order.Uint32(tiff[entry+4:entry+8]))
+			if size > uint64(len(tiff)) {
+				return 0, false
+			}
+
+			switch order.Uint16(tiff[entry : entry+2]) {
+			case exifIFDPointerTag, gpsIFDPointerTag, interopIFDPointerTag:
+				if sub := order.Uint32(tiff[entry+8 : entry+12]); !visited[sub] {
+					if _, ok := checkDir(sub); !ok {
+						return 0, false
+					}
+				}
+			}
+		}
+
+		end := int(offset) + 2 + count*12
+		if end+4 > len(tiff) {
+			return 0, true
+		}
+		return order.Uint32(tiff[end : end+4]), true
+	}
+
+	for offset, dirs := order.Uint32(tiff[4:8]), 0; offset != 0; dirs++ {
+		if visited[offset] || dirs == maxTIFFDirs {
+			return false
+		}
+		next, ok := checkDir(offset)
+		if !ok {
+			return false
+		}
+		offset = next
+	}
+	return true
+}
+
+func exifValue(name exif.FieldName, tag *tiff.Tag) (interface{}, bool) {
+	count := int(tag.Count)
+
+	switch tag.Format() {
+	case tiff.StringVal:
+		value, err := tag.StringVal()
+		if err != nil {
+			return nil, false
+		}
+		value = strings.TrimSpace(strings.TrimRight(value, "\x00"))
+		return value, value != ""
+	case tiff.IntVal:
+		if count == 1 {
+			value, err := tag.Int(0)
+			return value, err == nil
+		}
+		if count == 0 || count > maxExifValues {
+			return nil, false
+		}
+		values := make([]int, 0, count)
+		for i := 0; i < count; i++ {
+			value, err := tag.Int(i)
+			if err != nil {
+				return nil, false
+			}
+			values = append(values, value)
+		}
+		return values, true
+	case tiff.RatVal, tiff.FloatVal:
+		if count == 0 || count > maxExifValues {
+			return nil, false
+		}
+		values := make([]float64, 0, count)
+		for i := 0; i < count; i++ {
+			value, ok := exifFloat(tag, i)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, value)
+		}
+		if count == 1 {
+			return values[0], true
+		}
+		return values, true
+	case tiff.UndefVal:
+		return exifText(name, tag.Val)
+	}
+
+	return nil, false
+}
+
+func exifFloat(tag *tiff.Tag, i int) (float64, bool) {
+	if tag.Format() == tiff.FloatVal {
+		value, err := tag.Float(i)
+		return value, err == nil
+	}
+
+	// Rat panics on a zero denominator, which broken writers do emit.
+	num, den, err := tag.Rat2(i)
+	if err != nil || den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// exifText keeps UNDEFINED values that are really text, such as ExifVersion
+// or an ASCII UserComment, and drops binary ones.
+func exifText(name exif.FieldName, raw []byte) (interface{}, bool) {
+	if name == exif.UserComment {
+		if len(raw) < 8 || !bytes.HasPrefix(raw, []byte("ASCII")) {
+			return nil, false
+		}
+		raw = raw[8:]
+	}
+
+	value := strings.TrimSpace(strings.TrimRight(string(raw), "\x00"))
+	if value == "" {
+		return nil, false
+	}
+	for _, r := range value {
+		if r < 0x20 || r > 0x7E {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,223 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"regexp"
+	"strings"
+)
+
+const gpsIFDPointerTag = 0x8825
+
+// xmpLocationProperty matches GPS properties and the place name properties
+// of the photoshop and IPTC schemas.
+const xmpLocationProperty = `(?:[A-Za-z0-9_-]+:GPS[A-Za-z]*|photoshop:(?:City|State|Country)|Iptc4xmpCore:(?:Location|CountryCode)|Iptc4xmpExt:Location(?:Created|Shown))`
+
+var (
+	xmpGPSAttribute = regexp.MustCompile(`\s` + xmpLocationProperty + `="[^"]*"`)
+	xmpGPSElement   = regexp.MustCompile(`(?s)<` + xmpLocationProperty + `\b[^>]*?(/>|>.*?</` + xmpLocationProperty + `>)`)
+)
+
+// iptcLocationDatasets are the IPTC-IIM datasets naming where a photo was
+// taken.
+var iptcLocationDatasets = map[string]bool{
+	"City":          true,
+	"Sublocation":   true,
+	"ProvinceState": true,
+	"CountryCode":   true,
+	"CountryName":   true,
+}
+
+// xmpLocationPrefixes are the keys of the XMP dump that hold a location,
+// either as coordinates or as place names.
+var xmpLocationPrefixes = []string{
+	"exif:GPS",
+	"photoshop:City",
+	"photoshop:State",
+	"photoshop:Country",
+	"Iptc4xmpCore:Location",
+	"Iptc4xmpCore:CountryCode",
+	"Iptc4xmpExt:LocationCreated",
+	"Iptc4xmpExt:LocationShown",
+}
+
+// tiffTypeSizes maps TIFF field types to their size in bytes.
+var tiffTypeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+func isGPSKey(key string) bool {
+	return key == "Latitude" || key == "Longitude" || strings.HasPrefix(key, "GPS")
+}
+
+func isXMPLocationKey(key string) bool {
+	for _, prefix := range xmpLocationPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveGPS deletes coordinates and every other GPS field from an ExifData
+// map, along with the place names in the nested IPTC and XMP dumps, which
+// give the location away just as well. Nested maps are replaced rather than
+// edited, so a shallow copy of the map can be passed safely. It reports
+// whether anything was removed.
+func RemoveGPS(data map[string]interface{}) bool {
+	removed := false
+	for key := range data {
+		if isGPSKey(key) {
+			delete(data, key)
+			removed = true
+		}
+	}
+
+	nested := map[string]func(string) bool{
+		"IPTC": func(key string) bool { return iptcLocationDatasets[key] },
+		"XMP":  isXMPLocationKey,
+	}
+	for name, drop := range nested {
+		fields, ok := data[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		filtered := make(map[string]interface{}, len(fields))
+		for key, value := range fields {
+			if drop(key) {
+				removed = true
+				continue
+			}
+			filtered[key] = value
+		}
+		data[name] = filtered
+	}
+
+	return removed
+}
+
+// StripGPS returns a copy of an image file with its location removed. The
+// EXIF GPS IFD is unlinked and zeroed, and IPTC place names and the GPS and
+// place properties of an embedded XMP packet are blanked; all of it is done
+// in place so no offsets or segment lengths change. It returns the input
+// unchanged when there is nothing to strip.
+//
+// Only JPEG segments and plain XMP packets are understood. Uploaded images
+// are re-encoded as WebP without any metadata before they are stored, so on
+// stored photos there is nothing left for it to do.
+func StripGPS(data []byte) []byte {
+	out := bytes.Clone(data)
+	changed := false
+
+	if block := findExif(out); block != nil {
+		if stripExifGPS(block[len(exifSignature):]) {
+			changed = true
+		}
+	}
+
+	if block := findIPTC(out); block != nil {
+		if blankIPTCLocation(block) {
+			changed = true
+		}
+	}
+
+	if packet := findXMPPacket(out); packet != nil {
+		if blankXMPGPS(packet) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data
+	}
+	return out
+}
+
+func stripExifGPS(tiff []byte) bool {
+	order := tiffByteOrder(tiff)
+	if order == nil {
+		return false
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return false
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	end := ifd + 2 + count*12
+	if end+4 > len(tiff) {
+		return false
+	}
+
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if order.Uint16(tiff[entry:entry+2]) != gpsIFDPointerTag {
+			continue
+		}
+
+		zeroGPSIFD(tiff, order, int(order.Uint32(tiff[entry+8:entry+12])))
+
+		// Drop the pointer entry: shift the remaining entries and the
+		// next-IFD offset up by one slot and clear the freed bytes.
+		copy(tiff[entry:], tiff[entry+12:end+4])
+		for j := end - 8; j < end+4; j++ {
+			tiff[j] = 0
+		}
+		order.PutUint16(tiff[ifd:ifd+2], uint16(count-1))
+		return true
+	}
+
+	return false
+}
+
+// tiffByteOrder reads the byte order from a TIFF header, or returns nil if
+// there is no header.
+func tiffByteOrder(tiff []byte) binary.ByteOrder {
+	if len(tiff) < 8 {
+		return nil
+	}
+	switch string(tiff[:2]) {
+	case "II":
+		return binary.LittleEndian
+	case "MM":
+		return binary.BigEndian
+	}
+	return nil
+}
+
+func zeroGPSIFD(tiff []byte, order binary.ByteOrder, offset int) {
+	if offset <= 0 || offset+2 > len(tiff) {
+		return
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	end := offset + 2 + count*12 + 4
+	if end > len(tiff) {
+		return
+	}
+
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		size := tiffTypeSizes[order.Uint16(tiff[entry+2:entry+4])] * int(order.Uint32(tiff[entry+4:entry+8]))
+		if size <= 4 {
+			continue
+		}
+		valueOffset := int(order.Uint32(tiff[entry+8 : entry+12]))
+		if valueOffset > 0 && valueOffset+size <= len(tiff) {
+			clear(tiff[valueOffset : valueOffset+size])
+		}
+	}
+	clear(tiff[offset:end])
+}
+
+func blankXMPGPS(packet []byte) bool {
+	changed := false
+	blank := func(match []byte) []byte {
+		changed = true
+		return bytes.Repeat([]byte(" "), len(match))
+	}
+
+	result := xmpGPSElement.ReplaceAllFunc(packet, blank)
+	result = xmpGPSAttribute.ReplaceAllFunc(result, blank)
+	copy(packet, result)
+	return changed
+}
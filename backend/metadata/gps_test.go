@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStripGPS(t *testing.T) {
+	xmp := xmpPacket(`<exif:GPSLatitude>51,30.0N</exif:GPSLatitude><dc:format>image/jpeg</dc:format>`)
+	xmpAttribute := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" exif:GPSLongitude="4,30.0E"/></rdf:RDF></x:xmpmeta>`
+	xmpPlace := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/" photoshop:Country="Netherlands">` +
+		`<photoshop:City>Rotterdam</photoshop:City></rdf:Description></rdf:RDF></x:xmpmeta>`
+	iptc := bytes.Join([][]byte{
+		iptcDataset(iptcApplicationRecord, 5, "Harbour"),
+		iptcDataset(iptcApplicationRecord, 90, "Rotterdam"),
+		iptcDataset(iptcApplicationRecord, 92, "Kop van Zuid"),
+		iptcDataset(iptcApplicationRecord, 101, "Netherlands"),
+	}, nil)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		changed bool
+	}{
+		{"empty", nil, false},
+		{"no metadata", jpegFile(), false},
+		{"exif gps", jpegFile(exifSegment(gpsTIFF())), true},
+		{"xmp gps element", jpegFile(xmpSegment(xmp)), true},
+		{"xmp gps attribute", jpegFile(xmpSegment(xmpAttribute)), true},
+		{"xmp place names", jpegFile(xmpSegment(xmpPlace)), true},
+		{"iptc place names", jpegFile(iptcSegment(iptc)), true},
+		{"iptc without place", jpegFile(iptcSegment(iptcDataset(iptcApplicationRecord, 5, "Harbour"))), false},
+		{"truncated exif", jpegFile(exifSegment(gpsTIFF()[:24])), false},
+		{"gps ifd offset past end", jpegFile(exifSegment(append(gpsTIFF()[:26], 0x01))), true},
+		{"exif without gps", jpegFile(exifSegment([]byte("II\x2a\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"))), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			original := bytes.Clone(tc.data)
+			stripped := StripGPS(tc.data)
+
+			if !bytes.Equal(tc.data, original) {
+				t.Fatal("input was modified")
+			}
+			if len(stripped) != len(tc.data) {
+				t.Fatalf("length changed from %d to %d", len(tc.data), len(stripped))
+			}
+			if changed := !bytes.Equal(stripped, tc.data); changed != tc.changed {
+				t.Fatalf("changed = %v, want %v", changed, tc.changed)
+			}
+
+			m := Extract(stripped)
+			if m.Latitude != nil || m.Longitude != nil {
+				t.Errorf("location survived: %v, %v", *m.Latitude, *m.Longitude)
+			}
+			for key := range m.XMP {
+				if isXMPLocationKey(key) {
+					t.Errorf("XMP kept %q", key)
+				}
+			}
+			for key := range m.IPTC {
+				if iptcLocationDatasets[key] {
+					t.Errorf("IPTC kept %q", key)
+				}
+			}
+			if tc.name == "iptc place names" && m.Title != "Harbour" {
+				t.Errorf("Title = %q, want the IPTC title kept", m.Title)
+			}
+		})
+	}
+}
+
+func TestRemoveGPS(t *testing.T) {
+	data := map[string]interface{}{
+		"Make":        "Canon",
+		"Latitude":    51.5,
+		"Longitude":   4.5,
+		"GPSAltitude": 12.0,
+		"IPTC": map[string]interface{}{
+			"ObjectName":  "Harbour",
+			"City":        "Rotterdam",
+			"Sublocation": "Kop van Zuid",
+			"CountryName": "Netherlands",
+		},
+		"XMP": map[string]interface{}{
+			"exif:GPSLatitude":               "51,30.0N",
+			"photoshop:City":                 "Rotterdam",
+			"Iptc4xmpExt:LocationShown/City": "Rotterdam",
+			"dc:format":                      "image/jpeg",
+		},
+	}
+	nested := data["XMP"].(map[string]interface{})
+
+	if !RemoveGPS(data) {
+		t.Fatal("RemoveGPS reported nothing removed")
+	}
+	for _, key := range []string{"Latitude", "Longitude", "GPSAltitude"} {
+		if _, ok := data[key]; ok {
+			t.Errorf("%s was kept", key)
+		}
+	}
+	if data["Make"] != "Canon" {
+		t.Error("Make was removed")
+	}
+	iptc := data["IPTC"].(map[string]interface{})
+	if len(iptc) != 1 || iptc["ObjectName"] != "Harbour" {
+		t.Errorf("IPTC = %v, want only ObjectName", iptc)
+	}
+	xmp := data["XMP"].(map[string]interface{})
+	if len(xmp) != 1 || xmp["dc:format"] != "image/jpeg" {
+		t.Errorf("XMP = %v, want only dc:format", xmp)
+	}
+	if _, ok := nested["exif:GPSLatitude"]; !ok {
+		t.Error("the original nested XMP map was edited")
+	}
+
+	if RemoveGPS(map[string]interface{}{"Make": "Canon"}) {
+		t.Error("RemoveGPS reported a removal without GPS fields")
+	}
+}
@@ -0,0 +1,102 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// jpegFile wraps segments between SOI and a minimal scan so the parsers see
+// them the way they appear in a real file.
+func jpegFile(segments ...[]byte) []byte {
+	out := []byte{0xFF, 0xD8}
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, 0xFF, markerSOS, 0x00, 0x02, 0xFF, markerEOI)
+}
+
+func jpegSegmentBytes(marker byte, payload []byte) []byte {
+	out := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(out[2:], uint16(len(payload)+2))
+	return append(out, payload...)
+}
+
+func exifSegment(tiff []byte) []byte {
+	return jpegSegmentBytes(markerAPP1, append(bytes.Clone(exifSignature), tiff...))
+}
+
+func xmpSegment(packet string) []byte {
+	return jpegSegmentBytes(markerAPP1, append(bytes.Clone(xmpSignature), packet...))
+}
+
+func iptcSegment(iptc []byte) []byte {
+	return jpegSegmentBytes(markerAPP13, append(bytes.Clone(photoshopSignature), photoshopBlock(0x0404, iptc)...))
+}
+
+// photoshopBlock builds an 8BIM image resource with an empty name.
+func photoshopBlock(id uint16, data []byte) []byte {
+	out := []byte("8BIM")
+	out = binary.BigEndian.AppendUint16(out, id)
+	out = append(out, 0, 0)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 != 0 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func iptcDataset(record, dataset byte, value string) []byte {
+	out := []byte{iptcTagMarker, record, dataset}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(value)))
+	return append(out, value...)
+}
+
+func xmpPacket(description string) string {
+	return `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:exif="http://ns.adobe.com/exif/1.0/">` +
+		description +
+		`</rdf:Description></rdf:RDF></x:xmpmeta>`
+}
+
+// gpsTIFF builds a little-endian TIFF whose only IFD0 entry points at a GPS
+// IFD placing the image at 51.5 N, 4.5 E.
+func gpsTIFF() []byte {
+	const (
+		ifd0   = 8
+		gpsIFD = ifd0 + 2 + 12 + 4
+		latAt  = gpsIFD + 2 + 4*12 + 4
+		lonAt  = latAt + 24
+	)
+	order := binary.LittleEndian
+	out := make([]byte, lonAt+24)
+	copy(out, "II")
+	order.PutUint16(out[2:], 42)
+	order.PutUint32(out[4:], ifd0)
+
+	entry := func(at int, tag, kind uint16, count, value uint32) {
+		order.PutUint16(out[at:], tag)
+		order.PutUint16(out[at+2:], kind)
+		order.PutUint32(out[at+4:], count)
+		order.PutUint32(out[at+8:], value)
+	}
+	rationals := func(at int, values ...uint32) {
+		for i, value := range values {
+			order.PutUint32(out[at+i*8:], value)
+			order.PutUint32(out[at+i*8+4:], 1)
+		}
+	}
+
+	order.PutUint16(out[ifd0:], 1)
+	entry(ifd0+2, gpsIFDPointerTag, 4, 1, gpsIFD)
+
+	order.PutUint16(out[gpsIFD:], 4)
+	entry(gpsIFD+2, 1, 2, 2, uint32('N'))
+	entry(gpsIFD+14, 2, 5, 3, latAt)
+	entry(gpsIFD+26, 3, 2, 2, uint32('E'))
+	entry(gpsIFD+38, 4, 5, 3, lonAt)
+	rationals(latAt, 51, 30, 0)
+	rationals(lonAt, 4, 30, 0)
+
+	return out
+}
@@ -0,0 +1,150 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	iptcTagMarker         = 0x1C
+	iptcEnvelopeRecord    = 1
+	iptcApplicationRecord = 2
+	iptcCodedCharset      = 90
+)
+
+var iptcDatasets = map[byte]string{
+	5:   "ObjectName",
+	7:   "EditStatus",
+	10:  "Urgency",
+	15:  "Category",
+	20:  "SupplementalCategories",
+	25:  "Keywords",
+	40:  "SpecialInstructions",
+	55:  "DateCreated",
+	60:  "TimeCreated",
+	80:  "Byline",
+	85:  "BylineTitle",
+	90:  "City",
+	92:  "Sublocation",
+	95:  "ProvinceState",
+	100: "CountryCode",
+	101: "CountryName",
+	103: "OriginalTransmissionReference",
+	105: "Headline",
+	110: "Credit",
+	115: "Source",
+	116: "CopyrightNotice",
+	118: "Contact",
+	120: "Caption",
+	122: "WriterEditor",
+}
+
+var iptcRepeatable = map[string]bool{
+	"SupplementalCategories": true,
+	"Keywords":               true,
+	"Byline":                 true,
+	"BylineTitle":            true,
+	"Contact":                true,
+	"WriterEditor":           true,
+}
+
+// parseIPTC decodes the application record of an IPTC-IIM block into named
+// fields. Repeatable datasets become string slices.
+func parseIPTC(data []byte) map[string]interface{} {
+	fields := make(map[string]interface{})
+	utf8Charset := false
+
+	walkIPTC(data, func(record, dataset byte, value []byte) {
+		if record == iptcEnvelopeRecord && dataset == iptcCodedCharset {
+			// ESC % G declares UTF-8.
+			utf8Charset = string(value) == "\x1b%G"
+			return
+		}
+		if record != iptcApplicationRecord {
+			return
+		}
+
+		name, ok := iptcDatasets[dataset]
+		if !ok {
+			return
+		}
+		text := strings.TrimSpace(decodeIPTCString(value, utf8Charset))
+		if text == "" {
+			return
+		}
+
+		if iptcRepeatable[name] {
+			existing, _ := fields[name].([]string)
+			fields[name] = append(existing, text)
+		} else {
+			fields[name] = text
+		}
+	})
+
+	return fields
+}
+
+// blankIPTCLocation overwrites the values of the place name datasets with
+// spaces, which readers treat as empty. It reports whether any were found.
+func blankIPTCLocation(data []byte) bool {
+	changed := false
+	walkIPTC(data, func(record, dataset byte, value []byte) {
+		if record == iptcApplicationRecord && iptcLocationDatasets[iptcDatasets[dataset]] {
+			for i := range value {
+				value[i] = ' '
+			}
+			changed = true
+		}
+	})
+	return changed
+}
+
+// walkIPTC calls visit with every dataset of an IPTC-IIM block, stopping at
+// the first malformed one. The values share data's storage.
+func walkIPTC(data []byte, visit func(record, dataset byte, value []byte)) {
+	pos := 0
+	for pos+5 <= len(data) {
+		if data[pos] != iptcTagMarker {
+			break
+		}
+		record := data[pos+1]
+		dataset := data[pos+2]
+		size := int(binary.BigEndian.Uint16(data[pos+3 : pos+5]))
+		pos += 5
+
+		// Extended datasets store the length in the following N bytes.
+		if size&0x8000 != 0 {
+			lengthBytes := size & 0x7FFF
+			if lengthBytes > 4 || pos+lengthBytes > len(data) {
+				break
+			}
+			size = 0
+			for _, b := range data[pos : pos+lengthBytes] {
+				size = size<<8 | int(b)
+			}
+			pos += lengthBytes
+		}
+		if size < 0 || pos+size > len(data) {
+			break
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		visit(record, dataset, value)
+	}
+}
+
+// decodeIPTCString treats the value as UTF-8 when declared or when it happens
+// to be valid, and falls back to Latin-1, which is what most older writers use.
+func decodeIPTCString(value []byte, utf8Charset bool) string {
+	if utf8Charset || utf8.Valid(value) {
+		return string(value)
+	}
+
+	runes := make([]rune, len(value))
+	for i, b := range value {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
@@ -0,0 +1,83 @@
+package metadata
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseIPTC(t *testing.T) {
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	title := iptcDataset(iptcApplicationRecord, 5, "Harbour")
+
+	tests := []struct {
+		name string
+		data []byte
+		want map[string]interface{}
+	}{
+		{"empty", nil, map[string]interface{}{}},
+		{
+			"fields and repeatable keywords",
+			join(title, iptcDataset(iptcApplicationRecord, 25, "sea"), iptcDataset(iptcApplicationRecord, 25, "boats")),
+			map[string]interface{}{"ObjectName": "Harbour", "Keywords": []string{"sea", "boats"}},
+		},
+		{
+			"unknown datasets and other records skipped",
+			join(iptcDataset(iptcApplicationRecord, 200, "x"), iptcDataset(3, 5, "y"), title),
+			map[string]interface{}{"ObjectName": "Harbour"},
+		},
+		{
+			"blank values skipped",
+			join(iptcDataset(iptcApplicationRecord, 120, "  "), title),
+			map[string]interface{}{"ObjectName": "Harbour"},
+		},
+		{
+			"latin-1 fallback",
+			iptcDataset(iptcApplicationRecord, 90, "K\xf8benhavn"),
+			map[string]interface{}{"City": "København"},
+		},
+		{
+			"declared utf-8",
+			join(iptcDataset(iptcEnvelopeRecord, iptcCodedCharset, "\x1b%G"), iptcDataset(iptcApplicationRecord, 90, "Zürich")),
+			map[string]interface{}{"City": "Zürich"},
+		},
+		{
+			"extended length",
+			[]byte{iptcTagMarker, iptcApplicationRecord, 5, 0x80, 0x02, 0x00, 0x03, 'B', 'a', 'y'},
+			map[string]interface{}{"ObjectName": "Bay"},
+		},
+		{
+			"extended length wider than four bytes",
+			join([]byte{iptcTagMarker, iptcApplicationRecord, 5, 0x80, 0x05, 0, 0, 0, 0, 3, 'B', 'a', 'y'}, title),
+			map[string]interface{}{},
+		},
+		{
+			"extended length truncated",
+			[]byte{iptcTagMarker, iptcApplicationRecord, 5, 0x80, 0x04, 0x00},
+			map[string]interface{}{},
+		},
+		{
+			"value past end keeps earlier fields",
+			join(title, iptcDataset(iptcApplicationRecord, 25, "keyword")[:8]),
+			map[string]interface{}{"ObjectName": "Harbour"},
+		},
+		{
+			"truncated header",
+			join(title, []byte{iptcTagMarker, iptcApplicationRecord}),
+			map[string]interface{}{"ObjectName": "Harbour"},
+		},
+		{
+			"missing tag marker stops parsing",
+			join(title, []byte{0x00}, iptcDataset(iptcApplicationRecord, 90, "Oslo")),
+			map[string]interface{}{"ObjectName": "Harbour"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseIPTC(tc.data); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	markerAPP1  = 0xE1
+	markerAPP13 = 0xED
+	markerSOS   = 0xDA
+	markerEOI   = 0xD9
+)
+
+var (
+	exifSignature      = []byte("Exif\x00\x00")
+	xmpSignature       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	photoshopSignature = []byte("Photoshop 3.0\x00")
+)
+
+type jpegSegment struct {
+	marker  byte
+	payload []byte
+}
+
+// jpegSegments returns the marker segments that precede the image data. It
+// returns nil for anything that is not a JPEG.
+func jpegSegments(data []byte) []jpegSegment {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	var segments []jpegSegment
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			break
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		if marker == markerSOS || marker == markerEOI {
+			break
+		}
+		if (marker >= 0xD0 && marker <= 0xD7) || marker == 0x01 {
+			pos += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segments = append(segments, jpegSegment{
+			marker:  marker,
+			payload: data[pos+4 : pos+2+length],
+		})
+		pos += 2 + length
+	}
+
+	return segments
+}
+
+// findExif returns the Exif APP1 segment of a JPEG. goexif only looks at the
+// first APP1 segment, which misses EXIF written after an XMP packet.
+func findExif(data []byte) []byte {
+	for _, segment := range jpegSegments(data) {
+		if segment.marker == markerAPP1 && bytes.HasPrefix(segment.payload, exifSignature) {
+			return segment.payload
+		}
+	}
+	return nil
+}
+
+// findXMPPacket returns the first embedded XMP packet. JPEGs carry it in an
+// APP1 segment; for other containers (TIFF, PNG, WebP) the packet is stored
+// as plain XML, so we fall back to scanning for the xmpmeta element.
+func findXMPPacket(data []byte) []byte {
+	for _, segment := range jpegSegments(data) {
+		if segment.marker == markerAPP1 && bytes.HasPrefix(segment.payload, xmpSignature) {
+			return segment.payload[len(xmpSignature):]
+		}
+	}
+
+	start := bytes.Index(data, []byte("<x:xmpmeta"))
+	if start < 0 {
+		return nil
+	}
+	end := bytes.Index(data[start:], []byte("</x:xmpmeta>"))
+	if end < 0 {
+		return nil
+	}
+	return data[start : start+end+len("</x:xmpmeta>")]
+}
+
+// findIPTC returns the IPTC-IIM block stored in the Photoshop image resources
+// of a JPEG APP13 segment.
+func findIPTC(data []byte) []byte {
+	for _, segment := range jpegSegments(data) {
+		if segment.marker == markerAPP13 && bytes.HasPrefix(segment.payload, photoshopSignature) {
+			if block := photoshopResource(segment.payload[len(photoshopSignature):], 0x0404); block != nil {
+				return block
+			}
+		}
+	}
+	return nil
+}
+
+// photoshopResource walks a sequence of 8BIM image resource blocks and returns
+// the payload of the one with the given id.
+func photoshopResource(data []byte, id uint16) []byte {
+	pos := 0
+	for pos+12 <= len(data) {
+		if !bytes.Equal(data[pos:pos+4], []byte("8BIM")) {
+			return nil
+		}
+		resourceID := binary.BigEndian.Uint16(data[pos+4 : pos+6])
+		pos += 6
+
+		// Pascal string name, padded so that length byte + name is even.
+		nameLength := int(data[pos]) + 1
+		if nameLength%2 != 0 {
+			nameLength++
+		}
+		pos += nameLength
+		if pos+4 > len(data) {
+			return nil
+		}
+
+		size := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		pos += 4
+		if size < 0 || pos+size > len(data) {
+			return nil
+		}
+		if resourceID == id {
+			return data[pos : pos+size]
+		}
+
+		pos += size
+		if size%2 != 0 {
+			pos++
+		}
+	}
+	return nil
+}
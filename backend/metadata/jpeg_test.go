@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJPEGSegments(t *testing.T) {
+	app1 := jpegSegmentBytes(markerAPP1, []byte("one"))
+	app13 := jpegSegmentBytes(markerAPP13, []byte("two"))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		payloads []string
+	}{
+		{"empty", nil, nil},
+		{"not a jpeg", []byte("GIF89a\x01\x00\x01\x00"), nil},
+		{"soi only", []byte{0xFF, 0xD8}, nil},
+		{"segments before scan", jpegFile(app1, app13), []string{"one", "two"}},
+		{"fill bytes between segments", jpegFile(app1, []byte{0xFF}, app13), []string{"one", "two"}},
+		{"restart markers skipped", jpegFile([]byte{0xFF, 0xD0}, app1), []string{"one"}},
+		{"nothing after scan", append(jpegFile(app1), app13...), []string{"one"}},
+		{"garbage between segments", jpegFile(app1, []byte{0x00}, app13), []string{"one"}},
+		{"length below two", jpegFile([]byte{0xFF, markerAPP1, 0x00, 0x01}, app13), nil},
+		{"length past end", []byte{0xFF, 0xD8, 0xFF, markerAPP1, 0x00, 0x10, 'x'}, nil},
+		{"truncated header", []byte{0xFF, 0xD8, 0xFF, markerAPP1, 0x00}, nil},
+		{"truncated after first segment", append([]byte{0xFF, 0xD8}, append(app1, app13[:5]...)...), []string{"one"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			segments := jpegSegments(tc.data)
+			if len(segments) != len(tc.payloads) {
+				t.Fatalf("got %d segments, want %d", len(segments), len(tc.payloads))
+			}
+			for i, segment := range segments {
+				if string(segment.payload) != tc.payloads[i] {
+					t.Errorf("segment %d = %q, want %q", i, segment.payload, tc.payloads[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPhotoshopResource(t *testing.T) {
+	iptc := []byte("iptc")
+	named := append([]byte("8BIM\x04\x04\x03abc\x00\x00\x00\x04"), iptc...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"empty", nil, nil},
+		{"single block", photoshopBlock(0x0404, iptc), iptc},
+		{"after odd sized block", append(photoshopBlock(0x0409, []byte("odd")), photoshopBlock(0x0404, iptc)...), iptc},
+		{"padded name", named, iptc},
+		{"other id only", photoshopBlock(0x0409, iptc), nil},
+		{"bad signature", append([]byte("8BIX"), photoshopBlock(0x0404, iptc)[4:]...), nil},
+		{"size past end", photoshopBlock(0x0404, iptc)[:14], nil},
+		{"truncated name", []byte("8BIM\x04\x04\xFFabcdef"), nil},
+		{"huge size", []byte("8BIM\x04\x04\x00\x00\xFF\xFF\xFF\xFFiptc"), nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := photoshopResource(tc.data, 0x0404); !bytes.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindXMPPacket(t *testing.T) {
+	packet := xmpPacket("")
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"jpeg app1", jpegFile(xmpSegment(packet)), packet},
+		{"plain xml in other container", append([]byte("RIFF....WEBPXMP "), packet...), packet},
+		{"unterminated plain xml", []byte("RIFF" + packet[:40]), ""},
+		{"none", jpegFile(jpegSegmentBytes(markerAPP1, []byte("nothing"))), ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(findXMPPacket(tc.data)); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
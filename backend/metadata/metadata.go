@@ -0,0 +1,228 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Metadata is what we could read from an uploaded file. The raw maps keep
+// every field each source provided; the typed fields are resolved across
+// sources, preferring XMP (what Lightroom and friends keep current) over
+// IPTC-IIM over EXIF.
+type Metadata struct {
+	EXIF map[string]interface{}
+	IPTC map[string]interface{}
+	XMP  map[string]interface{}
+
+	Title     string
+	Caption   string
+	Keywords  []string
+	Creator   string
+	Copyright string
+	Lens      LensInfo
+
+	Latitude  *float64
+	Longitude *float64
+}
+
+type LensInfo struct {
+	Make          string
+	Model         string
+	Specification string
+	SerialNumber  string
+}
+
+// Extract reads EXIF, IPTC-IIM and XMP from an image file. Missing or
+// malformed blocks are skipped; it never fails.
+func Extract(data []byte) *Metadata {
+	m := &Metadata{}
+
+	exifFields, x := parseExif(data)
+	m.EXIF = exifFields
+	if x != nil {
+		if lat, lon, err := x.LatLong(); err == nil {
+			m.Latitude = &lat
+			m.Longitude = &lon
+		}
+	}
+
+	if block := findIPTC(data); block != nil {
+		if fields := parseIPTC(block); len(fields) > 0 {
+			m.IPTC = fields
+		}
+	}
+
+	if packet := findXMPPacket(data); packet != nil {
+		if props, err := ParseXMP(packet); err == nil && len(props) > 0 {
+			m.XMP = props
+		}
+	}
+
+	m.normalize()
+	return m
+}
+
+func (m *Metadata) normalize() {
+	m.Title = firstString(
+		stringField(m.XMP, "dc:title"),
+		stringField(m.IPTC, "ObjectName"),
+	)
+	m.Caption = firstString(
+		stringField(m.XMP, "dc:description"),
+		stringField(m.IPTC, "Caption"),
+		stringField(m.EXIF, "ImageDescription"),
+		stringField(m.EXIF, "UserComment"),
+	)
+	m.Creator = firstString(
+		strings.Join(listField(m.XMP, "dc:creator"), ", "),
+		strings.Join(listField(m.IPTC, "Byline"), ", "),
+		stringField(m.EXIF, "Artist"),
+	)
+	m.Copyright = firstString(
+		stringField(m.XMP, "dc:rights"),
+		stringField(m.IPTC, "CopyrightNotice"),
+		stringField(m.EXIF, "Copyright"),
+	)
+	m.Keywords = mergeKeywords(
+		listField(m.XMP, "dc:subject"),
+		listField(m.IPTC, "Keywords"),
+	)
+
+	m.Lens = LensInfo{
+		Make: firstString(
+			stringField(m.EXIF, "LensMake"),
+			stringField(m.XMP, "exifEX:LensMake"),
+		),
+		Model: firstString(
+			stringField(m.EXIF, "LensModel"),
+			stringField(m.XMP, "exifEX:LensModel"),
+			stringField(m.XMP, "aux:Lens"),
+		),
+		Specification: firstString(
+			formatLensSpecification(m.EXIF["LensSpecification"]),
+			stringField(m.XMP, "aux:LensInfo"),
+		),
+		SerialNumber: firstString(
+			stringField(m.EXIF, "LensSerialNumber"),
+			stringField(m.XMP, "exifEX:LensSerialNumber"),
+			stringField(m.XMP, "aux:LensSerialNumber"),
+		),
+	}
+}
+
+// ExifData flattens the metadata into the shape stored on a photo: every EXIF
+// field at the top level under its usual name, the resolved fields alongside,
+// and the IPTC and XMP dumps nested under their own keys.
+func (m *Metadata) ExifData() map[string]interface{} {
+	data := make(map[string]interface{}, len(m.EXIF)+12)
+	for key, value := range m.EXIF {
+		data[key] = value
+	}
+
+	if m.Latitude != nil && m.Longitude != nil {
+		data["Latitude"] = *m.Latitude
+		data["Longitude"] = *m.Longitude
+	}
+
+	setString(data, "Title", m.Title)
+	setString(data, "Caption", m.Caption)
+	setString(data, "Creator", m.Creator)
+	setString(data, "Copyright", m.Copyright)
+	setString(data, "LensMake", m.Lens.Make)
+	setString(data, "LensModel", m.Lens.Model)
+	setString(data, "LensSpecification", m.Lens.Specification)
+	setString(data, "LensSerialNumber", m.Lens.SerialNumber)
+	if len(m.Keywords) > 0 {
+		data["Keywords"] = m.Keywords
+	}
+
+	if len(m.IPTC) > 0 {
+		data["IPTC"] = m.IPTC
+	}
+	if len(m.XMP) > 0 {
+		data["XMP"] = m.XMP
+	}
+
+	return data
+}
+
+func setString(data map[string]interface{}, key, value string) {
+	if value != "" {
+		data[key] = value
+	}
+}
+
+func stringField(fields map[string]interface{}, key string) string {
+	switch value := fields[key].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case []string:
+		if len(value) > 0 {
+			return strings.TrimSpace(value[0])
+		}
+	}
+	return ""
+}
+
+func listField(fields map[string]interface{}, key string) []string {
+	switch value := fields[key].(type) {
+	case string:
+		if value = strings.TrimSpace(value); value != "" {
+			return []string{value}
+		}
+	case []string:
+		return value
+	}
+	return nil
+}
+
+func firstString(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// mergeKeywords combines keyword lists, dropping blanks and case-insensitive
+// duplicates while keeping the first spelling seen.
+func mergeKeywords(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var keywords []string
+	for _, list := range lists {
+		for _, keyword := range list {
+			keyword = strings.TrimSpace(keyword)
+			key := strings.ToLower(keyword)
+			if keyword == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// formatLensSpecification renders the EXIF min/max focal length and aperture
+// quadruple the way lenses are usually named, e.g. "24-70mm f/2.8".
+func formatLensSpecification(value interface{}) string {
+	spec, ok := value.([]float64)
+	if !ok || len(spec) != 4 || spec[0] == 0 {
+		return ""
+	}
+
+	focal := fmt.Sprintf("%gmm", spec[0])
+	if spec[1] != 0 && spec[1] != spec[0] {
+		focal = fmt.Sprintf("%g-%gmm", spec[0], spec[1])
+	}
+	if spec[2] == 0 {
+		return focal
+	}
+
+	aperture := fmt.Sprintf("f/%g", spec[2])
+	if spec[3] != 0 && spec[3] != spec[2] {
+		aperture = fmt.Sprintf("f/%g-%g", spec[2], spec[3])
+	}
+	return focal + " " + aperture
+}
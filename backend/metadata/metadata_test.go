@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	iptc := bytes.Join([][]byte{
+		iptcDataset(iptcApplicationRecord, 5, "IPTC title"),
+		iptcDataset(iptcApplicationRecord, 25, "Sea"),
+		iptcDataset(iptcApplicationRecord, 80, "Ana"),
+	}, nil)
+	xmp := xmpPacket(
+		`<dc:title><rdf:Alt><rdf:li xml:lang="x-default">XMP title</rdf:li></rdf:Alt></dc:title>` +
+			`<dc:subject><rdf:Bag><rdf:li>sea</rdf:li><rdf:li>boats</rdf:li></rdf:Bag></dc:subject>`,
+	)
+	tiff := gpsTIFF()
+
+	type want struct {
+		title    string
+		creator  string
+		keywords []string
+		located  bool
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want want
+	}{
+		{"empty", nil, want{}},
+		{"not an image", []byte("hello world"), want{}},
+		{"soi only", []byte{0xFF, 0xD8}, want{}},
+		{
+			"all sources",
+			jpegFile(exifSegment(tiff), iptcSegment(iptc), xmpSegment(xmp)),
+			want{title: "XMP title", creator: "Ana", keywords: []string{"sea", "boats"}, located: true},
+		},
+		{
+			"exif after xmp",
+			jpegFile(xmpSegment(xmp), exifSegment(tiff)),
+			want{title: "XMP title", keywords: []string{"sea", "boats"}, located: true},
+		},
+		{
+			"truncated xmp falls back to iptc",
+			jpegFile(iptcSegment(iptc), xmpSegment(xmp[:len(xmp)/2])),
+			want{title: "IPTC title", creator: "Ana", keywords: []string{"Sea"}},
+		},
+		{
+			"malformed xmp falls back to iptc",
+			jpegFile(iptcSegment(iptc), xmpSegment("<x:xmpmeta><rdf:RDF></x:xmpmeta>")),
+			want{title: "IPTC title", creator: "Ana", keywords: []string{"Sea"}},
+		},
+		{
+			"truncated iptc keeps complete datasets",
+			jpegFile(iptcSegment(iptc[:len(iptc)-2])),
+			want{title: "IPTC title", keywords: []string{"Sea"}},
+		},
+		{
+			"iptc segment cut short",
+			jpegFile(iptcSegment(iptc))[:30],
+			want{},
+		},
+		{
+			"truncated exif",
+			jpegFile(exifSegment(tiff[:20]), xmpSegment(xmp)),
+			want{title: "XMP title", keywords: []string{"sea", "boats"}},
+		},
+		{
+			"exif with bad byte order",
+			jpegFile(exifSegment(append([]byte("XX"), tiff[2:]...))),
+			want{},
+		},
+		{
+			"exif ifd offset past end",
+			jpegFile(exifSegment([]byte("II\x2a\x00\xff\xff\xff\x7f"))),
+			want{},
+		},
+		{
+			"tag count overflowing 32 bits",
+			jpegFile(exifSegment([]byte("II*\x00\x08\x00\x00\x00\x01\x00\x25\x88\x04\x00\x01\x00\x00\x80\x00\x00\x00\x00\x00\x00\x00\x00"))),
+			want{},
+		},
+		{
+			"looping ifd chain",
+			jpegFile(exifSegment([]byte("II*\x00\x08\x00\x00\x00\x00\x00\x0e\x00\x00\x00\x00\x00\x08\x00\x00\x00"))),
+			want{},
+		},
+		{
+			"segment length past end",
+			append(jpegFile(iptcSegment(iptc))[:2], 0xFF, markerAPP13, 0xFF, 0xFF, 'x'),
+			want{},
+		},
+		{
+			"plain xmp in another container",
+			append([]byte("RIFF\x00\x00\x00\x00WEBPXMP "), xmp...),
+			want{title: "XMP title", keywords: []string{"sea", "boats"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := Extract(tc.data)
+			if m.Title != tc.want.title {
+				t.Errorf("Title = %q, want %q", m.Title, tc.want.title)
+			}
+			if m.Creator != tc.want.creator {
+				t.Errorf("Creator = %q, want %q", m.Creator, tc.want.creator)
+			}
+			if !reflect.DeepEqual(m.Keywords, tc.want.keywords) {
+				t.Errorf("Keywords = %q, want %q", m.Keywords, tc.want.keywords)
+			}
+			if located := m.Latitude != nil && m.Longitude != nil; located != tc.want.located {
+				t.Fatalf("located = %v, want %v", located, tc.want.located)
+			}
+			if tc.want.located && (*m.Latitude != 51.5 || *m.Longitude != 4.5) {
+				t.Errorf("location = %v, %v, want 51.5, 4.5", *m.Latitude, *m.Longitude)
+			}
+		})
+	}
+}
+
+func FuzzExtract(f *testing.F) {
+	iptc := bytes.Join([][]byte{
+		iptcDataset(iptcEnvelopeRecord, iptcCodedCharset, "\x1b%G"),
+		iptcDataset(iptcApplicationRecord, 5, "Title"),
+		iptcDataset(iptcApplicationRecord, 25, "keyword"),
+	}, nil)
+	xmp := xmpPacket(`<dc:title><rdf:Alt><rdf:li xml:lang="x-default">Title</rdf:li></rdf:Alt></dc:title>` +
+		`<exif:GPSLatitude>51,30.0N</exif:GPSLatitude>`)
+
+	f.Add([]byte{})
+	f.Add([]byte{0xFF, 0xD8})
+	f.Add(gpsTIFF())
+	f.Add(jpegFile(exifSegment(gpsTIFF()), iptcSegment(iptc), xmpSegment(xmp)))
+	f.Add(jpegFile(iptcSegment(iptc[:len(iptc)-3])))
+	f.Add(jpegFile(xmpSegment(xmp[:len(xmp)/2])))
+	f.Add([]byte(xmp))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		original := bytes.Clone(data)
+
+		m := Extract(data)
+		if m == nil {
+			t.Fatal("Extract returned nil")
+		}
+		fields := m.ExifData()
+		RemoveGPS(fields)
+		for key := range fields {
+			if isGPSKey(key) {
+				t.Errorf("RemoveGPS left %q", key)
+			}
+		}
+
+		stripped := StripGPS(data)
+		if len(stripped) != len(data) {
+			t.Errorf("StripGPS changed the length from %d to %d", len(data), len(stripped))
+		}
+		if !bytes.Equal(data, original) {
+			t.Error("input was modified")
+		}
+		Extract(stripped)
+	})
+}
@@ -0,0 +1,271 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+const namespaceRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// Writers are free to pick their own prefixes, so properties are keyed by the
+// conventional prefix of their namespace rather than the one in the document.
+var xmpPrefixes = map[string]string{
+	"http://purl.org/dc/elements/1.1/":                     "dc",
+	"http://ns.adobe.com/xap/1.0/":                         "xmp",
+	"http://ns.adobe.com/xap/1.0/rights/":                  "xmpRights",
+	"http://ns.adobe.com/xap/1.0/mm/":                      "xmpMM",
+	"http://ns.adobe.com/photoshop/1.0/":                   "photoshop",
+	"http://ns.adobe.com/lightroom/1.0/":                   "lr",
+	"http://ns.adobe.com/exif/1.0/aux/":                    "aux",
+	"http://ns.adobe.com/exif/1.0/":                        "exif",
+	"http://cipa.jp/exif/1.0/":                             "exifEX",
+	"http://ns.adobe.com/tiff/1.0/":                        "tiff",
+	"http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/":          "Iptc4xmpCore",
+	"http://iptc.org/std/Iptc4xmpExt/2008-02-29/":          "Iptc4xmpExt",
+	"http://ns.adobe.com/camera-raw-settings/1.0/":         "crs",
+	"http://ns.adobe.com/xap/1.0/sType/ResourceEvent#":     "stEvt",
+	"http://ns.adobe.com/xap/1.0/sType/ResourceRef#":       "stRef",
+	"http://ns.adobe.com/xmp/1.0/DynamicMedia/":            "xmpDM",
+	"http://ns.microsoft.com/photo/1.0/":                   "MicrosoftPhoto",
+	"http://ns.useplus.org/ldf/xmp/1.0/":                   "plus",
+	"http://www.metadataworkinggroup.com/schemas/regions/": "mwg-rs",
+}
+
+// Develop settings and edit history are large and say nothing about the
+// picture itself, so they are left out of the dump.
+var xmpSkippedPrefixes = map[string]bool{
+	"crs":   true,
+	"xmpMM": true,
+}
+
+type xmpFrame struct {
+	kind      int
+	key       string
+	container string
+	lang      string
+	text      strings.Builder
+	values    []string
+	defaultAt int
+}
+
+const (
+	xmpFrameOther = iota
+	xmpFrameDescription
+	xmpFrameProperty
+	xmpFrameContainer
+	xmpFrameItem
+	xmpFrameIgnored
+)
+
+type xmpParser struct {
+	props    map[string]interface{}
+	prefixes map[string]string
+	stack    []*xmpFrame
+}
+
+// ParseXMP flattens an XMP packet into a map keyed by "prefix:Property".
+// Bags and sequences become string slices, language alternatives resolve to
+// the x-default entry, and struct fields are keyed "outer/inner".
+func ParseXMP(data []byte) (map[string]interface{}, error) {
+	p := &xmpParser{
+		props:    make(map[string]interface{}),
+		prefixes: make(map[string]string),
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			p.start(t)
+		case xml.EndElement:
+			p.end()
+		case xml.CharData:
+			if len(p.stack) > 0 {
+				p.stack[len(p.stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	return p.props, nil
+}
+
+func (p *xmpParser) start(el xml.StartElement) {
+	for _, attr := range el.Attr {
+		if attr.Name.Space == "xmlns" {
+			if _, known := xmpPrefixes[attr.Value]; !known {
+				p.prefixes[attr.Value] = attr.Name.Local
+			}
+		}
+	}
+
+	frame := &xmpFrame{kind: xmpFrameOther, defaultAt: -1}
+	parent := p.top()
+	if parent != nil && parent.kind == xmpFrameIgnored {
+		frame.kind = xmpFrameIgnored
+		p.stack = append(p.stack, frame)
+		return
+	}
+
+	switch {
+	case el.Name.Space == namespaceRDF && el.Name.Local == "Description":
+		frame.kind = xmpFrameDescription
+		if property := p.enclosingProperty(); property != nil {
+			frame.key = property.key
+		}
+		p.setAttributes(frame.key, el.Attr)
+	case el.Name.Space == namespaceRDF && (el.Name.Local == "Bag" || el.Name.Local == "Seq" || el.Name.Local == "Alt"):
+		frame.kind = xmpFrameContainer
+		if parent != nil && parent.kind == xmpFrameProperty {
+			parent.container = el.Name.Local
+		}
+	case el.Name.Space == namespaceRDF && el.Name.Local == "li":
+		frame.kind = xmpFrameItem
+		for _, attr := range el.Attr {
+			if attr.Name.Local == "lang" {
+				frame.lang = attr.Value
+			}
+		}
+	case el.Name.Space == namespaceRDF || el.Name.Space == "adobe:ns:meta/":
+	default:
+		if base, ok := p.structKey(); ok {
+			name := p.qualifiedName(el.Name)
+			if name == "" {
+				frame.kind = xmpFrameIgnored
+				break
+			}
+			frame.kind = xmpFrameProperty
+			frame.key = joinKey(base, name)
+			for _, attr := range el.Attr {
+				if attr.Name.Space == namespaceRDF && attr.Name.Local == "resource" {
+					p.props[frame.key] = attr.Value
+				}
+			}
+			p.setAttributes(frame.key, el.Attr)
+		}
+	}
+
+	p.stack = append(p.stack, frame)
+}
+
+func (p *xmpParser) end() {
+	if len(p.stack) == 0 {
+		return
+	}
+	frame := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	switch frame.kind {
+	case xmpFrameItem:
+		value := strings.TrimSpace(frame.text.String())
+		if value == "" {
+			return
+		}
+		// Item -> container -> property.
+		if len(p.stack) >= 2 {
+			property := p.stack[len(p.stack)-2]
+			if property.kind == xmpFrameProperty {
+				if frame.lang == "x-default" && property.defaultAt < 0 {
+					property.defaultAt = len(property.values)
+				}
+				property.values = append(property.values, value)
+			}
+		}
+	case xmpFrameProperty:
+		switch {
+		case frame.container == "Alt":
+			if len(frame.values) == 0 {
+				return
+			}
+			index := 0
+			if frame.defaultAt >= 0 {
+				index = frame.defaultAt
+			}
+			p.props[frame.key] = frame.values[index]
+		case frame.container != "":
+			if len(frame.values) > 0 {
+				p.props[frame.key] = frame.values
+			}
+		default:
+			if value := strings.TrimSpace(frame.text.String()); value != "" {
+				p.props[frame.key] = value
+			}
+		}
+	}
+}
+
+func (p *xmpParser) top() *xmpFrame {
+	if len(p.stack) == 0 {
+		return nil
+	}
+	return p.stack[len(p.stack)-1]
+}
+
+// structKey returns the key prefix for a property element opened at the
+// current position: empty for a top-level description, the enclosing
+// property's key inside a struct. Properties elsewhere are ignored.
+func (p *xmpParser) structKey() (string, bool) {
+	parent := p.top()
+	if parent == nil {
+		return "", false
+	}
+	switch parent.kind {
+	case xmpFrameDescription:
+		return parent.key, true
+	case xmpFrameProperty, xmpFrameItem:
+		// rdf:parseType="Resource" lets a property or list item hold
+		// fields directly.
+		if property := p.enclosingProperty(); property != nil {
+			return property.key, true
+		}
+	}
+	return "", false
+}
+
+func (p *xmpParser) enclosingProperty() *xmpFrame {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].kind == xmpFrameProperty {
+			return p.stack[i]
+		}
+	}
+	return nil
+}
+
+func (p *xmpParser) setAttributes(base string, attrs []xml.Attr) {
+	for _, attr := range attrs {
+		if attr.Name.Space == "xmlns" || attr.Name.Space == namespaceRDF || attr.Name.Space == "xml" || attr.Name.Space == "" {
+			continue
+		}
+		if name := p.qualifiedName(attr.Name); name != "" {
+			p.props[joinKey(base, name)] = attr.Value
+		}
+	}
+}
+
+func (p *xmpParser) qualifiedName(name xml.Name) string {
+	prefix, ok := xmpPrefixes[name.Space]
+	if !ok {
+		prefix, ok = p.prefixes[name.Space]
+	}
+	if !ok || xmpSkippedPrefixes[prefix] {
+		return ""
+	}
+	return prefix + ":" + name.Local
+}
+
+func joinKey(base, name string) string {
+	if base == "" {
+		return name
+	}
+	return base + "/" + name
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -20,19 +22,104 @@ func authenticate(c *fiber.Ctx, authService *services.AuthService) error {
 	}
 
 	token := parts[1]
+	if strings.HasPrefix(token, services.APITokenPrefix) {
+		return authenticateAPIToken(c, authService, token)
+	}
+
 	claims, err := authService.ValidateToken(token)
 	if err != nil {
 		return fiber.NewError(fiber.StatusUnauthorized, "invalid or expired token")
 	}
 
-	c.Locals("user_id", claims.UserID)
-	c.Locals("user_email", claims.Email)
-	c.Locals("user_username", claims.Username)
-	c.Locals("user_role", claims.Role)
+	if err := authService.ValidateSession(c.Context(), claims, RequestClient(c)); err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "session has been revoked or expired")
+	}
+
+	// The account is looked up rather than trusted from the claims, so that
+	// suspensions and role changes apply before the access token expires.
+	user, err := authService.ActiveUser(c.Context(), claims.UserID)
+	if err != nil {
+		return accountError(err)
+	}
+
+	c.Locals("user_id", user.ID)
+	c.Locals("user_email", user.Email)
+	c.Locals("user_username", user.Username)
+	c.Locals("user_role", user.Role)
+	c.Locals("session_id", claims.SessionID)
 
 	return nil
 }
 
+// uploadRoutes are the only changes the upload scope allows, by method and
+// route pattern.
+var uploadRoutes = map[string]bool{
+	fiber.MethodPost + " /api/albums":                 true,
+	fiber.MethodPost + " /api/albums/:albumId/photos": true,
+	fiber.MethodPost + " /api/photos":                 true,
+}
+
+// apiTokenScope returns the scope a request needs: read for reading, upload
+// for the upload routes and write for any other change. Admin endpoints
+// check for the admin scope themselves.
+func apiTokenScope(c *fiber.Ctx) string {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return models.ScopeRead
+	}
+	if uploadRoutes[c.Method()+" "+strings.TrimSuffix(c.Route().Path, "/")] {
+		return models.ScopeUpload
+	}
+	return models.ScopeWrite
+}
+
+// authenticateAPIToken signs a request in with a personal access token that
+// has the scope the request needs.
+func authenticateAPIToken(c *fiber.Ctx, authService *services.AuthService, plain string) error {
+	token, user, err := authService.ValidateAPIToken(c.Context(), plain, RequestClient(c))
+	if err != nil {
+		return accountError(err)
+	}
+
+	scope := apiTokenScope(c)
+	if !token.Allows(scope) {
+		return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("token lacks the %s scope", scope))
+	}
+
+	c.Locals("user_id", user.ID)
+	c.Locals("user_email", user.Email)
+	c.Locals("user_username", user.Username)
+	c.Locals("user_role", user.Role)
+	c.Locals("session_id", "")
+	c.Locals("api_token", token)
+
+	return nil
+}
+
+func accountError(err error) error {
+	if errors.Is(err, services.ErrAccountSuspended) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return fiber.NewError(fiber.StatusUnauthorized, "invalid or expired token")
+}
+
+// RequestClient describes the device a request came from, for recording
+// against login sessions. Behind a trusted proxy the address is taken from
+// the configured proxy header; when the proxy sends none, the proxy's own
+// address is used and marked as such.
+func RequestClient(c *fiber.Ctx) services.SessionClient {
+	remoteIP := c.Context().RemoteIP().String()
+	ip := c.IP()
+	if ip == "" {
+		ip = remoteIP
+	}
+	return services.SessionClient{
+		IPAddress: ip,
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		ViaProxy:  c.App().Config().EnableTrustedProxyCheck && ip == remoteIP && c.IsProxyTrusted(),
+	}
+}
+
 func AuthRequired(authService *services.AuthService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if err := authenticate(c, authService); err != nil {
@@ -42,6 +129,32 @@ func AuthRequired(authService *services.AuthService) fiber.Handler {
 	}
 }
 
+func OptionalAuth(authService *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") != "" {
+			if err := authenticate(c, authService); err != nil {
+				return err
+			}
+		}
+		return c.Next()
+	}
+}
+
+// SessionRequired is AuthRequired for account management: it refuses
+// personal access tokens, so a leaked token cannot be used to change the
+// password, issue more tokens or take over sessions.
+func SessionRequired(authService *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := authenticate(c, authService); err != nil {
+			return err
+		}
+		if _, ok := c.Locals("api_token").(*models.APIToken); ok {
+			return fiber.NewError(fiber.StatusForbidden, "this endpoint cannot be used with an API token")
+		}
+		return c.Next()
+	}
+}
+
 func AdminOnly(authService *services.AuthService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if err := authenticate(c, authService); err != nil {
@@ -52,6 +165,9 @@ func AdminOnly(authService *services.AuthService) fiber.Handler {
 		if !ok || role != models.RoleAdmin {
 			return fiber.NewError(fiber.StatusForbidden, "admin access required")
 		}
+		if token, ok := c.Locals("api_token").(*models.APIToken); ok && !token.Allows(models.ScopeAdmin) {
+			return fiber.NewError(fiber.StatusForbidden, "token lacks the admin scope")
+		}
 
 		return c.Next()
 	}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/suipic/backend/services"
+)
+
+// ShareTokenHeader carries the guest token of a share link. Image URLs used
+// in <img> tags cannot set headers and pass it as ?share_token= instead.
+const ShareTokenHeader = "X-Share-Token"
+
+func shareToken(c *fiber.Ctx) string {
+	if token := c.Get(ShareTokenHeader); token != "" {
+		return token
+	}
+	return c.Query("share_token")
+}
+
+func authenticateShare(c *fiber.Ctx, shareService *services.ShareService, token string) error {
+	link, err := shareService.ValidateGuestToken(c.Context(), token)
+	if errors.Is(err, services.ErrShareLinkInvalid) {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to validate share link")
+	}
+
+	c.Locals("share_link", link)
+	return nil
+}
+
+// AuthOrShare is AuthRequired for endpoints that guests of a share link may
+// also use. Guests get no user_id; handlers find the link in the share_link
+// local instead and must limit them to what it shares.
+func AuthOrShare(authService *services.AuthService, shareService *services.ShareService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") == "" {
+			if token := shareToken(c); token != "" {
+				if err := authenticateShare(c, shareService, token); err != nil {
+					return err
+				}
+				return c.Next()
+			}
+		}
+		if err := authenticate(c, authService); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
+
+// OptionalAuthOrShare is OptionalAuth that also accepts share link guests.
+func OptionalAuthOrShare(authService *services.AuthService, shareService *services.ShareService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") != "" {
+			if err := authenticate(c, authService); err != nil {
+				return err
+			}
+		} else if token := shareToken(c); token != "" {
+			if err := authenticateShare(c, shareService, token); err != nil {
+				return err
+			}
+		}
+		return c.Next()
+	}
+}
+
+// ShareGuest requires a share link guest token.
+func ShareGuest(shareService *services.ShareService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := shareToken(c)
+		if token == "" {
+			return fiber.NewError(fiber.StatusUnauthorized, "missing share token")
+		}
+		if err := authenticateShare(c, shareService, token); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
@@ -7,16 +7,23 @@ import (
 )
 
 type Album struct {
-	ID               int          `json:"id"`
-	Title            string       `json:"title"`
-	DateTaken        *time.Time   `json:"dateTaken,omitempty"`
-	Description      *string      `json:"description,omitempty"`
-	Location         *string      `json:"location,omitempty"`
-	CustomFields     CustomFields `json:"customFields,omitempty"`
-	ThumbnailPhotoID *int         `json:"thumbnailPhotoId,omitempty"`
-	PhotographerID   int          `json:"photographerId"`
-	CreatedAt        time.Time    `json:"createdAt"`
-	UpdatedAt        time.Time    `json:"updatedAt"`
+	ID               int               `json:"id"`
+	Title            string            `json:"title"`
+	DateTaken        *time.Time        `json:"dateTaken,omitempty"`
+	Description      *string           `json:"description,omitempty"`
+	Location         *string           `json:"location,omitempty"`
+	Timezone         *string           `json:"timezone,omitempty"`
+	CustomFields     CustomFields      `json:"customFields,omitempty"`
+	GPSPolicy        GPSPolicyOverride `json:"gpsPolicy"`
+	ThumbnailPhotoID *int              `json:"thumbnailPhotoId,omitempty"`
+	PhotographerID   int               `json:"photographerId"`
+	OrganizationID   *int              `json:"organizationId,omitempty"`
+	CreatedAt        time.Time         `json:"createdAt"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
+
+	// SuggestedLocation is derived from where the album's photos were taken
+	// and only filled in when Location is empty. It is not stored.
+	SuggestedLocation *string `json:"suggestedLocation,omitempty"`
 }
 
 type CustomFields map[string]interface{}
@@ -41,3 +48,83 @@ func (c *CustomFields) Scan(value interface{}) error {
 
 	return json.Unmarshal(bytes, c)
 }
+
+// GPSPolicy controls what happens to photo coordinates. Retain keeps them in
+// ExifData at all; Expose returns them in API responses to album clients;
+// Index puts them in the search index; Strip removes location from stored
+// files before they can be served.
+//
+// Stored files are public objects that anyone who learns their name can
+// fetch, whatever Expose says. Images are re-encoded as WebP without
+// metadata on upload, so Strip only makes a difference for files stored as
+// they were sent.
+type GPSPolicy struct {
+	Retain bool `json:"retain"`
+	Expose bool `json:"expose"`
+	Index  bool `json:"index"`
+	Strip  bool `json:"strip"`
+}
+
+// GPSPolicyOverride is an album's deviation from the system GPS policy. Nil
+// fields inherit the system default.
+type GPSPolicyOverride struct {
+	Retain *bool `json:"retain,omitempty"`
+	Expose *bool `json:"expose,omitempty"`
+	Index  *bool `json:"index,omitempty"`
+	Strip  *bool `json:"strip,omitempty"`
+}
+
+func (o GPSPolicyOverride) IsEmpty() bool {
+	return o.Retain == nil && o.Expose == nil && o.Index == nil && o.Strip == nil
+}
+
+func (o GPSPolicyOverride) Equal(other GPSPolicyOverride) bool {
+	same := func(a, b *bool) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+	}
+	return same(o.Retain, other.Retain) && same(o.Expose, other.Expose) &&
+		same(o.Index, other.Index) && same(o.Strip, other.Strip)
+}
+
+func (o GPSPolicyOverride) Resolve(defaults GPSPolicy) GPSPolicy {
+	policy := defaults
+	if o.Retain != nil {
+		policy.Retain = *o.Retain
+	}
+	if o.Expose != nil {
+		policy.Expose = *o.Expose
+	}
+	if o.Index != nil {
+		policy.Index = *o.Index
+	}
+	if o.Strip != nil {
+		policy.Strip = *o.Strip
+	}
+
+	if !policy.Retain {
+		policy.Expose = false
+		policy.Index = false
+	}
+	return policy
+}
+
+func (o GPSPolicyOverride) Value() (driver.Value, error) {
+	if o.IsEmpty() {
+		return nil, nil
+	}
+	return json.Marshal(o)
+}
+
+func (o *GPSPolicyOverride) Scan(value interface{}) error {
+	*o = GPSPolicyOverride{}
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(bytes, o)
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AlbumCollaborator is a photographer who works on someone else's album,
+// such as a second shooter at a wedding. Collaborators can upload photos,
+// see the clients' selections and comment; managing, sharing and deleting
+// stay with the album's photographer.
+type AlbumCollaborator struct {
+	ID        int       `json:"id"`
+	AlbumID   int       `json:"albumId"`
+	UserID    int       `json:"userId"`
+	AddedBy   *int      `json:"addedBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Username and FriendlyName are filled in when listing an album's
+	// collaborators.
+	Username     string `json:"username,omitempty"`
+	FriendlyName string `json:"friendlyName,omitempty"`
+}
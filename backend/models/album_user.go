@@ -2,9 +2,57 @@ package models
 
 import "time"
 
+// AlbumPermission is something an assigned user may be allowed to do in an
+// album. Album owners and admins may always do everything.
+type AlbumPermission string
+
+const (
+	AlbumPermissionView     AlbumPermission = "view"
+	AlbumPermissionComment  AlbumPermission = "comment"
+	AlbumPermissionRate     AlbumPermission = "rate"
+	AlbumPermissionDownload AlbumPermission = "download"
+	AlbumPermissionShare    AlbumPermission = "share"
+)
+
+// AlbumUser assigns a user to an album. Being assigned is what lets them
+// view it; the flags decide what else they may do. CanRate covers both stars
+// and pick/reject, and CanShare lets them create share links for guests.
 type AlbumUser struct {
-	ID        int       `json:"id"`
-	AlbumID   int       `json:"albumId"`
-	UserID    int       `json:"userId"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID          int       `json:"id"`
+	AlbumID     int       `json:"albumId"`
+	UserID      int       `json:"userId"`
+	CanComment  bool      `json:"canComment"`
+	CanRate     bool      `json:"canRate"`
+	CanDownload bool      `json:"canDownload"`
+	CanShare    bool      `json:"canShare"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// NewAlbumUser returns an assignment with the default permissions: everything
+// except sharing the album further.
+func NewAlbumUser(albumID, userID int) *AlbumUser {
+	return &AlbumUser{
+		AlbumID:     albumID,
+		UserID:      userID,
+		CanComment:  true,
+		CanRate:     true,
+		CanDownload: true,
+	}
+}
+
+// Allows reports whether the assignment grants the permission.
+func (u *AlbumUser) Allows(permission AlbumPermission) bool {
+	switch permission {
+	case AlbumPermissionView:
+		return true
+	case AlbumPermissionComment:
+		return u.CanComment
+	case AlbumPermissionRate:
+		return u.CanRate
+	case AlbumPermissionDownload:
+		return u.CanDownload
+	case AlbumPermissionShare:
+		return u.CanShare
+	}
+	return false
 }
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+const (
+	// ScopeRead allows reading albums, photos and everything else the user
+	// can see.
+	ScopeRead = "read"
+	// ScopeUpload additionally allows creating albums and uploading photos
+	// to them, and nothing else.
+	ScopeUpload = "upload"
+	// ScopeWrite additionally allows every other change the user can make,
+	// including deletions.
+	ScopeWrite = "write"
+	// ScopeAdmin additionally allows the admin endpoints, for admins only.
+	ScopeAdmin = "admin"
+)
+
+// APIToken is a personal access token for scripts and integrations. Only a
+// hash of the token is stored; TokenPrefix is kept so users can tell their
+// tokens apart.
+type APIToken struct {
+	ID          int        `json:"id"`
+	UserID      int64      `json:"userId"`
+	Name        string     `json:"name"`
+	TokenHash   string     `json:"-"`
+	TokenPrefix string     `json:"tokenPrefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP  *string    `json:"lastUsedIp,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Active reports whether the token can still be used at the given time.
+func (t *APIToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// Allows reports whether the token grants scope. Scopes build on each other
+// in the order read, upload, write, admin.
+func (t *APIToken) Allows(scope string) bool {
+	rank := map[string]int{ScopeRead: 1, ScopeUpload: 2, ScopeWrite: 3, ScopeAdmin: 4}
+	for _, granted := range t.Scopes {
+		if rank[granted] >= rank[scope] && rank[scope] > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditLogin              AuditAction = "login"
+	AuditLoginFailed        AuditAction = "login_failed"
+	AuditSettingUpdate      AuditAction = "setting_update"
+	AuditAlbumDelete        AuditAction = "album_delete"
+	AuditAlbumUsersAssign   AuditAction = "album_users_assign"
+	AuditCollaboratorAdd    AuditAction = "album_collaborator_add"
+	AuditCollaboratorRemove AuditAction = "album_collaborator_remove"
+	AuditShareLinkCreate    AuditAction = "share_link_create"
+	AuditShareLinkRevoke    AuditAction = "share_link_revoke"
+	AuditPhotoDelete        AuditAction = "photo_delete"
+	AuditPhotoState         AuditAction = "photo_state"
+	AuditPhotoStars         AuditAction = "photo_stars"
+	AuditPhotoDownload      AuditAction = "photo_download"
+	AuditUserCreate         AuditAction = "user_create"
+	AuditUserUpdate         AuditAction = "user_update"
+	AuditUserDelete         AuditAction = "user_delete"
+	AuditUserSuspend        AuditAction = "user_suspend"
+	AuditUserUnsuspend      AuditAction = "user_unsuspend"
+	AuditUserPasswordSet    AuditAction = "user_password_set"
+	AuditUserPasswordReset  AuditAction = "user_password_reset_forced"
+	AuditUserMFAReset       AuditAction = "user_mfa_reset"
+	AuditUserUnlock         AuditAction = "user_unlock"
+	AuditIPUnlock           AuditAction = "ip_unlock"
+	AuditSessionsRevoke     AuditAction = "sessions_revoke"
+	AuditAPITokenCreate     AuditAction = "api_token_create"
+	AuditAPITokenRevoke     AuditAction = "api_token_revoke"
+	AuditOrgInvite          AuditAction = "organization_invite"
+	AuditOrgInviteRevoke    AuditAction = "organization_invite_revoke"
+	AuditOrgInviteAccept    AuditAction = "organization_invite_accept"
+	AuditOrgMemberRole      AuditAction = "organization_member_role"
+	AuditOrgMemberRemove    AuditAction = "organization_member_remove"
+	AuditAlbumCaptureShift  AuditAction = "album_capture_shift"
+	AuditAlbumExport        AuditAction = "album_export"
+	AuditPhotographerExport AuditAction = "photographer_export"
+)
+
+// What an audit entry is about.
+const (
+	AuditTargetUser      = "user"
+	AuditTargetSetting   = "setting"
+	AuditTargetAlbum     = "album"
+	AuditTargetPhoto     = "photo"
+	AuditTargetShareLink = "share_link"
+	AuditTargetIP        = "ip_address"
+	AuditTargetAPIToken  = "api_token"
+)
+
+// AuditValues holds the fields an action changed, before or after it.
+type AuditValues map[string]interface{}
+
+func (v AuditValues) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func (v *AuditValues) Scan(value interface{}) error {
+	if value == nil {
+		*v = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(bytes, v)
+}
+
+// AuditEntry records who did what to which target, and from where. Entries
+// are never changed once written. The actor is either a user or, for
+// guests, a share link.
+type AuditEntry struct {
+	ID          int64       `json:"id"`
+	ActorID     *int64      `json:"actorId,omitempty"`
+	ActorName   string      `json:"actorName"`
+	ShareLinkID *int        `json:"shareLinkId,omitempty"`
+	Action      AuditAction `json:"action"`
+	TargetType  string      `json:"targetType"`
+	TargetID    string      `json:"targetId"`
+	AlbumID     *int        `json:"albumId,omitempty"`
+	Before      AuditValues `json:"before,omitempty"`
+	After       AuditValues `json:"after,omitempty"`
+	IPAddress   *string     `json:"ipAddress,omitempty"`
+	UserAgent   *string     `json:"userAgent,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+// AuditFilter narrows down the audit log. Zero values match everything.
+type AuditFilter struct {
+	ActorID int64
+	AlbumID int
+	Action  AuditAction
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+	Offset  int
+}
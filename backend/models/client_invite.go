@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ClientInvite invites someone by email to become a photographer's client.
+// Following the emailed link either creates their account with a password
+// of their own choosing or links an account they already have. Only a hash
+// of the token is stored.
+type ClientInvite struct {
+	ID             int        `json:"id"`
+	PhotographerID int64      `json:"photographerId"`
+	Email          string     `json:"email"`
+	FriendlyName   string     `json:"friendlyName"`
+	AlbumIDs       []int64    `json:"albumIds"`
+	TokenHash      string     `json:"-"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	SentAt         time.Time  `json:"sentAt"`
+	AcceptedAt     *time.Time `json:"acceptedAt,omitempty"`
+	AcceptedBy     *int64     `json:"acceptedBy,omitempty"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// Open reports whether the invite was neither accepted nor revoked. An open
+// invite may still have expired; resending it issues a fresh link.
+func (i *ClientInvite) Open() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil
+}
+
+// Usable reports whether the invite link can be followed at the given time.
+func (i *ClientInvite) Usable(now time.Time) bool {
+	return i.Open() && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+const (
+	LoginAttemptSuccess         = "success"
+	LoginAttemptUnknownUser     = "unknown_user"
+	LoginAttemptInvalidPassword = "invalid_password"
+	LoginAttemptInvalidMFACode  = "invalid_mfa_code"
+	LoginAttemptThrottled       = "throttled"
+	// The password was right, but the account may not sign in yet.
+	LoginAttemptEmailUnverified       = "email_unverified"
+	LoginAttemptAccountSuspended      = "account_suspended"
+	LoginAttemptPasswordResetRequired = "password_reset_required"
+
+	// Failed logins are counted per account and per client IP.
+	ThrottleKindUser = "user"
+	ThrottleKindIP   = "ip"
+)
+
+// LoginAttempt is an entry in the login audit log.
+type LoginAttempt struct {
+	ID         int64     `json:"id"`
+	UserID     *int64    `json:"userId,omitempty"`
+	Identifier string    `json:"identifier"`
+	IPAddress  *string   `json:"ipAddress,omitempty"`
+	UserAgent  *string   `json:"userAgent,omitempty"`
+	Success    bool      `json:"success"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// LoginAttemptFilter narrows down the audit log. Zero values match
+// everything.
+type LoginAttemptFilter struct {
+	UserID    int64
+	IPAddress string
+	Limit     int
+}
+
+// LoginThrottle counts recent failed logins for an account or IP address.
+type LoginThrottle struct {
+	Kind          string     `json:"kind"`
+	Subject       string     `json:"subject"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+}
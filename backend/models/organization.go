@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+type OrganizationRole string
+
+const (
+	// OrgRoleOwner can do everything, including changing other owners and
+	// deleting the organization.
+	OrgRoleOwner OrganizationRole = "owner"
+	// OrgRoleManager manages photographers, settings and every album in
+	// the organization.
+	OrgRoleManager OrganizationRole = "manager"
+	// OrgRolePhotographer works on their own albums and shares the
+	// organization's clients.
+	OrgRolePhotographer OrganizationRole = "photographer"
+)
+
+// Organization is a studio whose photographers share clients, settings and
+// quotas. Installs with a single photographer need none.
+type Organization struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Quotas are set by admins. Nil means unlimited.
+	MaxMembers *int      `json:"maxMembers,omitempty"`
+	MaxAlbums  *int      `json:"maxAlbums,omitempty"`
+	MaxPhotos  *int      `json:"maxPhotos,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type OrganizationMember struct {
+	ID             int              `json:"id"`
+	OrganizationID int              `json:"organizationId"`
+	UserID         int64            `json:"userId"`
+	Role           OrganizationRole `json:"role"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	// Username, Email and FriendlyName are filled in when listing members.
+	Username     string `json:"username,omitempty"`
+	Email        string `json:"email,omitempty"`
+	FriendlyName string `json:"friendlyName,omitempty"`
+}
+
+// CanManage reports whether the member may manage the organization's
+// members, settings and albums.
+func (m *OrganizationMember) CanManage() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleManager
+}
+
+// OrganizationInvite asks a photographer to join an organization. Nothing
+// changes for them until they accept it.
+type OrganizationInvite struct {
+	ID             int              `json:"id"`
+	OrganizationID int              `json:"organizationId"`
+	UserID         int64            `json:"userId"`
+	Role           OrganizationRole `json:"role"`
+	InvitedBy      *int64           `json:"invitedBy,omitempty"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	// OrganizationName and Username are filled in when listing invites.
+	OrganizationName string `json:"organizationName,omitempty"`
+	Username         string `json:"username,omitempty"`
+}
+
+// OrganizationUsage is how much of its quotas an organization uses.
+type OrganizationUsage struct {
+	Members int `json:"members"`
+	Albums  int `json:"albums"`
+	Photos  int `json:"photos"`
+}
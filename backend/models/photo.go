@@ -15,16 +15,25 @@ const (
 )
 
 type Photo struct {
-	ID              int             `json:"id"`
-	AlbumID         int             `json:"albumId"`
-	Filename        string          `json:"filename"`
-	Title           *string         `json:"title,omitempty"`
-	DateTime        *time.Time      `json:"dateTime,omitempty"`
-	ExifData        ExifData        `json:"exifData,omitempty"`
-	PickRejectState PickRejectState `json:"pickRejectState"`
-	Stars           int             `json:"stars"`
-	CreatedAt       time.Time       `json:"createdAt"`
-	UpdatedAt       time.Time       `json:"updatedAt"`
+	ID               int             `json:"id"`
+	AlbumID          int             `json:"albumId"`
+	Filename         string          `json:"filename"`
+	OriginalFilename *string         `json:"originalFilename,omitempty"`
+	Title            *string         `json:"title,omitempty"`
+	Caption          *string         `json:"caption,omitempty"`
+	Keywords         []string        `json:"keywords,omitempty"`
+	CountryCode      *string         `json:"countryCode,omitempty"`
+	Country          *string         `json:"country,omitempty"`
+	Region           *string         `json:"region,omitempty"`
+	City             *string         `json:"city,omitempty"`
+	DateTime         *time.Time      `json:"dateTime,omitempty"`
+	TimeShift        int             `json:"timeShift"`
+	ExifData         ExifData        `json:"exifData,omitempty"`
+	PickRejectState  PickRejectState `json:"pickRejectState"`
+	Stars            int             `json:"stars"`
+	UploadedBy       *int64          `json:"uploadedBy,omitempty"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
 }
 
 type ExifData map[string]interface{}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Session is one signed-in device. Access tokens carry its ID and stop
+// working as soon as it is revoked.
+type Session struct {
+	ID            string     `json:"id"`
+	UserID        int64      `json:"userId"`
+	IPAddress     *string    `json:"ipAddress,omitempty"`
+	UserAgent     *string    `json:"userAgent,omitempty"`
+	Device        *string    `json:"device,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	LastUsedAt    time.Time  `json:"lastUsedAt"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	RevokedAt     *time.Time `json:"revokedAt,omitempty"`
+	RevokedReason *string    `json:"revokedReason,omitempty"`
+
+	// Current marks the session the listing request was made with. It is not
+	// stored.
+	Current bool `json:"current"`
+}
+
+// Active reports whether the session can still be used at the given time.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshToken is a single-use refresh token. Only a hash of the token is
+// stored; a token that comes back after it was used means it leaked.
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	SessionID string     `json:"sessionId"`
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+type ShareMode string
+
+const (
+	// ShareModeView only lets guests look at the photos.
+	ShareModeView ShareMode = "view"
+	// ShareModePick also lets guests pick and reject photos.
+	ShareModePick ShareMode = "pick"
+)
+
+const (
+	ShareAccessOpen          = "open"
+	ShareAccessWrongPassword = "wrong_password"
+	ShareAccessDownload      = "download"
+	ShareAccessPick          = "pick"
+)
+
+// ShareLink gives people without an account access to an album, or to a
+// selection of its photos when PhotoIDs is set. Only a hash of the token is
+// stored; TokenPrefix is kept so photographers can tell their links apart.
+type ShareLink struct {
+	ID                int        `json:"id"`
+	AlbumID           int        `json:"albumId"`
+	CreatedBy         int64      `json:"createdBy"`
+	Name              string     `json:"name"`
+	TokenHash         string     `json:"-"`
+	TokenPrefix       string     `json:"tokenPrefix"`
+	PhotoIDs          []int64    `json:"photoIds,omitempty"`
+	PasswordHash      *string    `json:"-"`
+	PasswordProtected bool       `json:"passwordProtected"`
+	Mode              ShareMode  `json:"mode"`
+	AllowDownload     bool       `json:"allowDownload"`
+	ExpiresAt         *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt         *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+}
+
+// Active reports whether the link can still be used at the given time.
+func (l *ShareLink) Active(now time.Time) bool {
+	return l.RevokedAt == nil && (l.ExpiresAt == nil || now.Before(*l.ExpiresAt))
+}
+
+// Includes reports whether the link shares the photo.
+func (l *ShareLink) Includes(photo *Photo) bool {
+	if photo.AlbumID != l.AlbumID {
+		return false
+	}
+	return l.PhotoIDs == nil || slices.Contains(l.PhotoIDs, int64(photo.ID))
+}
+
+// ShareLinkAccess is an entry in a share link's access log.
+type ShareLinkAccess struct {
+	ID          int64     `json:"id"`
+	ShareLinkID int       `json:"shareLinkId"`
+	Action      string    `json:"action"`
+	PhotoID     *int      `json:"photoId,omitempty"`
+	IPAddress   *string   `json:"ipAddress,omitempty"`
+	UserAgent   *string   `json:"userAgent,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -11,14 +11,27 @@ const (
 )
 
 type User struct {
-	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"`
-	Email        string    `json:"email"`
-	FriendlyName string    `json:"friendlyName"`
-	Role         UserRole  `json:"role"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	ID              int64      `json:"id"`
+	Username        string     `json:"username"`
+	PasswordHash    string     `json:"-"`
+	Email           string     `json:"email"`
+	FriendlyName    string     `json:"friendlyName"`
+	Role            UserRole   `json:"role"`
+	AvatarID        *string    `json:"avatarId,omitempty"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	// SuspendedAt is set while an admin has disabled the account.
+	SuspendedAt      *time.Time `json:"suspendedAt,omitempty"`
+	SuspensionReason *string    `json:"suspensionReason,omitempty"`
+	// PasswordResetRequired blocks sign-in until the password is reset
+	// through an emailed link.
+	PasswordResetRequired bool      `json:"passwordResetRequired"`
+	CreatedAt             time.Time `json:"createdAt"`
+	UpdatedAt             time.Time `json:"updatedAt"`
+}
+
+// Suspended reports whether an admin has disabled the account.
+func (u *User) Suspended() bool {
+	return u.SuspendedAt != nil
 }
 
 type PhotographerClient struct {
@@ -27,3 +40,18 @@ type PhotographerClient struct {
 	ClientID       int64     `json:"client_id"`
 	CreatedAt      time.Time `json:"created_at"`
 }
+
+// UserFilter selects users for the admin user list. Empty fields match
+// everything.
+type UserFilter struct {
+	Role   UserRole
+	Query  string
+	Status string
+	Limit  int
+	Offset int
+}
+
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+)
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's subject claim.
+type UserIdentity struct {
+	ID          int       `json:"id"`
+	UserID      int64     `json:"userId"`
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       *string   `json:"email,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastLoginAt time.Time `json:"lastLoginAt"`
+}
+
+// OIDCAuthRequest remembers the PKCE verifier and nonce of a sign-in that was
+// sent to an identity provider, keyed by a hash of its state parameter.
+type OIDCAuthRequest struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// UserMFA holds a user's TOTP enrollment. The secret is stored encrypted and
+// the enrollment only counts once EnabledAt is set, after the user proved
+// their authenticator produces matching codes.
+type UserMFA struct {
+	UserID       int64      `json:"userId"`
+	TOTPSecret   string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabledAt,omitempty"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+func (m *UserMFA) Enabled() bool {
+	return m != nil && m.EnabledAt != nil
+}
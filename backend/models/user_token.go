@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type UserTokenPurpose string
+
+const (
+	TokenPurposeEmailChange       UserTokenPurpose = "email_change"
+	TokenPurposeEmailVerification UserTokenPurpose = "email_verification"
+	TokenPurposePasswordReset     UserTokenPurpose = "password_reset"
+)
+
+// UserToken is a single-use secret sent to a user out of band, such as an
+// email confirmation link. Only a hash of the token is stored.
+type UserToken struct {
+	ID        int              `json:"id"`
+	UserID    int64            `json:"userId"`
+	Purpose   UserTokenPurpose `json:"purpose"`
+	TokenHash string           `json:"-"`
+	Payload   *string          `json:"-"`
+	ExpiresAt time.Time        `json:"expiresAt"`
+	UsedAt    *time.Time       `json:"usedAt,omitempty"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
@@ -0,0 +1,334 @@
+// Package oidc implements the parts of OpenID Connect needed to sign users in
+// with an external identity provider: discovery, the authorization code flow
+// with PKCE, and ID token verification.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/suipic/backend/config"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	clockSkew      = time.Minute
+	maxResponse    = 1 << 20
+)
+
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Provider talks to one identity provider. Its discovery document is fetched
+// on first use, so the server starts even while the provider is unreachable.
+type Provider struct {
+	cfg         *config.OIDCProviderConfig
+	redirectURL string
+	client      *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Token is the response of the token endpoint.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// Claims are the identity claims of a verified ID token, merged with the
+// userinfo response when the ID token leaves the email out.
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Raw               map[string]interface{}
+}
+
+func NewProvider(cfg *config.OIDCProviderConfig, redirectURL string) *Provider {
+	return &Provider{
+		cfg:         cfg,
+		redirectURL: redirectURL,
+		client:      &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (p *Provider) Config() *config.OIDCProviderConfig {
+	return p.cfg
+}
+
+// AuthCodeURL returns the provider URL to send the browser to.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.cfg.ClientID)
+	values.Set("redirect_uri", p.redirectURL)
+	values.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", codeChallenge)
+	values.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	token := &Token{}
+	if err := p.do(req, token); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+	return token, nil
+}
+
+// Verify checks an ID token's signature, issuer, audience, expiry and nonce,
+// and returns its claims. When the token has no email claim and the
+// provider has a userinfo endpoint, the email is read from there.
+func (p *Provider) Verify(ctx context.Context, token *Token, nonce string) (*Claims, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token.IDToken, raw, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, doc, kid)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if claimNonce, _ := raw["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("invalid id token: nonce mismatch")
+	}
+	if aud, _ := raw.GetAudience(); len(aud) > 1 {
+		if azp, _ := raw["azp"].(string); azp != p.cfg.ClientID {
+			return nil, fmt.Errorf("invalid id token: authorized party mismatch")
+		}
+	}
+
+	claims := newClaims(raw)
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid id token: no subject")
+	}
+
+	if claims.Email == "" && doc.UserinfoEndpoint != "" && token.AccessToken != "" {
+		if err := p.mergeUserinfo(ctx, doc, token.AccessToken, claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+func (p *Provider) mergeUserinfo(ctx context.Context, doc *discovery, accessToken string, claims *Claims) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, doc.UserinfoEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	info := map[string]interface{}{}
+	if err := p.do(req, &info); err != nil {
+		return fmt.Errorf("userinfo request failed: %w", err)
+	}
+
+	// The userinfo response is not signed, so it only counts when it is
+	// about the same subject as the verified ID token.
+	if sub, _ := info["sub"].(string); sub != claims.Subject {
+		return fmt.Errorf("userinfo subject does not match id token")
+	}
+	for key, value := range info {
+		if _, ok := claims.Raw[key]; !ok {
+			claims.Raw[key] = value
+		}
+	}
+	*claims = *newClaims(claims.Raw)
+	return nil
+}
+
+// key returns the verification key with the given ID, fetching the key set
+// again when the provider may have rotated its keys.
+func (p *Provider) key(ctx context.Context, doc *discovery, kid string) (interface{}, error) {
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+
+	if keys != nil {
+		if key, ok := keys.find(kid); ok {
+			return key, nil
+		}
+		if !keys.stale() {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+
+	keys, err := p.fetchKeys(ctx, doc.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	if key, ok := keys.find(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	doc := &discovery{}
+	if err := p.do(req, doc); err != nil {
+		return nil, fmt.Errorf("discovery failed for %s: %w", p.cfg.Issuer, err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, p.cfg.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document for %s is incomplete", p.cfg.Issuer)
+	}
+
+	p.discovery = doc
+	return doc, nil
+}
+
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			return fmt.Errorf("%s: %s", oauthErr.Error, oauthErr.Description)
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+func newClaims(raw map[string]interface{}) *Claims {
+	str := func(key string) string {
+		value, _ := raw[key].(string)
+		return value
+	}
+
+	// Some providers send email_verified as a string.
+	verified := false
+	switch value := raw["email_verified"].(type) {
+	case bool:
+		verified = value
+	case string:
+		verified = value == "true"
+	}
+
+	return &Claims{
+		Subject:           str("sub"),
+		Email:             str("email"),
+		EmailVerified:     verified,
+		Name:              str("name"),
+		PreferredUsername: str("preferred_username"),
+		Raw:               raw,
+	}
+}
+
+// Values returns a claim as a list of strings, accepting both a single
+// string and an array, which is how group and role claims usually look.
+func (c *Claims) Values(name string) []string {
+	switch value := c.Raw[name].(type) {
+	case string:
+		return strings.Fields(strings.ReplaceAll(value, ",", " "))
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
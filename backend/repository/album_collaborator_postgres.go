@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/suipic/backend/models"
+)
+
+type PostgresAlbumCollaboratorRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresAlbumCollaboratorRepository(db *sql.DB) *PostgresAlbumCollaboratorRepository {
+	return &PostgresAlbumCollaboratorRepository{db: db}
+}
+
+func (r *PostgresAlbumCollaboratorRepository) Create(ctx context.Context, collaborator *models.AlbumCollaborator) error {
+	query := `
+		INSERT INTO album_collaborators (album_id, user_id, added_by, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		collaborator.AlbumID,
+		collaborator.UserID,
+		collaborator.AddedBy,
+	).Scan(&collaborator.ID, &collaborator.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create album collaborator: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the user as a collaborator on the album. It reports false
+// when they were not one.
+func (r *PostgresAlbumCollaboratorRepository) Delete(ctx context.Context, albumID, userID int) (bool, error) {
+	query := `DELETE FROM album_collaborators WHERE album_id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, albumID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete album collaborator: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *PostgresAlbumCollaboratorRepository) GetByAlbum(ctx context.Context, albumID int) ([]*models.AlbumCollaborator, error) {
+	query := `
+		SELECT ac.id, ac.album_id, ac.user_id, ac.added_by, ac.created_at, u.username, u.friendly_name
+		FROM album_collaborators ac
+		JOIN users u ON u.id = ac.user_id
+		WHERE ac.album_id = $1
+		ORDER BY ac.created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, albumID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get album collaborators: %w", err)
+	}
+	defer rows.Close()
+
+	var collaborators []*models.AlbumCollaborator
+	for rows.Next() {
+		collaborator := &models.AlbumCollaborator{}
+		err := rows.Scan(
+			&collaborator.ID,
+			&collaborator.AlbumID,
+			&collaborator.UserID,
+			&collaborator.AddedBy,
+			&collaborator.CreatedAt,
+			&collaborator.Username,
+			&collaborator.FriendlyName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan album collaborator: %w", err)
+		}
+		collaborators = append(collaborators, collaborator)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating album collaborators: %w", err)
+	}
+
+	return collaborators, nil
+}
+
+func (r *PostgresAlbumCollaboratorRepository) IsCollaborator(ctx context.Context, albumID, userID int) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM album_collaborators
+			WHERE album_id = $1 AND user_id = $2
+		)
+	`
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, albumID, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if user is an album collaborator: %w", err)
+	}
+	return exists, nil
+}
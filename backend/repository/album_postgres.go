@@ -18,8 +18,8 @@ func NewPostgresAlbumRepository(db *sql.DB) *PostgresAlbumRepository {
 
 func (r *PostgresAlbumRepository) Create(ctx context.Context, album *models.Album) error {
 	query := `
-		INSERT INTO albums (title, date_taken, description, location, custom_fields, thumbnail_photo_id, photographer_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		INSERT INTO albums (title, date_taken, description, location, custom_fields, gps_policy, timezone, thumbnail_photo_id, photographer_id, organization_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
 	err := r.db.QueryRowContext(
@@ -30,8 +30,11 @@ func (r *PostgresAlbumRepository) Create(ctx context.Context, album *models.Albu
 		album.Description,
 		album.Location,
 		album.CustomFields,
+		album.GPSPolicy,
+		album.Timezone,
 		album.ThumbnailPhotoID,
 		album.PhotographerID,
+		album.OrganizationID,
 	).Scan(&album.ID, &album.CreatedAt, &album.UpdatedAt)
 
 	if err != nil {
@@ -43,7 +46,7 @@ func (r *PostgresAlbumRepository) Create(ctx context.Context, album *models.Albu
 
 func (r *PostgresAlbumRepository) GetByID(ctx context.Context, id int) (*models.Album, error) {
 	query := `
-		SELECT id, title, date_taken, description, location, custom_fields, thumbnail_photo_id, photographer_id, created_at, updated_at
+		SELECT id, title, date_taken, description, location, custom_fields, gps_policy, timezone, thumbnail_photo_id, photographer_id, organization_id, created_at, updated_at
 		FROM albums
 		WHERE id = $1
 	`
@@ -55,8 +58,11 @@ func (r *PostgresAlbumRepository) GetByID(ctx context.Context, id int) (*models.
 		&album.Description,
 		&album.Location,
 		&album.CustomFields,
+		&album.GPSPolicy,
+		&album.Timezone,
 		&album.ThumbnailPhotoID,
 		&album.PhotographerID,
+		&album.OrganizationID,
 		&album.CreatedAt,
 		&album.UpdatedAt,
 	)
@@ -74,8 +80,8 @@ func (r *PostgresAlbumRepository) GetByID(ctx context.Context, id int) (*models.
 func (r *PostgresAlbumRepository) Update(ctx context.Context, album *models.Album) error {
 	query := `
 		UPDATE albums
-		SET title = $1, date_taken = $2, description = $3, location = $4, custom_fields = $5, thumbnail_photo_id = $6, photographer_id = $7, updated_at = NOW()
-		WHERE id = $8
+		SET title = $1, date_taken = $2, description = $3, location = $4, custom_fields = $5, gps_policy = $6, timezone = $7, thumbnail_photo_id = $8, photographer_id = $9, updated_at = NOW()
+		WHERE id = $10
 		RETURNING updated_at
 	`
 	err := r.db.QueryRowContext(
@@ -86,6 +92,8 @@ func (r *PostgresAlbumRepository) Update(ctx context.Context, album *models.Albu
 		album.Description,
 		album.Location,
 		album.CustomFields,
+		album.GPSPolicy,
+		album.Timezone,
 		album.ThumbnailPhotoID,
 		album.PhotographerID,
 		album.ID,
@@ -122,7 +130,7 @@ func (r *PostgresAlbumRepository) Delete(ctx context.Context, id int) error {
 
 func (r *PostgresAlbumRepository) List(ctx context.Context, limit, offset int) ([]*models.Album, error) {
 	query := `
-		SELECT id, title, date_taken, description, location, custom_fields, thumbnail_photo_id, photographer_id, created_at, updated_at
+		SELECT id, title, date_taken, description, location, custom_fields, gps_policy, timezone, thumbnail_photo_id, photographer_id, organization_id, created_at, updated_at
 		FROM albums
 		ORDER BY id
 		LIMIT $1 OFFSET $2
@@ -143,8 +151,11 @@ func (r *PostgresAlbumRepository) List(ctx context.Context, limit, offset int) (
 			&album.Description,
 			&album.Location,
 			&album.CustomFields,
+			&album.GPSPolicy,
+			&album.Timezone,
 			&album.ThumbnailPhotoID,
 			&album.PhotographerID,
+			&album.OrganizationID,
 			&album.CreatedAt,
 			&album.UpdatedAt,
 		)
@@ -163,7 +174,7 @@ func (r *PostgresAlbumRepository) List(ctx context.Context, limit, offset int) (
 
 func (r *PostgresAlbumRepository) GetByPhotographer(ctx context.Context, photographerID int) ([]*models.Album, error) {
 	query := `
-		SELECT id, title, date_taken, description, location, custom_fields, thumbnail_photo_id, photographer_id, created_at, updated_at
+		SELECT id, title, date_taken, description, location, custom_fields, gps_policy, timezone, thumbnail_photo_id, photographer_id, organization_id, created_at, updated_at
 		FROM albums
 		WHERE photographer_id = $1
 		ORDER BY created_at DESC
@@ -184,8 +195,11 @@ func (r *PostgresAlbumRepository) GetByPhotographer(ctx context.Context, photogr
 			&album.Description,
 			&album.Location,
 			&album.CustomFields,
+			&album.GPSPolicy,
+			&album.Timezone,
 			&album.ThumbnailPhotoID,
 			&album.PhotographerID,
+			&album.OrganizationID,
 			&album.CreatedAt,
 			&album.UpdatedAt,
 		)
@@ -203,7 +217,7 @@ func (r *PostgresAlbumRepository) GetByPhotographer(ctx context.Context, photogr
 }
 func (r *PostgresAlbumRepository) GetByUserID(ctx context.Context, userID int) ([]*models.Album, error) {
 	query := `
-		SELECT a.id, a.title, a.date_taken, a.description, a.location, a.custom_fields, a.thumbnail_photo_id, a.photographer_id, a.created_at, a.updated_at
+		SELECT a.id, a.title, a.date_taken, a.description, a.location, a.custom_fields, a.gps_policy, a.timezone, a.thumbnail_photo_id, a.photographer_id, a.organization_id, a.created_at, a.updated_at
 		FROM albums a
 		JOIN album_users au ON a.id = au.album_id
 		WHERE au.user_id = $1
@@ -225,8 +239,11 @@ func (r *PostgresAlbumRepository) GetByUserID(ctx context.Context, userID int) (
 			&album.Description,
 			&album.Location,
 			&album.CustomFields,
+			&album.GPSPolicy,
+			&album.Timezone,
 			&album.ThumbnailPhotoID,
 			&album.PhotographerID,
+			&album.OrganizationID,
 			&album.CreatedAt,
 			&album.UpdatedAt,
 		)
@@ -242,3 +259,105 @@ func (r *PostgresAlbumRepository) GetByUserID(ctx context.Context, userID int) (
 
 	return albums, nil
 }
+
+// GetByCollaborator returns the albums the user collaborates on.
+func (r *PostgresAlbumRepository) GetByCollaborator(ctx context.Context, userID int) ([]*models.Album, error) {
+	query := `
+		SELECT a.id, a.title, a.date_taken, a.description, a.location, a.custom_fields, a.gps_policy, a.timezone, a.thumbnail_photo_id, a.photographer_id, a.organization_id, a.created_at, a.updated_at
+		FROM albums a
+		JOIN album_collaborators ac ON a.id = ac.album_id
+		WHERE ac.user_id = $1
+		ORDER BY a.created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get albums by collaborator: %w", err)
+	}
+	defer rows.Close()
+
+	var albums []*models.Album
+	for rows.Next() {
+		album := &models.Album{}
+		err := rows.Scan(
+			&album.ID,
+			&album.Title,
+			&album.DateTaken,
+			&album.Description,
+			&album.Location,
+			&album.CustomFields,
+			&album.GPSPolicy,
+			&album.Timezone,
+			&album.ThumbnailPhotoID,
+			&album.PhotographerID,
+			&album.OrganizationID,
+			&album.CreatedAt,
+			&album.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan album: %w", err)
+		}
+		albums = append(albums, album)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating albums: %w", err)
+	}
+
+	return albums, nil
+}
+
+// GetByOrganization returns the albums of every photographer in the
+// organization.
+func (r *PostgresAlbumRepository) GetByOrganization(ctx context.Context, organizationID int) ([]*models.Album, error) {
+	query := `
+		SELECT id, title, date_taken, description, location, custom_fields, gps_policy, timezone, thumbnail_photo_id, photographer_id, organization_id, created_at, updated_at
+		FROM albums
+		WHERE organization_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get albums by organization: %w", err)
+	}
+	defer rows.Close()
+
+	var albums []*models.Album
+	for rows.Next() {
+		album := &models.Album{}
+		err := rows.Scan(
+			&album.ID,
+			&album.Title,
+			&album.DateTaken,
+			&album.Description,
+			&album.Location,
+			&album.CustomFields,
+			&album.GPSPolicy,
+			&album.Timezone,
+			&album.ThumbnailPhotoID,
+			&album.PhotographerID,
+			&album.OrganizationID,
+			&album.CreatedAt,
+			&album.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan album: %w", err)
+		}
+		albums = append(albums, album)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating albums: %w", err)
+	}
+
+	return albums, nil
+}
+
+// SetOrganizationForPhotographer moves the photographer's albums into the
+// organization, or out of any organization when organizationID is nil.
+func (r *PostgresAlbumRepository) SetOrganizationForPhotographer(ctx context.Context, photographerID int, organizationID *int) error {
+	query := `UPDATE albums SET organization_id = $1, updated_at = NOW() WHERE photographer_id = $2`
+	if _, err := r.db.ExecContext(ctx, query, organizationID, photographerID); err != nil {
+		return fmt.Errorf("failed to update album organization: %w", err)
+	}
+	return nil
+}
@@ -16,10 +16,27 @@ func NewPostgresAlbumUserRepository(db *sql.DB) *PostgresAlbumUserRepository {
 	return &PostgresAlbumUserRepository{db: db}
 }
 
+const albumUserColumns = `id, album_id, user_id, can_comment, can_rate, can_download, can_share, created_at`
+
+func scanAlbumUser(row interface{ Scan(...interface{}) error }) (*models.AlbumUser, error) {
+	albumUser := &models.AlbumUser{}
+	err := row.Scan(
+		&albumUser.ID,
+		&albumUser.AlbumID,
+		&albumUser.UserID,
+		&albumUser.CanComment,
+		&albumUser.CanRate,
+		&albumUser.CanDownload,
+		&albumUser.CanShare,
+		&albumUser.CreatedAt,
+	)
+	return albumUser, err
+}
+
 func (r *PostgresAlbumUserRepository) Create(ctx context.Context, albumUser *models.AlbumUser) error {
 	query := `
-		INSERT INTO album_users (album_id, user_id, created_at)
-		VALUES ($1, $2, NOW())
+		INSERT INTO album_users (album_id, user_id, can_comment, can_rate, can_download, can_share, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
 		RETURNING id, created_at
 	`
 	err := r.db.QueryRowContext(
@@ -27,6 +44,10 @@ func (r *PostgresAlbumUserRepository) Create(ctx context.Context, albumUser *mod
 		query,
 		albumUser.AlbumID,
 		albumUser.UserID,
+		albumUser.CanComment,
+		albumUser.CanRate,
+		albumUser.CanDownload,
+		albumUser.CanShare,
 	).Scan(&albumUser.ID, &albumUser.CreatedAt)
 
 	if err != nil {
@@ -38,17 +59,11 @@ func (r *PostgresAlbumUserRepository) Create(ctx context.Context, albumUser *mod
 
 func (r *PostgresAlbumUserRepository) GetByID(ctx context.Context, id int) (*models.AlbumUser, error) {
 	query := `
-		SELECT id, album_id, user_id, created_at
+		SELECT ` + albumUserColumns + `
 		FROM album_users
 		WHERE id = $1
 	`
-	albumUser := &models.AlbumUser{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&albumUser.ID,
-		&albumUser.AlbumID,
-		&albumUser.UserID,
-		&albumUser.CreatedAt,
-	)
+	albumUser, err := scanAlbumUser(r.db.QueryRowContext(ctx, query, id))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -100,7 +115,7 @@ func (r *PostgresAlbumUserRepository) DeleteByAlbumAndUser(ctx context.Context,
 
 func (r *PostgresAlbumUserRepository) List(ctx context.Context, limit, offset int) ([]*models.AlbumUser, error) {
 	query := `
-		SELECT id, album_id, user_id, created_at
+		SELECT ` + albumUserColumns + `
 		FROM album_users
 		ORDER BY id
 		LIMIT $1 OFFSET $2
@@ -113,13 +128,7 @@ func (r *PostgresAlbumUserRepository) List(ctx context.Context, limit, offset in
 
 	var albumUsers []*models.AlbumUser
 	for rows.Next() {
-		albumUser := &models.AlbumUser{}
-		err := rows.Scan(
-			&albumUser.ID,
-			&albumUser.AlbumID,
-			&albumUser.UserID,
-			&albumUser.CreatedAt,
-		)
+		albumUser, err := scanAlbumUser(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan album user: %w", err)
 		}
@@ -135,7 +144,7 @@ func (r *PostgresAlbumUserRepository) List(ctx context.Context, limit, offset in
 
 func (r *PostgresAlbumUserRepository) GetByAlbum(ctx context.Context, albumID int) ([]*models.AlbumUser, error) {
 	query := `
-		SELECT id, album_id, user_id, created_at
+		SELECT ` + albumUserColumns + `
 		FROM album_users
 		WHERE album_id = $1
 		ORDER BY created_at
@@ -148,13 +157,7 @@ func (r *PostgresAlbumUserRepository) GetByAlbum(ctx context.Context, albumID in
 
 	var albumUsers []*models.AlbumUser
 	for rows.Next() {
-		albumUser := &models.AlbumUser{}
-		err := rows.Scan(
-			&albumUser.ID,
-			&albumUser.AlbumID,
-			&albumUser.UserID,
-			&albumUser.CreatedAt,
-		)
+		albumUser, err := scanAlbumUser(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan album user: %w", err)
 		}
@@ -170,7 +173,7 @@ func (r *PostgresAlbumUserRepository) GetByAlbum(ctx context.Context, albumID in
 
 func (r *PostgresAlbumUserRepository) GetByUser(ctx context.Context, userID int) ([]*models.AlbumUser, error) {
 	query := `
-		SELECT id, album_id, user_id, created_at
+		SELECT ` + albumUserColumns + `
 		FROM album_users
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -183,13 +186,7 @@ func (r *PostgresAlbumUserRepository) GetByUser(ctx context.Context, userID int)
 
 	var albumUsers []*models.AlbumUser
 	for rows.Next() {
-		albumUser := &models.AlbumUser{}
-		err := rows.Scan(
-			&albumUser.ID,
-			&albumUser.AlbumID,
-			&albumUser.UserID,
-			&albumUser.CreatedAt,
-		)
+		albumUser, err := scanAlbumUser(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan album user: %w", err)
 		}
@@ -211,6 +208,26 @@ func (r *PostgresAlbumUserRepository) DeleteByAlbum(ctx context.Context, albumID
 	}
 	return nil
 }
+
+// GetByAlbumAndUser returns the user's assignment to the album, or nil when
+// they are not assigned to it.
+func (r *PostgresAlbumUserRepository) GetByAlbumAndUser(ctx context.Context, albumID, userID int) (*models.AlbumUser, error) {
+	query := `
+		SELECT ` + albumUserColumns + `
+		FROM album_users
+		WHERE album_id = $1 AND user_id = $2
+	`
+	albumUser, err := scanAlbumUser(r.db.QueryRowContext(ctx, query, albumID, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get album user: %w", err)
+	}
+
+	return albumUser, nil
+}
+
 func (r *PostgresAlbumUserRepository) IsUserInAlbum(ctx context.Context, userID, albumID int) (bool, error) {
 	query := `
 		SELECT EXISTS(
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/suipic/backend/models"
+)
+
+type PostgresAPITokenRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresAPITokenRepository(db *sql.DB) *PostgresAPITokenRepository {
+	return &PostgresAPITokenRepository{db: db}
+}
+
+func (r *PostgresAPITokenRepository) Create(ctx context.Context, token *models.APIToken) error {
+	query := `
+		INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.TokenPrefix,
+		pq.Array(token.Scopes),
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create api token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresAPITokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	query := `
+		SELECT id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, last_used_ip, revoked_at, created_at
+		FROM api_tokens
+		WHERE token_hash = $1
+	`
+	token := &models.APIToken{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.TokenPrefix,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.LastUsedIP,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ListActiveByUser returns the user's tokens that are neither revoked nor
+// expired, newest first.
+func (r *PostgresAPITokenRepository) ListActiveByUser(ctx context.Context, userID int64) ([]*models.APIToken, error) {
+	query := `
+		SELECT id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, last_used_ip, revoked_at, created_at
+		FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*models.APIToken
+	for rows.Next() {
+		token := &models.APIToken{}
+		err := rows.Scan(
+			&token.ID,
+			&token.UserID,
+			&token.Name,
+			&token.TokenHash,
+			&token.TokenPrefix,
+			pq.Array(&token.Scopes),
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.LastUsedIP,
+			&token.RevokedAt,
+			&token.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (r *PostgresAPITokenRepository) Touch(ctx context.Context, token *models.APIToken) error {
+	query := `
+		UPDATE api_tokens
+		SET last_used_at = NOW(), last_used_ip = $1
+		WHERE id = $2
+		RETURNING last_used_at
+	`
+	err := r.db.QueryRowContext(ctx, query, token.LastUsedIP, token.ID).Scan(&token.LastUsedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("api token not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update api token: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke revokes one of a user's tokens, reporting false when the user has
+// no active token with that ID.
+func (r *PostgresAPITokenRepository) Revoke(ctx context.Context, userID int64, id int) (bool, error) {
+	query := `UPDATE api_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *PostgresAPITokenRepository) RevokeAllForUser(ctx context.Context, userID int64) (int64, error) {
+	query := `UPDATE api_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke api tokens: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
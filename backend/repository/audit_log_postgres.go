@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/suipic/backend/models"
+)
+
+type PostgresAuditLogRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditLogRepository(db *sql.DB) *PostgresAuditLogRepository {
+	return &PostgresAuditLogRepository{db: db}
+}
+
+func (r *PostgresAuditLogRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (actor_id, actor_name, share_link_id, action, target_type, target_id, album_id, before_values, after_values, ip_address, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		entry.ActorID,
+		entry.ActorName,
+		entry.ShareLinkID,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.AlbumID,
+		entry.Before,
+		entry.After,
+		entry.IPAddress,
+		entry.UserAgent,
+	).Scan(&entry.ID, &entry.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the entries matching the filter, newest first.
+func (r *PostgresAuditLogRepository) List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	query := `
+		SELECT id, actor_id, actor_name, share_link_id, action, target_type, target_id, album_id, before_values, after_values, ip_address, user_agent, created_at
+		FROM audit_log
+		WHERE ($1 = 0 OR actor_id = $1)
+			AND ($2 = 0 OR album_id = $2)
+			AND ($3 = '' OR action = $3)
+			AND ($4::timestamptz IS NULL OR created_at >= $4)
+			AND ($5::timestamptz IS NULL OR created_at < $5)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $6 OFFSET $7
+	`
+	rows, err := r.db.QueryContext(ctx, query, filter.ActorID, filter.AlbumID, filter.Action, filter.From, filter.To, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.ActorName,
+			&entry.ShareLinkID,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.AlbumID,
+			&entry.Before,
+			&entry.After,
+			&entry.IPAddress,
+			&entry.UserAgent,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit entries: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/suipic/backend/models"
+)
+
+type PostgresClientInviteRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresClientInviteRepository(db *sql.DB) *PostgresClientInviteRepository {
+	return &PostgresClientInviteRepository{db: db}
+}
+
+const clientInviteColumns = `id, photographer_id, email, friendly_name, album_ids, token_hash, expires_at, sent_at, accepted_at, accepted_by, revoked_at, created_at`
+
+func scanClientInvite(row interface{ Scan(...interface{}) error }) (*models.ClientInvite, error) {
+	invite := &models.ClientInvite{}
+	err := row.Scan(
+		&invite.ID,
+		&invite.PhotographerID,
+		&invite.Email,
+		&invite.FriendlyName,
+		pq.Array(&invite.AlbumIDs),
+		&invite.TokenHash,
+		&invite.ExpiresAt,
+		&invite.SentAt,
+		&invite.AcceptedAt,
+		&invite.AcceptedBy,
+		&invite.RevokedAt,
+		&invite.CreatedAt,
+	)
+	return invite, err
+}
+
+func (r *PostgresClientInviteRepository) Create(ctx context.Context, invite *models.ClientInvite) error {
+	query := `
+		INSERT INTO client_invites (photographer_id, email, friendly_name, album_ids, token_hash, expires_at, sent_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING id, sent_at, created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		invite.PhotographerID,
+		invite.Email,
+		invite.FriendlyName,
+		pq.Array(invite.AlbumIDs),
+		invite.TokenHash,
+		invite.ExpiresAt,
+	).Scan(&invite.ID, &invite.SentAt, &invite.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create client invite: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresClientInviteRepository) GetByID(ctx context.Context, id int) (*models.ClientInvite, error) {
+	query := `SELECT ` + clientInviteColumns + ` FROM client_invites WHERE id = $1`
+	invite, err := scanClientInvite(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+func (r *PostgresClientInviteRepository) GetByHash(ctx context.Context, tokenHash string) (*models.ClientInvite, error) {
+	query := `SELECT ` + clientInviteColumns + ` FROM client_invites WHERE token_hash = $1`
+	invite, err := scanClientInvite(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+// GetOpenByEmail returns the photographer's open invite for email, if any.
+func (r *PostgresClientInviteRepository) GetOpenByEmail(ctx context.Context, photographerID int64, email string) (*models.ClientInvite, error) {
+	query := `
+		SELECT ` + clientInviteColumns + `
+		FROM client_invites
+		WHERE photographer_id = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL AND revoked_at IS NULL
+	`
+	invite, err := scanClientInvite(r.db.QueryRowContext(ctx, query, photographerID, email))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+// ListOpenByPhotographer returns the invites that were neither accepted nor
+// revoked, expired ones included, newest first.
+func (r *PostgresClientInviteRepository) ListOpenByPhotographer(ctx context.Context, photographerID int64) ([]*models.ClientInvite, error) {
+	query := `
+		SELECT ` + clientInviteColumns + `
+		FROM client_invites
+		WHERE photographer_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, photographerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list client invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []*models.ClientInvite
+	for rows.Next() {
+		invite, err := scanClientInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan client invite: %w", err)
+		}
+		invites = append(invites, invite)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating client invites: %w", err)
+	}
+
+	return invites, nil
+}
+
+// Reissue replaces the token of an open invite, so the previous link stops
+// working, and records when it was sent.
+func (r *PostgresClientInviteRepository) Reissue(ctx context.Context, invite *models.ClientInvite) error {
+	query := `
+		UPDATE client_invites
+		SET token_hash = $1, expires_at = $2, sent_at = NOW()
+		WHERE id = $3 AND accepted_at IS NULL AND revoked_at IS NULL
+		RETURNING sent_at
+	`
+	err := r.db.QueryRowContext(ctx, query, invite.TokenHash, invite.ExpiresAt, invite.ID).Scan(&invite.SentAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("invite is no longer open")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reissue client invite: %w", err)
+	}
+
+	return nil
+}
+
+// MarkAccepted records that userID accepted the invite. It reports false
+// when the invite was accepted, revoked or had expired in the meantime.
+func (r *PostgresClientInviteRepository) MarkAccepted(ctx context.Context, id int, userID int64) (bool, error) {
+	query := `
+		UPDATE client_invites
+		SET accepted_at = NOW(), accepted_by = $2
+		WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+	`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to accept client invite: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// Revoke revokes one of the photographer's open invites. It reports false
+// when there is no such invite.
+func (r *PostgresClientInviteRepository) Revoke(ctx context.Context, photographerID int64, id int) (bool, error) {
+	query := `
+		UPDATE client_invites
+		SET revoked_at = NOW()
+		WHERE id = $1 AND photographer_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, id, photographerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke client invite: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/suipic/backend/models"
 )
@@ -14,6 +15,7 @@ type UserRepository interface {
 	Update(ctx context.Context, user *models.User) error
 	Delete(ctx context.Context, id int) error
 	List(ctx context.Context, limit, offset int) ([]*models.User, error)
+	Search(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error)
 	FindClientsByUsername(ctx context.Context, username string) ([]*models.User, error)
 }
 
@@ -25,6 +27,9 @@ type AlbumRepository interface {
 	List(ctx context.Context, limit, offset int) ([]*models.Album, error)
 	GetByPhotographer(ctx context.Context, photographerID int) ([]*models.Album, error)
 	GetByUserID(ctx context.Context, userID int) ([]*models.Album, error)
+	GetByCollaborator(ctx context.Context, userID int) ([]*models.Album, error)
+	GetByOrganization(ctx context.Context, organizationID int) ([]*models.Album, error)
+	SetOrganizationForPhotographer(ctx context.Context, photographerID int, organizationID *int) error
 }
 
 type PhotoRepository interface {
@@ -34,6 +39,7 @@ type PhotoRepository interface {
 	Delete(ctx context.Context, id int) error
 	List(ctx context.Context, limit, offset int) ([]*models.Photo, error)
 	GetByAlbum(ctx context.Context, albumID int) ([]*models.Photo, error)
+	GetByFilename(ctx context.Context, filename string) (*models.Photo, error)
 }
 
 type AlbumUserRepository interface {
@@ -45,6 +51,7 @@ type AlbumUserRepository interface {
 	List(ctx context.Context, limit, offset int) ([]*models.AlbumUser, error)
 	GetByAlbum(ctx context.Context, albumID int) ([]*models.AlbumUser, error)
 	GetByUser(ctx context.Context, userID int) ([]*models.AlbumUser, error)
+	GetByAlbumAndUser(ctx context.Context, albumID, userID int) (*models.AlbumUser, error)
 	IsUserInAlbum(ctx context.Context, userID, albumID int) (bool, error)
 }
 
@@ -71,3 +78,120 @@ type SystemSettingsRepository interface {
 	Set(ctx context.Context, key string, value string) error
 	GetAll(ctx context.Context) (map[string]string, error)
 }
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, id string) (*models.Session, error)
+	ListActiveByUser(ctx context.Context, userID int64) ([]*models.Session, error)
+	Touch(ctx context.Context, session *models.Session) error
+	Revoke(ctx context.Context, id string, reason string) error
+	RevokeAllForUser(ctx context.Context, userID int64, exceptID string, reason string) (int64, error)
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, id int) (bool, error)
+}
+
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.UserToken, error)
+	MarkUsed(ctx context.Context, id int) (bool, error)
+	DeleteUnused(ctx context.Context, userID int64, purpose models.UserTokenPurpose) error
+}
+
+type MFARepository interface {
+	GetByUser(ctx context.Context, userID int64) (*models.UserMFA, error)
+	SavePending(ctx context.Context, mfa *models.UserMFA) error
+	Enable(ctx context.Context, userID int64, step int64) error
+	UseStep(ctx context.Context, userID int64, step int64) (bool, error)
+	Delete(ctx context.Context, userID int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID int64) (int, error)
+	CountEnrolled(ctx context.Context) (int, error)
+}
+
+type OIDCRepository interface {
+	CreateAuthRequest(ctx context.Context, request *models.OIDCAuthRequest) error
+	ConsumeAuthRequest(ctx context.Context, stateHash string) (*models.OIDCAuthRequest, error)
+	DeleteExpiredAuthRequests(ctx context.Context) error
+	GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *models.UserIdentity) error
+	TouchIdentity(ctx context.Context, identity *models.UserIdentity) error
+	ListIdentitiesByUser(ctx context.Context, userID int64) ([]*models.UserIdentity, error)
+	DeleteIdentity(ctx context.Context, id int) error
+}
+
+type APITokenRepository interface {
+	Create(ctx context.Context, token *models.APIToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.APIToken, error)
+	ListActiveByUser(ctx context.Context, userID int64) ([]*models.APIToken, error)
+	Touch(ctx context.Context, token *models.APIToken) error
+	Revoke(ctx context.Context, userID int64, id int) (bool, error)
+	RevokeAllForUser(ctx context.Context, userID int64) (int64, error)
+}
+
+type LoginAttemptRepository interface {
+	Record(ctx context.Context, attempt *models.LoginAttempt) error
+	List(ctx context.Context, filter models.LoginAttemptFilter) ([]*models.LoginAttempt, error)
+	GetThrottle(ctx context.Context, kind, subject string) (*models.LoginThrottle, error)
+	RecordFailure(ctx context.Context, kind, subject string, window time.Duration) (*models.LoginThrottle, error)
+	Lock(ctx context.Context, kind, subject string, until time.Time) error
+	ClearThrottle(ctx context.Context, kind, subject string) (bool, error)
+	ListLocked(ctx context.Context) ([]*models.LoginThrottle, error)
+	DeleteStaleThrottles(ctx context.Context, before time.Time) error
+}
+
+type ClientInviteRepository interface {
+	Create(ctx context.Context, invite *models.ClientInvite) error
+	GetByID(ctx context.Context, id int) (*models.ClientInvite, error)
+	GetByHash(ctx context.Context, tokenHash string) (*models.ClientInvite, error)
+	GetOpenByEmail(ctx context.Context, photographerID int64, email string) (*models.ClientInvite, error)
+	ListOpenByPhotographer(ctx context.Context, photographerID int64) ([]*models.ClientInvite, error)
+	Reissue(ctx context.Context, invite *models.ClientInvite) error
+	MarkAccepted(ctx context.Context, id int, userID int64) (bool, error)
+	Revoke(ctx context.Context, photographerID int64, id int) (bool, error)
+}
+
+type ShareLinkRepository interface {
+	Create(ctx context.Context, link *models.ShareLink) error
+	GetByID(ctx context.Context, id int) (*models.ShareLink, error)
+	GetByHash(ctx context.Context, tokenHash string) (*models.ShareLink, error)
+	ListByAlbum(ctx context.Context, albumID int) ([]*models.ShareLink, error)
+	Revoke(ctx context.Context, albumID, id int) (bool, error)
+	RecordAccess(ctx context.Context, access *models.ShareLinkAccess) error
+	ListAccesses(ctx context.Context, shareLinkID, limit int) ([]*models.ShareLinkAccess, error)
+}
+
+type AlbumCollaboratorRepository interface {
+	Create(ctx context.Context, collaborator *models.AlbumCollaborator) error
+	Delete(ctx context.Context, albumID, userID int) (bool, error)
+	GetByAlbum(ctx context.Context, albumID int) ([]*models.AlbumCollaborator, error)
+	IsCollaborator(ctx context.Context, albumID, userID int) (bool, error)
+}
+
+type OrganizationRepository interface {
+	Create(ctx context.Context, org *models.Organization, owner *models.OrganizationMember) error
+	GetByID(ctx context.Context, id int) (*models.Organization, error)
+	List(ctx context.Context) ([]*models.Organization, error)
+	Update(ctx context.Context, org *models.Organization) error
+	Delete(ctx context.Context, id int) error
+	CreateInvite(ctx context.Context, invite *models.OrganizationInvite) error
+	GetInvite(ctx context.Context, id int) (*models.OrganizationInvite, error)
+	ListInvites(ctx context.Context, organizationID int, userID int64) ([]*models.OrganizationInvite, error)
+	DeleteInvite(ctx context.Context, id int) (bool, error)
+	AcceptInvite(ctx context.Context, invite *models.OrganizationInvite) (*models.OrganizationMember, error)
+	GetMembership(ctx context.Context, userID int64) (*models.OrganizationMember, error)
+	ListMembers(ctx context.Context, organizationID int) ([]*models.OrganizationMember, error)
+	UpdateMemberRole(ctx context.Context, organizationID int, userID int64, role models.OrganizationRole) (bool, error)
+	RemoveMember(ctx context.Context, organizationID int, userID int64) (bool, error)
+	GetUsage(ctx context.Context, organizationID int) (*models.OrganizationUsage, error)
+	GetIncomingUsage(ctx context.Context, organizationID int, photographerID int64) (*models.OrganizationUsage, error)
+	ListClients(ctx context.Context, organizationID int) ([]*models.User, error)
+	GetSettings(ctx context.Context, organizationID int) (map[string]string, error)
+	SetSetting(ctx context.Context, organizationID int, key, value string) error
+}
+
+type AuditLogRepository interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+}
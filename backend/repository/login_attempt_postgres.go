@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/suipic/backend/models"
+)
+
+type PostgresLoginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresLoginAttemptRepository(db *sql.DB) *PostgresLoginAttemptRepository {
+	return &PostgresLoginAttemptRepository{db: db}
+}
+
+func (r *PostgresLoginAttemptRepository) Record(ctx context.Context, attempt *models.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (user_id, identifier, ip_address, user_agent, success, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		attempt.UserID,
+		attempt.Identifier,
+		attempt.IPAddress,
+		attempt.UserAgent,
+		attempt.Success,
+		attempt.Reason,
+	).Scan(&attempt.ID, &attempt.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the most recent attempts matching the filter, newest first.
+func (r *PostgresLoginAttemptRepository) List(ctx context.Context, filter models.LoginAttemptFilter) ([]*models.LoginAttempt, error) {
+	query := `
+		SELECT id, user_id, identifier, ip_address, user_agent, success, reason, created_at
+		FROM login_attempts
+		WHERE ($1 = 0 OR user_id = $1) AND ($2 = '' OR ip_address = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, filter.UserID, filter.IPAddress, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []*models.LoginAttempt
+	for rows.Next() {
+		attempt := &models.LoginAttempt{}
+		err := rows.Scan(
+			&attempt.ID,
+			&attempt.UserID,
+			&attempt.Identifier,
+			&attempt.IPAddress,
+			&attempt.UserAgent,
+			&attempt.Success,
+			&attempt.Reason,
+			&attempt.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan login attempt: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating login attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+func (r *PostgresLoginAttemptRepository) GetThrottle(ctx context.Context, kind, subject string) (*models.LoginThrottle, error) {
+	query := `
+		SELECT kind, subject, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE kind = $1 AND subject = $2
+	`
+	throttle := &models.LoginThrottle{}
+	err := r.db.QueryRowContext(ctx, query, kind, subject).Scan(
+		&throttle.Kind,
+		&throttle.Subject,
+		&throttle.Failures,
+		&throttle.LastFailureAt,
+		&throttle.LockedUntil,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+
+	return throttle, nil
+}
+
+// RecordFailure counts a failed login. Failures older than window no longer
+// count, so the tally starts over after a quiet period.
+func (r *PostgresLoginAttemptRepository) RecordFailure(ctx context.Context, kind, subject string, window time.Duration) (*models.LoginThrottle, error) {
+	query := `
+		INSERT INTO login_throttles (kind, subject, failures, last_failure_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (kind, subject) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure_at < NOW() - make_interval(secs => $3) THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure_at = NOW()
+		RETURNING kind, subject, failures, last_failure_at, locked_until
+	`
+	throttle := &models.LoginThrottle{}
+	err := r.db.QueryRowContext(ctx, query, kind, subject, window.Seconds()).Scan(
+		&throttle.Kind,
+		&throttle.Subject,
+		&throttle.Failures,
+		&throttle.LastFailureAt,
+		&throttle.LockedUntil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return throttle, nil
+}
+
+func (r *PostgresLoginAttemptRepository) Lock(ctx context.Context, kind, subject string, until time.Time) error {
+	query := `UPDATE login_throttles SET locked_until = $1 WHERE kind = $2 AND subject = $3`
+	if _, err := r.db.ExecContext(ctx, query, until, kind, subject); err != nil {
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+	return nil
+}
+
+// ClearThrottle forgets the failures of an account or IP address, lifting any
+// lockout. It reports whether there was anything to clear.
+func (r *PostgresLoginAttemptRepository) ClearThrottle(ctx context.Context, kind, subject string) (bool, error) {
+	query := `DELETE FROM login_throttles WHERE kind = $1 AND subject = $2`
+	result, err := r.db.ExecContext(ctx, query, kind, subject)
+	if err != nil {
+		return false, fmt.Errorf("failed to clear login throttle: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// ListLocked returns the accounts and IP addresses that are locked out now.
+func (r *PostgresLoginAttemptRepository) ListLocked(ctx context.Context) ([]*models.LoginThrottle, error) {
+	query := `
+		SELECT kind, subject, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE locked_until > NOW()
+		ORDER BY locked_until DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lockouts: %w", err)
+	}
+	defer rows.Close()
+
+	var throttles []*models.LoginThrottle
+	for rows.Next() {
+		throttle := &models.LoginThrottle{}
+		err := rows.Scan(
+			&throttle.Kind,
+			&throttle.Subject,
+			&throttle.Failures,
+			&throttle.LastFailureAt,
+			&throttle.LockedUntil,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lockout: %w", err)
+		}
+		throttles = append(throttles, throttle)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lockouts: %w", err)
+	}
+
+	return throttles, nil
+}
+
+// DeleteStaleThrottles removes entries whose last failure is before the
+// given time and which are not locked any more.
+func (r *PostgresLoginAttemptRepository) DeleteStaleThrottles(ctx context.Context, before time.Time) error {
+	query := `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < NOW())
+	`
+	if _, err := r.db.ExecContext(ctx, query, before); err != nil {
+		return fmt.Errorf("failed to delete stale login throttles: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/suipic/backend/models"
+)
+
+type PostgresMFARepository struct {
+	db *sql.DB
+}
+
+func NewPostgresMFARepository(db *sql.DB) *PostgresMFARepository {
+	return &PostgresMFARepository{db: db}
+}
+
+func (r *PostgresMFARepository) GetByUser(ctx context.Context, userID int64) (*models.UserMFA, error) {
+	query := `
+		SELECT user_id, totp_secret, enabled_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+	mfa := &models.UserMFA{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&mfa.UserID,
+		&mfa.TOTPSecret,
+		&mfa.EnabledAt,
+		&mfa.LastUsedStep,
+		&mfa.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user mfa: %w", err)
+	}
+
+	return mfa, nil
+}
+
+// SavePending stores a new secret that is not enabled yet, replacing any
+// earlier enrollment that was never confirmed.
+func (r *PostgresMFARepository) SavePending(ctx context.Context, mfa *models.UserMFA) error {
+	query := `
+		INSERT INTO user_mfa (user_id, totp_secret, enabled_at, last_used_step, created_at)
+		VALUES ($1, $2, NULL, 0, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = EXCLUDED.totp_secret, last_used_step = 0, created_at = NOW()
+		WHERE user_mfa.enabled_at IS NULL
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, mfa.UserID, mfa.TOTPSecret).Scan(&mfa.CreatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("two-factor authentication is already enabled")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save user mfa: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresMFARepository) Enable(ctx context.Context, userID int64, step int64) error {
+	query := `
+		UPDATE user_mfa
+		SET enabled_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable user mfa: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("no pending two-factor enrollment")
+	}
+
+	return nil
+}
+
+// UseStep records that the code for a time step was accepted, reporting
+// false when that step or a later one was already used.
+func (r *PostgresMFARepository) UseStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := `UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record mfa step: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *PostgresMFARepository) Delete(ctx context.Context, userID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete user mfa: %w", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps all of a user's recovery codes for new ones.
+func (r *PostgresMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	query := `
+		INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at)
+		SELECT $1, unnest($2::text[]), NOW()
+	`
+	if _, err := tx.ExecContext(ctx, query, userID, pq.Array(codeHashes)); err != nil {
+		return fmt.Errorf("failed to create recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit recovery codes: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes a recovery code, reporting false when the user
+// has no unused code with that hash.
+func (r *PostgresMFARepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *PostgresMFARepository) CountUnusedRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// CountEnrolled counts the users with a stored TOTP secret, including
+// enrollments that were never confirmed.
+func (r *PostgresMFARepository) CountEnrolled(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_mfa`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count user mfa: %w", err)
+	}
+	return count, nil
+}
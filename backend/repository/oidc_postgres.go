@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/suipic/backend/models"
+)
+
+type PostgresOIDCRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresOIDCRepository(db *sql.DB) *PostgresOIDCRepository {
+	return &PostgresOIDCRepository{db: db}
+}
+
+func (r *PostgresOIDCRepository) CreateAuthRequest(ctx context.Context, request *models.OIDCAuthRequest) error {
+	query := `
+		INSERT INTO oidc_auth_requests (state_hash, provider, code_verifier, nonce, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		request.StateHash,
+		request.Provider,
+		request.CodeVerifier,
+		request.Nonce,
+		request.ExpiresAt,
+	).Scan(&request.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create oidc auth request: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeAuthRequest deletes and returns the request for a state, so that
+// each state can complete a sign-in only once.
+func (r *PostgresOIDCRepository) ConsumeAuthRequest(ctx context.Context, stateHash string) (*models.OIDCAuthRequest, error) {
+	query := `
+		DELETE FROM oidc_auth_requests
+		WHERE state_hash = $1
+		RETURNING state_hash, provider, code_verifier, nonce, expires_at, created_at
+	`
+	request := &models.OIDCAuthRequest{}
+	err := r.db.QueryRowContext(ctx, query, stateHash).Scan(
+		&request.StateHash,
+		&request.Provider,
+		&request.CodeVerifier,
+		&request.Nonce,
+		&request.ExpiresAt,
+		&request.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume oidc auth request: %w", err)
+	}
+
+	return request, nil
+}
+
+// DeleteExpiredAuthRequests drops sign-ins that were abandoned at the
+// identity provider.
+func (r *PostgresOIDCRepository) DeleteExpiredAuthRequests(ctx context.Context) error {
+	query := `DELETE FROM oidc_auth_requests WHERE expires_at < NOW()`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to delete expired oidc auth requests: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresOIDCRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+	identity := &models.UserIdentity{}
+	err := r.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+func (r *PostgresOIDCRepository) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, created_at, last_login_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	).Scan(&identity.ID, &identity.CreatedAt, &identity.LastLoginAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create user identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresOIDCRepository) TouchIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		UPDATE user_identities
+		SET email = $1, last_login_at = NOW()
+		WHERE id = $2
+		RETURNING last_login_at
+	`
+	err := r.db.QueryRowContext(ctx, query, identity.Email, identity.ID).Scan(&identity.LastLoginAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user identity not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update user identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresOIDCRepository) ListIdentitiesByUser(ctx context.Context, userID int64) ([]*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []*models.UserIdentity
+	for rows.Next() {
+		identity := &models.UserIdentity{}
+		err := rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+			&identity.LastLoginAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+func (r *PostgresOIDCRepository) DeleteIdentity(ctx context.Context, id int) error {
+	query := `DELETE FROM user_identities WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user identity: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user identity not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,495 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/suipic/backend/models"
+)
+
+type PostgresOrganizationRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresOrganizationRepository(db *sql.DB) *PostgresOrganizationRepository {
+	return &PostgresOrganizationRepository{db: db}
+}
+
+const organizationColumns = `id, name, max_members, max_albums, max_photos, created_at, updated_at`
+
+func scanOrganization(row interface{ Scan(...interface{}) error }) (*models.Organization, error) {
+	org := &models.Organization{}
+	err := row.Scan(
+		&org.ID,
+		&org.Name,
+		&org.MaxMembers,
+		&org.MaxAlbums,
+		&org.MaxPhotos,
+		&org.CreatedAt,
+		&org.UpdatedAt,
+	)
+	return org, err
+}
+
+// Create creates the organization with owner as its first member.
+func (r *PostgresOrganizationRepository) Create(ctx context.Context, org *models.Organization, owner *models.OrganizationMember) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO organizations (name, max_members, max_albums, max_photos, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRowContext(ctx, query, org.Name, org.MaxMembers, org.MaxAlbums, org.MaxPhotos).
+		Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	owner.OrganizationID = org.ID
+	if err := addMember(ctx, tx, owner); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit organization: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresOrganizationRepository) GetByID(ctx context.Context, id int) (*models.Organization, error) {
+	query := `SELECT ` + organizationColumns + ` FROM organizations WHERE id = $1`
+	org, err := scanOrganization(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+func (r *PostgresOrganizationRepository) List(ctx context.Context) ([]*models.Organization, error) {
+	query := `SELECT ` + organizationColumns + ` FROM organizations ORDER BY name`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	var orgs []*models.Organization
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organizations: %w", err)
+	}
+
+	return orgs, nil
+}
+
+func (r *PostgresOrganizationRepository) Update(ctx context.Context, org *models.Organization) error {
+	query := `
+		UPDATE organizations
+		SET name = $1, max_members = $2, max_albums = $3, max_photos = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at
+	`
+	err := r.db.QueryRowContext(ctx, query, org.Name, org.MaxMembers, org.MaxAlbums, org.MaxPhotos, org.ID).Scan(&org.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("organization not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresOrganizationRepository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM organizations WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func addMember(ctx context.Context, db execer, member *models.OrganizationMember) error {
+	query := `
+		INSERT INTO organization_members (organization_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at
+	`
+	err := db.QueryRowContext(ctx, query, member.OrganizationID, member.UserID, member.Role).
+		Scan(&member.ID, &member.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add organization member: %w", err)
+	}
+	return nil
+}
+
+const organizationInviteColumns = `i.id, i.organization_id, i.user_id, i.role, i.invited_by, i.created_at, o.name, u.username`
+
+func scanOrganizationInvite(row interface{ Scan(...interface{}) error }) (*models.OrganizationInvite, error) {
+	invite := &models.OrganizationInvite{}
+	err := row.Scan(
+		&invite.ID,
+		&invite.OrganizationID,
+		&invite.UserID,
+		&invite.Role,
+		&invite.InvitedBy,
+		&invite.CreatedAt,
+		&invite.OrganizationName,
+		&invite.Username,
+	)
+	return invite, err
+}
+
+func (r *PostgresOrganizationRepository) CreateInvite(ctx context.Context, invite *models.OrganizationInvite) error {
+	query := `
+		INSERT INTO organization_invites (organization_id, user_id, role, invited_by, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (organization_id, user_id) DO UPDATE SET role = $3, invited_by = $4
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, invite.OrganizationID, invite.UserID, invite.Role, invite.InvitedBy).
+		Scan(&invite.ID, &invite.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create organization invite: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresOrganizationRepository) GetInvite(ctx context.Context, id int) (*models.OrganizationInvite, error) {
+	query := `
+		SELECT ` + organizationInviteColumns + `
+		FROM organization_invites i
+		JOIN organizations o ON o.id = i.organization_id
+		JOIN users u ON u.id = i.user_id
+		WHERE i.id = $1
+	`
+	invite, err := scanOrganizationInvite(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+// ListInvites returns the organization's pending invites when
+// organizationID is set, or the user's when userID is.
+func (r *PostgresOrganizationRepository) ListInvites(ctx context.Context, organizationID int, userID int64) ([]*models.OrganizationInvite, error) {
+	query := `
+		SELECT ` + organizationInviteColumns + `
+		FROM organization_invites i
+		JOIN organizations o ON o.id = i.organization_id
+		JOIN users u ON u.id = i.user_id
+		WHERE ($1 = 0 OR i.organization_id = $1) AND ($2 = 0 OR i.user_id = $2)
+		ORDER BY i.created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, organizationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []*models.OrganizationInvite
+	for rows.Next() {
+		invite, err := scanOrganizationInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization invite: %w", err)
+		}
+		invites = append(invites, invite)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization invites: %w", err)
+	}
+
+	return invites, nil
+}
+
+// DeleteInvite reports false when there is no such invite.
+func (r *PostgresOrganizationRepository) DeleteInvite(ctx context.Context, id int) (bool, error) {
+	query := `DELETE FROM organization_invites WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete organization invite: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// AcceptInvite makes the invited user a member and drops their other
+// invites, in one transaction.
+func (r *PostgresOrganizationRepository) AcceptInvite(ctx context.Context, invite *models.OrganizationInvite) (*models.OrganizationMember, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM organization_invites WHERE id = $1`, invite.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept organization invite: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows != 1 {
+		return nil, fmt.Errorf("invite is no longer open")
+	}
+
+	member := &models.OrganizationMember{
+		OrganizationID: invite.OrganizationID,
+		UserID:         invite.UserID,
+		Role:           invite.Role,
+	}
+	if err := addMember(ctx, tx, member); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM organization_invites WHERE user_id = $1`, invite.UserID); err != nil {
+		return nil, fmt.Errorf("failed to clear organization invites: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit organization invite: %w", err)
+	}
+	return member, nil
+}
+
+// GetMembership returns the user's membership, or nil when they do not
+// belong to an organization.
+func (r *PostgresOrganizationRepository) GetMembership(ctx context.Context, userID int64) (*models.OrganizationMember, error) {
+	query := `
+		SELECT id, organization_id, user_id, role, created_at
+		FROM organization_members
+		WHERE user_id = $1
+	`
+	member := &models.OrganizationMember{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&member.ID,
+		&member.OrganizationID,
+		&member.UserID,
+		&member.Role,
+		&member.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	return member, nil
+}
+
+func (r *PostgresOrganizationRepository) ListMembers(ctx context.Context, organizationID int) ([]*models.OrganizationMember, error) {
+	query := `
+		SELECT m.id, m.organization_id, m.user_id, m.role, m.created_at, u.username, u.email, u.friendly_name
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY m.created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*models.OrganizationMember
+	for rows.Next() {
+		member := &models.OrganizationMember{}
+		err := rows.Scan(
+			&member.ID,
+			&member.OrganizationID,
+			&member.UserID,
+			&member.Role,
+			&member.CreatedAt,
+			&member.Username,
+			&member.Email,
+			&member.FriendlyName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization members: %w", err)
+	}
+
+	return members, nil
+}
+
+// UpdateMemberRole reports false when the user is not a member.
+func (r *PostgresOrganizationRepository) UpdateMemberRole(ctx context.Context, organizationID int, userID int64, role models.OrganizationRole) (bool, error) {
+	query := `UPDATE organization_members SET role = $1 WHERE organization_id = $2 AND user_id = $3`
+	result, err := r.db.ExecContext(ctx, query, role, organizationID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update organization member: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// RemoveMember reports false when the user is not a member.
+func (r *PostgresOrganizationRepository) RemoveMember(ctx context.Context, organizationID int, userID int64) (bool, error) {
+	query := `DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, organizationID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove organization member: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *PostgresOrganizationRepository) GetUsage(ctx context.Context, organizationID int) (*models.OrganizationUsage, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM organization_members WHERE organization_id = $1),
+			(SELECT COUNT(*) FROM albums WHERE organization_id = $1),
+			(SELECT COUNT(*) FROM photos p JOIN albums a ON a.id = p.album_id WHERE a.organization_id = $1)
+	`
+	usage := &models.OrganizationUsage{}
+	err := r.db.QueryRowContext(ctx, query, organizationID).Scan(&usage.Members, &usage.Albums, &usage.Photos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// GetIncomingUsage counts the photographer's albums, and their photos, that
+// are not in the organization yet. Members is always zero.
+func (r *PostgresOrganizationRepository) GetIncomingUsage(ctx context.Context, organizationID int, photographerID int64) (*models.OrganizationUsage, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM albums WHERE photographer_id = $2 AND organization_id IS DISTINCT FROM $1),
+			(SELECT COUNT(*) FROM photos p JOIN albums a ON a.id = p.album_id WHERE a.photographer_id = $2 AND a.organization_id IS DISTINCT FROM $1)
+	`
+	usage := &models.OrganizationUsage{}
+	err := r.db.QueryRowContext(ctx, query, organizationID, photographerID).Scan(&usage.Albums, &usage.Photos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incoming organization usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// ListClients returns the clients of every photographer in the
+// organization, each once.
+func (r *PostgresOrganizationRepository) ListClients(ctx context.Context, organizationID int) ([]*models.User, error) {
+	query := `
+		SELECT DISTINCT u.id, u.email, u.username, u.friendly_name, u.role, u.avatar_id, u.created_at, u.updated_at
+		FROM users u
+		JOIN photographer_clients pc ON pc.client_id = u.id
+		JOIN organization_members m ON m.user_id = pc.photographer_id
+		WHERE m.organization_id = $1
+		ORDER BY u.username
+	`
+	rows, err := r.db.QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization clients: %w", err)
+	}
+	defer rows.Close()
+
+	var clients []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.Username,
+			&user.FriendlyName,
+			&user.Role,
+			&user.AvatarID,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization client: %w", err)
+		}
+		clients = append(clients, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization clients: %w", err)
+	}
+
+	return clients, nil
+}
+
+func (r *PostgresOrganizationRepository) GetSettings(ctx context.Context, organizationID int) (map[string]string, error) {
+	query := `SELECT key, value FROM organization_settings WHERE organization_id = $1`
+	rows, err := r.db.QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan organization setting: %w", err)
+		}
+		settings[key] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+func (r *PostgresOrganizationRepository) SetSetting(ctx context.Context, organizationID int, key, value string) error {
+	query := `
+		INSERT INTO organization_settings (organization_id, key, value, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (organization_id, key)
+		DO UPDATE SET value = $3, updated_at = NOW()
+	`
+	if _, err := r.db.ExecContext(ctx, query, organizationID, key, value); err != nil {
+		return fmt.Errorf("failed to set organization setting: %w", err)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/lib/pq"
 	"github.com/suipic/backend/models"
 )
 
@@ -18,8 +19,8 @@ func NewPostgresPhotoRepository(db *sql.DB) *PostgresPhotoRepository {
 
 func (r *PostgresPhotoRepository) Create(ctx context.Context, photo *models.Photo) error {
 	query := `
-		INSERT INTO photos (album_id, filename, title, date_time, exif_data, pick_reject_state, stars, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		INSERT INTO photos (album_id, filename, original_filename, title, caption, keywords, country_code, country, region, city, date_time, time_shift, exif_data, pick_reject_state, stars, uploaded_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
 	err := r.db.QueryRowContext(
@@ -27,11 +28,20 @@ func (r *PostgresPhotoRepository) Create(ctx context.Context, photo *models.Phot
 		query,
 		photo.AlbumID,
 		photo.Filename,
+		photo.OriginalFilename,
 		photo.Title,
+		photo.Caption,
+		pq.Array(photo.Keywords),
+		photo.CountryCode,
+		photo.Country,
+		photo.Region,
+		photo.City,
 		photo.DateTime,
+		photo.TimeShift,
 		photo.ExifData,
 		photo.PickRejectState,
 		photo.Stars,
+		photo.UploadedBy,
 	).Scan(&photo.ID, &photo.CreatedAt, &photo.UpdatedAt)
 
 	if err != nil {
@@ -43,7 +53,7 @@ func (r *PostgresPhotoRepository) Create(ctx context.Context, photo *models.Phot
 
 func (r *PostgresPhotoRepository) GetByID(ctx context.Context, id int) (*models.Photo, error) {
 	query := `
-		SELECT id, album_id, filename, title, date_time, exif_data, pick_reject_state, stars, created_at, updated_at
+		SELECT id, album_id, filename, original_filename, title, caption, keywords, country_code, country, region, city, date_time, time_shift, exif_data, pick_reject_state, stars, uploaded_by, created_at, updated_at
 		FROM photos
 		WHERE id = $1
 	`
@@ -52,11 +62,20 @@ func (r *PostgresPhotoRepository) GetByID(ctx context.Context, id int) (*models.
 		&photo.ID,
 		&photo.AlbumID,
 		&photo.Filename,
+		&photo.OriginalFilename,
 		&photo.Title,
+		&photo.Caption,
+		pq.Array(&photo.Keywords),
+		&photo.CountryCode,
+		&photo.Country,
+		&photo.Region,
+		&photo.City,
 		&photo.DateTime,
+		&photo.TimeShift,
 		&photo.ExifData,
 		&photo.PickRejectState,
 		&photo.Stars,
+		&photo.UploadedBy,
 		&photo.CreatedAt,
 		&photo.UpdatedAt,
 	)
@@ -74,8 +93,8 @@ func (r *PostgresPhotoRepository) GetByID(ctx context.Context, id int) (*models.
 func (r *PostgresPhotoRepository) Update(ctx context.Context, photo *models.Photo) error {
 	query := `
 		UPDATE photos
-		SET album_id = $1, filename = $2, title = $3, date_time = $4, exif_data = $5, pick_reject_state = $6, stars = $7, updated_at = NOW()
-		WHERE id = $8
+		SET album_id = $1, filename = $2, original_filename = $3, title = $4, caption = $5, keywords = $6, country_code = $7, country = $8, region = $9, city = $10, date_time = $11, time_shift = $12, exif_data = $13, pick_reject_state = $14, stars = $15, updated_at = NOW()
+		WHERE id = $16
 		RETURNING updated_at
 	`
 	err := r.db.QueryRowContext(
@@ -83,8 +102,16 @@ func (r *PostgresPhotoRepository) Update(ctx context.Context, photo *models.Phot
 		query,
 		photo.AlbumID,
 		photo.Filename,
+		photo.OriginalFilename,
 		photo.Title,
+		photo.Caption,
+		pq.Array(photo.Keywords),
+		photo.CountryCode,
+		photo.Country,
+		photo.Region,
+		photo.City,
 		photo.DateTime,
+		photo.TimeShift,
 		photo.ExifData,
 		photo.PickRejectState,
 		photo.Stars,
@@ -122,7 +149,7 @@ func (r *PostgresPhotoRepository) Delete(ctx context.Context, id int) error {
 
 func (r *PostgresPhotoRepository) List(ctx context.Context, limit, offset int) ([]*models.Photo, error) {
 	query := `
-		SELECT id, album_id, filename, title, date_time, exif_data, pick_reject_state, stars, created_at, updated_at
+		SELECT id, album_id, filename, original_filename, title, caption, keywords, country_code, country, region, city, date_time, time_shift, exif_data, pick_reject_state, stars, uploaded_by, created_at, updated_at
 		FROM photos
 		ORDER BY id
 		LIMIT $1 OFFSET $2
@@ -140,11 +167,20 @@ func (r *PostgresPhotoRepository) List(ctx context.Context, limit, offset int) (
 			&photo.ID,
 			&photo.AlbumID,
 			&photo.Filename,
+			&photo.OriginalFilename,
 			&photo.Title,
+			&photo.Caption,
+			pq.Array(&photo.Keywords),
+			&photo.CountryCode,
+			&photo.Country,
+			&photo.Region,
+			&photo.City,
 			&photo.DateTime,
+			&photo.TimeShift,
 			&photo.ExifData,
 			&photo.PickRejectState,
 			&photo.Stars,
+			&photo.UploadedBy,
 			&photo.CreatedAt,
 			&photo.UpdatedAt,
 		)
@@ -163,7 +199,7 @@ func (r *PostgresPhotoRepository) List(ctx context.Context, limit, offset int) (
 
 func (r *PostgresPhotoRepository) GetByAlbum(ctx context.Context, albumID int) ([]*models.Photo, error) {
 	query := `
-		SELECT id, album_id, filename, title, date_time, exif_data, pick_reject_state, stars, created_at, updated_at
+		SELECT id, album_id, filename, original_filename, title, caption, keywords, country_code, country, region, city, date_time, time_shift, exif_data, pick_reject_state, stars, uploaded_by, created_at, updated_at
 		FROM photos
 		WHERE album_id = $1
 		ORDER BY date_time DESC NULLS LAST, created_at DESC
@@ -181,11 +217,20 @@ func (r *PostgresPhotoRepository) GetByAlbum(ctx context.Context, albumID int) (
 			&photo.ID,
 			&photo.AlbumID,
 			&photo.Filename,
+			&photo.OriginalFilename,
 			&photo.Title,
+			&photo.Caption,
+			pq.Array(&photo.Keywords),
+			&photo.CountryCode,
+			&photo.Country,
+			&photo.Region,
+			&photo.City,
 			&photo.DateTime,
+			&photo.TimeShift,
 			&photo.ExifData,
 			&photo.PickRejectState,
 			&photo.Stars,
+			&photo.UploadedBy,
 			&photo.CreatedAt,
 			&photo.UpdatedAt,
 		)
@@ -201,3 +246,43 @@ func (r *PostgresPhotoRepository) GetByAlbum(ctx context.Context, albumID int) (
 
 	return photos, nil
 }
+
+// GetByFilename returns the photo stored under the given file ID.
+func (r *PostgresPhotoRepository) GetByFilename(ctx context.Context, filename string) (*models.Photo, error) {
+	query := `
+		SELECT id, album_id, filename, original_filename, title, caption, keywords, country_code, country, region, city, date_time, time_shift, exif_data, pick_reject_state, stars, uploaded_by, created_at, updated_at
+		FROM photos
+		WHERE filename = $1
+	`
+	photo := &models.Photo{}
+	err := r.db.QueryRowContext(ctx, query, filename).Scan(
+		&photo.ID,
+		&photo.AlbumID,
+		&photo.Filename,
+		&photo.OriginalFilename,
+		&photo.Title,
+		&photo.Caption,
+		pq.Array(&photo.Keywords),
+		&photo.CountryCode,
+		&photo.Country,
+		&photo.Region,
+		&photo.City,
+		&photo.DateTime,
+		&photo.TimeShift,
+		&photo.ExifData,
+		&photo.PickRejectState,
+		&photo.Stars,
+		&photo.UploadedBy,
+		&photo.CreatedAt,
+		&photo.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photo by filename: %w", err)
+	}
+
+	return photo, nil
+}
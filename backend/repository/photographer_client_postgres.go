@@ -62,7 +62,7 @@ func (r *PostgresPhotographerClientRepository) GetByPhotographerAndClient(ctx co
 
 func (r *PostgresPhotographerClientRepository) GetClientsByPhotographer(ctx context.Context, photographerID int64) ([]*models.User, error) {
 	query := `
-		SELECT u.id, u.username, u.password_hash, u.email, u.friendly_name, u.role, u.created_at, u.updated_at
+		SELECT u.id, u.username, u.password_hash, u.email, u.friendly_name, u.role, u.avatar_id, u.email_verified_at, u.suspended_at, u.suspension_reason, u.password_reset_required, u.created_at, u.updated_at
 		FROM users u
 		INNER JOIN photographer_clients pc ON u.id = pc.client_id
 		WHERE pc.photographer_id = $1
@@ -84,6 +84,11 @@ func (r *PostgresPhotographerClientRepository) GetClientsByPhotographer(ctx cont
 			&user.Email,
 			&user.FriendlyName,
 			&user.Role,
+			&user.AvatarID,
+			&user.EmailVerifiedAt,
+			&user.SuspendedAt,
+			&user.SuspensionReason,
+			&user.PasswordResetRequired,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/suipic/backend/models"
+)
+
+type PostgresSessionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresSessionRepository(db *sql.DB) *PostgresSessionRepository {
+	return &PostgresSessionRepository{db: db}
+}
+
+func (r *PostgresSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, ip_address, user_agent, device, expires_at, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING created_at, last_used_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		session.ID,
+		session.UserID,
+		session.IPAddress,
+		session.UserAgent,
+		session.Device,
+		session.ExpiresAt,
+	).Scan(&session.CreatedAt, &session.LastUsedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresSessionRepository) GetByID(ctx context.Context, id string) (*models.Session, error) {
+	query := `
+		SELECT id, user_id, ip_address, user_agent, device, created_at, last_used_at, expires_at, revoked_at, revoked_reason
+		FROM sessions
+		WHERE id = $1
+	`
+	session := &models.Session{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.IPAddress,
+		&session.UserAgent,
+		&session.Device,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+		&session.RevokedReason,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session by id: %w", err)
+	}
+
+	return session, nil
+}
+
+func (r *PostgresSessionRepository) ListActiveByUser(ctx context.Context, userID int64) ([]*models.Session, error) {
+	query := `
+		SELECT id, user_id, ip_address, user_agent, device, created_at, last_used_at, expires_at, revoked_at, revoked_reason
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session := &models.Session{}
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.IPAddress,
+			&session.UserAgent,
+			&session.Device,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+			&session.RevokedAt,
+			&session.RevokedReason,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// Touch records that the session was just used, along with the address and
+// client it was used from.
+func (r *PostgresSessionRepository) Touch(ctx context.Context, session *models.Session) error {
+	query := `
+		UPDATE sessions
+		SET ip_address = $2, user_agent = $3, device = $4, last_used_at = NOW()
+		WHERE id = $1
+		RETURNING last_used_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		session.ID,
+		session.IPAddress,
+		session.UserAgent,
+		session.Device,
+	).Scan(&session.LastUsedAt)
+
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke ends a session. A session that is already revoked keeps its
+// original reason.
+func (r *PostgresSessionRepository) Revoke(ctx context.Context, id string, reason string) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW(), revoked_reason = $2
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+	if _, err := r.db.ExecContext(ctx, query, id, reason); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllForUser ends every open session of a user except exceptID, which
+// may be empty. It returns how many sessions were revoked.
+func (r *PostgresSessionRepository) RevokeAllForUser(ctx context.Context, userID int64, exceptID string, reason string) (int64, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW(), revoked_reason = $3
+		WHERE user_id = $1 AND id::text <> $2 AND revoked_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, exceptID, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+// CreateRefreshToken stores a refresh token. Storing one that exists already
+// does nothing, so racing refreshes can both store the successor they share;
+// the token's ID is then left unset.
+func (r *PostgresSessionRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (session_id, token_hash, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (token_hash) DO NOTHING
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, token.SessionID, token.TokenHash).Scan(&token.ID, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresSessionRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT id, session_id, token_hash, created_at, used_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+	token := &models.RefreshToken{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.SessionID,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.UsedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// MarkRefreshTokenUsed consumes a refresh token. It reports false when the
+// token had already been used, so two concurrent refreshes cannot both win.
+func (r *PostgresSessionRepository) MarkRefreshTokenUsed(ctx context.Context, id int) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
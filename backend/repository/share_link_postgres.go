@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/suipic/backend/models"
+)
+
+type PostgresShareLinkRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresShareLinkRepository(db *sql.DB) *PostgresShareLinkRepository {
+	return &PostgresShareLinkRepository{db: db}
+}
+
+const shareLinkColumns = `id, album_id, created_by, name, token_hash, token_prefix, photo_ids, password_hash, mode, allow_download, expires_at, revoked_at, created_at`
+
+func scanShareLink(row interface{ Scan(...interface{}) error }) (*models.ShareLink, error) {
+	link := &models.ShareLink{}
+	err := row.Scan(
+		&link.ID,
+		&link.AlbumID,
+		&link.CreatedBy,
+		&link.Name,
+		&link.TokenHash,
+		&link.TokenPrefix,
+		pq.Array(&link.PhotoIDs),
+		&link.PasswordHash,
+		&link.Mode,
+		&link.AllowDownload,
+		&link.ExpiresAt,
+		&link.RevokedAt,
+		&link.CreatedAt,
+	)
+	link.PasswordProtected = link.PasswordHash != nil
+	return link, err
+}
+
+func (r *PostgresShareLinkRepository) Create(ctx context.Context, link *models.ShareLink) error {
+	// A nil slice must reach the database as NULL, which means the whole album.
+	var photoIDs interface{}
+	if link.PhotoIDs != nil {
+		photoIDs = pq.Array(link.PhotoIDs)
+	}
+
+	query := `
+		INSERT INTO share_links (album_id, created_by, name, token_hash, token_prefix, photo_ids, password_hash, mode, allow_download, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		link.AlbumID,
+		link.CreatedBy,
+		link.Name,
+		link.TokenHash,
+		link.TokenPrefix,
+		photoIDs,
+		link.PasswordHash,
+		link.Mode,
+		link.AllowDownload,
+		link.ExpiresAt,
+	).Scan(&link.ID, &link.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create share link: %w", err)
+	}
+
+	link.PasswordProtected = link.PasswordHash != nil
+	return nil
+}
+
+func (r *PostgresShareLinkRepository) GetByID(ctx context.Context, id int) (*models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + ` FROM share_links WHERE id = $1`
+	link, err := scanShareLink(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+
+	return link, nil
+}
+
+func (r *PostgresShareLinkRepository) GetByHash(ctx context.Context, tokenHash string) (*models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + ` FROM share_links WHERE token_hash = $1`
+	link, err := scanShareLink(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+
+	return link, nil
+}
+
+// ListByAlbum returns all of the album's links, revoked and expired ones
+// included, newest first.
+func (r *PostgresShareLinkRepository) ListByAlbum(ctx context.Context, albumID int) ([]*models.ShareLink, error) {
+	query := `
+		SELECT ` + shareLinkColumns + `
+		FROM share_links
+		WHERE album_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, albumID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list share links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []*models.ShareLink
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating share links: %w", err)
+	}
+
+	return links, nil
+}
+
+// Revoke revokes one of the album's links. It reports false when there is
+// no such link or it was already revoked.
+func (r *PostgresShareLinkRepository) Revoke(ctx context.Context, albumID, id int) (bool, error) {
+	query := `
+		UPDATE share_links
+		SET revoked_at = NOW()
+		WHERE id = $1 AND album_id = $2 AND revoked_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, id, albumID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke share link: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *PostgresShareLinkRepository) RecordAccess(ctx context.Context, access *models.ShareLinkAccess) error {
+	query := `
+		INSERT INTO share_link_accesses (share_link_id, action, photo_id, ip_address, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		access.ShareLinkID,
+		access.Action,
+		access.PhotoID,
+		access.IPAddress,
+		access.UserAgent,
+	).Scan(&access.ID, &access.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to record share link access: %w", err)
+	}
+
+	return nil
+}
+
+// ListAccesses returns the link's most recent accesses, newest first.
+func (r *PostgresShareLinkRepository) ListAccesses(ctx context.Context, shareLinkID, limit int) ([]*models.ShareLinkAccess, error) {
+	query := `
+		SELECT id, share_link_id, action, photo_id, ip_address, user_agent, created_at
+		FROM share_link_accesses
+		WHERE share_link_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, shareLinkID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list share link accesses: %w", err)
+	}
+	defer rows.Close()
+
+	var accesses []*models.ShareLinkAccess
+	for rows.Next() {
+		access := &models.ShareLinkAccess{}
+		if err := rows.Scan(
+			&access.ID,
+			&access.ShareLinkID,
+			&access.Action,
+			&access.PhotoID,
+			&access.IPAddress,
+			&access.UserAgent,
+			&access.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan share link access: %w", err)
+		}
+		accesses = append(accesses, access)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating share link accesses: %w", err)
+	}
+
+	return accesses, nil
+}
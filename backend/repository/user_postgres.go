@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/suipic/backend/models"
 )
@@ -18,7 +19,7 @@ func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
 
 func (r *PostgresUserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (username, password_hash, email, friendly_name, role, created_at, updated_at)
+		INSERT INTO users (username, password_hash, email, friendly_name, role, avatar_id, email_verified_at, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
@@ -41,7 +42,7 @@ func (r *PostgresUserRepository) Create(ctx context.Context, user *models.User)
 
 func (r *PostgresUserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
 	query := `
-		SELECT id, username, password_hash, email, friendly_name, role, created_at, updated_at
+		SELECT id, username, password_hash, email, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -53,6 +54,11 @@ func (r *PostgresUserRepository) GetByID(ctx context.Context, id int) (*models.U
 		&user.Email,
 		&user.FriendlyName,
 		&user.Role,
+		&user.AvatarID,
+		&user.EmailVerifiedAt,
+		&user.SuspendedAt,
+		&user.SuspensionReason,
+		&user.PasswordResetRequired,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -69,7 +75,7 @@ func (r *PostgresUserRepository) GetByID(ctx context.Context, id int) (*models.U
 
 func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	query := `
-		SELECT id, username, password_hash, email, friendly_name, role, created_at, updated_at
+		SELECT id, username, password_hash, email, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users
 		WHERE username = $1
 	`
@@ -81,6 +87,11 @@ func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username str
 		&user.Email,
 		&user.FriendlyName,
 		&user.Role,
+		&user.AvatarID,
+		&user.EmailVerifiedAt,
+		&user.SuspendedAt,
+		&user.SuspensionReason,
+		&user.PasswordResetRequired,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -97,7 +108,7 @@ func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username str
 
 func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, username, password_hash, email, friendly_name, role, created_at, updated_at
+		SELECT id, username, password_hash, email, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
@@ -109,6 +120,11 @@ func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (
 		&user.Email,
 		&user.FriendlyName,
 		&user.Role,
+		&user.AvatarID,
+		&user.EmailVerifiedAt,
+		&user.SuspendedAt,
+		&user.SuspensionReason,
+		&user.PasswordResetRequired,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -126,8 +142,9 @@ func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (
 func (r *PostgresUserRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users
-		SET username = $1, password_hash = $2, email = $3, friendly_name = $4, role = $5, updated_at = NOW()
-		WHERE id = $6
+		SET username = $1, password_hash = $2, email = $3, friendly_name = $4, role = $5, avatar_id = $6, email_verified_at = $7,
+			suspended_at = $8, suspension_reason = $9, password_reset_required = $10, updated_at = NOW()
+		WHERE id = $11
 		RETURNING updated_at
 	`
 	err := r.db.QueryRowContext(
@@ -138,6 +155,11 @@ func (r *PostgresUserRepository) Update(ctx context.Context, user *models.User)
 		user.Email,
 		user.FriendlyName,
 		user.Role,
+		user.AvatarID,
+		user.EmailVerifiedAt,
+		user.SuspendedAt,
+		user.SuspensionReason,
+		user.PasswordResetRequired,
 		user.ID,
 	).Scan(&user.UpdatedAt)
 
@@ -172,7 +194,7 @@ func (r *PostgresUserRepository) Delete(ctx context.Context, id int) error {
 
 func (r *PostgresUserRepository) List(ctx context.Context, limit, offset int) ([]*models.User, error) {
 	query := `
-		SELECT id, username, password_hash, email, friendly_name, role, created_at, updated_at
+		SELECT id, username, password_hash, email, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users
 		ORDER BY id
 		LIMIT $1 OFFSET $2
@@ -193,6 +215,11 @@ func (r *PostgresUserRepository) List(ctx context.Context, limit, offset int) ([
 			&user.Email,
 			&user.FriendlyName,
 			&user.Role,
+			&user.AvatarID,
+			&user.EmailVerifiedAt,
+			&user.SuspendedAt,
+			&user.SuspensionReason,
+			&user.PasswordResetRequired,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -211,7 +238,7 @@ func (r *PostgresUserRepository) List(ctx context.Context, limit, offset int) ([
 
 func (r *PostgresUserRepository) FindClientsByUsername(ctx context.Context, username string) ([]*models.User, error) {
 	query := `
-		SELECT id, username, password_hash, email, friendly_name, role, created_at, updated_at
+		SELECT id, username, password_hash, email, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users
 		WHERE role = 'client' AND username ILIKE $1
 		ORDER BY username
@@ -232,6 +259,11 @@ func (r *PostgresUserRepository) FindClientsByUsername(ctx context.Context, user
 			&user.Email,
 			&user.FriendlyName,
 			&user.Role,
+			&user.AvatarID,
+			&user.EmailVerifiedAt,
+			&user.SuspendedAt,
+			&user.SuspensionReason,
+			&user.PasswordResetRequired,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -247,3 +279,72 @@ func (r *PostgresUserRepository) FindClientsByUsername(ctx context.Context, user
 
 	return users, nil
 }
+
+// Search returns one page of users matching the filter, ordered by ID, and
+// the number of matching users across all pages. Query matches username,
+// email and friendly name.
+func (r *PostgresUserRepository) Search(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error) {
+	where := `
+		WHERE ($1::text = '' OR role::text = $1::text)
+			AND ($2::text = '' OR username ILIKE $2::text OR email ILIKE $2::text OR friendly_name ILIKE $2::text)
+			AND ($3::text = '' OR ($3::text = 'suspended') = (suspended_at IS NOT NULL))
+	`
+	pattern := ""
+	if filter.Query != "" {
+		pattern = "%" + escapeLike(filter.Query) + "%"
+	}
+	args := []interface{}{string(filter.Role), pattern, filter.Status}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := `
+		SELECT id, username, password_hash, email, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
+		FROM users` + where + `
+		ORDER BY id
+		LIMIT $4 OFFSET $5
+	`
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.PasswordHash,
+			&user.Email,
+			&user.FriendlyName,
+			&user.Role,
+			&user.AvatarID,
+			&user.EmailVerifiedAt,
+			&user.SuspendedAt,
+			&user.SuspensionReason,
+			&user.PasswordResetRequired,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a search term so they match
+// literally.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
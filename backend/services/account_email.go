@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/suipic/backend/mailer"
+	"github.com/suipic/backend/models"
+)
+
+const (
+	emailVerificationExpiry = 48 * time.Hour
+	passwordResetExpiry     = time.Hour
+	mailSendTimeout         = time.Minute
+
+	SessionRevokedPasswordReset = "password_reset"
+)
+
+// EmailVerificationRequired reports whether users must confirm their address
+// before they can sign in.
+func (s *AuthService) EmailVerificationRequired(ctx context.Context) bool {
+	return s.settings.GetBool(ctx, SettingRequireEmailVerification, false)
+}
+
+// SendVerificationEmail mails the user a link that confirms they own their
+// address. Earlier links stop working.
+func (s *AuthService) SendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.issueUserToken(ctx, user.ID, models.TokenPurposeEmailVerification, &user.Email, emailVerificationExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.sendAccountEmail(user, user.Email, mailer.TemplateVerifyEmail, "Confirm email address", "/verify-email", token, emailVerificationExpiry)
+}
+
+// VerifyEmail confirms an address from a link sent by SendVerificationEmail
+// or RequestEmailChange.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	userToken, err := s.lookupUserToken(ctx, token, models.TokenPurposeEmailVerification, models.TokenPurposeEmailChange)
+	if err != nil {
+		return nil, err
+	}
+	if userToken.Purpose == models.TokenPurposeEmailChange {
+		return s.applyEmailChange(ctx, userToken)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, int(userToken.UserID))
+	if err != nil {
+		return nil, err
+	}
+	// The link is for the address it was sent to, not whatever the account
+	// uses now.
+	if user == nil || userToken.Payload == nil || *userToken.Payload != user.Email {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	if err := s.consumeUserToken(ctx, userToken); err != nil {
+		return nil, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// RequestPasswordReset mails a reset link if an account uses the address.
+// It reports success either way so the endpoint cannot be used to find out
+// which addresses have accounts.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.dbService.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	token, err := s.issueUserToken(ctx, user.ID, models.TokenPurposePasswordReset, nil, passwordResetExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.sendAccountEmail(user, user.Email, mailer.TemplatePasswordReset, "Reset password", "/reset-password", token, passwordResetExpiry)
+}
+
+// ResetPassword sets a new password from a reset link, signs the user out
+// everywhere and revokes their API tokens. Following the link also proves the user reads that mailbox, so
+// the address counts as verified.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if len(newPassword) < minPasswordLength {
+		return fmt.Errorf("new password must be at least %d characters", minPasswordLength)
+	}
+
+	userToken, err := s.lookupUserToken(ctx, token, models.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetByID(ctx, int(userToken.UserID))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("invalid or expired token")
+	}
+
+	if err := s.consumeUserToken(ctx, userToken); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.PasswordHash = hashedPassword
+	user.PasswordResetRequired = false
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if _, err := s.sessionRepo.RevokeAllForUser(ctx, user.ID, "", SessionRevokedPasswordReset); err != nil {
+		fmt.Printf("Warning: failed to revoke sessions of user %d after password reset: %v\n", user.ID, err)
+	}
+	if _, err := s.apiTokenRepo.RevokeAllForUser(ctx, user.ID); err != nil {
+		fmt.Printf("Warning: failed to revoke api tokens of user %d: %v\n", user.ID, err)
+	}
+	// Whoever reset the password controls the mailbox, so a lockout from
+	// failed guesses should not keep them out.
+	if _, err := s.UnlockUser(ctx, user.ID); err != nil {
+		fmt.Printf("Warning: failed to unlock user %d after password reset: %v\n", user.ID, err)
+	}
+	return nil
+}
+
+// issueUserToken creates a single-use token for the user, replacing any
+// unused ones issued for the same purpose.
+func (s *AuthService) issueUserToken(ctx context.Context, userID int64, purpose models.UserTokenPurpose, payload *string, expiry time.Duration) (string, error) {
+	if err := s.userTokenRepo.DeleteUnused(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	err = s.userTokenRepo.Create(ctx, &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		Payload:   payload,
+		ExpiresAt: time.Now().Add(expiry),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// lookupUserToken finds an unused, unexpired token issued for one of the
+// given purposes.
+func (s *AuthService) lookupUserToken(ctx context.Context, token string, purposes ...models.UserTokenPurpose) (*models.UserToken, error) {
+	userToken, err := s.userTokenRepo.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if userToken == nil || userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+	for _, purpose := range purposes {
+		if userToken.Purpose == purpose {
+			return userToken, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid or expired token")
+}
+
+// consumeUserToken marks a token used, failing if a concurrent request got
+// there first.
+func (s *AuthService) consumeUserToken(ctx context.Context, userToken *models.UserToken) error {
+	consumed, err := s.userTokenRepo.MarkUsed(ctx, userToken.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return fmt.Errorf("invalid or expired token")
+	}
+	return nil
+}
+
+// sendAccountEmail renders a template with a link to path in the web app
+// carrying the token, and sends it in the background so that slow mail
+// servers do not hold up the request or reveal whether an account exists.
+func (s *AuthService) sendAccountEmail(user *models.User, to, template, action, path, token string, expiry time.Duration) error {
+	name := user.FriendlyName
+	if name == "" {
+		name = user.Username
+	}
+
+	msg, err := mailer.Render(template, to, &mailer.TemplateData{
+		AppName:   "Suipic",
+		Name:      name,
+		Link:      s.publicURL + path + "?token=" + url.QueryEscape(token),
+		Action:    action,
+		ExpiresIn: formatExpiry(expiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.deliverEmail(msg, fmt.Sprintf("%s email to user %d", template, user.ID))
+	return nil
+}
+
+// deliverEmail sends msg in the background, logging failures as what.
+func (s *AuthService) deliverEmail(msg *mailer.Message, what string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			fmt.Printf("Warning: failed to send %s: %v\n", what, err)
+		}
+	}()
+}
+
+func formatExpiry(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0 && d > 24*time.Hour:
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	case d == time.Hour:
+		return "1 hour"
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	}
+}
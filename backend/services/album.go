@@ -3,28 +3,160 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/suipic/backend/models"
 	"github.com/suipic/backend/repository"
 )
 
+// ErrQuotaExceeded is returned when an organization has used up a quota.
+var ErrQuotaExceeded = errors.New("organization quota exceeded")
+
 type AlbumService struct {
-	albumRepo     repository.AlbumRepository
-	albumUserRepo repository.AlbumUserRepository
+	albumRepo        repository.AlbumRepository
+	albumUserRepo    repository.AlbumUserRepository
+	collaboratorRepo repository.AlbumCollaboratorRepository
+	orgRepo          repository.OrganizationRepository
 }
 
 func NewAlbumService(db *sql.DB) *AlbumService {
 	return &AlbumService{
-		albumRepo:     repository.NewPostgresAlbumRepository(db),
-		albumUserRepo: repository.NewPostgresAlbumUserRepository(db),
+		albumRepo:        repository.NewPostgresAlbumRepository(db),
+		albumUserRepo:    repository.NewPostgresAlbumUserRepository(db),
+		collaboratorRepo: repository.NewPostgresAlbumCollaboratorRepository(db),
+		orgRepo:          repository.NewPostgresOrganizationRepository(db),
 	}
 }
 
+// CreateAlbum creates the album in its photographer's organization, if they
+// belong to one and it has room for another album.
 func (s *AlbumService) CreateAlbum(ctx context.Context, album *models.Album) error {
+	album.OrganizationID = nil
+	member, err := s.orgRepo.GetMembership(ctx, int64(album.PhotographerID))
+	if err != nil {
+		return err
+	}
+	if member != nil {
+		if err := s.checkQuota(ctx, member.OrganizationID, "albums"); err != nil {
+			return err
+		}
+		album.OrganizationID = &member.OrganizationID
+	}
+
 	return s.albumRepo.Create(ctx, album)
 }
 
+// CheckPhotoQuota fails with ErrQuotaExceeded when the album's organization
+// may not hold another photo.
+func (s *AlbumService) CheckPhotoQuota(ctx context.Context, album *models.Album) error {
+	if album.OrganizationID == nil {
+		return nil
+	}
+	return s.checkQuota(ctx, *album.OrganizationID, "photos")
+}
+
+// checkQuota fails with ErrQuotaExceeded when the organization has no room
+// for another of what (members, albums or photos).
+func (s *AlbumService) checkQuota(ctx context.Context, organizationID int, what string) error {
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if org == nil {
+		return nil
+	}
+
+	var limit *int
+	switch what {
+	case "members":
+		limit = org.MaxMembers
+	case "albums":
+		limit = org.MaxAlbums
+	case "photos":
+		limit = org.MaxPhotos
+	}
+	if limit == nil {
+		return nil
+	}
+
+	usage, err := s.orgRepo.GetUsage(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	used := map[string]int{"members": usage.Members, "albums": usage.Albums, "photos": usage.Photos}[what]
+	if used >= *limit {
+		return fmt.Errorf("%w: the organization may have at most %d %s", ErrQuotaExceeded, *limit, what)
+	}
+	return nil
+}
+
+// checkMoveQuota fails with ErrQuotaExceeded unless the photographer's
+// albums outside the organization, and their photos, fit in what is left of
+// its album and photo quotas.
+func (s *AlbumService) checkMoveQuota(ctx context.Context, photographerID int64, organizationID int) error {
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if org == nil || (org.MaxAlbums == nil && org.MaxPhotos == nil) {
+		return nil
+	}
+
+	usage, err := s.orgRepo.GetUsage(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	incoming, err := s.orgRepo.GetIncomingUsage(ctx, organizationID, photographerID)
+	if err != nil {
+		return err
+	}
+
+	if org.MaxAlbums != nil && usage.Albums+incoming.Albums > *org.MaxAlbums {
+		return fmt.Errorf("%w: the organization may have at most %d albums and moving in %d would make %d", ErrQuotaExceeded, *org.MaxAlbums, incoming.Albums, usage.Albums+incoming.Albums)
+	}
+	if org.MaxPhotos != nil && usage.Photos+incoming.Photos > *org.MaxPhotos {
+		return fmt.Errorf("%w: the organization may have at most %d photos and moving in %d would make %d", ErrQuotaExceeded, *org.MaxPhotos, incoming.Photos, usage.Photos+incoming.Photos)
+	}
+	return nil
+}
+
+// moveAlbumsToOrganization moves the photographer's albums into the
+// organization, or out of theirs when organizationID is nil. Moving in fails
+// with ErrQuotaExceeded when the albums do not fit the organization's quotas.
+func (s *AlbumService) moveAlbumsToOrganization(ctx context.Context, photographerID int64, organizationID *int) error {
+	if organizationID != nil {
+		if err := s.checkMoveQuota(ctx, photographerID, *organizationID); err != nil {
+			return err
+		}
+	}
+	return s.albumRepo.SetOrganizationForPhotographer(ctx, int(photographerID), organizationID)
+}
+
+// managesAlbum reports whether the user is an owner or manager of the
+// organization the album belongs to.
+func (s *AlbumService) managesAlbum(ctx context.Context, userID int, album *models.Album) (bool, error) {
+	if album.OrganizationID == nil {
+		return false, nil
+	}
+	member, err := s.orgRepo.GetMembership(ctx, int64(userID))
+	if err != nil {
+		return false, err
+	}
+	return member != nil && member.OrganizationID == *album.OrganizationID && member.CanManage(), nil
+}
+
+// CanManageAlbum reports whether the user may edit, share, assign users to
+// and delete from the album: admins, the album's photographer and the
+// owners and managers of its organization may.
+func (s *AlbumService) CanManageAlbum(ctx context.Context, userID int, role models.UserRole, album *models.Album) (bool, error) {
+	if role == models.RoleAdmin || album.PhotographerID == userID {
+		return true, nil
+	}
+	return s.managesAlbum(ctx, userID, album)
+}
+
 func (s *AlbumService) GetAlbumByID(ctx context.Context, id int) (*models.Album, error) {
 	return s.albumRepo.GetByID(ctx, id)
 }
@@ -49,23 +181,77 @@ func (s *AlbumService) ListAlbums(ctx context.Context, photographerID *int, user
 	return s.albumRepo.List(ctx, 1000, 0)
 }
 
-func (s *AlbumService) AssignUsersToAlbum(ctx context.Context, albumID int, userIDs []int) error {
+// ListAlbumsForPhotographer returns the albums the photographer owns,
+// collaborates on or manages for their organization, newest first.
+func (s *AlbumService) ListAlbumsForPhotographer(ctx context.Context, photographerID int) ([]*models.Album, error) {
+	owned, err := s.albumRepo.GetByPhotographer(ctx, photographerID)
+	if err != nil {
+		return nil, err
+	}
+	collaborating, err := s.albumRepo.GetByCollaborator(ctx, photographerID)
+	if err != nil {
+		return nil, err
+	}
+	var managed []*models.Album
+	member, err := s.orgRepo.GetMembership(ctx, int64(photographerID))
+	if err != nil {
+		return nil, err
+	}
+	if member != nil && member.CanManage() {
+		managed, err = s.albumRepo.GetByOrganization(ctx, member.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(collaborating) == 0 && len(managed) == 0 {
+		return owned, nil
+	}
+
+	seen := make(map[int]bool)
+	var albums []*models.Album
+	for _, list := range [][]*models.Album{owned, collaborating, managed} {
+		for _, album := range list {
+			if !seen[album.ID] {
+				seen[album.ID] = true
+				albums = append(albums, album)
+			}
+		}
+	}
+	sort.SliceStable(albums, func(i, j int) bool {
+		return albums[i].CreatedAt.After(albums[j].CreatedAt)
+	})
+	return albums, nil
+}
+
+// AssignUsersToAlbum replaces the album's assigned users, with their
+// permissions, by albumUsers.
+func (s *AlbumService) AssignUsersToAlbum(ctx context.Context, albumID int, albumUsers []*models.AlbumUser) error {
 	if err := s.albumUserRepo.DeleteByAlbum(ctx, albumID); err != nil {
 		return fmt.Errorf("failed to clear existing users: %w", err)
 	}
 
-	for _, userID := range userIDs {
-		albumUser := &models.AlbumUser{
-			AlbumID: albumID,
-			UserID:  userID,
-		}
+	for _, albumUser := range albumUsers {
+		albumUser.AlbumID = albumID
 		if err := s.albumUserRepo.Create(ctx, albumUser); err != nil {
-			return fmt.Errorf("failed to assign user %d to album: %w", userID, err)
+			return fmt.Errorf("failed to assign user %d to album: %w", albumUser.UserID, err)
 		}
 	}
 	return nil
 }
 
+// AddUserToAlbum assigns a user to an album with the default permissions,
+// keeping the users already assigned. Assigning a user twice is a no-op.
+func (s *AlbumService) AddUserToAlbum(ctx context.Context, albumID, userID int) error {
+	assigned, err := s.albumUserRepo.IsUserInAlbum(ctx, userID, albumID)
+	if err != nil {
+		return err
+	}
+	if assigned {
+		return nil
+	}
+	return s.albumUserRepo.Create(ctx, models.NewAlbumUser(albumID, userID))
+}
+
 func (s *AlbumService) GetAlbumUsers(ctx context.Context, albumID int) ([]*models.AlbumUser, error) {
 	return s.albumUserRepo.GetByAlbum(ctx, albumID)
 }
@@ -83,5 +269,166 @@ func (s *AlbumService) CanUserAccessAlbum(ctx context.Context, userID int, album
 		return true, nil
 	}
 
+	managed, err := s.managesAlbum(ctx, userID, album)
+	if err != nil || managed {
+		return managed, err
+	}
+
+	collaborator, err := s.collaboratorRepo.IsCollaborator(ctx, albumID, userID)
+	if err != nil || collaborator {
+		return collaborator, err
+	}
+
 	return s.albumUserRepo.IsUserInAlbum(ctx, userID, albumID)
 }
+
+// CanUploadToAlbum reports whether the user may add photos to the album:
+// admins, the album's photographer, its organization's owners and managers
+// and its collaborators may.
+func (s *AlbumService) CanUploadToAlbum(ctx context.Context, userID int, role models.UserRole, albumID int) (bool, error) {
+	if role == models.RoleAdmin {
+		return true, nil
+	}
+
+	album, err := s.albumRepo.GetByID(ctx, albumID)
+	if err != nil {
+		return false, err
+	}
+	if album == nil {
+		return false, fmt.Errorf("album not found")
+	}
+	if album.PhotographerID == userID {
+		return true, nil
+	}
+
+	managed, err := s.managesAlbum(ctx, userID, album)
+	if err != nil || managed {
+		return managed, err
+	}
+
+	return s.collaboratorRepo.IsCollaborator(ctx, albumID, userID)
+}
+
+// AddCollaborator lets another photographer work on the album.
+func (s *AlbumService) AddCollaborator(ctx context.Context, album *models.Album, collaborator *models.User, addedBy int) (*models.AlbumCollaborator, error) {
+	if collaborator.Role != models.RolePhotographer {
+		return nil, fmt.Errorf("only photographers can collaborate on albums")
+	}
+	if int(collaborator.ID) == album.PhotographerID {
+		return nil, fmt.Errorf("the album's photographer cannot be added as a collaborator")
+	}
+
+	exists, err := s.collaboratorRepo.IsCollaborator(ctx, album.ID, int(collaborator.ID))
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("user is already a collaborator on this album")
+	}
+
+	albumCollaborator := &models.AlbumCollaborator{
+		AlbumID:      album.ID,
+		UserID:       int(collaborator.ID),
+		AddedBy:      &addedBy,
+		Username:     collaborator.Username,
+		FriendlyName: collaborator.FriendlyName,
+	}
+	if err := s.collaboratorRepo.Create(ctx, albumCollaborator); err != nil {
+		return nil, err
+	}
+	return albumCollaborator, nil
+}
+
+// RemoveCollaborator reports false when the user was not a collaborator.
+// Photos they uploaded stay in the album.
+func (s *AlbumService) RemoveCollaborator(ctx context.Context, albumID, userID int) (bool, error) {
+	return s.collaboratorRepo.Delete(ctx, albumID, userID)
+}
+
+func (s *AlbumService) GetAlbumCollaborators(ctx context.Context, albumID int) ([]*models.AlbumCollaborator, error) {
+	collaborators, err := s.collaboratorRepo.GetByAlbum(ctx, albumID)
+	if err != nil {
+		return nil, err
+	}
+	if collaborators == nil {
+		collaborators = []*models.AlbumCollaborator{}
+	}
+	return collaborators, nil
+}
+
+// collaboratorPermissions is what collaborators may do in an album besides
+// uploading. Rating and picking are left to the clients, and sharing to the
+// album's photographer.
+var collaboratorPermissions = map[models.AlbumPermission]bool{
+	models.AlbumPermissionView:     true,
+	models.AlbumPermissionComment:  true,
+	models.AlbumPermissionDownload: true,
+}
+
+// HasAlbumPermission reports whether the user may do what the permission
+// covers in the album. Admins, the album's photographer and its
+// organization's owners and managers always may;
+// collaborators and assigned users as far as their role allows.
+func (s *AlbumService) HasAlbumPermission(ctx context.Context, userID int, role models.UserRole, albumID int, permission models.AlbumPermission) (bool, error) {
+	if role == models.RoleAdmin {
+		return true, nil
+	}
+
+	album, err := s.albumRepo.GetByID(ctx, albumID)
+	if err != nil {
+		return false, err
+	}
+	if album == nil {
+		return false, fmt.Errorf("album not found")
+	}
+	if album.PhotographerID == userID {
+		return true, nil
+	}
+
+	managed, err := s.managesAlbum(ctx, userID, album)
+	if err != nil || managed {
+		return managed, err
+	}
+
+	collaborator, err := s.collaboratorRepo.IsCollaborator(ctx, albumID, userID)
+	if err != nil {
+		return false, err
+	}
+	if collaborator {
+		return collaboratorPermissions[permission], nil
+	}
+
+	albumUser, err := s.albumUserRepo.GetByAlbumAndUser(ctx, albumID, userID)
+	if err != nil {
+		return false, err
+	}
+	return albumUser != nil && albumUser.Allows(permission), nil
+}
+
+// AccessibleAlbumIDs lists the albums a user may see: the ones they own or
+// collaborate on and the ones they are assigned to. Admins see everything,
+// which is reported as nil.
+func (s *AlbumService) AccessibleAlbumIDs(ctx context.Context, userID int, role models.UserRole) ([]int, error) {
+	if role == models.RoleAdmin {
+		return nil, nil
+	}
+
+	assigned, err := s.albumRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	albums := assigned
+	if role == models.RolePhotographer {
+		worked, err := s.ListAlbumsForPhotographer(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		albums = append(worked, assigned...)
+	}
+
+	ids := make([]int, 0, len(albums))
+	for _, album := range albums {
+		ids = append(ids, album.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/suipic/backend/models"
+)
+
+const (
+	// APITokenPrefix marks personal access tokens so they can be told apart
+	// from session JWTs, and found by secret scanners.
+	APITokenPrefix = "spat_"
+
+	defaultAPITokenExpiry = 90 * 24 * time.Hour
+	maxAPITokenExpiry     = 365 * 24 * time.Hour
+	maxAPITokenNameLength = 100
+)
+
+// CreatedAPIToken is returned once when a token is created; the plain token
+// cannot be retrieved again.
+type CreatedAPIToken struct {
+	*models.APIToken
+	Token string `json:"token"`
+}
+
+// CreateAPIToken issues a personal access token for the user. expiresIn of
+// zero uses the default lifetime. The admin scope is only granted to admins.
+func (s *AuthService) CreateAPIToken(ctx context.Context, user *models.User, name string, scopes []string, expiresIn time.Duration) (*CreatedAPIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(name) > maxAPITokenNameLength {
+		return nil, fmt.Errorf("name must be at most %d characters", maxAPITokenNameLength)
+	}
+
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case models.ScopeRead, models.ScopeUpload, models.ScopeWrite:
+		case models.ScopeAdmin:
+			if user.Role != models.RoleAdmin {
+				return nil, fmt.Errorf("only admins can create tokens with the admin scope")
+			}
+		default:
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	if expiresIn == 0 {
+		expiresIn = defaultAPITokenExpiry
+	}
+	if expiresIn < 0 || expiresIn > maxAPITokenExpiry {
+		return nil, fmt.Errorf("tokens must expire within %d days", int(maxAPITokenExpiry.Hours()/24))
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	plain := APITokenPrefix + secret
+
+	token := &models.APIToken{
+		UserID:      user.ID,
+		Name:        name,
+		TokenHash:   hashToken(plain),
+		TokenPrefix: plain[:len(APITokenPrefix)+6],
+		Scopes:      scopes,
+		ExpiresAt:   time.Now().Add(expiresIn),
+	}
+	if err := s.apiTokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &CreatedAPIToken{APIToken: token, Token: plain}, nil
+}
+
+func (s *AuthService) ListAPITokens(ctx context.Context, userID int64) ([]*models.APIToken, error) {
+	tokens, err := s.apiTokenRepo.ListActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []*models.APIToken{}
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken revokes one of the user's tokens. It reports false when the
+// user has no active token with that ID.
+func (s *AuthService) RevokeAPIToken(ctx context.Context, userID int64, tokenID int) (bool, error) {
+	return s.apiTokenRepo.Revoke(ctx, userID, tokenID)
+}
+
+// ValidateAPIToken checks a personal access token and returns it with the
+// user it belongs to.
+func (s *AuthService) ValidateAPIToken(ctx context.Context, plain string, client SessionClient) (*models.APIToken, *models.User, error) {
+	token, err := s.apiTokenRepo.GetByHash(ctx, hashToken(plain))
+	if err != nil {
+		return nil, nil, err
+	}
+	if token == nil || !token.Active(time.Now()) {
+		return nil, nil, fmt.Errorf("token is invalid or expired")
+	}
+
+	user, err := s.dbService.GetUserByID(token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, fmt.Errorf("token is invalid or expired")
+	}
+	if user.Suspended() {
+		return nil, nil, ErrAccountSuspended
+	}
+
+	// Tokens keep working after a demotion, but no longer reach admin
+	// endpoints.
+	if user.Role != models.RoleAdmin {
+		token.Scopes = slices.DeleteFunc(token.Scopes, func(scope string) bool {
+			return scope == models.ScopeAdmin
+		})
+	}
+
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > sessionTouchInterval {
+		ip := truncate(client.IPAddress, 64)
+		token.LastUsedIP = &ip
+		if err := s.apiTokenRepo.Touch(ctx, token); err != nil {
+			fmt.Printf("Warning: failed to update last use of api token %d: %v\n", token.ID, err)
+		}
+	}
+
+	return token, user, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/repository"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	// maxAuditExport caps a CSV export, which ignores the page size.
+	maxAuditExport = 50000
+)
+
+// AuditService keeps the append-only audit log of security-relevant and
+// content actions, so disputes such as who picked a photo can be settled.
+type AuditService struct {
+	repo      repository.AuditLogRepository
+	dbService *DatabaseService
+}
+
+func NewAuditService(dbService *DatabaseService) *AuditService {
+	return &AuditService{
+		repo:      dbService.GetAuditLogRepo(),
+		dbService: dbService,
+	}
+}
+
+// Record writes an entry, naming the actor if the caller did not. A failure
+// is only logged: the action itself has already happened.
+func (s *AuditService) Record(ctx context.Context, entry *models.AuditEntry) {
+	if entry.ActorName == "" && entry.ActorID != nil {
+		user, err := s.dbService.GetUserByID(*entry.ActorID)
+		if err != nil {
+			fmt.Printf("Warning: failed to look up audit actor %d: %v\n", *entry.ActorID, err)
+		} else if user != nil {
+			entry.ActorName = user.Username
+		}
+	}
+	if entry.ActorName == "" && entry.ShareLinkID != nil {
+		entry.ActorName = fmt.Sprintf("share link #%d", *entry.ShareLinkID)
+	}
+	entry.ActorName = truncate(entry.ActorName, 255)
+	entry.TargetID = truncate(entry.TargetID, 255)
+	if entry.UserAgent != nil {
+		userAgent := truncate(*entry.UserAgent, 512)
+		entry.UserAgent = &userAgent
+	}
+
+	if err := s.repo.Record(ctx, entry); err != nil {
+		fmt.Printf("Warning: failed to record audit entry %s: %v\n", entry.Action, err)
+	}
+}
+
+// List returns a page of entries matching the filter, newest first.
+func (s *AuditService) List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	entries, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []*models.AuditEntry{}
+	}
+	return entries, nil
+}
+
+// ExportCSV writes every entry matching the filter, up to maxAuditExport,
+// as CSV. Cells that a spreadsheet would run as a formula are escaped, since
+// names, targets and user agents come from users.
+func (s *AuditService) ExportCSV(ctx context.Context, filter models.AuditFilter, w io.Writer) error {
+	filter.Limit = maxAuditExport
+	filter.Offset = 0
+	entries, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	header := []string{"id", "created_at", "actor_id", "actor_name", "share_link_id", "action", "target_type", "target_id", "album_id", "before", "after", "ip_address", "user_agent"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		record := []string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			formatOptionalInt64(entry.ActorID),
+			entry.ActorName,
+			formatOptionalInt(entry.ShareLinkID),
+			string(entry.Action),
+			entry.TargetType,
+			entry.TargetID,
+			formatOptionalInt(entry.AlbumID),
+			formatAuditValues(entry.Before),
+			formatAuditValues(entry.After),
+			formatOptionalString(entry.IPAddress),
+			formatOptionalString(entry.UserAgent),
+		}
+		for i := range record {
+			record[i] = escapeCSVFormula(record[i])
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// escapeCSVFormula prefixes a cell that a spreadsheet could take for a
+// formula with a quote, so that it is shown as text. That covers formula
+// characters after leading whitespace, which spreadsheets skip, and cells
+// starting with a tab or carriage return, which some treat as a formula.
+func escapeCSVFormula(cell string) string {
+	if cell == "" {
+		return cell
+	}
+	trimmed := strings.TrimLeft(cell, " \t\r\n")
+	if cell[0] == '\t' || cell[0] == '\r' || (trimmed != "" && strings.ContainsRune("=+-@", rune(trimmed[0]))) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func formatOptionalInt64(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func formatOptionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatOptionalString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func formatAuditValues(v models.AuditValues) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -2,45 +2,80 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/suipic/backend/jwtkeys"
+	"github.com/suipic/backend/mailer"
 	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
-	dbService  *DatabaseService
-	jwtSecret  string
-	jwtExpiry  string
-	adminEmail string
-	adminPass  string
-	adminUser  string
+	dbService        *DatabaseService
+	userRepo         repository.UserRepository
+	sessionRepo      repository.SessionRepository
+	userTokenRepo    repository.UserTokenRepository
+	mfaRepo          repository.MFARepository
+	apiTokenRepo     repository.APITokenRepository
+	loginRepo        repository.LoginAttemptRepository
+	audit            *AuditService
+	settings         *SystemSettingsService
+	mailer           mailer.Mailer
+	publicURL        string
+	keys             *jwtkeys.KeySet
+	jwtExpiry        string
+	jwtRefreshExpiry string
+	mfaIssuer        string
+	mfaKey           string
+	adminEmail       string
+	adminPass        string
+	adminUser        string
 }
 
 type JWTClaims struct {
-	UserID   int64           `json:"user_id"`
-	Email    string          `json:"email"`
-	Username string          `json:"username"`
-	Role     models.UserRole `json:"role"`
+	UserID    int64           `json:"user_id"`
+	Email     string          `json:"email"`
+	Username  string          `json:"username"`
+	Role      models.UserRole `json:"role"`
+	SessionID string          `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(dbService *DatabaseService, jwtSecret, jwtExpiry, adminEmail, adminPass, adminUser string) (*AuthService, error) {
+func NewAuthService(dbService *DatabaseService, accountMailer mailer.Mailer, publicURL string, keys *jwtkeys.KeySet, jwtExpiry, jwtRefreshExpiry, mfaIssuer, mfaKey, adminEmail, adminPass, adminUser string) (*AuthService, error) {
 	service := &AuthService{
-		dbService:  dbService,
-		jwtSecret:  jwtSecret,
-		jwtExpiry:  jwtExpiry,
-		adminEmail: adminEmail,
-		adminPass:  adminPass,
-		adminUser:  adminUser,
+		dbService:        dbService,
+		userRepo:         dbService.GetUserRepo(),
+		sessionRepo:      dbService.GetSessionRepo(),
+		userTokenRepo:    dbService.GetUserTokenRepo(),
+		mfaRepo:          dbService.GetMFARepo(),
+		apiTokenRepo:     dbService.GetAPITokenRepo(),
+		loginRepo:        dbService.GetLoginAttemptRepo(),
+		audit:            NewAuditService(dbService),
+		settings:         NewSystemSettingsService(dbService.GetSystemSettingsRepo()),
+		mailer:           accountMailer,
+		publicURL:        publicURL,
+		keys:             keys,
+		jwtExpiry:        jwtExpiry,
+		jwtRefreshExpiry: jwtRefreshExpiry,
+		mfaIssuer:        mfaIssuer,
+		mfaKey:           mfaKey,
+		adminEmail:       adminEmail,
+		adminPass:        adminPass,
+		adminUser:        adminUser,
 	}
 
 	if err := service.seedAdminUser(); err != nil {
 		return nil, fmt.Errorf("failed to seed admin user: %w", err)
 	}
 
+	if err := service.checkMFAKey(context.Background()); err != nil {
+		return nil, err
+	}
+
 	return service, nil
 }
 
@@ -73,11 +108,18 @@ func (s *AuthService) seedAdminUser() error {
 		return nil
 	}
 
-	_, err = s.dbService.CreateUser(s.adminEmail, s.adminUser, hashedPassword, models.RoleAdmin)
+	admin, err := s.dbService.CreateUser(s.adminEmail, s.adminUser, hashedPassword, models.RoleAdmin)
 	if err != nil {
 		return fmt.Errorf("failed to create admin user: %w", err)
 	}
 
+	// The address comes from the operator's own configuration.
+	now := time.Now()
+	admin.EmailVerifiedAt = &now
+	if err := s.dbService.GetUserRepo().Update(context.Background(), admin); err != nil {
+		return fmt.Errorf("failed to verify admin email: %w", err)
+	}
+
 	return nil
 }
 
@@ -93,34 +135,10 @@ func (s *AuthService) CheckPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-func (s *AuthService) GenerateToken(user *models.User) (string, error) {
-	expiry, err := time.ParseDuration(s.jwtExpiry)
-	if err != nil {
-		expiry = 24 * time.Hour
-	}
-
-	claims := JWTClaims{
-		UserID:   user.ID,
-		Email:    user.Email,
-		Username: user.Username,
-		Role:     user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
-}
-
+// ValidateToken checks an access token. Only tokens issued for the API
+// audience are accepted, so no other token can stand in for one.
 func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, s.keys.Keyfunc, jwt.WithAudience(AccessTokenAudience))
 
 	if err != nil {
 		return nil, err
@@ -133,6 +151,11 @@ func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// PublicKeys returns the keys other services can verify access tokens with.
+func (s *AuthService) PublicKeys() []jwtkeys.JSONWebKey {
+	return s.keys.JWKS()
+}
+
 func (s *AuthService) Register(email, username, password string, role models.UserRole) (*models.User, error) {
 	return s.RegisterWithFriendlyName(email, username, password, "", role)
 }
@@ -167,59 +190,87 @@ func (s *AuthService) RegisterWithFriendlyName(email, username, password, friend
 	return user, nil
 }
 
-func (s *AuthService) Login(email, password string) (*models.User, string, error) {
+func (s *AuthService) Login(email, password string, client SessionClient) (*LoginResult, error) {
 	user, err := s.dbService.GetUserByEmail(email)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
-	if user == nil {
-		return nil, "", fmt.Errorf("invalid credentials")
+
+	ctx := context.Background()
+	if err := s.authenticatePassword(ctx, user, email, password, client); err != nil {
+		return nil, err
 	}
 
-	if err := s.CheckPassword(user.PasswordHash, password); err != nil {
-		return nil, "", fmt.Errorf("invalid credentials")
+	return s.beginPasswordSession(ctx, user, email, client)
+}
+
+// beginPasswordSession signs in a user whose password checked out. The
+// login only counts as successful once a session exists, which
+// beginSession records; when a second factor is still needed,
+// CompleteMFALogin records the outcome.
+func (s *AuthService) beginPasswordSession(ctx context.Context, user *models.User, identifier string, client SessionClient) (*LoginResult, error) {
+	if err := s.checkEmailVerified(ctx, user); err != nil {
+		s.auditLogin(ctx, user, identifier, client, models.LoginAttemptEmailUnverified)
+		return nil, err
 	}
 
-	token, err := s.GenerateToken(user)
+	result, err := s.beginSession(ctx, user, identifier, client)
 	if err != nil {
-		return nil, "", err
+		switch {
+		case errors.Is(err, ErrAccountSuspended):
+			s.auditLogin(ctx, user, identifier, client, models.LoginAttemptAccountSuspended)
+		case errors.Is(err, ErrPasswordResetRequired):
+			s.auditLogin(ctx, user, identifier, client, models.LoginAttemptPasswordResetRequired)
+		}
+		return nil, err
 	}
+	if result.Tokens != nil {
+		s.clearLoginFailures(ctx, user)
+	}
+	return result, nil
+}
 
-	return user, token, nil
+// checkEmailVerified refuses sign-in for unverified addresses when the
+// require_email_verification setting is on. Admins are never locked out.
+func (s *AuthService) checkEmailVerified(ctx context.Context, user *models.User) error {
+	if user.EmailVerifiedAt != nil || user.Role == models.RoleAdmin {
+		return nil
+	}
+	if s.EmailVerificationRequired(ctx) {
+		return fmt.Errorf("email address has not been verified")
+	}
+	return nil
 }
 
-func (s *AuthService) LoginWithUsernameOrEmail(username, email, password string) (*models.User, string, error) {
+func (s *AuthService) LoginWithUsernameOrEmail(username, email, password string, client SessionClient) (*LoginResult, error) {
 	var user *models.User
 	var err error
 
 	if username != "" {
 		user, err = s.dbService.GetUserByUsername(username)
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
 	}
 
 	if user == nil && email != "" {
 		user, err = s.dbService.GetUserByEmail(email)
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
 	}
 
-	if user == nil {
-		return nil, "", fmt.Errorf("invalid credentials")
+	identifier := username
+	if identifier == "" {
+		identifier = email
 	}
 
-	if err := s.CheckPassword(user.PasswordHash, password); err != nil {
-		return nil, "", fmt.Errorf("invalid credentials")
-	}
-
-	token, err := s.GenerateToken(user)
-	if err != nil {
-		return nil, "", err
+	ctx := context.Background()
+	if err := s.authenticatePassword(ctx, user, identifier, password, client); err != nil {
+		return nil, err
 	}
 
-	return user, token, nil
+	return s.beginPasswordSession(ctx, user, identifier, client)
 }
 
 func (s *AuthService) GetUserByID(userID int64) (*models.User, error) {
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/suipic/backend/models"
+)
+
+// Camera clocks have no zone. EXIF 2.31 added OffsetTimeOriginal for it, and
+// older bodies leave it out, in which case the album's timezone is used.
+var exifDateFormats = []string{
+	"2006:01:02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// CameraBody identifies the camera a photo was taken with. Second shooters
+// often use the same model, so the serial number is part of the key.
+type CameraBody struct {
+	Make         string `json:"make"`
+	Model        string `json:"model"`
+	SerialNumber string `json:"serialNumber"`
+}
+
+type CameraBodySummary struct {
+	CameraBody
+	PhotoCount   int        `json:"photoCount"`
+	FirstCapture *time.Time `json:"firstCapture,omitempty"`
+	LastCapture  *time.Time `json:"lastCapture,omitempty"`
+}
+
+func cameraBodyOf(photo *models.Photo) CameraBody {
+	field := func(key string) string {
+		value, _ := photo.ExifData[key].(string)
+		return strings.TrimSpace(value)
+	}
+	return CameraBody{
+		Make:         field("Make"),
+		Model:        field("Model"),
+		SerialNumber: field("BodySerialNumber"),
+	}
+}
+
+// captureTime reads when a photo was taken from its EXIF data, using the
+// recorded offset when there is one and loc otherwise.
+func captureTime(exifData models.ExifData, loc *time.Location) *time.Time {
+	dateStr, ok := exifData["DateTimeOriginal"].(string)
+	if !ok {
+		return nil
+	}
+	dateStr = strings.TrimSpace(dateStr)
+
+	if t, err := time.Parse(time.RFC3339, dateStr); err == nil {
+		return &t
+	}
+
+	if offset := exifOffset(exifData); offset != nil {
+		loc = offset
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, format := range exifDateFormats {
+		if t, err := time.ParseInLocation(format, dateStr, loc); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+// exifOffset returns the zone recorded alongside DateTimeOriginal, such as
+// "+02:00", or nil when the camera did not write one.
+func exifOffset(exifData models.ExifData) *time.Location {
+	for _, key := range []string{"OffsetTimeOriginal", "OffsetTime"} {
+		value, ok := exifData[key].(string)
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "Z" {
+			return time.UTC
+		}
+		t, err := time.Parse("-07:00", value)
+		if err != nil {
+			continue
+		}
+		_, seconds := t.Zone()
+		return time.FixedZone(value, seconds)
+	}
+	return nil
+}
+
+// AlbumLocation returns the album's timezone, or UTC when it has none or it
+// cannot be loaded.
+func AlbumLocation(album *models.Album) *time.Location {
+	if album == nil || album.Timezone == nil || *album.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(*album.Timezone)
+	if err != nil {
+		fmt.Printf("Warning: invalid timezone %q on album %d: %v\n", *album.Timezone, album.ID, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// ListCameraBodies groups an album's photos by the camera that took them.
+func (s *PhotoService) ListCameraBodies(ctx context.Context, albumID int) ([]*CameraBodySummary, error) {
+	photos, err := s.photoRepo.GetByAlbum(ctx, albumID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photos: %w", err)
+	}
+
+	summaries := make(map[CameraBody]*CameraBodySummary)
+	for _, photo := range photos {
+		body := cameraBodyOf(photo)
+		summary, ok := summaries[body]
+		if !ok {
+			summary = &CameraBodySummary{CameraBody: body}
+			summaries[body] = summary
+		}
+		summary.PhotoCount++
+		if photo.DateTime == nil {
+			continue
+		}
+		if summary.FirstCapture == nil || photo.DateTime.Before(*summary.FirstCapture) {
+			summary.FirstCapture = photo.DateTime
+		}
+		if summary.LastCapture == nil || photo.DateTime.After(*summary.LastCapture) {
+			summary.LastCapture = photo.DateTime
+		}
+	}
+
+	result := make([]*CameraBodySummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PhotoCount > result[j].PhotoCount
+	})
+	return result, nil
+}
+
+// ShiftCaptureTime moves the capture time of every photo in the album taken
+// with the given camera body by shift, to correct a camera clock that was
+// off. Shifts accumulate on the photo so they survive a timezone change. It
+// returns the number of photos updated.
+func (s *PhotoService) ShiftCaptureTime(ctx context.Context, album *models.Album, body CameraBody, shift time.Duration) (int, error) {
+	photos, err := s.photoRepo.GetByAlbum(ctx, album.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get photos: %w", err)
+	}
+
+	updated := 0
+	for _, photo := range photos {
+		if photo.DateTime == nil || cameraBodyOf(photo) != body {
+			continue
+		}
+
+		shifted := photo.DateTime.Add(shift)
+		photo.DateTime = &shifted
+		photo.TimeShift += int(shift / time.Second)
+		if err := s.photoRepo.Update(ctx, photo); err != nil {
+			return updated, fmt.Errorf("failed to update photo %d: %w", photo.ID, err)
+		}
+		updated++
+	}
+
+	s.reindexAlbum(ctx, album.ID, updated)
+	return updated, nil
+}
+
+// RecomputeCaptureTimes re-reads every photo's capture time from its EXIF
+// data against the album's current timezone, keeping any clock shifts
+// already applied. It is run when the album timezone changes.
+func (s *PhotoService) RecomputeCaptureTimes(ctx context.Context, album *models.Album) error {
+	photos, err := s.photoRepo.GetByAlbum(ctx, album.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get photos: %w", err)
+	}
+
+	loc := AlbumLocation(album)
+	updated := 0
+	for _, photo := range photos {
+		dateTime := captureTime(photo.ExifData, loc)
+		if dateTime == nil {
+			continue
+		}
+		shifted := dateTime.Add(time.Duration(photo.TimeShift) * time.Second)
+		if photo.DateTime != nil && photo.DateTime.Equal(shifted) {
+			continue
+		}
+
+		photo.DateTime = &shifted
+		if err := s.photoRepo.Update(ctx, photo); err != nil {
+			return fmt.Errorf("failed to update photo %d: %w", photo.ID, err)
+		}
+		updated++
+	}
+
+	s.reindexAlbum(ctx, album.ID, updated)
+	return nil
+}
+
+func (s *PhotoService) reindexAlbum(ctx context.Context, albumID int, changed int) {
+	if s.esService == nil || changed == 0 {
+		return
+	}
+	if err := s.BulkIndexPhotosByAlbum(ctx, albumID); err != nil {
+		fmt.Printf("Warning: failed to reindex album %d: %v\n", albumID, err)
+	}
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/suipic/backend/mailer"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/repository"
+)
+
+const clientInviteExpiry = 7 * 24 * time.Hour
+
+var (
+	ErrInviteInvalid       = errors.New("invalid or expired invitation")
+	ErrInviteAccountExists = errors.New("an account with this email address already exists; sign in to accept the invitation")
+	ErrInviteWrongAccount  = errors.New("this invitation was sent to a different email address; sign in with that account to accept it")
+)
+
+// InvitePreview is what the invite page shows before the link is accepted.
+type InvitePreview struct {
+	Email         string    `json:"email"`
+	FriendlyName  string    `json:"friendlyName"`
+	Photographer  string    `json:"photographer"`
+	AlbumCount    int       `json:"albumCount"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	AccountExists bool      `json:"accountExists"`
+}
+
+// InviteService lets photographers invite clients by email, so that clients
+// choose their own password instead of being handed one.
+type InviteService struct {
+	authService  *AuthService
+	albumService *AlbumService
+	dbService    *DatabaseService
+	inviteRepo   repository.ClientInviteRepository
+}
+
+func NewInviteService(authService *AuthService, albumService *AlbumService, dbService *DatabaseService) *InviteService {
+	return &InviteService{
+		authService:  authService,
+		albumService: albumService,
+		dbService:    dbService,
+		inviteRepo:   dbService.GetClientInviteRepo(),
+	}
+}
+
+// CreateInvite invites email to become the photographer's client, to be
+// assigned to albumIDs on acceptance. Admins may pre-assign any album,
+// photographers only their own.
+func (s *InviteService) CreateInvite(ctx context.Context, inviter *models.User, email, friendlyName string, albumIDs []int64) (*models.ClientInvite, error) {
+	email = strings.TrimSpace(email)
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	friendlyName = strings.TrimSpace(friendlyName)
+	if len(friendlyName) > maxFriendlyNameLen {
+		return nil, fmt.Errorf("friendly name must be at most %d characters", maxFriendlyNameLen)
+	}
+	albumIDs, err := s.checkAlbums(ctx, inviter, albumIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.dbService.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.Role != models.RoleClient {
+			return nil, fmt.Errorf("user is not a client")
+		}
+		relation, err := s.dbService.GetPhotographerClient(inviter.ID, existing.ID)
+		if err != nil {
+			return nil, err
+		}
+		if relation != nil {
+			return nil, fmt.Errorf("client already linked to photographer")
+		}
+	}
+
+	open, err := s.inviteRepo.GetOpenByEmail(ctx, inviter.ID, email)
+	if err != nil {
+		return nil, err
+	}
+	if open != nil {
+		if open.Usable(time.Now()) {
+			return nil, fmt.Errorf("an invitation to this address is already pending; resend it instead")
+		}
+		// An expired invite would otherwise block inviting the address again.
+		if _, err := s.inviteRepo.Revoke(ctx, inviter.ID, open.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	invite := &models.ClientInvite{
+		PhotographerID: inviter.ID,
+		Email:          email,
+		FriendlyName:   friendlyName,
+		AlbumIDs:       albumIDs,
+		TokenHash:      hashToken(token),
+		ExpiresAt:      time.Now().Add(clientInviteExpiry),
+	}
+	if err := s.inviteRepo.Create(ctx, invite); err != nil {
+		return nil, err
+	}
+
+	if err := s.sendInvite(inviter, invite, token); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// checkAlbums drops duplicates and makes sure the inviter may assign users
+// to every album.
+func (s *InviteService) checkAlbums(ctx context.Context, inviter *models.User, albumIDs []int64) ([]int64, error) {
+	checked := make([]int64, 0, len(albumIDs))
+	seen := make(map[int64]bool, len(albumIDs))
+	for _, albumID := range albumIDs {
+		if seen[albumID] {
+			continue
+		}
+		seen[albumID] = true
+
+		album, err := s.albumService.GetAlbumByID(ctx, int(albumID))
+		if err != nil {
+			return nil, err
+		}
+		if album == nil {
+			return nil, fmt.Errorf("album %d not found", albumID)
+		}
+		if inviter.Role != models.RoleAdmin && int64(album.PhotographerID) != inviter.ID {
+			return nil, fmt.Errorf("you can only assign users to your own albums")
+		}
+		checked = append(checked, albumID)
+	}
+	return checked, nil
+}
+
+func (s *InviteService) ListInvites(ctx context.Context, photographerID int64) ([]*models.ClientInvite, error) {
+	invites, err := s.inviteRepo.ListOpenByPhotographer(ctx, photographerID)
+	if err != nil {
+		return nil, err
+	}
+	if invites == nil {
+		invites = []*models.ClientInvite{}
+	}
+	return invites, nil
+}
+
+// ResendInvite mails a fresh link for an open invite, which also extends
+// its expiry. The previous link stops working. It returns nil when the
+// photographer has no such open invite.
+func (s *InviteService) ResendInvite(ctx context.Context, inviter *models.User, inviteID int) (*models.ClientInvite, error) {
+	invite, err := s.inviteRepo.GetByID(ctx, inviteID)
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || invite.PhotographerID != inviter.ID || !invite.Open() {
+		return nil, nil
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	invite.TokenHash = hashToken(token)
+	invite.ExpiresAt = time.Now().Add(clientInviteExpiry)
+	if err := s.inviteRepo.Reissue(ctx, invite); err != nil {
+		return nil, err
+	}
+
+	if err := s.sendInvite(inviter, invite, token); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// RevokeInvite makes an open invite's link stop working. It reports false
+// when the photographer has no such open invite.
+func (s *InviteService) RevokeInvite(ctx context.Context, photographerID int64, inviteID int) (bool, error) {
+	return s.inviteRepo.Revoke(ctx, photographerID, inviteID)
+}
+
+// PreviewInvite describes the invite behind a link without using it up.
+func (s *InviteService) PreviewInvite(ctx context.Context, token string) (*InvitePreview, error) {
+	invite, err := s.lookupInvite(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	photographer, err := s.dbService.GetUserByID(invite.PhotographerID)
+	if err != nil {
+		return nil, err
+	}
+	if photographer == nil {
+		return nil, ErrInviteInvalid
+	}
+	existing, err := s.dbService.GetUserByEmail(invite.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InvitePreview{
+		Email:         invite.Email,
+		FriendlyName:  invite.FriendlyName,
+		Photographer:  displayName(photographer),
+		AlbumCount:    len(invite.AlbumIDs),
+		ExpiresAt:     invite.ExpiresAt,
+		AccountExists: existing != nil,
+	}, nil
+}
+
+// AcceptInvite creates the client's account with the invited address and a
+// password of their choosing, then signs them in. Following the link proves
+// they read the mailbox, so the address counts as verified.
+func (s *InviteService) AcceptInvite(ctx context.Context, token, username, password, friendlyName string, client SessionClient) (*LoginResult, error) {
+	invite, err := s.lookupInvite(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.dbService.GetUserByEmail(invite.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrInviteAccountExists
+	}
+
+	username = strings.TrimSpace(username)
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	friendlyName = strings.TrimSpace(friendlyName)
+	if friendlyName == "" {
+		friendlyName = invite.FriendlyName
+	}
+	if len(friendlyName) > maxFriendlyNameLen {
+		return nil, fmt.Errorf("friendly name must be at most %d characters", maxFriendlyNameLen)
+	}
+
+	user, err := s.authService.RegisterWithFriendlyName(invite.Email, username, password, friendlyName, models.RoleClient)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, err := s.inviteRepo.MarkAccepted(ctx, invite.ID, user.ID)
+	if err == nil && !accepted {
+		err = ErrInviteInvalid
+	}
+	if err != nil {
+		// Someone else used the link first; do not leave a stray account.
+		if deleteErr := s.authService.userRepo.Delete(ctx, int(user.ID)); deleteErr != nil {
+			fmt.Printf("Warning: failed to delete user %d after failed invite acceptance: %v\n", user.ID, deleteErr)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := s.authService.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.linkClient(ctx, invite, user)
+	return s.authService.BeginSession(ctx, user, client)
+}
+
+// LinkInvite accepts an invite for an account the client already has. The
+// account must have the invited address, so that a forwarded or leaked link
+// cannot attach someone else to the photographer's albums.
+func (s *InviteService) LinkInvite(ctx context.Context, token string, user *models.User) error {
+	invite, err := s.lookupInvite(ctx, token)
+	if err != nil {
+		return err
+	}
+	if user.Role != models.RoleClient {
+		return fmt.Errorf("only client accounts can accept invitations")
+	}
+	if !strings.EqualFold(user.Email, invite.Email) {
+		return ErrInviteWrongAccount
+	}
+
+	accepted, err := s.inviteRepo.MarkAccepted(ctx, invite.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return ErrInviteInvalid
+	}
+
+	s.linkClient(ctx, invite, user)
+	return nil
+}
+
+func (s *InviteService) lookupInvite(ctx context.Context, token string) (*models.ClientInvite, error) {
+	invite, err := s.inviteRepo.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || !invite.Usable(time.Now()) {
+		return nil, ErrInviteInvalid
+	}
+	return invite, nil
+}
+
+// linkClient makes the user the photographer's client and assigns them to
+// the invite's albums. The invite is already used up at this point, so
+// failures are logged rather than returned; the photographer can still link
+// and assign by hand.
+func (s *InviteService) linkClient(ctx context.Context, invite *models.ClientInvite, user *models.User) {
+	relation, err := s.dbService.GetPhotographerClient(invite.PhotographerID, user.ID)
+	if err == nil && relation == nil {
+		_, err = s.dbService.CreatePhotographerClient(invite.PhotographerID, user.ID)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to link client %d to photographer %d: %v\n", user.ID, invite.PhotographerID, err)
+	}
+
+	for _, albumID := range invite.AlbumIDs {
+		if err := s.albumService.AddUserToAlbum(ctx, int(albumID), int(user.ID)); err != nil {
+			fmt.Printf("Warning: failed to assign client %d to album %d: %v\n", user.ID, albumID, err)
+		}
+	}
+}
+
+func (s *InviteService) sendInvite(inviter *models.User, invite *models.ClientInvite, token string) error {
+	name := invite.FriendlyName
+	if name == "" {
+		name = invite.Email
+	}
+
+	msg, err := mailer.Render(mailer.TemplateClientInvite, invite.Email, &mailer.TemplateData{
+		AppName:   "Suipic",
+		Name:      name,
+		Inviter:   displayName(inviter),
+		Link:      s.authService.publicURL + "/invite?token=" + url.QueryEscape(token),
+		Action:    "Accept invitation",
+		ExpiresIn: formatExpiry(clientInviteExpiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.authService.deliverEmail(msg, fmt.Sprintf("invite %d", invite.ID))
+	return nil
+}
+
+func displayName(user *models.User) string {
+	if user.FriendlyName != "" {
+		return user.FriendlyName
+	}
+	return user.Username
+}
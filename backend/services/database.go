@@ -39,11 +39,11 @@ func (s *DatabaseService) CreateUserWithFriendlyName(email, username, passwordHa
 	query := `
 		INSERT INTO users (email, username, password_hash, friendly_name, role)
 		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, email, username, password_hash, friendly_name, role, created_at, updated_at
+		RETURNING id, email, username, password_hash, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 	`
 	err := s.db.QueryRow(query, email, username, passwordHash, friendlyName, role).Scan(
 		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.FriendlyName,
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.AvatarID, &user.EmailVerifiedAt, &user.SuspendedAt, &user.SuspensionReason, &user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -54,12 +54,12 @@ func (s *DatabaseService) CreateUserWithFriendlyName(email, username, passwordHa
 func (s *DatabaseService) GetUserByEmail(email string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, email, username, password_hash, friendly_name, role, created_at, updated_at
+		SELECT id, email, username, password_hash, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users WHERE email = $1
 	`
 	err := s.db.QueryRow(query, email).Scan(
 		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.FriendlyName,
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.AvatarID, &user.EmailVerifiedAt, &user.SuspendedAt, &user.SuspensionReason, &user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -73,12 +73,12 @@ func (s *DatabaseService) GetUserByEmail(email string) (*models.User, error) {
 func (s *DatabaseService) GetUserByID(id int64) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, email, username, password_hash, friendly_name, role, created_at, updated_at
+		SELECT id, email, username, password_hash, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users WHERE id = $1
 	`
 	err := s.db.QueryRow(query, id).Scan(
 		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.FriendlyName,
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.AvatarID, &user.EmailVerifiedAt, &user.SuspendedAt, &user.SuspensionReason, &user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -92,12 +92,12 @@ func (s *DatabaseService) GetUserByID(id int64) (*models.User, error) {
 func (s *DatabaseService) GetUserByUsername(username string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, email, username, password_hash, friendly_name, role, created_at, updated_at
+		SELECT id, email, username, password_hash, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users WHERE username = $1
 	`
 	err := s.db.QueryRow(query, username).Scan(
 		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.FriendlyName,
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.AvatarID, &user.EmailVerifiedAt, &user.SuspendedAt, &user.SuspensionReason, &user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -110,7 +110,7 @@ func (s *DatabaseService) GetUserByUsername(username string) (*models.User, erro
 
 func (s *DatabaseService) GetUsersByRole(role models.UserRole) ([]*models.User, error) {
 	query := `
-		SELECT id, email, username, password_hash, friendly_name, role, created_at, updated_at
+		SELECT id, email, username, password_hash, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users WHERE role = $1
 		ORDER BY created_at DESC
 	`
@@ -125,7 +125,7 @@ func (s *DatabaseService) GetUsersByRole(role models.UserRole) ([]*models.User,
 		user := &models.User{}
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.Username, &user.PasswordHash, &user.FriendlyName,
-			&user.Role, &user.CreatedAt, &user.UpdatedAt,
+			&user.Role, &user.AvatarID, &user.EmailVerifiedAt, &user.SuspendedAt, &user.SuspensionReason, &user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -177,7 +177,7 @@ func (s *DatabaseService) GetPhotographerClient(photographerID, clientID int64)
 
 func (s *DatabaseService) GetClientsByPhotographer(photographerID int64) ([]*models.User, error) {
 	query := `
-		SELECT u.id, u.email, u.username, u.password_hash, u.friendly_name, u.role, u.created_at, u.updated_at
+		SELECT u.id, u.email, u.username, u.password_hash, u.friendly_name, u.role, u.avatar_id, u.email_verified_at, u.suspended_at, u.suspension_reason, u.password_reset_required, u.created_at, u.updated_at
 		FROM users u
 		INNER JOIN photographer_clients pc ON u.id = pc.client_id
 		WHERE pc.photographer_id = $1
@@ -194,7 +194,7 @@ func (s *DatabaseService) GetClientsByPhotographer(photographerID int64) ([]*mod
 		user := &models.User{}
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.Username, &user.PasswordHash,
-			&user.FriendlyName, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+			&user.FriendlyName, &user.Role, &user.AvatarID, &user.EmailVerifiedAt, &user.SuspendedAt, &user.SuspensionReason, &user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -211,7 +211,7 @@ func (s *DatabaseService) GetClientsByPhotographer(photographerID int64) ([]*mod
 
 func (s *DatabaseService) SearchClientsByUsername(username string) ([]*models.User, error) {
 	query := `
-		SELECT id, email, username, password_hash, friendly_name, role, created_at, updated_at
+		SELECT id, email, username, password_hash, friendly_name, role, avatar_id, email_verified_at, suspended_at, suspension_reason, password_reset_required, created_at, updated_at
 		FROM users
 		WHERE role = 'client' AND username ILIKE $1
 		ORDER BY username
@@ -228,7 +228,7 @@ func (s *DatabaseService) SearchClientsByUsername(username string) ([]*models.Us
 		user := &models.User{}
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.Username, &user.PasswordHash,
-			&user.FriendlyName, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+			&user.FriendlyName, &user.Role, &user.AvatarID, &user.EmailVerifiedAt, &user.SuspendedAt, &user.SuspensionReason, &user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -305,6 +305,46 @@ func (s *DatabaseService) GetUserRepo() repository.UserRepository {
 func (s *DatabaseService) GetSystemSettingsRepo() repository.SystemSettingsRepository {
 	return repository.NewPostgresSystemSettingsRepository(s.db)
 }
+
+func (s *DatabaseService) GetSessionRepo() repository.SessionRepository {
+	return repository.NewPostgresSessionRepository(s.db)
+}
+
+func (s *DatabaseService) GetUserTokenRepo() repository.UserTokenRepository {
+	return repository.NewPostgresUserTokenRepository(s.db)
+}
+
+func (s *DatabaseService) GetMFARepo() repository.MFARepository {
+	return repository.NewPostgresMFARepository(s.db)
+}
+
+func (s *DatabaseService) GetOIDCRepo() repository.OIDCRepository {
+	return repository.NewPostgresOIDCRepository(s.db)
+}
+
+func (s *DatabaseService) GetAPITokenRepo() repository.APITokenRepository {
+	return repository.NewPostgresAPITokenRepository(s.db)
+}
+
+func (s *DatabaseService) GetLoginAttemptRepo() repository.LoginAttemptRepository {
+	return repository.NewPostgresLoginAttemptRepository(s.db)
+}
+
+func (s *DatabaseService) GetClientInviteRepo() repository.ClientInviteRepository {
+	return repository.NewPostgresClientInviteRepository(s.db)
+}
+
+func (s *DatabaseService) GetShareLinkRepo() repository.ShareLinkRepository {
+	return repository.NewPostgresShareLinkRepository(s.db)
+}
+
+func (s *DatabaseService) GetOrganizationRepo() repository.OrganizationRepository {
+	return repository.NewPostgresOrganizationRepository(s.db)
+}
+
+func (s *DatabaseService) GetAuditLogRepo() repository.AuditLogRepository {
+	return repository.NewPostgresAuditLogRepository(s.db)
+}
 type GlobalStats struct {
 	TotalUsers  int64 `json:"totalUsers"`
 	TotalAlbums int64 `json:"totalAlbums"`
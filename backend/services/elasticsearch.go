@@ -16,6 +16,17 @@ import (
 
 const photosIndex = "photos"
 
+// Only a fixed set of EXIF fields is indexed. The full metadata dump can hold
+// hundreds of vendor-specific keys, which would blow through the index's
+// field limit and invite mapping conflicts.
+var indexedExifFields = []string{
+	"Make", "Model", "LensMake", "LensModel", "LensSpecification",
+	"FocalLength", "FNumber", "ExposureTime", "ISO", "DateTimeOriginal",
+	"ImageWidth", "ImageHeight", "Orientation", "Software", "Artist",
+	"Copyright", "Creator", "ExposureProgram", "MeteringMode", "Flash",
+	"WhiteBalance", "Latitude", "Longitude",
+}
+
 type ElasticsearchService struct {
 	client *elasticsearch.Client
 }
@@ -24,6 +35,13 @@ type PhotoDocument struct {
 	ID                int                    `json:"id"`
 	AlbumID           int                    `json:"album_id"`
 	Title             string                 `json:"title"`
+	Caption           string                 `json:"caption"`
+	Keywords          []string               `json:"keywords"`
+	CountryCode       string                 `json:"country_code,omitempty"`
+	Country           string                 `json:"country,omitempty"`
+	Region            string                 `json:"region,omitempty"`
+	City              string                 `json:"city,omitempty"`
+	Location          *GeoPoint              `json:"location,omitempty"`
 	DateTime          *time.Time             `json:"date_time,omitempty"`
 	ExifData          map[string]interface{} `json:"exif_data,omitempty"`
 	AlbumTitle        string                 `json:"album_title"`
@@ -36,6 +54,11 @@ type PhotoDocument struct {
 	UpdatedAt         time.Time              `json:"updated_at"`
 }
 
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
 func NewElasticsearchService(cfg *config.ElasticsearchConfig) (*ElasticsearchService, error) {
 	esCfg := elasticsearch.Config{
 		Addresses: cfg.Addresses,
@@ -72,27 +95,32 @@ func NewElasticsearchService(cfg *config.ElasticsearchConfig) (*ElasticsearchSer
 	return service, nil
 }
 
-func (s *ElasticsearchService) createIndex() error {
-	mapping := `{
-		"mappings": {
-			"properties": {
-				"id": { "type": "integer" },
-				"album_id": { "type": "integer" },
-				"title": { "type": "text" },
-				"date_time": { "type": "date" },
-				"exif_data": { "type": "object", "enabled": true },
-				"album_title": { "type": "text" },
-				"album_location": { "type": "text" },
-				"album_custom_fields": { "type": "object", "enabled": true },
-				"comments": { "type": "text" },
-				"pick_reject_state": { "type": "keyword" },
-				"stars": { "type": "integer" },
-				"created_at": { "type": "date" },
-				"updated_at": { "type": "date" }
-			}
-		}
-	}`
+const photoMappingProperties = `{
+	"properties": {
+		"id": { "type": "integer" },
+		"album_id": { "type": "integer" },
+		"title": { "type": "text" },
+		"caption": { "type": "text" },
+		"keywords": { "type": "text", "fields": { "raw": { "type": "keyword" } } },
+		"country_code": { "type": "keyword" },
+		"country": { "type": "text", "fields": { "raw": { "type": "keyword" } } },
+		"region": { "type": "text", "fields": { "raw": { "type": "keyword" } } },
+		"city": { "type": "text", "fields": { "raw": { "type": "keyword" } } },
+		"location": { "type": "geo_point" },
+		"date_time": { "type": "date" },
+		"exif_data": { "type": "object", "enabled": true },
+		"album_title": { "type": "text" },
+		"album_location": { "type": "text" },
+		"album_custom_fields": { "type": "object", "enabled": true },
+		"comments": { "type": "text" },
+		"pick_reject_state": { "type": "keyword" },
+		"stars": { "type": "integer" },
+		"created_at": { "type": "date" },
+		"updated_at": { "type": "date" }
+	}
+}`
 
+func (s *ElasticsearchService) createIndex() error {
 	res, err := s.client.Indices.Exists([]string{photosIndex})
 	if err != nil {
 		return fmt.Errorf("failed to check if index exists: %w", err)
@@ -100,12 +128,12 @@ func (s *ElasticsearchService) createIndex() error {
 	defer res.Body.Close()
 
 	if res.StatusCode == 200 {
-		return nil
+		return s.updateMapping()
 	}
 
 	res, err = s.client.Indices.Create(
 		photosIndex,
-		s.client.Indices.Create.WithBody(strings.NewReader(mapping)),
+		s.client.Indices.Create.WithBody(strings.NewReader(`{"mappings": `+photoMappingProperties+`}`)),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create index: %w", err)
@@ -119,6 +147,26 @@ func (s *ElasticsearchService) createIndex() error {
 	return nil
 }
 
+// updateMapping adds fields introduced since an existing index was created.
+// Elasticsearch accepts new properties on a live index; documents indexed
+// before the change pick them up on the next reindex.
+func (s *ElasticsearchService) updateMapping() error {
+	res, err := s.client.Indices.PutMapping(
+		[]string{photosIndex},
+		strings.NewReader(photoMappingProperties),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update index mapping: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		fmt.Printf("Warning: failed to update index mapping: %s\n", res.String())
+	}
+
+	return nil
+}
+
 func (s *ElasticsearchService) IndexPhoto(ctx context.Context, photo *models.Photo, album *models.Album, comments []*models.Comment) error {
 	doc := s.buildPhotoDocument(photo, album, comments)
 
@@ -220,8 +268,9 @@ func (s *ElasticsearchService) buildPhotoDocument(photo *models.Photo, album *mo
 		ID:              photo.ID,
 		AlbumID:         photo.AlbumID,
 		Title:           "",
+		Keywords:        photo.Keywords,
 		DateTime:        photo.DateTime,
-		ExifData:        photo.ExifData,
+		ExifData:        indexedExifData(photo.ExifData),
 		PickRejectState: string(photo.PickRejectState),
 		Stars:           photo.Stars,
 		CreatedAt:       photo.CreatedAt,
@@ -232,6 +281,19 @@ func (s *ElasticsearchService) buildPhotoDocument(photo *models.Photo, album *mo
 		doc.Title = *photo.Title
 	}
 
+	if photo.Caption != nil {
+		doc.Caption = *photo.Caption
+	}
+
+	doc.CountryCode = derefString(photo.CountryCode)
+	doc.Country = derefString(photo.Country)
+	doc.Region = derefString(photo.Region)
+	doc.City = derefString(photo.City)
+
+	if lat, lon, ok := photoCoordinates(photo); ok {
+		doc.Location = &GeoPoint{Lat: lat, Lon: lon}
+	}
+
 	if album != nil {
 		doc.AlbumTitle = album.Title
 		if album.Location != nil {
@@ -249,16 +311,37 @@ func (s *ElasticsearchService) buildPhotoDocument(photo *models.Photo, album *mo
 	return doc
 }
 
+func indexedExifData(exifData models.ExifData) map[string]interface{} {
+	if exifData == nil {
+		return nil
+	}
+
+	indexed := make(map[string]interface{})
+	for _, key := range indexedExifFields {
+		if value, ok := exifData[key]; ok {
+			indexed[key] = value
+		}
+	}
+	return indexed
+}
+
 type SearchFilter struct {
-	Query    string
-	AlbumID  *int
-	DateFrom *time.Time
-	DateTo   *time.Time
-	MinStars *int
-	MaxStars *int
-	State    *string
-	Limit    int
-	Offset   int
+	Query       string
+	Keyword     *string
+	Country     *string
+	City        *string
+	AlbumID     *int
+	AlbumIDs    []int
+	BoundingBox *BoundingBox
+	Near        *GeoDistance
+	HasLocation bool
+	DateFrom    *time.Time
+	DateTo      *time.Time
+	MinStars    *int
+	MaxStars    *int
+	State       *string
+	Limit       int
+	Offset      int
 }
 
 type SearchResult struct {
@@ -314,6 +397,29 @@ func (s *ElasticsearchService) Search(ctx context.Context, filter *SearchFilter)
 			photo.Title = &title
 		}
 
+		if caption, ok := source["caption"].(string); ok && caption != "" {
+			photo.Caption = &caption
+		}
+
+		if keywords, ok := source["keywords"].([]interface{}); ok {
+			for _, keyword := range keywords {
+				if k, ok := keyword.(string); ok {
+					photo.Keywords = append(photo.Keywords, k)
+				}
+			}
+		}
+
+		for field, target := range map[string]**string{
+			"country_code": &photo.CountryCode,
+			"country":      &photo.Country,
+			"region":       &photo.Region,
+			"city":         &photo.City,
+		} {
+			if value, ok := source[field].(string); ok && value != "" {
+				*target = &value
+			}
+		}
+
 		if dateTime, ok := source["date_time"].(string); ok && dateTime != "" {
 			t, _ := time.Parse(time.RFC3339, dateTime)
 			photo.DateTime = &t
@@ -351,6 +457,11 @@ func (s *ElasticsearchService) buildSearchQuery(filter *SearchFilter) map[string
 				"query": filter.Query,
 				"fields": []string{
 					"title^3",
+					"keywords^2",
+					"caption^2",
+					"city^2",
+					"region",
+					"country",
 					"album_title^2",
 					"album_location",
 					"comments",
@@ -360,6 +471,30 @@ func (s *ElasticsearchService) buildSearchQuery(filter *SearchFilter) map[string
 		})
 	}
 
+	if filter.Keyword != nil {
+		must = append(must, map[string]interface{}{
+			"term": map[string]interface{}{
+				"keywords.raw": *filter.Keyword,
+			},
+		})
+	}
+
+	if filter.Country != nil {
+		must = append(must, map[string]interface{}{
+			"term": map[string]interface{}{
+				"country_code": *filter.Country,
+			},
+		})
+	}
+
+	if filter.City != nil {
+		must = append(must, map[string]interface{}{
+			"term": map[string]interface{}{
+				"city.raw": *filter.City,
+			},
+		})
+	}
+
 	if filter.AlbumID != nil {
 		must = append(must, map[string]interface{}{
 			"term": map[string]interface{}{
@@ -368,6 +503,42 @@ func (s *ElasticsearchService) buildSearchQuery(filter *SearchFilter) map[string
 		})
 	}
 
+	if filter.AlbumIDs != nil {
+		must = append(must, map[string]interface{}{
+			"terms": map[string]interface{}{
+				"album_id": filter.AlbumIDs,
+			},
+		})
+	}
+
+	if filter.HasLocation {
+		must = append(must, map[string]interface{}{
+			"exists": map[string]interface{}{
+				"field": "location",
+			},
+		})
+	}
+
+	if filter.BoundingBox != nil {
+		must = append(must, map[string]interface{}{
+			"geo_bounding_box": map[string]interface{}{
+				"location": map[string]interface{}{
+					"top_left":     map[string]float64{"lat": filter.BoundingBox.North, "lon": filter.BoundingBox.West},
+					"bottom_right": map[string]float64{"lat": filter.BoundingBox.South, "lon": filter.BoundingBox.East},
+				},
+			},
+		})
+	}
+
+	if filter.Near != nil {
+		must = append(must, map[string]interface{}{
+			"geo_distance": map[string]interface{}{
+				"distance": fmt.Sprintf("%gkm", filter.Near.RadiusKm),
+				"location": map[string]float64{"lat": filter.Near.Lat, "lon": filter.Near.Lon},
+			},
+		})
+	}
+
 	if filter.DateFrom != nil || filter.DateTo != nil {
 		rangeQuery := map[string]interface{}{}
 		if filter.DateFrom != nil {
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/repository"
+)
+
+const (
+	ExportBundleVersion = 1
+	exportManifestName  = "manifest.json"
+	exportPhotosDir     = "photos/"
+	exportThumbnailsDir = "thumbnails/"
+)
+
+type ExportOptions struct {
+	IncludeRenditions bool
+}
+
+type ExportManifest struct {
+	Version      int              `json:"version"`
+	ExportedAt   time.Time        `json:"exportedAt"`
+	Photographer *ExportedUser    `json:"photographer,omitempty"`
+	Albums       []*ExportedAlbum `json:"albums"`
+}
+
+type ExportedUser struct {
+	Username     string               `json:"username"`
+	Email        string               `json:"email"`
+	FriendlyName string               `json:"friendlyName"`
+	Role         models.UserRole      `json:"role"`
+	Permissions  *ExportedPermissions `json:"permissions,omitempty"`
+}
+
+// ExportedPermissions are an album user's permissions. Bundles written
+// before permissions existed lack them, and get the defaults on import.
+type ExportedPermissions struct {
+	CanComment  bool `json:"canComment"`
+	CanRate     bool `json:"canRate"`
+	CanDownload bool `json:"canDownload"`
+	CanShare    bool `json:"canShare"`
+}
+
+type ExportedAlbum struct {
+	Album  *models.Album    `json:"album"`
+	Users  []*ExportedUser  `json:"users"`
+	Photos []*ExportedPhoto `json:"photos"`
+}
+
+type ExportedPhoto struct {
+	Photo     *models.Photo      `json:"photo"`
+	File      string             `json:"file"`
+	Thumbnail string             `json:"thumbnail,omitempty"`
+	Comments  []*ExportedComment `json:"comments"`
+}
+
+type ExportedComment struct {
+	*models.Comment
+	Author *ExportedUser `json:"author"`
+}
+
+type BundleWriter interface {
+	WriteFile(name string, reader io.Reader) error
+	Close() error
+}
+
+type zipBundleWriter struct {
+	zw *zip.Writer
+}
+
+func NewZipBundleWriter(w io.Writer) BundleWriter {
+	return &zipBundleWriter{zw: zip.NewWriter(w)}
+}
+
+func (w *zipBundleWriter) WriteFile(name string, reader io.Reader) error {
+	fw, err := w.zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s in archive: %w", name, err)
+	}
+	if _, err := io.Copy(fw, reader); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+func (w *zipBundleWriter) Close() error {
+	return w.zw.Close()
+}
+
+type dirBundleWriter struct {
+	root string
+}
+
+func NewDirBundleWriter(root string) (BundleWriter, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	return &dirBundleWriter{root: root}, nil
+}
+
+func (w *dirBundleWriter) WriteFile(name string, reader io.Reader) error {
+	path := filepath.Join(w.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func (w *dirBundleWriter) Close() error {
+	return nil
+}
+
+type ExportService struct {
+	albumService   *AlbumService
+	photoService   *PhotoService
+	storageService *StorageService
+	photoRepo      repository.PhotoRepository
+	commentRepo    repository.CommentRepository
+	userRepo       repository.UserRepository
+}
+
+func NewExportService(albumService *AlbumService, photoService *PhotoService, storageService *StorageService, photoRepo repository.PhotoRepository, commentRepo repository.CommentRepository, userRepo repository.UserRepository) *ExportService {
+	return &ExportService{
+		albumService:   albumService,
+		photoService:   photoService,
+		storageService: storageService,
+		photoRepo:      photoRepo,
+		commentRepo:    commentRepo,
+		userRepo:       userRepo,
+	}
+}
+
+func (s *ExportService) ExportAlbum(ctx context.Context, albumID int, opts ExportOptions, bundle BundleWriter) (*ExportManifest, error) {
+	album, err := s.albumService.GetAlbumByID(ctx, albumID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get album: %w", err)
+	}
+	if album == nil {
+		return nil, fmt.Errorf("album not found")
+	}
+
+	photographer, err := s.exportUser(ctx, album.PhotographerID)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &ExportManifest{
+		Version:      ExportBundleVersion,
+		ExportedAt:   time.Now().UTC(),
+		Photographer: photographer,
+	}
+
+	exported, err := s.exportAlbum(ctx, album, opts, bundle)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Albums = append(manifest.Albums, exported)
+
+	if err := s.writeManifest(manifest, bundle); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func (s *ExportService) ExportPhotographer(ctx context.Context, photographerID int, opts ExportOptions, bundle BundleWriter) (*ExportManifest, error) {
+	photographer, err := s.exportUser(ctx, photographerID)
+	if err != nil {
+		return nil, err
+	}
+	if photographer == nil {
+		return nil, fmt.Errorf("photographer not found")
+	}
+
+	albums, err := s.albumService.ListAlbums(ctx, &photographerID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list albums: %w", err)
+	}
+
+	manifest := &ExportManifest{
+		Version:      ExportBundleVersion,
+		ExportedAt:   time.Now().UTC(),
+		Photographer: photographer,
+		Albums:       []*ExportedAlbum{},
+	}
+
+	for _, album := range albums {
+		exported, err := s.exportAlbum(ctx, album, opts, bundle)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Albums = append(manifest.Albums, exported)
+	}
+
+	if err := s.writeManifest(manifest, bundle); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func (s *ExportService) exportAlbum(ctx context.Context, album *models.Album, opts ExportOptions, bundle BundleWriter) (*ExportedAlbum, error) {
+	exported := &ExportedAlbum{
+		Album:  album,
+		Users:  []*ExportedUser{},
+		Photos: []*ExportedPhoto{},
+	}
+
+	albumUsers, err := s.albumService.GetAlbumUsers(ctx, album.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get album users: %w", err)
+	}
+	for _, albumUser := range albumUsers {
+		user, err := s.exportUser(ctx, albumUser.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			user.Permissions = &ExportedPermissions{
+				CanComment:  albumUser.CanComment,
+				CanRate:     albumUser.CanRate,
+				CanDownload: albumUser.CanDownload,
+				CanShare:    albumUser.CanShare,
+			}
+			exported.Users = append(exported.Users, user)
+		}
+	}
+
+	photos, err := s.photoService.GetPhotosByAlbum(ctx, album.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photos: %w", err)
+	}
+
+	users := make(map[int]*ExportedUser)
+	for _, photo := range photos {
+		exportedPhoto := &ExportedPhoto{
+			Photo:    photo,
+			File:     exportPhotosDir + photo.Filename + ".webp",
+			Comments: []*ExportedComment{},
+		}
+
+		object, _, err := s.storageService.DownloadPhoto(ctx, photo.Filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download photo %d: %w", photo.ID, err)
+		}
+		if err := writeObject(bundle, exportedPhoto.File, object); err != nil {
+			return nil, err
+		}
+
+		if opts.IncludeRenditions {
+			thumbnail, _, err := s.storageService.DownloadThumbnail(ctx, photo.Filename)
+			if err == nil {
+				exportedPhoto.Thumbnail = exportThumbnailsDir + photo.Filename + ".webp"
+				if err := writeObject(bundle, exportedPhoto.Thumbnail, thumbnail); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		comments, err := s.commentRepo.GetByPhoto(ctx, photo.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get comments for photo %d: %w", photo.ID, err)
+		}
+		for _, comment := range comments {
+			author, ok := users[comment.UserID]
+			if !ok {
+				author, err = s.exportUser(ctx, comment.UserID)
+				if err != nil {
+					return nil, err
+				}
+				users[comment.UserID] = author
+			}
+			exportedPhoto.Comments = append(exportedPhoto.Comments, &ExportedComment{
+				Comment: comment,
+				Author:  author,
+			})
+		}
+
+		exported.Photos = append(exported.Photos, exportedPhoto)
+	}
+
+	return exported, nil
+}
+
+func writeObject(bundle BundleWriter, name string, object io.ReadCloser) error {
+	defer object.Close()
+	return bundle.WriteFile(name, object)
+}
+
+func (s *ExportService) exportUser(ctx context.Context, userID int) (*ExportedUser, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	return &ExportedUser{
+		Username:     user.Username,
+		Email:        user.Email,
+		FriendlyName: user.FriendlyName,
+		Role:         user.Role,
+	}, nil
+}
+
+func (s *ExportService) writeManifest(manifest *ExportManifest, bundle BundleWriter) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	return bundle.WriteFile(exportManifestName, bytes.NewReader(data))
+}
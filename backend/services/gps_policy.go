@@ -0,0 +1,188 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/suipic/backend/metadata"
+	"github.com/suipic/backend/models"
+)
+
+// GPSPolicyForAlbum resolves the album's overrides against the system
+// default. A nil album gets the system default.
+func (s *PhotoService) GPSPolicyForAlbum(ctx context.Context, album *models.Album) models.GPSPolicy {
+	defaults, err := s.settingsService.GetGPSPolicy(ctx)
+	if err != nil {
+		fmt.Printf("Warning: failed to load GPS policy, using defaults: %v\n", err)
+	}
+
+	if album == nil {
+		return defaults
+	}
+	return album.GPSPolicy.Resolve(defaults)
+}
+
+// GPSVisibleTo reports whether a user may see where the album's photos were
+// taken: admins and the album's photographer always can, others only when
+// the policy exposes it.
+func (s *PhotoService) GPSVisibleTo(ctx context.Context, album *models.Album, userID int, role models.UserRole) bool {
+	if role == models.RoleAdmin {
+		return true
+	}
+	return album != nil && (album.PhotographerID == userID || s.GPSPolicyForAlbum(ctx, album).Expose)
+}
+
+// RedactPhotosForViewer hides coordinates and the place derived from them
+// from clients of albums whose policy does not expose them. Admins and the
+// album's photographer always see what is retained. Redacted photos are
+// copies; the inputs are not modified.
+func (s *PhotoService) RedactPhotosForViewer(ctx context.Context, photos []*models.Photo, userID int, role models.UserRole) []*models.Photo {
+	if role == models.RoleAdmin {
+		return photos
+	}
+
+	exposed := make(map[int]bool)
+	redacted := make([]*models.Photo, len(photos))
+	for i, photo := range photos {
+		visible, ok := exposed[photo.AlbumID]
+		if !ok {
+			album, err := s.albumService.GetAlbumByID(ctx, photo.AlbumID)
+			if err != nil {
+				fmt.Printf("Warning: failed to get album %d for GPS policy: %v\n", photo.AlbumID, err)
+			}
+			visible = s.GPSVisibleTo(ctx, album, userID, role)
+			exposed[photo.AlbumID] = visible
+		}
+
+		if visible {
+			redacted[i] = photo
+		} else {
+			redacted[i] = withoutGPS(photo)
+		}
+	}
+
+	return redacted
+}
+
+// AlbumIDsWithVisibleGPS lists the albums a user may access and whose photo
+// coordinates they are allowed to see. Geo-filtered searches are limited to
+// these so that hidden coordinates cannot be probed with shrinking bounding
+// boxes. Admins see everything, which is reported as nil.
+func (s *PhotoService) AlbumIDsWithVisibleGPS(ctx context.Context, userID int, role models.UserRole) ([]int, error) {
+	if role == models.RoleAdmin {
+		return nil, nil
+	}
+
+	albumIDs, err := s.albumService.AccessibleAlbumIDs(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]int, 0, len(albumIDs))
+	for _, albumID := range albumIDs {
+		album, err := s.albumService.GetAlbumByID(ctx, albumID)
+		if err != nil {
+			return nil, err
+		}
+		if s.GPSVisibleTo(ctx, album, userID, role) {
+			visible = append(visible, albumID)
+		}
+	}
+	return visible, nil
+}
+
+func (s *PhotoService) RedactPhotoForViewer(ctx context.Context, photo *models.Photo, userID int, role models.UserRole) *models.Photo {
+	return s.RedactPhotosForViewer(ctx, []*models.Photo{photo}, userID, role)[0]
+}
+
+// ApplyGPSPolicy brings an album's existing photos in line with its current
+// policy: coordinates and the place derived from them are dropped from the
+// database when they may no longer be retained, stored files are stripped
+// when required, and the album is reindexed so the search index follows
+// suit. Stored images are WebP without metadata, so stripping usually finds
+// nothing to remove.
+func (s *PhotoService) ApplyGPSPolicy(ctx context.Context, album *models.Album) error {
+	policy := s.GPSPolicyForAlbum(ctx, album)
+
+	photos, err := s.photoRepo.GetByAlbum(ctx, album.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get photos: %w", err)
+	}
+
+	for _, photo := range photos {
+		if !policy.Retain {
+			removedGPS := metadata.RemoveGPS(photo.ExifData)
+			if clearPhotoPlace(photo) || removedGPS {
+				if err := s.photoRepo.Update(ctx, photo); err != nil {
+					return fmt.Errorf("failed to update photo %d: %w", photo.ID, err)
+				}
+			}
+		}
+
+		if policy.Strip {
+			if err := s.stripStoredPhoto(ctx, photo); err != nil {
+				return fmt.Errorf("failed to strip photo %d: %w", photo.ID, err)
+			}
+		}
+	}
+
+	if s.esService != nil {
+		if err := s.BulkIndexPhotosByAlbum(ctx, album.ID); err != nil {
+			fmt.Printf("Warning: failed to reindex album %d after GPS policy change: %v\n", album.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *PhotoService) stripStoredPhoto(ctx context.Context, photo *models.Photo) error {
+	object, info, err := s.storageService.DownloadPhoto(ctx, photo.Filename)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(object)
+	object.Close()
+	if err != nil {
+		return err
+	}
+
+	stripped := metadata.StripGPS(data)
+	if bytes.Equal(stripped, data) {
+		return nil
+	}
+
+	return s.storageService.ReplacePhoto(ctx, photo.Filename, stripped, info.ContentType)
+}
+
+func (s *PhotoService) indexPhoto(ctx context.Context, photo *models.Photo, album *models.Album) error {
+	if !s.GPSPolicyForAlbum(ctx, album).Index {
+		photo = withoutGPS(photo)
+	}
+
+	comments, _ := s.commentRepo.GetByPhoto(ctx, photo.ID)
+	return s.esService.IndexPhoto(ctx, photo, album, comments)
+}
+
+// ReindexPhoto refreshes a single photo's search document.
+func (s *PhotoService) ReindexPhoto(ctx context.Context, photo *models.Photo) error {
+	if s.esService == nil {
+		return nil
+	}
+
+	album, _ := s.albumService.GetAlbumByID(ctx, photo.AlbumID)
+	return s.indexPhoto(ctx, photo, album)
+}
+
+// withoutGPS returns a copy of the photo without coordinates or place.
+func withoutGPS(photo *models.Photo) *models.Photo {
+	redacted := *photo
+	clearPhotoPlace(&redacted)
+	if photo.ExifData != nil {
+		redacted.ExifData = maps.Clone(photo.ExifData)
+		metadata.RemoveGPS(redacted.ExifData)
+	}
+	return &redacted
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+)
+
+const (
+	ImageFitContain = "contain"
+	ImageFitCover   = "cover"
+	ImageFitFill    = "fill"
+
+	ImageFormatWebP = "webp"
+	ImageFormatJPEG = "jpeg"
+	ImageFormatPNG  = "png"
+
+	defaultImageQuality = 85
+	maxSignedDimension  = 4096
+)
+
+var (
+	allowedImageDimensions = map[int]bool{
+		64: true, 128: true, 150: true, 256: true, 300: true, 320: true,
+		480: true, 600: true, 630: true, 640: true, 800: true, 960: true,
+		1024: true, 1080: true, 1200: true, 1280: true, 1600: true, 1920: true, 2048: true,
+	}
+	allowedImageQualities = map[int]bool{
+		50: true, 60: true, 70: true, 75: true, 80: true, 85: true, 90: true,
+	}
+)
+
+type TransformOptions struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int
+}
+
+// ImageSigningKeyLabel is the HKDF label the image URL signing key is derived
+// with when IMAGE_SIGNING_SECRET is not set.
+const ImageSigningKeyLabel = "suipic image urls"
+
+type ImageService struct {
+	storageService *StorageService
+	signingKey     []byte
+}
+
+func NewImageService(storageService *StorageService, signingKey []byte) *ImageService {
+	return &ImageService{
+		storageService: storageService,
+		signingKey:     signingKey,
+	}
+}
+
+func (o *TransformOptions) Normalize() {
+	if o.Fit == "" {
+		o.Fit = ImageFitContain
+	}
+	if o.Format == "" {
+		o.Format = ImageFormatWebP
+	}
+	if o.Format == "jpg" {
+		o.Format = ImageFormatJPEG
+	}
+	if o.Quality == 0 {
+		o.Quality = defaultImageQuality
+	}
+}
+
+func (o *TransformOptions) Validate(signed bool) error {
+	if o.Width < 0 || o.Height < 0 {
+		return fmt.Errorf("width and height must not be negative")
+	}
+	if o.Width == 0 && o.Height == 0 {
+		return fmt.Errorf("width or height is required")
+	}
+
+	switch o.Fit {
+	case ImageFitContain, ImageFitCover, ImageFitFill:
+	default:
+		return fmt.Errorf("fit must be 'contain', 'cover', or 'fill'")
+	}
+	if o.Fit != ImageFitContain && (o.Width == 0 || o.Height == 0) {
+		return fmt.Errorf("fit '%s' requires both width and height", o.Fit)
+	}
+
+	switch o.Format {
+	case ImageFormatWebP, ImageFormatJPEG, ImageFormatPNG:
+	default:
+		return fmt.Errorf("format must be 'webp', 'jpeg', or 'png'")
+	}
+
+	if signed {
+		if o.Width > maxSignedDimension || o.Height > maxSignedDimension {
+			return fmt.Errorf("width and height must be at most %d", maxSignedDimension)
+		}
+		if o.Quality < 1 || o.Quality > 100 {
+			return fmt.Errorf("quality must be between 1 and 100")
+		}
+		return nil
+	}
+
+	if o.Width != 0 && !allowedImageDimensions[o.Width] {
+		return fmt.Errorf("width %d is not an allowed size", o.Width)
+	}
+	if o.Height != 0 && !allowedImageDimensions[o.Height] {
+		return fmt.Errorf("height %d is not an allowed size", o.Height)
+	}
+	if !allowedImageQualities[o.Quality] {
+		return fmt.Errorf("quality %d is not an allowed value", o.Quality)
+	}
+
+	return nil
+}
+
+func (o *TransformOptions) variant() string {
+	return fmt.Sprintf("%dx%d_%s_q%d.%s", o.Width, o.Height, o.Fit, o.Quality, o.Format)
+}
+
+func (o *TransformOptions) ContentType() string {
+	switch o.Format {
+	case ImageFormatJPEG:
+		return "image/jpeg"
+	case ImageFormatPNG:
+		return "image/png"
+	default:
+		return "image/webp"
+	}
+}
+
+func (s *ImageService) signature(photoID int, opts TransformOptions, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%d|%s|%d", photoID, opts.variant(), expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *ImageService) SignQuery(photoID int, opts TransformOptions, expires time.Time) url.Values {
+	opts.Normalize()
+	exp := expires.Unix()
+
+	query := url.Values{}
+	if opts.Width > 0 {
+		query.Set("w", strconv.Itoa(opts.Width))
+	}
+	if opts.Height > 0 {
+		query.Set("h", strconv.Itoa(opts.Height))
+	}
+	query.Set("fit", opts.Fit)
+	query.Set("format", opts.Format)
+	query.Set("q", strconv.Itoa(opts.Quality))
+	query.Set("exp", strconv.FormatInt(exp, 10))
+	query.Set("sig", s.signature(photoID, opts, exp))
+	return query
+}
+
+func (s *ImageService) VerifySignature(photoID int, opts TransformOptions, expires int64, sig string) bool {
+	if len(s.signingKey) == 0 || sig == "" {
+		return false
+	}
+	if time.Now().Unix() > expires {
+		return false
+	}
+
+	expected := s.signature(photoID, opts, expires)
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
+
+func (s *ImageService) Transform(ctx context.Context, fileID string, opts TransformOptions) ([]byte, error) {
+	variant := opts.variant()
+
+	if cached, _, err := s.storageService.DownloadDerivative(ctx, fileID, variant); err == nil {
+		defer cached.Close()
+		data, err := io.ReadAll(cached)
+		if err == nil {
+			return data, nil
+		}
+	}
+
+	object, _, err := s.storageService.DownloadPhoto(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	src, _, err := image.Decode(object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	data, err := encodeImage(resizeImage(src, opts), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storageService.UploadDerivative(ctx, fileID, variant, data, opts.ContentType()); err != nil {
+		fmt.Printf("Warning: failed to cache derivative %s/%s: %v\n", fileID, variant, err)
+	}
+
+	return data, nil
+}
+
+func resizeImage(src image.Image, opts TransformOptions) image.Image {
+	bounds := src.Bounds()
+	width, height := opts.Width, opts.Height
+
+	switch opts.Fit {
+	case ImageFitCover:
+		return imaging.Fill(src, width, height, imaging.Center, imaging.Lanczos)
+	case ImageFitFill:
+		return imaging.Resize(src, width, height, imaging.Lanczos)
+	}
+
+	if width == 0 || height == 0 {
+		if width > bounds.Dx() || height > bounds.Dy() {
+			return src
+		}
+		return imaging.Resize(src, width, height, imaging.Lanczos)
+	}
+
+	return imaging.Fit(src, width, height, imaging.Lanczos)
+}
+
+func encodeImage(img image.Image, opts TransformOptions) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch opts.Format {
+	case ImageFormatJPEG:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.Quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode image as JPEG: %w", err)
+		}
+	case ImageFormatPNG:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode image as PNG: %w", err)
+		}
+	default:
+		if err := webp.Encode(&buf, img, &webp.Options{Quality: float32(opts.Quality)}); err != nil {
+			return nil, fmt.Errorf("failed to encode image as WebP: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
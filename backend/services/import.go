@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/suipic/backend/metadata"
+	"github.com/suipic/backend/models"
+)
+
+// ErrInvalidBundle is returned when a bundle cannot be imported because of
+// what it contains, rather than because the server failed.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+type ImportResult struct {
+	Albums       []*models.Album `json:"albums"`
+	PhotoCount   int             `json:"photoCount"`
+	CommentCount int             `json:"commentCount"`
+	SkippedUsers []string        `json:"skippedUsers"`
+}
+
+func (s *ExportService) ReadManifest(bundle fs.FS) (*ExportManifest, error) {
+	data, err := fs.ReadFile(bundle, exportManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read manifest: %v", ErrInvalidBundle, err)
+	}
+
+	var manifest ExportManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse manifest: %v", ErrInvalidBundle, err)
+	}
+
+	if manifest.Version < 1 || manifest.Version > ExportBundleVersion {
+		return nil, fmt.Errorf("%w: unsupported bundle version %d", ErrInvalidBundle, manifest.Version)
+	}
+
+	return &manifest, nil
+}
+
+// Import recreates the bundle's albums for the photographer. It is all or
+// nothing: when an album fails, everything imported so far is deleted again.
+// Errors caused by the bundle's contents wrap ErrInvalidBundle.
+func (s *ExportService) Import(ctx context.Context, bundle fs.FS, photographerID int) (*ImportResult, error) {
+	manifest, err := s.ReadManifest(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	photographer, err := s.userRepo.GetByID(ctx, photographerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photographer: %w", err)
+	}
+	if photographer == nil {
+		return nil, fmt.Errorf("%w: photographer not found", ErrInvalidBundle)
+	}
+
+	result := &ImportResult{
+		Albums:       []*models.Album{},
+		SkippedUsers: []string{},
+	}
+	users := make(map[string]*models.User)
+	skipped := make(map[string]bool)
+
+	resolveUser := func(exported *ExportedUser) (*models.User, error) {
+		if exported == nil {
+			return nil, nil
+		}
+		if user, ok := users[exported.Email]; ok {
+			return user, nil
+		}
+		user, err := s.userRepo.GetByEmail(ctx, exported.Email)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up user %s: %w", exported.Email, err)
+		}
+		users[exported.Email] = user
+		if user == nil && !skipped[exported.Email] {
+			skipped[exported.Email] = true
+			result.SkippedUsers = append(result.SkippedUsers, exported.Email)
+		}
+		return user, nil
+	}
+
+	for _, exportedAlbum := range manifest.Albums {
+		if err := s.importAlbum(ctx, bundle, exportedAlbum, photographerID, resolveUser, result); err != nil {
+			s.rollbackImport(ctx, result.Albums)
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// rollbackImport deletes the albums of a failed import, with their photos
+// in storage. Deleting an album takes its photos, comments and assigned
+// users with it in the database. It runs even when the import failed
+// because the request was cancelled.
+func (s *ExportService) rollbackImport(ctx context.Context, albums []*models.Album) {
+	ctx = context.WithoutCancel(ctx)
+	for _, album := range albums {
+		photos, err := s.photoRepo.GetByAlbum(ctx, album.ID)
+		if err != nil {
+			fmt.Printf("Warning: failed to list photos of album %d to roll back import: %v\n", album.ID, err)
+		}
+		for _, photo := range photos {
+			if err := s.photoService.DeletePhoto(ctx, photo.ID); err != nil {
+				fmt.Printf("Warning: failed to delete photo %d to roll back import: %v\n", photo.ID, err)
+			}
+		}
+		if err := s.albumService.DeleteAlbum(ctx, album.ID); err != nil {
+			fmt.Printf("Warning: failed to delete album %d to roll back import: %v\n", album.ID, err)
+		}
+	}
+}
+
+// importAlbum creates one of the bundle's albums and adds it to the result
+// as soon as it exists, so that a failure part way through can be rolled
+// back.
+func (s *ExportService) importAlbum(ctx context.Context, bundle fs.FS, exported *ExportedAlbum, photographerID int, resolveUser func(*ExportedUser) (*models.User, error), result *ImportResult) error {
+	if exported.Album == nil {
+		return fmt.Errorf("%w: album entry without album data", ErrInvalidBundle)
+	}
+
+	album := *exported.Album
+	album.ID = 0
+	album.ThumbnailPhotoID = nil
+	album.PhotographerID = photographerID
+
+	if err := s.albumService.CreateAlbum(ctx, &album); err != nil {
+		return fmt.Errorf("failed to create album %q: %w", album.Title, err)
+	}
+	result.Albums = append(result.Albums, &album)
+
+	var albumUsers []*models.AlbumUser
+	for _, exportedUser := range exported.Users {
+		user, err := resolveUser(exportedUser)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			albumUser := models.NewAlbumUser(album.ID, int(user.ID))
+			if perms := exportedUser.Permissions; perms != nil {
+				albumUser.CanComment = perms.CanComment
+				albumUser.CanRate = perms.CanRate
+				albumUser.CanDownload = perms.CanDownload
+				albumUser.CanShare = perms.CanShare
+			}
+			albumUsers = append(albumUsers, albumUser)
+		}
+	}
+	if len(albumUsers) > 0 {
+		if err := s.albumService.AssignUsersToAlbum(ctx, album.ID, albumUsers); err != nil {
+			return err
+		}
+	}
+
+	policy := s.photoService.GPSPolicyForAlbum(ctx, &album)
+
+	photoIDs := make(map[int]int)
+	for _, exportedPhoto := range exported.Photos {
+		if exportedPhoto.Photo == nil {
+			continue
+		}
+
+		photo, err := s.importPhoto(ctx, bundle, exportedPhoto, album.ID, policy)
+		if err != nil {
+			return err
+		}
+		photoIDs[exportedPhoto.Photo.ID] = photo.ID
+		result.PhotoCount++
+
+		commentIDs := make(map[int]int)
+		for _, exportedComment := range exportedPhoto.Comments {
+			if exportedComment.Comment == nil {
+				continue
+			}
+
+			author, err := resolveUser(exportedComment.Author)
+			if err != nil {
+				return err
+			}
+			if author == nil {
+				continue
+			}
+
+			comment := &models.Comment{
+				PhotoID: photo.ID,
+				UserID:  int(author.ID),
+				Text:    exportedComment.Text,
+			}
+			if exportedComment.ParentCommentID != nil {
+				parentID, ok := commentIDs[*exportedComment.ParentCommentID]
+				if !ok {
+					continue
+				}
+				comment.ParentCommentID = &parentID
+			}
+
+			if err := s.commentRepo.Create(ctx, comment); err != nil {
+				return fmt.Errorf("failed to import comment: %w", err)
+			}
+			commentIDs[exportedComment.ID] = comment.ID
+			result.CommentCount++
+		}
+	}
+
+	if exported.Album.ThumbnailPhotoID != nil {
+		if thumbnailID, ok := photoIDs[*exported.Album.ThumbnailPhotoID]; ok {
+			album.ThumbnailPhotoID = &thumbnailID
+			if err := s.albumService.UpdateAlbum(ctx, &album); err != nil {
+				return fmt.Errorf("failed to set album thumbnail: %w", err)
+			}
+		}
+	}
+
+	if s.photoService.esService != nil {
+		if err := s.photoService.BulkIndexPhotosByAlbum(ctx, album.ID); err != nil {
+			fmt.Printf("Warning: failed to index imported album %d: %v\n", album.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *ExportService) importPhoto(ctx context.Context, bundle fs.FS, exported *ExportedPhoto, albumID int, policy models.GPSPolicy) (*models.Photo, error) {
+	photoData, err := fs.ReadFile(bundle, exported.File)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read %s: %v", ErrInvalidBundle, exported.File, err)
+	}
+	if policy.Strip {
+		photoData = metadata.StripGPS(photoData)
+	}
+
+	var thumbnailData []byte
+	if exported.Thumbnail != "" {
+		thumbnailData, err = readOptional(bundle, exported.Thumbnail)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	uploadResult, err := s.storageService.ImportPhoto(ctx, photoData, thumbnailData)
+	if err != nil {
+		return nil, err
+	}
+
+	photo := *exported.Photo
+	photo.ID = 0
+	photo.AlbumID = albumID
+	photo.Filename = uploadResult.FileID
+	// User IDs do not carry over between installations; imported photos
+	// count as uploaded by the album's photographer.
+	photo.UploadedBy = nil
+	if photo.PickRejectState == "" {
+		photo.PickRejectState = models.PickRejectNone
+	}
+	if !policy.Retain {
+		metadata.RemoveGPS(photo.ExifData)
+	}
+
+	if err := s.photoRepo.Create(ctx, &photo); err != nil {
+		s.storageService.DeletePhoto(ctx, uploadResult.FileID)
+		return nil, fmt.Errorf("failed to create photo record: %w", err)
+	}
+
+	return &photo, nil
+}
+
+func readOptional(bundle fs.FS, name string) ([]byte, error) {
+	file, err := bundle.Open(name)
+	if err != nil {
+		return nil, nil
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read %s: %v", ErrInvalidBundle, name, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/suipic/backend/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyPasswordHash is compared against when no account matches a login.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// LoginThrottledError is returned while an account or IP address has to
+// wait before it may try to sign in again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Seconds is the wait rounded up to whole seconds, for the Retry-After
+// header.
+func (e *LoginThrottledError) Seconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// loginPolicy holds the brute-force protection settings. A zero limit turns
+// lockouts off for that kind, and a zero backoff turns off the delays.
+type loginPolicy struct {
+	maxAccountFailures int
+	maxIPFailures      int
+	window             time.Duration
+	lockout            time.Duration
+	backoffBase        time.Duration
+}
+
+func (s *AuthService) loginPolicy(ctx context.Context) loginPolicy {
+	policy := loginPolicy{
+		maxAccountFailures: 5,
+		maxIPFailures:      20,
+		window:             15 * time.Minute,
+		lockout:            15 * time.Minute,
+		backoffBase:        time.Second,
+	}
+
+	settings, err := s.settings.GetAllSettings(ctx)
+	if err != nil {
+		fmt.Printf("Warning: failed to load login settings, using defaults: %v\n", err)
+		return policy
+	}
+
+	for key, apply := range map[string]func(int){
+		SettingLoginMaxFailuresAccount:   func(v int) { policy.maxAccountFailures = v },
+		SettingLoginMaxFailuresIP:        func(v int) { policy.maxIPFailures = v },
+		SettingLoginFailureWindowMinutes: func(v int) { policy.window = time.Duration(v) * time.Minute },
+		SettingLoginLockoutMinutes:       func(v int) { policy.lockout = time.Duration(v) * time.Minute },
+		SettingLoginBackoffBaseSeconds:   func(v int) { policy.backoffBase = time.Duration(v) * time.Second },
+	} {
+		if value, ok := settings[key]; ok {
+			if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+				apply(parsed)
+			}
+		}
+	}
+	return policy
+}
+
+func (p loginPolicy) maxFailures(kind string) int {
+	if kind == models.ThrottleKindIP {
+		return p.maxIPFailures
+	}
+	return p.maxAccountFailures
+}
+
+// retryAt is when the next attempt is allowed: the end of a lockout, or the
+// last failure plus a delay that doubles with every failure.
+func (p loginPolicy) retryAt(throttle *models.LoginThrottle) time.Time {
+	if throttle == nil {
+		return time.Time{}
+	}
+	if throttle.LockedUntil != nil && throttle.LockedUntil.After(time.Now()) {
+		return *throttle.LockedUntil
+	}
+	if throttle.Failures == 0 || p.backoffBase == 0 || time.Since(throttle.LastFailureAt) > p.window {
+		return time.Time{}
+	}
+
+	limit := p.lockout
+	if limit == 0 {
+		limit = p.window
+	}
+	delay := limit
+	if shift := throttle.Failures - 1; shift < 30 {
+		delay = min(p.backoffBase<<shift, limit)
+	}
+	return throttle.LastFailureAt.Add(delay)
+}
+
+// checkLoginThrottle fails with a LoginThrottledError while the account or
+// IP address is backing off or locked out.
+func (s *AuthService) checkLoginThrottle(ctx context.Context, policy loginPolicy, kind, subject string) error {
+	if subject == "" {
+		return nil
+	}
+	throttle, err := s.loginRepo.GetThrottle(ctx, kind, subject)
+	if err != nil {
+		return err
+	}
+	if wait := time.Until(policy.retryAt(throttle)); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failure and locks the account or IP address
+// out once it reaches the configured limit.
+func (s *AuthService) recordLoginFailure(ctx context.Context, policy loginPolicy, kind, subject string) {
+	if subject == "" {
+		return
+	}
+	throttle, err := s.loginRepo.RecordFailure(ctx, kind, subject, policy.window)
+	if err != nil {
+		fmt.Printf("Warning: failed to record login failure for %s %s: %v\n", kind, subject, err)
+		return
+	}
+
+	max := policy.maxFailures(kind)
+	if max == 0 || policy.lockout == 0 || throttle.Failures < max {
+		return
+	}
+	if err := s.loginRepo.Lock(ctx, kind, subject, time.Now().Add(policy.lockout)); err != nil {
+		fmt.Printf("Warning: failed to lock out %s %s: %v\n", kind, subject, err)
+		return
+	}
+	fmt.Printf("Locked out %s %s for %s after %d failed logins\n", kind, subject, policy.lockout, throttle.Failures)
+}
+
+// auditLogin writes an entry to the login audit log and the general audit
+// log.
+func (s *AuthService) auditLogin(ctx context.Context, user *models.User, identifier string, client SessionClient, reason string) {
+	attempt := &models.LoginAttempt{
+		Identifier: truncate(identifier, 255),
+		Success:    reason == models.LoginAttemptSuccess,
+		Reason:     reason,
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+	if client.IPAddress != "" {
+		attempt.IPAddress = &client.IPAddress
+	}
+	if client.UserAgent != "" {
+		userAgent := truncate(client.UserAgent, 512)
+		attempt.UserAgent = &userAgent
+	}
+	if err := s.loginRepo.Record(ctx, attempt); err != nil {
+		fmt.Printf("Warning: failed to record login attempt: %v\n", err)
+	}
+
+	entry := &models.AuditEntry{
+		Action:     models.AuditLoginFailed,
+		ActorName:  attempt.Identifier,
+		TargetType: models.AuditTargetUser,
+		After:      models.AuditValues{"reason": reason},
+		IPAddress:  attempt.IPAddress,
+		UserAgent:  attempt.UserAgent,
+	}
+	if attempt.Success {
+		entry.Action = models.AuditLogin
+	}
+	if user != nil {
+		entry.ActorID = &user.ID
+		entry.ActorName = user.Username
+		entry.TargetID = strconv.FormatInt(user.ID, 10)
+	}
+	s.audit.Record(ctx, entry)
+}
+
+// authenticatePassword checks a password login against the brute-force
+// limits and records it when it fails. user is nil when no account matched
+// identifier.
+func (s *AuthService) authenticatePassword(ctx context.Context, user *models.User, identifier, password string, client SessionClient) error {
+	policy := s.loginPolicy(ctx)
+
+	if err := s.checkLoginThrottle(ctx, policy, models.ThrottleKindIP, client.throttleIP()); err != nil {
+		s.auditLogin(ctx, user, identifier, client, models.LoginAttemptThrottled)
+		return err
+	}
+
+	if user == nil {
+		// Spend the time of a password check so that response times do
+		// not tell which accounts exist.
+		s.CheckPassword(string(dummyPasswordHash), password)
+		s.recordLoginFailure(ctx, policy, models.ThrottleKindIP, client.throttleIP())
+		s.auditLogin(ctx, nil, identifier, client, models.LoginAttemptUnknownUser)
+		return fmt.Errorf("invalid credentials")
+	}
+
+	userKey := strconv.FormatInt(user.ID, 10)
+	if err := s.checkLoginThrottle(ctx, policy, models.ThrottleKindUser, userKey); err != nil {
+		s.auditLogin(ctx, user, identifier, client, models.LoginAttemptThrottled)
+		return err
+	}
+
+	if err := s.CheckPassword(user.PasswordHash, password); err != nil {
+		s.recordLoginFailure(ctx, policy, models.ThrottleKindUser, userKey)
+		s.recordLoginFailure(ctx, policy, models.ThrottleKindIP, client.throttleIP())
+		s.auditLogin(ctx, user, identifier, client, models.LoginAttemptInvalidPassword)
+		return fmt.Errorf("invalid credentials")
+	}
+
+	// The count is only reset once the login is complete, so that knowing
+	// the password does not reset the limit on guessing the second factor.
+	return nil
+}
+
+// verifySecondFactorThrottled is verifySecondFactor under the same limits
+// as passwords, since six-digit codes are short enough to guess otherwise.
+func (s *AuthService) verifySecondFactorThrottled(ctx context.Context, user *models.User, code string, client SessionClient) error {
+	policy := s.loginPolicy(ctx)
+	userKey := strconv.FormatInt(user.ID, 10)
+
+	err := s.checkLoginThrottle(ctx, policy, models.ThrottleKindIP, client.throttleIP())
+	if err == nil {
+		err = s.checkLoginThrottle(ctx, policy, models.ThrottleKindUser, userKey)
+	}
+	if err != nil {
+		s.auditLogin(ctx, user, user.Username, client, models.LoginAttemptThrottled)
+		return err
+	}
+
+	if err := s.verifySecondFactor(ctx, user, code); err != nil {
+		s.recordLoginFailure(ctx, policy, models.ThrottleKindUser, userKey)
+		s.recordLoginFailure(ctx, policy, models.ThrottleKindIP, client.throttleIP())
+		s.auditLogin(ctx, user, user.Username, client, models.LoginAttemptInvalidMFACode)
+		return err
+	}
+
+	return nil
+}
+
+// checkPasswordThrottled checks the password of a signed-in user who is asked
+// for it again, under the same limits as logins, so that a stolen session
+// cannot be used to guess it.
+func (s *AuthService) checkPasswordThrottled(ctx context.Context, user *models.User, password string, client SessionClient) error {
+	policy := s.loginPolicy(ctx)
+	userKey := strconv.FormatInt(user.ID, 10)
+
+	err := s.checkLoginThrottle(ctx, policy, models.ThrottleKindIP, client.throttleIP())
+	if err == nil {
+		err = s.checkLoginThrottle(ctx, policy, models.ThrottleKindUser, userKey)
+	}
+	if err != nil {
+		s.auditLogin(ctx, user, user.Username, client, models.LoginAttemptThrottled)
+		return err
+	}
+
+	if err := s.CheckPassword(user.PasswordHash, password); err != nil {
+		s.recordLoginFailure(ctx, policy, models.ThrottleKindUser, userKey)
+		s.recordLoginFailure(ctx, policy, models.ThrottleKindIP, client.throttleIP())
+		s.auditLogin(ctx, user, user.Username, client, models.LoginAttemptInvalidPassword)
+		return ErrPasswordIncorrect
+	}
+
+	return nil
+}
+
+// clearLoginFailures resets the account's count after a successful sign-in.
+// The IP address keeps its count, so that signing in to one account between
+// guesses at others does not reset the limit.
+func (s *AuthService) clearLoginFailures(ctx context.Context, user *models.User) {
+	policy := s.loginPolicy(ctx)
+	if _, err := s.loginRepo.ClearThrottle(ctx, models.ThrottleKindUser, strconv.FormatInt(user.ID, 10)); err != nil {
+		fmt.Printf("Warning: failed to clear login failures of user %d: %v\n", user.ID, err)
+	}
+	if err := s.loginRepo.DeleteStaleThrottles(ctx, time.Now().Add(-policy.window)); err != nil {
+		fmt.Printf("Warning: failed to clean up login throttles: %v\n", err)
+	}
+}
+
+// UnlockUser lifts a user's lockout and forgets their failed logins. It
+// reports whether the user had any.
+func (s *AuthService) UnlockUser(ctx context.Context, userID int64) (bool, error) {
+	return s.loginRepo.ClearThrottle(ctx, models.ThrottleKindUser, strconv.FormatInt(userID, 10))
+}
+
+// UnlockIP lifts the lockout of an IP address.
+func (s *AuthService) UnlockIP(ctx context.Context, ip string) (bool, error) {
+	return s.loginRepo.ClearThrottle(ctx, models.ThrottleKindIP, ip)
+}
+
+func (s *AuthService) ListLockouts(ctx context.Context) ([]*models.LoginThrottle, error) {
+	lockouts, err := s.loginRepo.ListLocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lockouts == nil {
+		lockouts = []*models.LoginThrottle{}
+	}
+	return lockouts, nil
+}
+
+func (s *AuthService) ListLoginAttempts(ctx context.Context, filter models.LoginAttemptFilter) ([]*models.LoginAttempt, error) {
+	attempts, err := s.loginRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if attempts == nil {
+		attempts = []*models.LoginAttempt{}
+	}
+	return attempts, nil
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/totp"
+)
+
+const (
+	mfaChallengeExpiry   = 5 * time.Minute
+	mfaChallengeAudience = "mfa"
+	mfaCodeSkew          = 1
+	mfaRecoveryCodes     = 10
+)
+
+// ErrMFANotConfigured is returned when enrolling without MFA_ENCRYPTION_KEY
+// set, as there is no key to protect the secret with.
+var ErrMFANotConfigured = errors.New("two-factor authentication is not configured on this server")
+
+// LoginResult is the outcome of a correct password. Either Tokens is set and
+// the user is signed in, or MFAToken is, and the user still has to present a
+// second factor, or enroll one first when MFAEnrollmentRequired is set.
+type LoginResult struct {
+	User                  *models.User
+	Tokens                *TokenPair
+	MFAToken              string
+	MFAEnrollmentRequired bool
+}
+
+// MFAClaims are carried by the short-lived challenge token handed out between
+// the password and the second factor. Its audience keeps it from being
+// accepted as an access token.
+type MFAClaims struct {
+	UserID int64 `json:"user_id"`
+	Enroll bool  `json:"enroll,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabledAt,omitempty"`
+	Required               bool       `json:"required"`
+	RecoveryCodesRemaining int        `json:"recoveryCodesRemaining"`
+}
+
+// TOTPEnrollment is what an authenticator app needs to be set up: the secret
+// to type in, and the otpauth:// URI to show as a QR code.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+// MFARequired reports whether the user's role has to use a second factor
+// under the require_mfa_privileged setting.
+func (s *AuthService) MFARequired(ctx context.Context, user *models.User) bool {
+	if user.Role != models.RoleAdmin && user.Role != models.RolePhotographer {
+		return false
+	}
+	return s.settings.GetBool(ctx, SettingRequireMFAPrivileged, false)
+}
+
+// BeginSession signs a user in whose password was checked, unless a second
+// factor is needed first, in which case it returns a challenge token. A
+// session being created is recorded as a successful login.
+func (s *AuthService) BeginSession(ctx context.Context, user *models.User, client SessionClient) (*LoginResult, error) {
+	return s.beginSession(ctx, user, user.Username, client)
+}
+
+// beginSession is BeginSession with the identifier the user signed in with,
+// for the login audit log.
+func (s *AuthService) beginSession(ctx context.Context, user *models.User, identifier string, client SessionClient) (*LoginResult, error) {
+	if err := checkAccountUsable(user); err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.mfaRepo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa.Enabled() || s.MFARequired(ctx, user) {
+		enroll := !mfa.Enabled()
+		token, err := s.generateMFAToken(user, enroll)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, MFAToken: token, MFAEnrollmentRequired: enroll}, nil
+	}
+
+	tokens, err := s.CreateSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	s.auditLogin(ctx, user, identifier, client, models.LoginAttemptSuccess)
+	return &LoginResult{User: user, Tokens: tokens}, nil
+}
+
+// CompleteMFALogin finishes a login with a TOTP or recovery code.
+func (s *AuthService) CompleteMFALogin(ctx context.Context, mfaToken, code string, client SessionClient) (*models.User, *TokenPair, error) {
+	user, err := s.mfaChallengeUser(mfaToken, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.verifySecondFactorThrottled(ctx, user, code, client); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.CreateSession(ctx, user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.clearLoginFailures(ctx, user)
+	s.auditLogin(ctx, user, user.Username, client, models.LoginAttemptSuccess)
+	return user, tokens, nil
+}
+
+// BeginChallengeEnrollment starts TOTP enrollment for a user who has to set
+// up two-factor authentication before their login can finish.
+func (s *AuthService) BeginChallengeEnrollment(ctx context.Context, mfaToken string) (*TOTPEnrollment, error) {
+	user, err := s.mfaChallengeUser(mfaToken, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.BeginTOTPEnrollment(ctx, user)
+}
+
+// CompleteChallengeEnrollment confirms the enrollment started with
+// BeginChallengeEnrollment and signs the user in.
+func (s *AuthService) CompleteChallengeEnrollment(ctx context.Context, mfaToken, code string, client SessionClient) (*models.User, *TokenPair, []string, error) {
+	user, err := s.mfaChallengeUser(mfaToken, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	recoveryCodes, err := s.EnableTOTP(ctx, user, code)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tokens, err := s.CreateSession(ctx, user, client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s.auditLogin(ctx, user, user.Username, client, models.LoginAttemptSuccess)
+	return user, tokens, recoveryCodes, nil
+}
+
+func (s *AuthService) GetMFAStatus(ctx context.Context, user *models.User) (*MFAStatus, error) {
+	mfa, err := s.mfaRepo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MFAStatus{Required: s.MFARequired(ctx, user)}
+	if !mfa.Enabled() {
+		return status, nil
+	}
+
+	remaining, err := s.mfaRepo.CountUnusedRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	status.Enabled = true
+	status.EnabledAt = mfa.EnabledAt
+	status.RecoveryCodesRemaining = remaining
+	return status, nil
+}
+
+// BeginTOTPEnrollment generates a new secret for the user. It does nothing
+// until EnableTOTP confirms the user's authenticator produces the same codes.
+func (s *AuthService) BeginTOTPEnrollment(ctx context.Context, user *models.User) (*TOTPEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	encrypted, err := s.encryptMFASecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SavePending(ctx, &models.UserMFA{UserID: user.ID, TOTPSecret: encrypted}); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.mfaIssuer, user.Username, secret),
+	}, nil
+}
+
+// EnableTOTP turns on two-factor authentication once the user enters a code
+// from their newly set up authenticator, and returns their recovery codes.
+// The codes are only ever shown this once.
+func (s *AuthService) EnableTOTP(ctx context.Context, user *models.User, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, fmt.Errorf("no pending two-factor enrollment")
+	}
+	if mfa.Enabled() {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	secret, err := s.decryptMFASecret(mfa.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), mfaCodeSkew)
+	if !ok {
+		return nil, fmt.Errorf("invalid two-factor code")
+	}
+
+	if err := s.mfaRepo.Enable(ctx, user.ID, step); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, user)
+}
+
+// DisableTOTP turns two-factor authentication off. It takes both the password
+// and a current code, so a stolen session alone cannot remove it. Both count
+// against the login limits.
+func (s *AuthService) DisableTOTP(ctx context.Context, user *models.User, password, code string, client SessionClient) error {
+	if err := s.checkPasswordThrottled(ctx, user, password, client); err != nil {
+		return err
+	}
+	if s.MFARequired(ctx, user) {
+		return fmt.Errorf("two-factor authentication is required for your account")
+	}
+	if err := s.verifySecondFactorThrottled(ctx, user, code, client); err != nil {
+		return err
+	}
+	return s.mfaRepo.Delete(ctx, user.ID)
+}
+
+// ResetMFA removes a user's second factor without asking for it, for an
+// admin helping someone who lost their authenticator and recovery codes.
+func (s *AuthService) ResetMFA(ctx context.Context, userID int64) error {
+	return s.mfaRepo.Delete(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a current TOTP code.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, user *models.User, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.Enabled() {
+		return nil, fmt.Errorf("two-factor authentication is not enabled")
+	}
+	if err := s.verifyTOTP(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, user)
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func (s *AuthService) verifySecondFactor(ctx context.Context, user *models.User, code string) error {
+	mfa, err := s.mfaRepo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled() {
+		return fmt.Errorf("two-factor authentication is not enabled")
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.verifyTOTP(ctx, mfa, code)
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(ctx, user.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return fmt.Errorf("invalid two-factor code")
+	}
+	return nil
+}
+
+// verifyTOTP checks a code and records its time step, so that a code seen
+// over someone's shoulder cannot be used a second time.
+func (s *AuthService) verifyTOTP(ctx context.Context, mfa *models.UserMFA, code string) error {
+	secret, err := s.decryptMFASecret(mfa.TOTPSecret)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), mfaCodeSkew)
+	if !ok {
+		return fmt.Errorf("invalid two-factor code")
+	}
+
+	fresh, err := s.mfaRepo.UseStep(ctx, mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return fmt.Errorf("two-factor code was already used")
+	}
+	return nil
+}
+
+func (s *AuthService) replaceRecoveryCodes(ctx context.Context, user *models.User) ([]string, error) {
+	codes := make([]string, 0, mfaRecoveryCodes)
+	hashes := make([]string, 0, mfaRecoveryCodes)
+	for range mfaRecoveryCodes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *AuthService) generateMFAToken(user *models.User, enroll bool) (string, error) {
+	now := time.Now()
+	claims := MFAClaims{
+		UserID: user.ID,
+		Enroll: enroll,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeExpiry)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return s.keys.SignInternal(claims)
+}
+
+// mfaChallengeUser returns the user a challenge token was issued to, checking
+// it is for the expected step of the login.
+func (s *AuthService) mfaChallengeUser(tokenString string, enroll bool) (*models.User, error) {
+	claims := &MFAClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, s.keys.InternalKeyfunc, jwt.WithAudience(mfaChallengeAudience))
+	if err != nil || claims.Enroll != enroll {
+		return nil, fmt.Errorf("invalid or expired two-factor challenge")
+	}
+
+	user, err := s.dbService.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("invalid or expired two-factor challenge")
+	}
+	if user.Suspended() {
+		return nil, ErrAccountSuspended
+	}
+	return user, nil
+}
+
+// encryptMFASecret seals a TOTP secret with AES-GCM so that a database dump
+// alone is not enough to generate codes.
+func (s *AuthService) encryptMFASecret(secret string) (string, error) {
+	gcm, err := s.mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *AuthService) decryptMFASecret(encrypted string) (string, error) {
+	gcm, err := s.mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("failed to decrypt two-factor secret")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	secret, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt two-factor secret")
+	}
+	return string(secret), nil
+}
+
+// checkMFAKey refuses to run without MFA_ENCRYPTION_KEY once secrets are
+// stored, since none of them could be decrypted.
+func (s *AuthService) checkMFAKey(ctx context.Context) error {
+	if s.mfaKey != "" {
+		return nil
+	}
+	enrolled, err := s.mfaRepo.CountEnrolled(ctx)
+	if err != nil {
+		return err
+	}
+	if enrolled > 0 {
+		return fmt.Errorf("MFA_ENCRYPTION_KEY is not set but %d users have two-factor secrets stored; set it to the key they were encrypted with (JWT_SECRET on earlier versions)", enrolled)
+	}
+	return nil
+}
+
+func (s *AuthService) mfaCipher() (cipher.AEAD, error) {
+	if s.mfaKey == "" {
+		return nil, ErrMFANotConfigured
+	}
+	key := sha256.Sum256([]byte(s.mfaKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// generateRecoveryCode returns a code such as "k7m2q-x3p4d". Base32 has no
+// 0, 1 or 8, which are easily mistaken for letters when written down.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suipic/backend/config"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/oidc"
+	"github.com/suipic/backend/repository"
+)
+
+const (
+	// How long a user may spend at the identity provider before the
+	// sign-in has to be started again.
+	oidcRequestExpiry = 10 * time.Minute
+
+	maxUsernameLength = 50
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// OIDCProviderInfo is what the login page needs to offer a provider.
+type OIDCProviderInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// OIDCService signs users in through external OpenID Connect providers.
+// Accounts are matched by the provider's subject, then by verified email,
+// and created on first sign-in when the provider allows it.
+type OIDCService struct {
+	authService *AuthService
+	dbService   *DatabaseService
+	oidcRepo    repository.OIDCRepository
+	providers   []*oidc.Provider
+}
+
+func NewOIDCService(cfg *config.OIDCConfig, publicURL string, authService *AuthService, dbService *DatabaseService) (*OIDCService, error) {
+	service := &OIDCService{
+		authService: authService,
+		dbService:   dbService,
+		oidcRepo:    dbService.GetOIDCRepo(),
+	}
+
+	seen := make(map[string]bool)
+	for i := range cfg.Providers {
+		providerCfg := &cfg.Providers[i]
+		if providerCfg.Issuer == "" || providerCfg.ClientID == "" {
+			return nil, fmt.Errorf("oidc provider %q needs an issuer and a client ID", providerCfg.ID)
+		}
+		if seen[providerCfg.ID] {
+			return nil, fmt.Errorf("oidc provider %q is configured twice", providerCfg.ID)
+		}
+		seen[providerCfg.ID] = true
+
+		redirectURL := providerCfg.RedirectURL
+		if redirectURL == "" {
+			redirectURL = publicURL + "/login/oidc/" + providerCfg.ID + "/callback"
+		}
+		service.providers = append(service.providers, oidc.NewProvider(providerCfg, redirectURL))
+	}
+
+	return service, nil
+}
+
+func (s *OIDCService) ListProviders() []OIDCProviderInfo {
+	providers := make([]OIDCProviderInfo, 0, len(s.providers))
+	for _, provider := range s.providers {
+		providers = append(providers, OIDCProviderInfo{
+			ID:   provider.Config().ID,
+			Name: provider.Config().Name,
+		})
+	}
+	return providers
+}
+
+// StartLogin begins a sign-in with a provider and returns the URL to send the
+// browser to, along with the state the callback will carry.
+func (s *OIDCService) StartLogin(ctx context.Context, providerID string) (string, string, error) {
+	provider := s.provider(providerID)
+	if provider == nil {
+		return "", "", fmt.Errorf("unknown identity provider")
+	}
+
+	if err := s.oidcRepo.DeleteExpiredAuthRequests(ctx); err != nil {
+		fmt.Printf("Warning: failed to clean up oidc auth requests: %v\n", err)
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to generate state: %w", err)
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.oidcRepo.CreateAuthRequest(ctx, &models.OIDCAuthRequest{
+		StateHash:    hashToken(state),
+		Provider:     providerID,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oidcRequestExpiry),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// CompleteLogin handles the provider's callback: it exchanges the code,
+// verifies the ID token and signs in the matching user.
+func (s *OIDCService) CompleteLogin(ctx context.Context, providerID, code, state string, client SessionClient) (*LoginResult, error) {
+	provider := s.provider(providerID)
+	if provider == nil {
+		return nil, fmt.Errorf("unknown identity provider")
+	}
+
+	request, err := s.oidcRepo.ConsumeAuthRequest(ctx, hashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	if request == nil || request.Provider != providerID || time.Now().After(request.ExpiresAt) {
+		return nil, fmt.Errorf("sign-in expired, please try again")
+	}
+
+	token, err := provider.Exchange(ctx, code, request.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := provider.Verify(ctx, token, request.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, provider.Config(), claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authService.BeginSession(ctx, user, client)
+}
+
+// resolveUser finds or creates the account for a verified identity.
+func (s *OIDCService) resolveUser(ctx context.Context, cfg *config.OIDCProviderConfig, claims *oidc.Claims) (*models.User, error) {
+	var email *string
+	if claims.Email != "" {
+		email = &claims.Email
+	}
+
+	identity, err := s.oidcRepo.GetIdentity(ctx, cfg.ID, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		user, err := s.dbService.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("linked account no longer exists")
+		}
+		identity.Email = email
+		if err := s.oidcRepo.TouchIdentity(ctx, identity); err != nil {
+			fmt.Printf("Warning: failed to update identity %d: %v\n", identity.ID, err)
+		}
+		return s.syncUser(ctx, cfg, claims, user)
+	}
+
+	if claims.Email == "" {
+		return nil, fmt.Errorf("identity provider did not share an email address")
+	}
+
+	user, err := s.dbService.GetUserByEmail(claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		// Linking on an address the provider has not checked would let
+		// anyone who can register it there take over the account here.
+		if !claims.EmailVerified {
+			return nil, fmt.Errorf("an account with this email already exists; sign in with your password instead")
+		}
+	} else {
+		if !cfg.AllowSignup {
+			return nil, fmt.Errorf("no account is linked to this identity")
+		}
+		user, err = s.provisionUser(cfg, claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.oidcRepo.CreateIdentity(ctx, &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: cfg.ID,
+		Subject:  claims.Subject,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.syncUser(ctx, cfg, claims, user)
+}
+
+// provisionUser creates an account on first sign-in. It is a client account
+// unless the role claim maps to another role.
+func (s *OIDCService) provisionUser(cfg *config.OIDCProviderConfig, claims *oidc.Claims) (*models.User, error) {
+	username, err := s.availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.RoleClient
+	if mapped, ok := mapRole(cfg, claims); ok {
+		role = mapped
+	}
+
+	// The account signs in through the provider. A random password keeps
+	// password login closed until the user sets one with a reset link.
+	password, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+	hashedPassword, err := s.authService.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user, err := s.dbService.CreateUserWithFriendlyName(claims.Email, username, hashedPassword, strings.TrimSpace(claims.Name), role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	fmt.Printf("Provisioned %s account %q from identity provider %s\n", role, username, cfg.ID)
+	return user, nil
+}
+
+// syncUser applies what the provider says about the user on every sign-in:
+// the role when the role claim maps to one, and email verification.
+func (s *OIDCService) syncUser(ctx context.Context, cfg *config.OIDCProviderConfig, claims *oidc.Claims, user *models.User) (*models.User, error) {
+	changed := false
+	previousRole := user.Role
+	if role, ok := mapRole(cfg, claims); ok && role != user.Role {
+		fmt.Printf("Changing role of user %d from %s to %s per identity provider %s\n", user.ID, user.Role, role, cfg.ID)
+		user.Role = role
+		changed = true
+	}
+	if user.EmailVerifiedAt == nil && claims.EmailVerified && strings.EqualFold(claims.Email, user.Email) {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		changed = true
+	}
+
+	if changed {
+		if err := s.dbService.GetUserRepo().Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.Role != previousRole {
+		s.authService.audit.Record(ctx, &models.AuditEntry{
+			Action:     models.AuditUserUpdate,
+			ActorName:  "identity provider " + cfg.ID,
+			TargetType: models.AuditTargetUser,
+			TargetID:   strconv.FormatInt(user.ID, 10),
+			Before:     models.AuditValues{"role": previousRole},
+			After:      models.AuditValues{"role": user.Role},
+		})
+	}
+	return user, nil
+}
+
+// availableUsername derives a username from the preferred_username claim or
+// the email's local part, adding a number when it is taken.
+func (s *OIDCService) availableUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.Trim(usernameInvalidChars.ReplaceAllString(strings.ToLower(base), "-"), "-.")
+	if base == "" {
+		base = "user"
+	}
+	base = truncate(base, maxUsernameLength-4)
+
+	candidate := base
+	for i := 2; i < 1000; i++ {
+		existing, err := s.dbService.GetUserByUsername(candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", fmt.Errorf("could not find a free username for %q", base)
+}
+
+func (s *OIDCService) ListIdentities(ctx context.Context, userID int64) ([]*models.UserIdentity, error) {
+	identities, err := s.oidcRepo.ListIdentitiesByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if identities == nil {
+		identities = []*models.UserIdentity{}
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity removes one of the user's linked provider accounts. It
+// reports false when the user has no identity with that ID.
+func (s *OIDCService) UnlinkIdentity(ctx context.Context, userID int64, identityID int) (bool, error) {
+	identities, err := s.oidcRepo.ListIdentitiesByUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, identity := range identities {
+		if identity.ID == identityID {
+			return true, s.oidcRepo.DeleteIdentity(ctx, identity.ID)
+		}
+	}
+	return false, nil
+}
+
+func (s *OIDCService) provider(id string) *oidc.Provider {
+	for _, provider := range s.providers {
+		if provider.Config().ID == id {
+			return provider
+		}
+	}
+	return nil
+}
+
+// mapRole looks up the user's role from the configured claim. Admin wins
+// over photographer when both match; no match leaves the role alone.
+func mapRole(cfg *config.OIDCProviderConfig, claims *oidc.Claims) (models.UserRole, bool) {
+	if cfg.RoleClaim == "" {
+		return "", false
+	}
+
+	values := claims.Values(cfg.RoleClaim)
+	matches := func(wanted []string) bool {
+		for _, value := range values {
+			if slices.Contains(wanted, value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case matches(cfg.AdminValues):
+		return models.RoleAdmin, true
+	case matches(cfg.PhotographerValues):
+		return models.RolePhotographer, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,499 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/repository"
+)
+
+const maxOrganizationNameLen = 100
+
+var (
+	ErrNotInOrganization  = errors.New("you are not a member of an organization")
+	ErrOrganizationDenied = errors.New("you are not allowed to manage this organization")
+)
+
+// Organization settings members may change. Anything else is rejected, so
+// the settings table cannot grow into a free-form store.
+const (
+	OrgSettingBrandName    = "brand_name"
+	OrgSettingBrandLogoURL = "brand_logo_url"
+	OrgSettingBrandColor   = "brand_primary_color"
+	OrgSettingSupportEmail = "support_email"
+)
+
+var organizationSettingKeys = map[string]bool{
+	OrgSettingBrandName:    true,
+	OrgSettingBrandLogoURL: true,
+	OrgSettingBrandColor:   true,
+	OrgSettingSupportEmail: true,
+}
+
+// OrganizationDetails is what a member sees of their organization.
+type OrganizationDetails struct {
+	*models.Organization
+	Role     models.OrganizationRole      `json:"role"`
+	Members  []*models.OrganizationMember `json:"members"`
+	Usage    *models.OrganizationUsage    `json:"usage"`
+	Settings map[string]string            `json:"settings"`
+}
+
+// OrganizationService groups photographers into studios that share
+// clients, settings and quotas. Photographers outside any organization work
+// exactly as before.
+type OrganizationService struct {
+	albumService *AlbumService
+	dbService    *DatabaseService
+	orgRepo      repository.OrganizationRepository
+}
+
+func NewOrganizationService(albumService *AlbumService, dbService *DatabaseService) *OrganizationService {
+	return &OrganizationService{
+		albumService: albumService,
+		dbService:    dbService,
+		orgRepo:      dbService.GetOrganizationRepo(),
+	}
+}
+
+// CreateOrganization creates an organization owned by the photographer. Their
+// existing albums only move into it when moveAlbums is set.
+func (s *OrganizationService) CreateOrganization(ctx context.Context, owner *models.User, name string, moveAlbums bool) (*models.Organization, error) {
+	if owner.Role != models.RolePhotographer {
+		return nil, fmt.Errorf("only photographers can create organizations")
+	}
+	name, err := validateOrganizationName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.orgRepo.GetMembership(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("you already belong to an organization")
+	}
+
+	org := &models.Organization{Name: name}
+	member := &models.OrganizationMember{UserID: owner.ID, Role: models.OrgRoleOwner}
+	if err := s.orgRepo.Create(ctx, org, member); err != nil {
+		return nil, err
+	}
+	if moveAlbums {
+		if err := s.albumService.moveAlbumsToOrganization(ctx, owner.ID, &org.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return org, nil
+}
+
+// GetMembership returns the user's membership, failing with
+// ErrNotInOrganization when they have none.
+func (s *OrganizationService) GetMembership(ctx context.Context, userID int64) (*models.OrganizationMember, error) {
+	member, err := s.orgRepo.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrNotInOrganization
+	}
+	return member, nil
+}
+
+func (s *OrganizationService) GetOrganizationDetails(ctx context.Context, member *models.OrganizationMember) (*OrganizationDetails, error) {
+	org, err := s.orgRepo.GetByID(ctx, member.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrNotInOrganization
+	}
+
+	members, err := s.orgRepo.ListMembers(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.orgRepo.GetUsage(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.orgRepo.GetSettings(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrganizationDetails{
+		Organization: org,
+		Role:         member.Role,
+		Members:      members,
+		Usage:        usage,
+		Settings:     settings,
+	}, nil
+}
+
+func (s *OrganizationService) RenameOrganization(ctx context.Context, actor *models.OrganizationMember, name string) (*models.Organization, error) {
+	if !actor.CanManage() {
+		return nil, ErrOrganizationDenied
+	}
+	name, err := validateOrganizationName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, actor.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrNotInOrganization
+	}
+
+	org.Name = name
+	if err := s.orgRepo.Update(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// InviteMember invites a photographer who is not in an organization yet.
+// They only become a member once they accept. Only owners may invite other
+// owners.
+func (s *OrganizationService) InviteMember(ctx context.Context, actor *models.OrganizationMember, userID int64, role models.OrganizationRole) (*models.OrganizationInvite, error) {
+	if err := s.checkRoleChange(actor, role); err != nil {
+		return nil, err
+	}
+
+	user, err := s.dbService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.Role != models.RolePhotographer {
+		return nil, fmt.Errorf("only photographers can join an organization")
+	}
+
+	existing, err := s.orgRepo.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("user already belongs to an organization")
+	}
+
+	if err := s.albumService.checkQuota(ctx, actor.OrganizationID, "members"); err != nil {
+		return nil, err
+	}
+
+	invite := &models.OrganizationInvite{
+		OrganizationID: actor.OrganizationID,
+		UserID:         userID,
+		Role:           role,
+		InvitedBy:      &actor.UserID,
+		Username:       user.Username,
+	}
+	if err := s.orgRepo.CreateInvite(ctx, invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// ListInvites returns the organization's pending invites.
+func (s *OrganizationService) ListInvites(ctx context.Context, actor *models.OrganizationMember) ([]*models.OrganizationInvite, error) {
+	if !actor.CanManage() {
+		return nil, ErrOrganizationDenied
+	}
+	return invitesOrEmpty(s.orgRepo.ListInvites(ctx, actor.OrganizationID, 0))
+}
+
+// RevokeInvite withdraws one of the organization's pending invites and
+// returns it.
+func (s *OrganizationService) RevokeInvite(ctx context.Context, actor *models.OrganizationMember, inviteID int) (*models.OrganizationInvite, error) {
+	if !actor.CanManage() {
+		return nil, ErrOrganizationDenied
+	}
+	invite, err := s.orgRepo.GetInvite(ctx, inviteID)
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || invite.OrganizationID != actor.OrganizationID {
+		return nil, fmt.Errorf("invite not found")
+	}
+	if invite.Role == models.OrgRoleOwner && actor.Role != models.OrgRoleOwner {
+		return nil, ErrOrganizationDenied
+	}
+
+	if _, err := s.orgRepo.DeleteInvite(ctx, inviteID); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// ListReceivedInvites returns the invites waiting for the user's answer.
+func (s *OrganizationService) ListReceivedInvites(ctx context.Context, userID int64) ([]*models.OrganizationInvite, error) {
+	return invitesOrEmpty(s.orgRepo.ListInvites(ctx, 0, userID))
+}
+
+// AcceptInvite makes the user a member of the inviting organization. Their
+// existing albums, which its owners and managers could then manage, only
+// move into it when moveAlbums is set.
+func (s *OrganizationService) AcceptInvite(ctx context.Context, userID int64, inviteID int, moveAlbums bool) (*models.OrganizationMember, error) {
+	invite, err := s.orgRepo.GetInvite(ctx, inviteID)
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || invite.UserID != userID {
+		return nil, fmt.Errorf("invite not found")
+	}
+
+	existing, err := s.orgRepo.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("you already belong to an organization")
+	}
+	if err := s.albumService.checkQuota(ctx, invite.OrganizationID, "members"); err != nil {
+		return nil, err
+	}
+	if moveAlbums {
+		// Check before joining, so that albums that do not fit leave the
+		// user free to join without them instead of half joined.
+		if err := s.albumService.checkMoveQuota(ctx, userID, invite.OrganizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	member, err := s.orgRepo.AcceptInvite(ctx, invite)
+	if err != nil {
+		return nil, err
+	}
+	if moveAlbums {
+		if err := s.albumService.moveAlbumsToOrganization(ctx, userID, &member.OrganizationID); err != nil {
+			return nil, err
+		}
+	}
+	return member, nil
+}
+
+// DeclineInvite turns down an invite addressed to the user.
+func (s *OrganizationService) DeclineInvite(ctx context.Context, userID int64, inviteID int) error {
+	invite, err := s.orgRepo.GetInvite(ctx, inviteID)
+	if err != nil {
+		return err
+	}
+	if invite == nil || invite.UserID != userID {
+		return fmt.Errorf("invite not found")
+	}
+
+	_, err = s.orgRepo.DeleteInvite(ctx, inviteID)
+	return err
+}
+
+// MoveAlbumsIntoOrganization moves the member's own albums into their
+// organization, for members who joined without them.
+func (s *OrganizationService) MoveAlbumsIntoOrganization(ctx context.Context, member *models.OrganizationMember) error {
+	return s.albumService.moveAlbumsToOrganization(ctx, member.UserID, &member.OrganizationID)
+}
+
+func invitesOrEmpty(invites []*models.OrganizationInvite, err error) ([]*models.OrganizationInvite, error) {
+	if err != nil {
+		return nil, err
+	}
+	if invites == nil {
+		invites = []*models.OrganizationInvite{}
+	}
+	return invites, nil
+}
+
+// UpdateMemberRole changes a member's role and returns the member as they
+// were before. Managers may not touch owners, and the organization always
+// keeps at least one owner.
+func (s *OrganizationService) UpdateMemberRole(ctx context.Context, actor *models.OrganizationMember, userID int64, role models.OrganizationRole) (*models.OrganizationMember, error) {
+	if err := s.checkRoleChange(actor, role); err != nil {
+		return nil, err
+	}
+
+	target, err := s.memberOf(ctx, actor.OrganizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if target.Role == models.OrgRoleOwner && actor.Role != models.OrgRoleOwner {
+		return nil, ErrOrganizationDenied
+	}
+	if target.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+		if err := s.checkNotLastOwner(ctx, actor.OrganizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.orgRepo.UpdateMemberRole(ctx, actor.OrganizationID, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("user is not a member of the organization")
+	}
+	return target, nil
+}
+
+// RemoveMember removes a member, or lets a member leave, and takes their
+// albums out of the organization. It returns the removed member. The last
+// owner cannot leave.
+func (s *OrganizationService) RemoveMember(ctx context.Context, actor *models.OrganizationMember, userID int64) (*models.OrganizationMember, error) {
+	if userID != actor.UserID && !actor.CanManage() {
+		return nil, ErrOrganizationDenied
+	}
+
+	target, err := s.memberOf(ctx, actor.OrganizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if target.Role == models.OrgRoleOwner {
+		if userID != actor.UserID && actor.Role != models.OrgRoleOwner {
+			return nil, ErrOrganizationDenied
+		}
+		if err := s.checkNotLastOwner(ctx, actor.OrganizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	removed, err := s.orgRepo.RemoveMember(ctx, actor.OrganizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, fmt.Errorf("user is not a member of the organization")
+	}
+	if err := s.albumService.moveAlbumsToOrganization(ctx, userID, nil); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// ListClients returns the clients of every photographer in the member's
+// organization.
+func (s *OrganizationService) ListClients(ctx context.Context, member *models.OrganizationMember) ([]*models.User, error) {
+	return s.orgRepo.ListClients(ctx, member.OrganizationID)
+}
+
+func (s *OrganizationService) GetSettings(ctx context.Context, member *models.OrganizationMember) (map[string]string, error) {
+	return s.orgRepo.GetSettings(ctx, member.OrganizationID)
+}
+
+// UpdateSettings stores the given settings. Unknown keys are rejected
+// before anything is written.
+func (s *OrganizationService) UpdateSettings(ctx context.Context, actor *models.OrganizationMember, settings map[string]string) error {
+	if !actor.CanManage() {
+		return ErrOrganizationDenied
+	}
+	for key, value := range settings {
+		if !organizationSettingKeys[key] {
+			return fmt.Errorf("unknown organization setting %q", key)
+		}
+		if key == OrgSettingSupportEmail && value != "" {
+			if err := validateEmail(value); err != nil {
+				return err
+			}
+		}
+	}
+
+	for key, value := range settings {
+		if err := s.orgRepo.SetSetting(ctx, actor.OrganizationID, key, strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListOrganizations returns every organization, for admins.
+func (s *OrganizationService) ListOrganizations(ctx context.Context) ([]*models.Organization, error) {
+	return s.orgRepo.List(ctx)
+}
+
+// SetQuotas replaces the organization's quotas. Nil lifts a quota; lowering
+// one below current usage only blocks further growth.
+func (s *OrganizationService) SetQuotas(ctx context.Context, organizationID int, maxMembers, maxAlbums, maxPhotos *int) (*models.Organization, error) {
+	for _, quota := range []*int{maxMembers, maxAlbums, maxPhotos} {
+		if quota != nil && *quota < 0 {
+			return nil, fmt.Errorf("quotas cannot be negative")
+		}
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, nil
+	}
+
+	org.MaxMembers = maxMembers
+	org.MaxAlbums = maxAlbums
+	org.MaxPhotos = maxPhotos
+	if err := s.orgRepo.Update(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// checkRoleChange fails unless actor may give someone role.
+func (s *OrganizationService) checkRoleChange(actor *models.OrganizationMember, role models.OrganizationRole) error {
+	switch role {
+	case models.OrgRoleOwner, models.OrgRoleManager, models.OrgRolePhotographer:
+	default:
+		return fmt.Errorf("invalid organization role")
+	}
+	if !actor.CanManage() {
+		return ErrOrganizationDenied
+	}
+	if role == models.OrgRoleOwner && actor.Role != models.OrgRoleOwner {
+		return ErrOrganizationDenied
+	}
+	return nil
+}
+
+func (s *OrganizationService) memberOf(ctx context.Context, organizationID int, userID int64) (*models.OrganizationMember, error) {
+	member, err := s.orgRepo.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil || member.OrganizationID != organizationID {
+		return nil, fmt.Errorf("user is not a member of the organization")
+	}
+	return member, nil
+}
+
+func (s *OrganizationService) checkNotLastOwner(ctx context.Context, organizationID int) error {
+	members, err := s.orgRepo.ListMembers(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	owners := 0
+	for _, member := range members {
+		if member.Role == models.OrgRoleOwner {
+			owners++
+		}
+	}
+	if owners <= 1 {
+		return fmt.Errorf("the organization must keep at least one owner")
+	}
+	return nil
+}
+
+func validateOrganizationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if len(name) > maxOrganizationNameLen {
+		return "", fmt.Errorf("name must be at most %d characters", maxOrganizationNameLen)
+	}
+	return name, nil
+}
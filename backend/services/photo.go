@@ -5,38 +5,70 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"time"
 
-	"github.com/rwcarlsen/goexif/exif"
+	"github.com/suipic/backend/geocoder"
+	"github.com/suipic/backend/metadata"
 	"github.com/suipic/backend/models"
 	"github.com/suipic/backend/repository"
 )
 
 type PhotoService struct {
-	photoRepo      repository.PhotoRepository
-	storageService *StorageService
-	esService      *ElasticsearchService
-	albumService   *AlbumService
-	commentRepo    repository.CommentRepository
+	photoRepo       repository.PhotoRepository
+	storageService  *StorageService
+	esService       *ElasticsearchService
+	albumService    *AlbumService
+	commentRepo     repository.CommentRepository
+	settingsService *SystemSettingsService
+	geocoder        *geocoder.Geocoder
 }
 
-func NewPhotoService(photoRepo repository.PhotoRepository, storageService *StorageService, esService *ElasticsearchService, albumService *AlbumService, commentRepo repository.CommentRepository) *PhotoService {
+func NewPhotoService(photoRepo repository.PhotoRepository, storageService *StorageService, esService *ElasticsearchService, albumService *AlbumService, commentRepo repository.CommentRepository, settingsService *SystemSettingsService, geocoder *geocoder.Geocoder) *PhotoService {
 	return &PhotoService{
-		photoRepo:      photoRepo,
-		storageService: storageService,
-		esService:      esService,
-		albumService:   albumService,
-		commentRepo:    commentRepo,
+		photoRepo:       photoRepo,
+		storageService:  storageService,
+		esService:       esService,
+		albumService:    albumService,
+		commentRepo:     commentRepo,
+		settingsService: settingsService,
+		geocoder:        geocoder,
 	}
 }
 
-func (s *PhotoService) CreatePhoto(ctx context.Context, albumID int, fileName string, fileReader io.Reader, fileSize int64, contentType string) (*models.Photo, error) {
+// CreatePhoto stores an uploaded photo in the album, attributed to the
+// photographer who uploaded it.
+func (s *PhotoService) CreatePhoto(ctx context.Context, albumID int, uploaderID int64, fileName string, fileReader io.Reader, fileSize int64, contentType string) (*models.Photo, error) {
 	data, err := io.ReadAll(fileReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	exifData := s.extractEXIF(bytes.NewReader(data))
+	album, err := s.albumService.GetAlbumByID(ctx, albumID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get album: %w", err)
+	}
+	if album != nil {
+		if err := s.albumService.CheckPhotoQuota(ctx, album); err != nil {
+			return nil, err
+		}
+	}
+	policy := s.GPSPolicyForAlbum(ctx, album)
+
+	meta := metadata.Extract(data)
+	exifData := models.ExifData(meta.ExifData())
+
+	// The place is derived from the coordinates, so it is only kept when
+	// they are.
+	var place *geocoder.Place
+	if policy.Retain && meta.Latitude != nil && meta.Longitude != nil {
+		place = s.geocoder.Lookup(*meta.Latitude, *meta.Longitude)
+	}
+	if !policy.Retain {
+		metadata.RemoveGPS(exifData)
+	}
+	if policy.Strip {
+		data = metadata.StripGPS(data)
+		fileSize = int64(len(data))
+	}
 
 	uploadResult, err := s.storageService.UploadPhoto(ctx, fileName, bytes.NewReader(data), fileSize, contentType)
 	if err != nil {
@@ -49,9 +81,23 @@ func (s *PhotoService) CreatePhoto(ctx context.Context, albumID int, fileName st
 		ExifData:        exifData,
 		PickRejectState: models.PickRejectNone,
 		Stars:           0,
+		UploadedBy:      &uploaderID,
 	}
 
-	if dateTime := extractDateTime(exifData); dateTime != nil {
+	if fileName != "" {
+		photo.OriginalFilename = &fileName
+	}
+
+	if meta.Title != "" {
+		photo.Title = &meta.Title
+	}
+	if meta.Caption != "" {
+		photo.Caption = &meta.Caption
+	}
+	photo.Keywords = meta.Keywords
+	setPhotoPlace(photo, place)
+
+	if dateTime := captureTime(exifData, AlbumLocation(album)); dateTime != nil {
 		photo.DateTime = dateTime
 	}
 
@@ -61,9 +107,7 @@ func (s *PhotoService) CreatePhoto(ctx context.Context, albumID int, fileName st
 	}
 
 	if s.esService != nil {
-		album, _ := s.albumService.GetAlbumByID(ctx, albumID)
-		comments, _ := s.commentRepo.GetByPhoto(ctx, photo.ID)
-		if err := s.esService.IndexPhoto(ctx, photo, album, comments); err != nil {
+		if err := s.indexPhoto(ctx, photo, album); err != nil {
 			fmt.Printf("Warning: failed to index photo in elasticsearch: %v\n", err)
 		}
 	}
@@ -75,6 +119,12 @@ func (s *PhotoService) GetPhotoByID(ctx context.Context, id int) (*models.Photo,
 	return s.photoRepo.GetByID(ctx, id)
 }
 
+// GetPhotoByFilename finds a photo by the storage file ID that the download
+// routes are addressed with.
+func (s *PhotoService) GetPhotoByFilename(ctx context.Context, filename string) (*models.Photo, error) {
+	return s.photoRepo.GetByFilename(ctx, filename)
+}
+
 func (s *PhotoService) GetPhotosByAlbum(ctx context.Context, albumID int) ([]*models.Photo, error) {
 	return s.photoRepo.GetByAlbum(ctx, albumID)
 }
@@ -86,8 +136,7 @@ func (s *PhotoService) UpdatePhoto(ctx context.Context, photo *models.Photo) err
 
 	if s.esService != nil {
 		album, _ := s.albumService.GetAlbumByID(ctx, photo.AlbumID)
-		comments, _ := s.commentRepo.GetByPhoto(ctx, photo.ID)
-		if err := s.esService.IndexPhoto(ctx, photo, album, comments); err != nil {
+		if err := s.indexPhoto(ctx, photo, album); err != nil {
 			fmt.Printf("Warning: failed to update photo in elasticsearch: %v\n", err)
 		}
 	}
@@ -121,59 +170,6 @@ func (s *PhotoService) DeletePhoto(ctx context.Context, id int) error {
 	return nil
 }
 
-func (s *PhotoService) extractEXIF(reader io.Reader) models.ExifData {
-	exifData := make(models.ExifData)
-
-	x, err := exif.Decode(reader)
-	if err != nil {
-		return exifData
-	}
-
-	exifFields := []struct {
-		name exif.FieldName
-		key  string
-	}{
-		{exif.Make, "Make"},
-		{exif.Model, "Model"},
-		{exif.LensModel, "LensModel"},
-		{exif.FocalLength, "FocalLength"},
-		{exif.FNumber, "FNumber"},
-		{exif.ExposureTime, "ExposureTime"},
-		{exif.ISOSpeedRatings, "ISO"},
-		{exif.DateTimeOriginal, "DateTimeOriginal"},
-		{exif.ImageWidth, "ImageWidth"},
-		{exif.ImageLength, "ImageHeight"},
-		{exif.Orientation, "Orientation"},
-		{exif.Software, "Software"},
-		{exif.Artist, "Artist"},
-		{exif.Copyright, "Copyright"},
-		{exif.ExposureProgram, "ExposureProgram"},
-		{exif.MeteringMode, "MeteringMode"},
-		{exif.Flash, "Flash"},
-		{exif.WhiteBalance, "WhiteBalance"},
-	}
-
-	for _, field := range exifFields {
-		if tag, err := x.Get(field.name); err == nil {
-			if str, err := tag.StringVal(); err == nil {
-				exifData[field.key] = str
-			} else if intVal, err := tag.Int(0); err == nil {
-				exifData[field.key] = intVal
-			} else if ratVal, err := tag.Rat(0); err == nil {
-				floatVal, _ := ratVal.Float64()
-				exifData[field.key] = floatVal
-			}
-		}
-	}
-
-	if lat, lon, err := x.LatLong(); err == nil {
-		exifData["Latitude"] = lat
-		exifData["Longitude"] = lon
-	}
-
-	return exifData
-}
-
 func (s *PhotoService) BulkIndexPhotosByAlbum(ctx context.Context, albumID int) error {
 	if s.esService == nil {
 		return fmt.Errorf("elasticsearch service not available")
@@ -193,6 +189,12 @@ func (s *PhotoService) BulkIndexPhotosByAlbum(ctx context.Context, albumID int)
 		return fmt.Errorf("failed to get album: %w", err)
 	}
 
+	if !s.GPSPolicyForAlbum(ctx, album).Index {
+		for i, photo := range photos {
+			photos[i] = withoutGPS(photo)
+		}
+	}
+
 	albums := map[int]*models.Album{
 		albumID: album,
 	}
@@ -209,21 +211,3 @@ func (s *PhotoService) BulkIndexPhotosByAlbum(ctx context.Context, albumID int)
 
 	return nil
 }
-
-func extractDateTime(exifData models.ExifData) *time.Time {
-	if dateStr, ok := exifData["DateTimeOriginal"].(string); ok {
-		formats := []string{
-			"2006:01:02 15:04:05",
-			time.RFC3339,
-			"2006-01-02T15:04:05",
-			"2006-01-02 15:04:05",
-		}
-
-		for _, format := range formats {
-			if t, err := time.Parse(format, dateStr); err == nil {
-				return &t
-			}
-		}
-	}
-	return nil
-}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/suipic/backend/models"
+)
+
+const (
+	// MaxMapPhotos caps how many photos a single map response is built from.
+	MaxMapPhotos = 5000
+
+	MaxMapZoom = 24
+
+	// Photos closer than this many screen pixels at the requested zoom are
+	// merged into one cluster.
+	mapClusterRadius = 60.0
+	mapTileSize      = 256.0
+	mapClusterIDs    = 20
+	mercatorMaxLat   = 85.05112878
+)
+
+// BoundingBox is a map viewport in degrees. West may be greater than East
+// when the box crosses the antimeridian.
+type BoundingBox struct {
+	West  float64
+	South float64
+	East  float64
+	North float64
+}
+
+// ParseBoundingBox reads a "west,south,east,north" box, the order GeoJSON
+// and most map libraries use.
+func ParseBoundingBox(value string) (*BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox must be west,south,east,north")
+	}
+
+	var coords [4]float64
+	for i, part := range parts {
+		coord, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bbox must be west,south,east,north")
+		}
+		coords[i] = coord
+	}
+
+	box := &BoundingBox{West: coords[0], South: coords[1], East: coords[2], North: coords[3]}
+	if box.South > box.North || box.South < -90 || box.North > 90 {
+		return nil, fmt.Errorf("bbox latitudes must be within -90..90 with south <= north")
+	}
+	if box.West < -180 || box.West > 180 || box.East < -180 || box.East > 180 {
+		return nil, fmt.Errorf("bbox longitudes must be within -180..180")
+	}
+	return box, nil
+}
+
+func (b *BoundingBox) Contains(lat, lon float64) bool {
+	if lat < b.South || lat > b.North {
+		return false
+	}
+	if b.West <= b.East {
+		return lon >= b.West && lon <= b.East
+	}
+	return lon >= b.West || lon <= b.East
+}
+
+// GeoDistance limits results to photos within RadiusKm of a point.
+type GeoDistance struct {
+	Lat      float64
+	Lon      float64
+	RadiusKm float64
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type mapCluster struct {
+	photos                   []*models.Photo
+	latSum, lonSum           float64
+	west, south, east, north float64
+}
+
+// BuildPhotoMap turns photos into a GeoJSON FeatureCollection. Photos without
+// coordinates, or outside bbox when one is given, are left out. With a zoom
+// level, photos that would overlap on screen are merged into cluster
+// features; without one every photo is its own feature.
+func BuildPhotoMap(photos []*models.Photo, zoom *int, bbox *BoundingBox) *GeoJSONFeatureCollection {
+	collection := &GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []GeoJSONFeature{},
+	}
+
+	var order []*mapCluster
+	cells := make(map[[2]int]*mapCluster)
+	for i, photo := range photos {
+		lat, lon, ok := photoCoordinates(photo)
+		if !ok || (bbox != nil && !bbox.Contains(lat, lon)) {
+			continue
+		}
+
+		key := [2]int{i, -1}
+		if zoom != nil {
+			key = clusterCell(lat, lon, *zoom)
+		}
+
+		cluster, ok := cells[key]
+		if !ok {
+			cluster = &mapCluster{west: lon, south: lat, east: lon, north: lat}
+			cells[key] = cluster
+			order = append(order, cluster)
+		}
+		cluster.photos = append(cluster.photos, photo)
+		cluster.latSum += lat
+		cluster.lonSum += lon
+		cluster.west = math.Min(cluster.west, lon)
+		cluster.south = math.Min(cluster.south, lat)
+		cluster.east = math.Max(cluster.east, lon)
+		cluster.north = math.Max(cluster.north, lat)
+	}
+
+	for _, cluster := range order {
+		collection.Features = append(collection.Features, cluster.feature())
+	}
+	return collection
+}
+
+func (c *mapCluster) feature() GeoJSONFeature {
+	count := float64(len(c.photos))
+	feature := GeoJSONFeature{
+		Type: "Feature",
+		Geometry: GeoJSONPoint{
+			Type:        "Point",
+			Coordinates: [2]float64{c.lonSum / count, c.latSum / count},
+		},
+	}
+
+	if len(c.photos) == 1 {
+		photo := c.photos[0]
+		properties := map[string]interface{}{
+			"cluster": false,
+			"photoId": photo.ID,
+			"albumId": photo.AlbumID,
+			"stars":   photo.Stars,
+		}
+		if photo.Title != nil {
+			properties["title"] = *photo.Title
+		}
+		if photo.DateTime != nil {
+			properties["dateTime"] = photo.DateTime
+		}
+		if photo.City != nil {
+			properties["city"] = *photo.City
+		}
+		feature.Properties = properties
+		return feature
+	}
+
+	preview := c.photos[:min(len(c.photos), mapClusterIDs)]
+	ids := make([]int, 0, len(preview))
+	for _, photo := range preview {
+		ids = append(ids, photo.ID)
+	}
+	feature.Properties = map[string]interface{}{
+		"cluster":    true,
+		"pointCount": len(c.photos),
+		"photoIds":   ids,
+		"bbox":       []float64{c.west, c.south, c.east, c.north},
+	}
+	return feature
+}
+
+// clusterCell returns the grid cell a point falls in at the given zoom, with
+// cells mapClusterRadius pixels wide in Web Mercator screen space.
+func clusterCell(lat, lon float64, zoom int) [2]int {
+	lat = math.Max(-mercatorMaxLat, math.Min(mercatorMaxLat, lat))
+	sin := math.Sin(lat * math.Pi / 180)
+	x := (lon + 180) / 360
+	y := 0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)
+
+	scale := math.Exp2(float64(zoom)) * mapTileSize / mapClusterRadius
+	return [2]int{int(math.Floor(x * scale)), int(math.Floor(y * scale))}
+}
+
+// photoCoordinates reads the position stored in a photo's EXIF data. Values
+// come back as float64 whether they were loaded from Postgres or the index.
+func photoCoordinates(photo *models.Photo) (float64, float64, bool) {
+	lat, latOK := photo.ExifData["Latitude"].(float64)
+	lon, lonOK := photo.ExifData["Longitude"].(float64)
+	if !latOK || !lonOK || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
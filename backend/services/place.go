@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+
+	"github.com/suipic/backend/geocoder"
+	"github.com/suipic/backend/models"
+)
+
+func setPhotoPlace(photo *models.Photo, place *geocoder.Place) {
+	if place == nil {
+		return
+	}
+	photo.CountryCode = optionalString(place.CountryCode)
+	photo.Country = optionalString(place.Country)
+	photo.Region = optionalString(place.Region)
+	photo.City = optionalString(place.City)
+}
+
+// clearPhotoPlace drops the place and reports whether there was one.
+func clearPhotoPlace(photo *models.Photo) bool {
+	if photo.CountryCode == nil && photo.Country == nil && photo.Region == nil && photo.City == nil {
+		return false
+	}
+	photo.CountryCode = nil
+	photo.Country = nil
+	photo.Region = nil
+	photo.City = nil
+	return true
+}
+
+func photoPlace(photo *models.Photo) *geocoder.Place {
+	if photo.CountryCode == nil && photo.City == nil {
+		return nil
+	}
+	return &geocoder.Place{
+		CountryCode: derefString(photo.CountryCode),
+		Country:     derefString(photo.Country),
+		Region:      derefString(photo.Region),
+		City:        derefString(photo.City),
+	}
+}
+
+// SuggestAlbumLocation proposes a location for an album from the places its
+// photos were taken. It names the most common city when at least half the
+// geotagged photos share it, otherwise falls back to the most common region
+// and then country. It returns "" when no photo has a place.
+func (s *PhotoService) SuggestAlbumLocation(ctx context.Context, albumID int) (string, error) {
+	photos, err := s.photoRepo.GetByAlbum(ctx, albumID)
+	if err != nil {
+		return "", err
+	}
+
+	var places []*geocoder.Place
+	for _, photo := range photos {
+		if place := photoPlace(photo); place != nil {
+			places = append(places, place)
+		}
+	}
+	if len(places) == 0 {
+		return "", nil
+	}
+
+	levels := []func(*geocoder.Place) geocoder.Place{
+		func(p *geocoder.Place) geocoder.Place { return *p },
+		func(p *geocoder.Place) geocoder.Place {
+			return geocoder.Place{CountryCode: p.CountryCode, Country: p.Country, Region: p.Region}
+		},
+		func(p *geocoder.Place) geocoder.Place {
+			return geocoder.Place{CountryCode: p.CountryCode, Country: p.Country}
+		},
+	}
+
+	var label string
+	for _, level := range levels {
+		var count int
+		label, count = mostCommonLabel(places, level)
+		if count*2 >= len(places) {
+			break
+		}
+	}
+	return label, nil
+}
+
+// mostCommonLabel returns the most frequent label at the given granularity,
+// preferring the one seen first on a tie.
+func mostCommonLabel(places []*geocoder.Place, level func(*geocoder.Place) geocoder.Place) (string, int) {
+	counts := make(map[string]int)
+	var best string
+	for _, place := range places {
+		reduced := level(place)
+		label := reduced.Label()
+		if label == "" {
+			continue
+		}
+		counts[label]++
+		if counts[label] > counts[best] {
+			best = label
+		}
+	}
+	return best, counts[best]
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/suipic/backend/mailer"
+	"github.com/suipic/backend/models"
+)
+
+const (
+	minPasswordLength  = 8
+	emailChangeExpiry  = 24 * time.Hour
+	maxFriendlyNameLen = 255
+
+	SessionRevokedPasswordChange = "password_changed"
+)
+
+var ErrPasswordIncorrect = errors.New("password is incorrect")
+
+func (s *AuthService) GetProfile(ctx context.Context, userID int64) (*models.User, error) {
+	return s.userRepo.GetByID(ctx, int(userID))
+}
+
+func (s *AuthService) UpdateUser(ctx context.Context, user *models.User) error {
+	return s.userRepo.Update(ctx, user)
+}
+
+func (s *AuthService) UpdateFriendlyName(ctx context.Context, user *models.User, friendlyName string) error {
+	friendlyName = strings.TrimSpace(friendlyName)
+	if len(friendlyName) > maxFriendlyNameLen {
+		return fmt.Errorf("friendly name must be at most %d characters", maxFriendlyNameLen)
+	}
+	user.FriendlyName = friendlyName
+	return s.userRepo.Update(ctx, user)
+}
+
+// ChangePassword sets a new password after checking the current one. Every
+// other session is signed out and every API token revoked, so a password
+// change also locks out anyone who had the old one.
+func (s *AuthService) ChangePassword(ctx context.Context, user *models.User, currentSessionID, currentPassword, newPassword string, client SessionClient) error {
+	if err := s.checkPasswordThrottled(ctx, user, currentPassword, client); err != nil {
+		return err
+	}
+	if len(newPassword) < minPasswordLength {
+		return fmt.Errorf("new password must be at least %d characters", minPasswordLength)
+	}
+
+	hashedPassword, err := s.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.PasswordHash = hashedPassword
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if _, err := s.sessionRepo.RevokeAllForUser(ctx, user.ID, currentSessionID, SessionRevokedPasswordChange); err != nil {
+		fmt.Printf("Warning: failed to revoke sessions of user %d after password change: %v\n", user.ID, err)
+	}
+	if _, err := s.apiTokenRepo.RevokeAllForUser(ctx, user.ID); err != nil {
+		fmt.Printf("Warning: failed to revoke api tokens of user %d: %v\n", user.ID, err)
+	}
+	return nil
+}
+
+// RequestEmailChange starts moving the account to a new address. The change
+// only takes effect once the link sent to the new address is followed.
+func (s *AuthService) RequestEmailChange(ctx context.Context, user *models.User, password, newEmail string, client SessionClient) error {
+	if err := s.checkPasswordThrottled(ctx, user, password, client); err != nil {
+		return err
+	}
+
+	newEmail = strings.TrimSpace(newEmail)
+	address, err := mail.ParseAddress(newEmail)
+	if err != nil || address.Address != newEmail {
+		return fmt.Errorf("invalid email address")
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return fmt.Errorf("new email is the same as the current one")
+	}
+
+	existingUser, err := s.dbService.GetUserByEmail(newEmail)
+	if err != nil {
+		return err
+	}
+	if existingUser != nil {
+		return fmt.Errorf("user with email already exists")
+	}
+
+	token, err := s.issueUserToken(ctx, user.ID, models.TokenPurposeEmailChange, &newEmail, emailChangeExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.sendAccountEmail(user, newEmail, mailer.TemplateEmailChange, "Confirm new address", "/verify-email", token, emailChangeExpiry)
+}
+
+// ConfirmEmailChange applies a pending email change from its confirmation
+// token.
+func (s *AuthService) ConfirmEmailChange(ctx context.Context, token string) (*models.User, error) {
+	userToken, err := s.lookupUserToken(ctx, token, models.TokenPurposeEmailChange)
+	if err != nil {
+		return nil, err
+	}
+	return s.applyEmailChange(ctx, userToken)
+}
+
+func (s *AuthService) applyEmailChange(ctx context.Context, userToken *models.UserToken) (*models.User, error) {
+	if userToken.Payload == nil {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, int(userToken.UserID))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	existingUser, err := s.dbService.GetUserByEmail(*userToken.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if existingUser != nil {
+		return nil, fmt.Errorf("user with email already exists")
+	}
+
+	if err := s.consumeUserToken(ctx, userToken); err != nil {
+		return nil, err
+	}
+
+	// Following the link proves the user reads the new address.
+	now := time.Now()
+	user.Email = *userToken.Payload
+	user.EmailVerifiedAt = &now
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
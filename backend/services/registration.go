@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/suipic/backend/models"
+)
+
+// Registration modes decide who may create an account through
+// /auth/register. Admins can create accounts of any role in every mode.
+const (
+	RegistrationClosed  = "closed"
+	RegistrationClients = "clients"
+	RegistrationInvite  = "invite"
+	RegistrationOpen    = "open"
+)
+
+var (
+	ErrRegistrationClosed     = errors.New("registration is closed")
+	ErrRegistrationInviteOnly = errors.New("registration is by invitation only")
+	ErrRegistrationRole       = errors.New("only client accounts can be registered")
+	ErrRegistrationDomain     = errors.New("registration is not open to this email domain")
+)
+
+// RegistrationPolicy is the self-registration configuration from the system
+// settings.
+type RegistrationPolicy struct {
+	Mode           string   `json:"mode"`
+	AllowedDomains []string `json:"allowedDomains"`
+}
+
+// RegistrationPolicy loads the policy. An unknown or missing mode counts as
+// clients only, so a typo never opens up photographer sign-ups.
+func (s *AuthService) RegistrationPolicy(ctx context.Context) RegistrationPolicy {
+	policy := RegistrationPolicy{Mode: RegistrationClients, AllowedDomains: []string{}}
+
+	if mode, err := s.settings.GetSetting(ctx, SettingRegistrationMode); err == nil {
+		switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+		case RegistrationClosed, RegistrationClients, RegistrationInvite, RegistrationOpen:
+			policy.Mode = mode
+		}
+	}
+
+	if domains, err := s.settings.GetSetting(ctx, SettingRegistrationAllowedDomains); err == nil {
+		for _, domain := range strings.Split(domains, ",") {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+				policy.AllowedDomains = append(policy.AllowedDomains, strings.TrimPrefix(domain, "@"))
+			}
+		}
+	}
+
+	return policy
+}
+
+// Check reports whether someone may register with email for role.
+func (p RegistrationPolicy) Check(email string, role models.UserRole) error {
+	switch p.Mode {
+	case RegistrationClosed:
+		return ErrRegistrationClosed
+	case RegistrationInvite:
+		return ErrRegistrationInviteOnly
+	case RegistrationClients:
+		if role != models.RoleClient {
+			return ErrRegistrationRole
+		}
+	}
+
+	if !p.AllowsDomain(email) {
+		return ErrRegistrationDomain
+	}
+	return nil
+}
+
+// AllowsDomain reports whether email is at one of the allowed domains. An
+// empty allowlist allows every domain.
+func (p RegistrationPolicy) AllowsDomain(email string) bool {
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range p.AllowedDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
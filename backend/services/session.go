@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/suipic/backend/models"
+)
+
+const (
+	defaultAccessExpiry  = 15 * time.Minute
+	defaultRefreshExpiry = 30 * 24 * time.Hour
+
+	// Requests only move a session's last-seen time forward when it is older
+	// than this, so browsing does not write to the database on every call.
+	sessionTouchInterval = time.Minute
+
+	// A refresh token presented again within this long of its first use
+	// gets the same successor instead of revoking the session.
+	refreshReuseGrace     = 30 * time.Second
+	refreshSuccessorLabel = "suipic refresh token successor"
+
+	SessionRevokedLogout = "logout"
+	SessionRevokedReuse  = "refresh_token_reuse"
+	SessionRevokedByUser = "revoked_by_user"
+	SessionRevokedAdmin  = "revoked_by_admin"
+)
+
+// AccessTokenAudience is the aud claim of access tokens. Services verifying
+// them through the JWKS document should require it.
+const AccessTokenAudience = "suipic-api"
+
+// SessionClient describes where a request came from.
+type SessionClient struct {
+	IPAddress string
+	UserAgent string
+	// ViaProxy is set when IPAddress belongs to a trusted proxy that did
+	// not say whom it was forwarding for.
+	ViaProxy bool
+}
+
+// throttleIP is the address failed logins are counted against. A proxy's own
+// address is not used, as that would lock out everyone behind it.
+func (c SessionClient) throttleIP() string {
+	if c.ViaProxy {
+		return ""
+	}
+	return c.IPAddress
+}
+
+// TokenPair is what a client gets on login or refresh: a short-lived access
+// token for API calls and an opaque refresh token to get the next pair.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+// CreateSession starts a new session for the user and issues its first token
+// pair.
+func (s *AuthService) CreateSession(ctx context.Context, user *models.User, client SessionClient) (*TokenPair, error) {
+	session := &models.Session{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.refreshExpiry()),
+	}
+	client.apply(session)
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokenPair(ctx, user, session)
+}
+
+// RefreshSession exchanges a refresh token for a new token pair. Each refresh
+// token works once; presenting one that was already used means it was
+// copied, so the whole session is revoked and both parties have to sign in
+// again. The exception is a token presented again within refreshReuseGrace
+// while its successor is unused, which is what a client refreshing from two
+// tabs at once looks like: that request gets the same successor.
+func (s *AuthService) RefreshSession(ctx context.Context, refreshToken string, client SessionClient) (*models.User, *TokenPair, error) {
+	token, err := s.sessionRepo.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	if token == nil {
+		return nil, nil, fmt.Errorf("invalid refresh token")
+	}
+
+	successor, err := s.successorRefreshToken(refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	consumed, err := s.sessionRepo.MarkRefreshTokenUsed(ctx, token.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !consumed {
+		consumed, err = s.refreshRaceLost(ctx, token, successor)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if !consumed {
+		fmt.Printf("Warning: refresh token reused, revoking session %s\n", token.SessionID)
+		if err := s.sessionRepo.Revoke(ctx, token.SessionID, SessionRevokedReuse); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("invalid refresh token")
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, token.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil || !session.Active(time.Now()) {
+		return nil, nil, fmt.Errorf("session expired")
+	}
+
+	user, err := s.dbService.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, fmt.Errorf("user not found")
+	}
+	if user.Suspended() {
+		return nil, nil, ErrAccountSuspended
+	}
+
+	client.apply(session)
+	if err := s.sessionRepo.Touch(ctx, session); err != nil {
+		return nil, nil, err
+	}
+
+	pair, err := s.issueTokens(ctx, user, session, successor)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, pair, nil
+}
+
+// refreshRaceLost reports whether a refresh token that turned out to be used
+// already was used moments ago, and its successor has not been used since.
+// token is as read before it was marked used, so UsedAt is nil when another
+// request marked it in the meantime.
+func (s *AuthService) refreshRaceLost(ctx context.Context, token *models.RefreshToken, successor string) (bool, error) {
+	if token.UsedAt != nil && time.Since(*token.UsedAt) > refreshReuseGrace {
+		return false, nil
+	}
+
+	next, err := s.sessionRepo.GetRefreshTokenByHash(ctx, hashToken(successor))
+	if err != nil {
+		return false, err
+	}
+	return next == nil || (next.SessionID == token.SessionID && next.UsedAt == nil), nil
+}
+
+// successorRefreshToken derives the refresh token that replaces refreshToken.
+// It is derived rather than random so that racing refreshes of the same token
+// hand out the same successor, whichever instance serves them.
+func (s *AuthService) successorRefreshToken(refreshToken string) (string, error) {
+	key, err := s.keys.DeriveKey(refreshSuccessorLabel)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(refreshToken))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// RevokeSession ends a session so neither its access tokens nor its refresh
+// token are accepted any more.
+func (s *AuthService) RevokeSession(ctx context.Context, sessionID, reason string) error {
+	return s.sessionRepo.Revoke(ctx, sessionID, reason)
+}
+
+// ValidateSession checks that the session an access token belongs to has not
+// been revoked or expired.
+func (s *AuthService) ValidateSession(ctx context.Context, claims *JWTClaims, client SessionClient) error {
+	if claims.SessionID == "" {
+		return fmt.Errorf("token has no session")
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, claims.SessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != claims.UserID || !session.Active(time.Now()) {
+		return fmt.Errorf("session is no longer valid")
+	}
+
+	if time.Since(session.LastUsedAt) > sessionTouchInterval {
+		client.apply(session)
+		if err := s.sessionRepo.Touch(ctx, session); err != nil {
+			fmt.Printf("Warning: failed to update last use of session %s: %v\n", session.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetSession returns a session by ID, or nil when there is none.
+func (s *AuthService) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return nil, nil
+	}
+	return s.sessionRepo.GetByID(ctx, sessionID)
+}
+
+// ListSessions returns the user's open sessions, most recently used first,
+// with the one identified by currentID marked as current.
+func (s *AuthService) ListSessions(ctx context.Context, userID int64, currentID string) ([]*models.Session, error) {
+	sessions, err := s.sessionRepo.ListActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeAllSessions signs the user out everywhere except exceptID, which may
+// be empty to include every session. It returns how many were revoked.
+func (s *AuthService) RevokeAllSessions(ctx context.Context, userID int64, exceptID, reason string) (int64, error) {
+	return s.sessionRepo.RevokeAllForUser(ctx, userID, exceptID, reason)
+}
+
+func (s *AuthService) issueTokenPair(ctx context.Context, user *models.User, session *models.Session) (*TokenPair, error) {
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return s.issueTokens(ctx, user, session, refreshToken)
+}
+
+// issueTokens stores refreshToken for the session and pairs it with a new
+// access token.
+func (s *AuthService) issueTokens(ctx context.Context, user *models.User, session *models.Session, refreshToken string) (*TokenPair, error) {
+	err := s.sessionRepo.CreateRefreshToken(ctx, &models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(refreshToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := s.accessExpiry()
+	accessToken, err := s.generateAccessToken(user, session.ID, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(expiry / time.Second),
+	}, nil
+}
+
+func (s *AuthService) generateAccessToken(user *models.User, sessionID string, expiry time.Duration) (string, error) {
+	now := time.Now()
+	claims := JWTClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Audience:  jwt.ClaimStrings{AccessTokenAudience},
+			Issuer:    s.publicURL,
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return s.keys.Sign(claims)
+}
+
+func (s *AuthService) accessExpiry() time.Duration {
+	expiry, err := time.ParseDuration(s.jwtExpiry)
+	if err != nil || expiry <= 0 {
+		return defaultAccessExpiry
+	}
+	return expiry
+}
+
+func (s *AuthService) refreshExpiry() time.Duration {
+	expiry, err := time.ParseDuration(s.jwtRefreshExpiry)
+	if err != nil || expiry <= 0 {
+		return defaultRefreshExpiry
+	}
+	return expiry
+}
+
+func (c SessionClient) apply(session *models.Session) {
+	if c.IPAddress != "" {
+		session.IPAddress = &c.IPAddress
+	}
+	if c.UserAgent != "" {
+		userAgent := truncate(c.UserAgent, 512)
+		device := describeDevice(userAgent)
+		session.UserAgent = &userAgent
+		session.Device = &device
+	}
+}
+
+var (
+	userAgentBrowsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"FxiOS/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"node", "Node.js"},
+	}
+	userAgentPlatforms = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Macintosh", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// describeDevice turns a user agent into a short label such as "Firefox on
+// Windows" for the session list. Unknown agents are shown as "Unknown device".
+func describeDevice(userAgent string) string {
+	browser := ""
+	for _, candidate := range userAgentBrowsers {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+	platform := ""
+	for _, candidate := range userAgentPlatforms {
+		if strings.Contains(userAgent, candidate.token) {
+			platform = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
+
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	return value[:max]
+}
+
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/repository"
+)
+
+const (
+	shareGuestAudience    = "share"
+	shareGuestTokenExpiry = 12 * time.Hour
+	shareTokenPrefixLen   = 8
+	maxShareLinkNameLen   = 100
+)
+
+var (
+	ErrShareLinkInvalid   = errors.New("invalid or expired share link")
+	ErrSharePasswordWrong = errors.New("incorrect password")
+)
+
+// ShareClaims are carried by the guest tokens handed out for share links.
+// They only grant what the link allows, and only while the link is active.
+type ShareClaims struct {
+	ShareLinkID int `json:"share_link_id"`
+	AlbumID     int `json:"album_id"`
+	jwt.RegisteredClaims
+}
+
+// ShareLinkOptions are what a photographer picks when creating a link.
+type ShareLinkOptions struct {
+	Name          string
+	PhotoIDs      []int64
+	Password      string
+	Mode          models.ShareMode
+	AllowDownload bool
+	ExpiresAt     *time.Time
+}
+
+// CreatedShareLink is returned once on creation; the token cannot be
+// recovered afterwards.
+type CreatedShareLink struct {
+	*models.ShareLink
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// GuestSession is what a visitor gets after opening a share link.
+type GuestSession struct {
+	Token         string           `json:"token"`
+	ExpiresAt     time.Time        `json:"expiresAt"`
+	AlbumID       int              `json:"albumId"`
+	Title         string           `json:"title"`
+	Description   *string          `json:"description,omitempty"`
+	Mode          models.ShareMode `json:"mode"`
+	AllowDownload bool             `json:"allowDownload"`
+}
+
+// ShareService manages public links that let people without an account view
+// an album, or a selection of its photos.
+type ShareService struct {
+	authService  *AuthService
+	albumService *AlbumService
+	photoService *PhotoService
+	linkRepo     repository.ShareLinkRepository
+}
+
+func NewShareService(authService *AuthService, albumService *AlbumService, photoService *PhotoService, dbService *DatabaseService) *ShareService {
+	return &ShareService{
+		authService:  authService,
+		albumService: albumService,
+		photoService: photoService,
+		linkRepo:     dbService.GetShareLinkRepo(),
+	}
+}
+
+// CreateShareLink creates a link for the album. Leaving PhotoIDs empty
+// shares the whole album, including photos uploaded later.
+func (s *ShareService) CreateShareLink(ctx context.Context, creatorID int64, albumID int, opts ShareLinkOptions) (*CreatedShareLink, error) {
+	opts.Name = strings.TrimSpace(opts.Name)
+	if len(opts.Name) > maxShareLinkNameLen {
+		return nil, fmt.Errorf("name must be at most %d characters", maxShareLinkNameLen)
+	}
+	if opts.Mode == "" {
+		opts.Mode = models.ShareModeView
+	}
+	if opts.Mode != models.ShareModeView && opts.Mode != models.ShareModePick {
+		return nil, fmt.Errorf("mode must be %q or %q", models.ShareModeView, models.ShareModePick)
+	}
+	if opts.ExpiresAt != nil && !opts.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+
+	photoIDs, err := s.checkPhotos(ctx, albumID, opts.PhotoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	link := &models.ShareLink{
+		AlbumID:       albumID,
+		CreatedBy:     creatorID,
+		Name:          opts.Name,
+		TokenHash:     hashToken(token),
+		TokenPrefix:   token[:shareTokenPrefixLen],
+		PhotoIDs:      photoIDs,
+		Mode:          opts.Mode,
+		AllowDownload: opts.AllowDownload,
+		ExpiresAt:     opts.ExpiresAt,
+	}
+	if opts.Password != "" {
+		hash, err := s.authService.HashPassword(opts.Password)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = &hash
+	}
+
+	if err := s.linkRepo.Create(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return &CreatedShareLink{
+		ShareLink: link,
+		Token:     token,
+		URL:       s.authService.publicURL + "/share?token=" + url.QueryEscape(token),
+	}, nil
+}
+
+// checkPhotos drops duplicates and makes sure every photo belongs to the
+// album. It returns nil for an empty selection.
+func (s *ShareService) checkPhotos(ctx context.Context, albumID int, photoIDs []int64) ([]int64, error) {
+	if len(photoIDs) == 0 {
+		return nil, nil
+	}
+
+	checked := make([]int64, 0, len(photoIDs))
+	for _, photoID := range photoIDs {
+		if slices.Contains(checked, photoID) {
+			continue
+		}
+		photo, err := s.photoService.GetPhotoByID(ctx, int(photoID))
+		if err != nil {
+			return nil, err
+		}
+		if photo == nil || photo.AlbumID != albumID {
+			return nil, fmt.Errorf("photo %d is not in this album", photoID)
+		}
+		checked = append(checked, photoID)
+	}
+	return checked, nil
+}
+
+func (s *ShareService) ListShareLinks(ctx context.Context, albumID int) ([]*models.ShareLink, error) {
+	links, err := s.linkRepo.ListByAlbum(ctx, albumID)
+	if err != nil {
+		return nil, err
+	}
+	if links == nil {
+		links = []*models.ShareLink{}
+	}
+	return links, nil
+}
+
+// GetShareLink returns one of the album's links, or nil when the album has
+// no such link.
+func (s *ShareService) GetShareLink(ctx context.Context, albumID, linkID int) (*models.ShareLink, error) {
+	link, err := s.linkRepo.GetByID(ctx, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil || link.AlbumID != albumID {
+		return nil, nil
+	}
+	return link, nil
+}
+
+// RevokeShareLink makes the link, and every guest token issued for it, stop
+// working. It reports false when the album has no such active link.
+func (s *ShareService) RevokeShareLink(ctx context.Context, albumID, linkID int) (bool, error) {
+	return s.linkRepo.Revoke(ctx, albumID, linkID)
+}
+
+// ListAccesses returns the access log of one of the album's links, or nil
+// when the album has no such link.
+func (s *ShareService) ListAccesses(ctx context.Context, albumID, linkID, limit int) ([]*models.ShareLinkAccess, error) {
+	link, err := s.GetShareLink(ctx, albumID, linkID)
+	if err != nil || link == nil {
+		return nil, err
+	}
+
+	accesses, err := s.linkRepo.ListAccesses(ctx, linkID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if accesses == nil {
+		accesses = []*models.ShareLinkAccess{}
+	}
+	return accesses, nil
+}
+
+// OpenShareLink checks the link's password, if it has one, and issues a guest
+// token for it. Wrong passwords count against the visitor's IP address like
+// failed logins do.
+func (s *ShareService) OpenShareLink(ctx context.Context, token, password string, client SessionClient) (*GuestSession, error) {
+	link, err := s.linkRepo.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if link == nil || !link.Active(time.Now()) {
+		return nil, ErrShareLinkInvalid
+	}
+	if err := s.checkCreator(ctx, link); err != nil {
+		return nil, err
+	}
+
+	if link.PasswordHash != nil {
+		policy := s.authService.loginPolicy(ctx)
+		if err := s.authService.checkLoginThrottle(ctx, policy, models.ThrottleKindIP, client.throttleIP()); err != nil {
+			return nil, err
+		}
+		if s.authService.CheckPassword(*link.PasswordHash, password) != nil {
+			s.authService.recordLoginFailure(ctx, policy, models.ThrottleKindIP, client.throttleIP())
+			s.RecordAccess(ctx, link, models.ShareAccessWrongPassword, nil, client)
+			return nil, ErrSharePasswordWrong
+		}
+	}
+
+	album, err := s.albumService.GetAlbumByID(ctx, link.AlbumID)
+	if err != nil {
+		return nil, err
+	}
+	if album == nil {
+		return nil, ErrShareLinkInvalid
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(shareGuestTokenExpiry)
+	if link.ExpiresAt != nil && link.ExpiresAt.Before(expiresAt) {
+		expiresAt = *link.ExpiresAt
+	}
+	guestToken, err := s.authService.keys.SignInternal(ShareClaims{
+		ShareLinkID: link.ID,
+		AlbumID:     link.AlbumID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{shareGuestAudience},
+			Issuer:    s.authService.publicURL,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.RecordAccess(ctx, link, models.ShareAccessOpen, nil, client)
+
+	return &GuestSession{
+		Token:         guestToken,
+		ExpiresAt:     expiresAt,
+		AlbumID:       album.ID,
+		Title:         album.Title,
+		Description:   album.Description,
+		Mode:          link.Mode,
+		AllowDownload: link.AllowDownload,
+	}, nil
+}
+
+// ValidateGuestToken returns the share link a guest token was issued for.
+// The link is reloaded on every request so that revoking it, or taking
+// permissions away from its creator, takes effect immediately.
+func (s *ShareService) ValidateGuestToken(ctx context.Context, tokenString string) (*models.ShareLink, error) {
+	claims := &ShareClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, s.authService.keys.InternalKeyfunc, jwt.WithAudience(shareGuestAudience))
+	if err != nil {
+		return nil, ErrShareLinkInvalid
+	}
+
+	link, err := s.linkRepo.GetByID(ctx, claims.ShareLinkID)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil || link.AlbumID != claims.AlbumID || !link.Active(time.Now()) {
+		return nil, ErrShareLinkInvalid
+	}
+	if err := s.checkCreator(ctx, link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// checkCreator fails with ErrShareLinkInvalid unless the link's creator may
+// still grant everything the link does. Assigned users lose their links this
+// way when they are removed from the album or their permissions shrink.
+func (s *ShareService) checkCreator(ctx context.Context, link *models.ShareLink) error {
+	creator, err := s.authService.userRepo.GetByID(ctx, int(link.CreatedBy))
+	if err != nil {
+		return err
+	}
+	if creator == nil {
+		return ErrShareLinkInvalid
+	}
+
+	permissions := []models.AlbumPermission{models.AlbumPermissionShare}
+	if link.Mode == models.ShareModePick {
+		permissions = append(permissions, models.AlbumPermissionRate)
+	}
+	if link.AllowDownload {
+		permissions = append(permissions, models.AlbumPermissionDownload)
+	}
+	for _, permission := range permissions {
+		allowed, err := s.albumService.HasAlbumPermission(ctx, int(creator.ID), creator.Role, link.AlbumID, permission)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrShareLinkInvalid
+		}
+	}
+	return nil
+}
+
+// RecordAccess adds an entry to the link's access log. Failures are only
+// logged so they never block the guest.
+func (s *ShareService) RecordAccess(ctx context.Context, link *models.ShareLink, action string, photoID *int, client SessionClient) {
+	access := &models.ShareLinkAccess{
+		ShareLinkID: link.ID,
+		Action:      action,
+		PhotoID:     photoID,
+	}
+	if client.IPAddress != "" {
+		access.IPAddress = &client.IPAddress
+	}
+	if client.UserAgent != "" {
+		userAgent := truncate(client.UserAgent, 512)
+		access.UserAgent = &userAgent
+	}
+	if err := s.linkRepo.RecordAccess(ctx, access); err != nil {
+		fmt.Printf("Warning: failed to record access to share link %d: %v\n", link.ID, err)
+	}
+}
@@ -38,10 +38,18 @@ type UploadResult struct {
 }
 
 const (
-	thumbnailWidth  = 300
-	thumbnailHeight = 300
-	thumbnailPrefix = "thumbnails/"
-	photosPrefix    = "photos/"
+	thumbnailWidth   = 300
+	thumbnailHeight  = 300
+	thumbnailPrefix  = "thumbnails/"
+	photosPrefix     = "photos/"
+	derivativePrefix = "derivatives/"
+	avatarPrefix     = "avatars/"
+	avatarSize       = 256
+
+	// MaxAvatarFileSize and maxAvatarPixels bound what an avatar upload may
+	// make the server read and decode.
+	MaxAvatarFileSize = 4 << 20
+	maxAvatarPixels   = 40_000_000
 )
 
 func NewStorageService(cfg *config.MinIOConfig) (*StorageService, error) {
@@ -79,6 +87,9 @@ func (s *StorageService) InitializeBucket(ctx context.Context) error {
 		}
 	}
 
+	// Photos and thumbnails are served straight from the bucket, so anyone
+	// with an object's name can read it without going through the API and
+	// its album permissions. Object names are random UUIDs.
 	policy := fmt.Sprintf(`{
 		"Version": "2012-10-17",
 		"Statement": [
@@ -340,9 +351,206 @@ func (s *StorageService) DeletePhoto(ctx context.Context, fileID string) error {
 		s.client.RemoveObject(ctx, s.bucketName, obj.Key, minio.RemoveObjectOptions{})
 	}
 
+	derivativeObjects := s.client.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{
+		Prefix:    derivativePrefix + fileID + "/",
+		Recursive: true,
+	})
+
+	for obj := range derivativeObjects {
+		if obj.Err != nil {
+			continue
+		}
+		s.client.RemoveObject(ctx, s.bucketName, obj.Key, minio.RemoveObjectOptions{})
+	}
+
+	return nil
+}
+
+func (s *StorageService) DownloadDerivative(ctx context.Context, fileID, variant string) (io.ReadCloser, *minio.ObjectInfo, error) {
+	objectName := derivativePrefix + fileID + "/" + variant
+
+	object, err := s.client.GetObject(ctx, s.bucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get derivative: %w", err)
+	}
+
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, nil, fmt.Errorf("derivative not found: %w", err)
+	}
+
+	return object, &info, nil
+}
+
+func (s *StorageService) UploadDerivative(ctx context.Context, fileID, variant string, data []byte, contentType string) error {
+	objectName := derivativePrefix + fileID + "/" + variant
+
+	_, err := s.client.PutObject(
+		ctx,
+		s.bucketName,
+		objectName,
+		bytes.NewReader(data),
+		int64(len(data)),
+		minio.PutObjectOptions{
+			ContentType: contentType,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upload derivative: %w", err)
+	}
+
+	return nil
+}
+
+// UploadAvatar crops an image to a square avatar, stores it as WebP and
+// returns its ID. Files over MaxAvatarFileSize and images over
+// maxAvatarPixels are refused before being decoded.
+func (s *StorageService) UploadAvatar(ctx context.Context, reader io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, MaxAvatarFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if len(data) > MaxAvatarFileSize {
+		return "", fmt.Errorf("avatar must be at most %d MB", MaxAvatarFileSize>>20)
+	}
+
+	dimensions, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if dimensions.Width <= 0 || dimensions.Height <= 0 || dimensions.Width*dimensions.Height > maxAvatarPixels {
+		return "", fmt.Errorf("avatar must be at most %d megapixels", maxAvatarPixels/1_000_000)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	avatar := imaging.Fill(img, avatarSize, avatarSize, imaging.Center, imaging.Lanczos)
+
+	var buf bytes.Buffer
+	if err := webp.Encode(&buf, avatar, &webp.Options{Quality: 85}); err != nil {
+		return "", fmt.Errorf("failed to encode avatar as WebP: %w", err)
+	}
+
+	avatarID := uuid.New().String()
+	_, err = s.client.PutObject(
+		ctx,
+		s.bucketName,
+		avatarPrefix+avatarID+".webp",
+		bytes.NewReader(buf.Bytes()),
+		int64(buf.Len()),
+		minio.PutObjectOptions{
+			ContentType: "image/webp",
+		},
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload avatar: %w", err)
+	}
+
+	return avatarID, nil
+}
+
+func (s *StorageService) DownloadAvatar(ctx context.Context, avatarID string) (io.ReadCloser, *minio.ObjectInfo, error) {
+	object, err := s.client.GetObject(ctx, s.bucketName, avatarPrefix+avatarID+".webp", minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get avatar: %w", err)
+	}
+
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, nil, fmt.Errorf("avatar not found: %w", err)
+	}
+
+	return object, &info, nil
+}
+
+func (s *StorageService) DeleteAvatar(ctx context.Context, avatarID string) error {
+	err := s.client.RemoveObject(ctx, s.bucketName, avatarPrefix+avatarID+".webp", minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete avatar: %w", err)
+	}
 	return nil
 }
 
 func isImageContentType(contentType string) bool {
 	return strings.HasPrefix(contentType, "image/")
 }
+
+// ReplacePhoto overwrites a stored photo in place, keeping its file ID.
+func (s *StorageService) ReplacePhoto(ctx context.Context, fileID string, data []byte, contentType string) error {
+	objectName := fmt.Sprintf("%s%s.webp", photosPrefix, fileID)
+
+	_, err := s.client.PutObject(
+		ctx,
+		s.bucketName,
+		objectName,
+		bytes.NewReader(data),
+		int64(len(data)),
+		minio.PutObjectOptions{
+			ContentType: contentType,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to replace photo: %w", err)
+	}
+
+	return nil
+}
+
+func (s *StorageService) ImportPhoto(ctx context.Context, photoData []byte, thumbnailData []byte) (*UploadResult, error) {
+	fileID := uuid.New().String()
+	objectName := fmt.Sprintf("%s%s.webp", photosPrefix, fileID)
+
+	_, err := s.client.PutObject(
+		ctx,
+		s.bucketName,
+		objectName,
+		bytes.NewReader(photoData),
+		int64(len(photoData)),
+		minio.PutObjectOptions{
+			ContentType: "image/webp",
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload photo: %w", err)
+	}
+
+	result := &UploadResult{
+		FileID:      fileID,
+		FileName:    objectName,
+		Size:        int64(len(photoData)),
+		ContentType: "image/webp",
+		UploadedAt:  time.Now(),
+	}
+
+	if thumbnailData == nil {
+		thumbnailID, err := s.generateThumbnail(ctx, fileID, bytes.NewReader(photoData))
+		if err != nil {
+			return result, nil
+		}
+		result.ThumbnailID = thumbnailID
+		return result, nil
+	}
+
+	thumbnailName := fmt.Sprintf("%s%s.webp", thumbnailPrefix, fileID)
+	_, err = s.client.PutObject(
+		ctx,
+		s.bucketName,
+		thumbnailName,
+		bytes.NewReader(thumbnailData),
+		int64(len(thumbnailData)),
+		minio.PutObjectOptions{
+			ContentType: "image/webp",
+		},
+	)
+	if err != nil {
+		return result, nil
+	}
+	result.ThumbnailID = fileID
+
+	return result, nil
+}
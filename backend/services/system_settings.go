@@ -4,9 +4,29 @@ import (
 	"context"
 	"strconv"
 
+	"github.com/suipic/backend/models"
 	"github.com/suipic/backend/repository"
 )
 
+const (
+	SettingGPSRetain = "gps_retain"
+	SettingGPSExpose = "gps_expose"
+	SettingGPSIndex  = "gps_index"
+	SettingGPSStrip  = "gps_strip"
+
+	SettingRequireEmailVerification = "require_email_verification"
+	SettingRequireMFAPrivileged     = "require_mfa_privileged"
+
+	SettingLoginMaxFailuresAccount   = "login_max_failures_account"
+	SettingLoginMaxFailuresIP        = "login_max_failures_ip"
+	SettingLoginFailureWindowMinutes = "login_failure_window_minutes"
+	SettingLoginLockoutMinutes       = "login_lockout_minutes"
+	SettingLoginBackoffBaseSeconds   = "login_backoff_base_seconds"
+
+	SettingRegistrationMode           = "registration_mode"
+	SettingRegistrationAllowedDomains = "registration_allowed_domains"
+)
+
 type SystemSettingsService struct {
 	repo repository.SystemSettingsRepository
 }
@@ -29,6 +49,34 @@ func (s *SystemSettingsService) UpdateSetting(ctx context.Context, key string, v
 	return s.repo.Set(ctx, key, value)
 }
 
+// GetBool reads a boolean setting, falling back to fallback when it is
+// missing or not a boolean.
+func (s *SystemSettingsService) GetBool(ctx context.Context, key string, fallback bool) bool {
+	value, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+// GetInt reads a non-negative integer setting, falling back to fallback
+// when it is missing or not such a number.
+func (s *SystemSettingsService) GetInt(ctx context.Context, key string, fallback int) int {
+	value, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return fallback
+	}
+	return parsed
+}
+
 func (s *SystemSettingsService) GetImageProtectionEnabled(ctx context.Context) (bool, error) {
 	value, err := s.repo.Get(ctx, "image_protection_enabled")
 	if err != nil {
@@ -42,3 +90,30 @@ func (s *SystemSettingsService) GetImageProtectionEnabled(ctx context.Context) (
 
 	return enabled, nil
 }
+
+// GetGPSPolicy returns the system-wide GPS policy. Settings that are missing
+// or unparseable fall back to keeping coordinates available while stripping
+// them from served files, which matches behaviour before the policy existed.
+func (s *SystemSettingsService) GetGPSPolicy(ctx context.Context) (models.GPSPolicy, error) {
+	policy := models.GPSPolicy{Retain: true, Expose: true, Index: true, Strip: true}
+
+	settings, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return policy, err
+	}
+
+	for key, target := range map[string]*bool{
+		SettingGPSRetain: &policy.Retain,
+		SettingGPSExpose: &policy.Expose,
+		SettingGPSIndex:  &policy.Index,
+		SettingGPSStrip:  &policy.Strip,
+	} {
+		if value, ok := settings[key]; ok {
+			if parsed, err := strconv.ParseBool(value); err == nil {
+				*target = parsed
+			}
+		}
+	}
+
+	return models.GPSPolicyOverride{}.Resolve(policy), nil
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/suipic/backend/mailer"
+	"github.com/suipic/backend/models"
+	"github.com/suipic/backend/repository"
+)
+
+const (
+	SessionRevokedSuspended     = "account_suspended"
+	SessionRevokedPasswordForce = "password_reset_forced"
+
+	maxSuspensionReasonLen = 500
+)
+
+var (
+	ErrAccountSuspended      = errors.New("account is suspended")
+	ErrPasswordResetRequired = errors.New("a password reset is required; use the link sent to your email address")
+)
+
+// UserChanges are the fields an admin edits on an account. Nil fields are
+// left alone.
+type UserChanges struct {
+	Email        *string
+	Username     *string
+	FriendlyName *string
+	Role         *models.UserRole
+}
+
+// checkAccountUsable refuses sign-in to suspended accounts and to accounts
+// that have to reset their password first.
+func checkAccountUsable(user *models.User) error {
+	if user.Suspended() {
+		return ErrAccountSuspended
+	}
+	if user.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// ActiveUser loads the user a request is made for, failing when the
+// account no longer exists or is suspended.
+func (s *AuthService) ActiveUser(ctx context.Context, userID int64) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, int(userID))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.Suspended() {
+		return nil, ErrAccountSuspended
+	}
+	return user, nil
+}
+
+func (s *AuthService) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error) {
+	users, total, err := s.userRepo.Search(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+	return users, total, nil
+}
+
+// CreateUser creates an account of any role on behalf of an admin.
+func (s *AuthService) CreateUser(email, username, password, friendlyName string, role models.UserRole) (*models.User, error) {
+	email, username = strings.TrimSpace(email), strings.TrimSpace(username)
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	friendlyName = strings.TrimSpace(friendlyName)
+	if len(friendlyName) > maxFriendlyNameLen {
+		return nil, fmt.Errorf("friendly name must be at most %d characters", maxFriendlyNameLen)
+	}
+
+	return s.RegisterWithFriendlyName(email, username, password, friendlyName, role)
+}
+
+// UpdateUserAsAdmin applies an admin's edits to an account. Admins cannot
+// change their own role, so there is always someone left to undo mistakes.
+func (s *AuthService) UpdateUserAsAdmin(ctx context.Context, actorID int64, user *models.User, changes UserChanges) error {
+	if changes.Email != nil {
+		email := strings.TrimSpace(*changes.Email)
+		if !strings.EqualFold(email, user.Email) {
+			if err := validateEmail(email); err != nil {
+				return err
+			}
+			existing, err := s.dbService.GetUserByEmail(email)
+			if err != nil {
+				return err
+			}
+			if existing != nil && existing.ID != user.ID {
+				return fmt.Errorf("user with email already exists")
+			}
+			// Nobody has shown they read the new address yet.
+			user.EmailVerifiedAt = nil
+		}
+		user.Email = email
+	}
+
+	if changes.Username != nil {
+		username := strings.TrimSpace(*changes.Username)
+		if username != user.Username {
+			if err := validateUsername(username); err != nil {
+				return err
+			}
+			existing, err := s.dbService.GetUserByUsername(username)
+			if err != nil {
+				return err
+			}
+			if existing != nil && existing.ID != user.ID {
+				return fmt.Errorf("user with username already exists")
+			}
+		}
+		user.Username = username
+	}
+
+	if changes.FriendlyName != nil {
+		friendlyName := strings.TrimSpace(*changes.FriendlyName)
+		if len(friendlyName) > maxFriendlyNameLen {
+			return fmt.Errorf("friendly name must be at most %d characters", maxFriendlyNameLen)
+		}
+		user.FriendlyName = friendlyName
+	}
+
+	if changes.Role != nil && *changes.Role != user.Role {
+		if err := validateRole(*changes.Role); err != nil {
+			return err
+		}
+		if user.ID == actorID {
+			return fmt.Errorf("you cannot change your own role")
+		}
+		user.Role = *changes.Role
+	}
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// SuspendUser disables an account and signs it out everywhere. Its API
+// tokens stop working until the account is unsuspended.
+func (s *AuthService) SuspendUser(ctx context.Context, actorID int64, user *models.User, reason string) error {
+	if user.ID == actorID {
+		return fmt.Errorf("you cannot suspend your own account")
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > maxSuspensionReasonLen {
+		return fmt.Errorf("reason must be at most %d characters", maxSuspensionReasonLen)
+	}
+
+	now := time.Now()
+	user.SuspendedAt = &now
+	user.SuspensionReason = nil
+	if reason != "" {
+		user.SuspensionReason = &reason
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if _, err := s.sessionRepo.RevokeAllForUser(ctx, user.ID, "", SessionRevokedSuspended); err != nil {
+		fmt.Printf("Warning: failed to revoke sessions of suspended user %d: %v\n", user.ID, err)
+	}
+	return nil
+}
+
+func (s *AuthService) UnsuspendUser(ctx context.Context, user *models.User) error {
+	user.SuspendedAt = nil
+	user.SuspensionReason = nil
+	return s.userRepo.Update(ctx, user)
+}
+
+// SetUserPassword sets a new password for a user who cannot reset it
+// themselves, signs them out everywhere and revokes their API tokens.
+func (s *AuthService) SetUserPassword(ctx context.Context, user *models.User, password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.PasswordHash = hashedPassword
+	user.PasswordResetRequired = false
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if _, err := s.sessionRepo.RevokeAllForUser(ctx, user.ID, "", SessionRevokedAdmin); err != nil {
+		fmt.Printf("Warning: failed to revoke sessions of user %d after password change: %v\n", user.ID, err)
+	}
+	if _, err := s.apiTokenRepo.RevokeAllForUser(ctx, user.ID); err != nil {
+		fmt.Printf("Warning: failed to revoke api tokens of user %d: %v\n", user.ID, err)
+	}
+	if _, err := s.UnlockUser(ctx, user.ID); err != nil {
+		fmt.Printf("Warning: failed to unlock user %d after password change: %v\n", user.ID, err)
+	}
+	return nil
+}
+
+// ForcePasswordReset signs the user out everywhere, revokes their API
+// tokens and blocks sign-in until they set a new password through the
+// emailed reset link, for when their password may have leaked.
+func (s *AuthService) ForcePasswordReset(ctx context.Context, user *models.User) error {
+	user.PasswordResetRequired = true
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if _, err := s.sessionRepo.RevokeAllForUser(ctx, user.ID, "", SessionRevokedPasswordForce); err != nil {
+		fmt.Printf("Warning: failed to revoke sessions of user %d: %v\n", user.ID, err)
+	}
+	if _, err := s.apiTokenRepo.RevokeAllForUser(ctx, user.ID); err != nil {
+		fmt.Printf("Warning: failed to revoke api tokens of user %d: %v\n", user.ID, err)
+	}
+
+	token, err := s.issueUserToken(ctx, user.ID, models.TokenPurposePasswordReset, nil, passwordResetExpiry)
+	if err != nil {
+		return err
+	}
+	return s.sendAccountEmail(user, user.Email, mailer.TemplatePasswordReset, "Reset password", "/reset-password", token, passwordResetExpiry)
+}
+
+// DeleteUser removes an account. Albums of a photographer are deleted with
+// it, so that needs force.
+func (s *AuthService) DeleteUser(ctx context.Context, actorID int64, user *models.User, force bool) error {
+	if user.ID == actorID {
+		return fmt.Errorf("you cannot delete your own account")
+	}
+
+	if !force {
+		albums, err := repository.NewPostgresAlbumRepository(s.dbService.GetDB()).GetByPhotographer(ctx, int(user.ID))
+		if err != nil {
+			return err
+		}
+		if len(albums) > 0 {
+			return fmt.Errorf("user owns %d albums, which would be deleted too; pass force=true to delete anyway", len(albums))
+		}
+	}
+
+	return s.userRepo.Delete(ctx, int(user.ID))
+}
+
+func validateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return fmt.Errorf("invalid email address")
+	}
+	return nil
+}
+
+func validateUsername(username string) error {
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if len(username) > maxUsernameLength {
+		return fmt.Errorf("username must be at most %d characters", maxUsernameLength)
+	}
+	return nil
+}
+
+func validateRole(role models.UserRole) error {
+	switch role {
+	case models.RoleAdmin, models.RolePhotographer, models.RoleClient:
+		return nil
+	default:
+		return fmt.Errorf("invalid role %q", role)
+	}
+}
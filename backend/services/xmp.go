@@ -0,0 +1,276 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/suipic/backend/metadata"
+	"github.com/suipic/backend/models"
+)
+
+const (
+	xmpNamespaceRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmpNamespaceXMP = "http://ns.adobe.com/xap/1.0/"
+	xmpNamespaceDC  = "http://purl.org/dc/elements/1.1/"
+
+	// Lightroom writes a rejected flag as rating -1 and has no XMP field for
+	// picks, so picks are carried as a colour label.
+	xmpRejectRating = -1
+	xmpPickLabel    = "Green"
+)
+
+type XMPSidecar struct {
+	Rating   *int
+	Label    string
+	Title    string
+	Caption  string
+	Keywords []string
+}
+
+type XMPImportResult struct {
+	Updated   int      `json:"updated"`
+	Unmatched []string `json:"unmatched"`
+
+	// Changes lists the updated photos with their rating and pick state
+	// from before the import, for the audit log.
+	Changes []PhotoRatingChange `json:"-"`
+}
+
+// PhotoRatingChange is a photo as updated, with the rating and pick state it
+// had before.
+type PhotoRatingChange struct {
+	Photo         *models.Photo
+	PreviousStars int
+	PreviousState models.PickRejectState
+}
+
+type XMPService struct {
+	photoService *PhotoService
+}
+
+func NewXMPService(photoService *PhotoService) *XMPService {
+	return &XMPService{
+		photoService: photoService,
+	}
+}
+
+func (s *XMPService) ExportAlbum(ctx context.Context, albumID int, bundle BundleWriter) (int, error) {
+	photos, err := s.photoService.GetPhotosByAlbum(ctx, albumID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get photos: %w", err)
+	}
+
+	names := sidecarNames(photos)
+	for i, photo := range photos {
+		if err := bundle.WriteFile(names[i], bytes.NewReader(BuildXMPSidecar(photo))); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(photos), nil
+}
+
+func (s *XMPService) ImportSidecars(ctx context.Context, albumID int, sidecars map[string][]byte) (*XMPImportResult, error) {
+	photos, err := s.photoService.GetPhotosByAlbum(ctx, albumID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photos: %w", err)
+	}
+
+	// Match on the names ExportAlbum gives the sidecars, so that photos
+	// sharing a base name come back to the right photo.
+	byName := make(map[string]*models.Photo)
+	for i, name := range sidecarNames(photos) {
+		byName[sidecarKey(name)] = photos[i]
+	}
+
+	result := &XMPImportResult{Unmatched: []string{}}
+	for name, data := range sidecars {
+		photo, ok := byName[sidecarKey(name)]
+		if !ok {
+			result.Unmatched = append(result.Unmatched, name)
+			continue
+		}
+
+		sidecar, err := ParseXMP(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+
+		change := PhotoRatingChange{Photo: photo, PreviousStars: photo.Stars, PreviousState: photo.PickRejectState}
+		if !applySidecar(photo, sidecar) {
+			continue
+		}
+
+		if err := s.photoService.UpdatePhoto(ctx, photo); err != nil {
+			return nil, fmt.Errorf("failed to update photo %d: %w", photo.ID, err)
+		}
+		result.Updated++
+		result.Changes = append(result.Changes, change)
+	}
+
+	return result, nil
+}
+
+// applySidecar copies the sidecar's fields onto the photo and reports
+// whether anything changed. A sidecar with a rating carries the whole pick
+// state: the reject rating rejects, the pick label picks and any other
+// rating clears a pick or reject, as it does when an editor un-rejects a
+// photo. Without a rating the pick state only changes on the pick label.
+func applySidecar(photo *models.Photo, sidecar *XMPSidecar) bool {
+	changed := false
+
+	state := photo.PickRejectState
+	switch {
+	case sidecar.Rating != nil && *sidecar.Rating == xmpRejectRating:
+		state = models.PickRejectReject
+	case strings.EqualFold(sidecar.Label, xmpPickLabel):
+		state = models.PickRejectPick
+	case sidecar.Rating != nil && *sidecar.Rating >= 0:
+		state = models.PickRejectNone
+	}
+	if photo.PickRejectState != state {
+		photo.PickRejectState = state
+		changed = true
+	}
+
+	if sidecar.Rating != nil {
+		rating := *sidecar.Rating
+		if rating >= 0 && rating <= 5 && photo.Stars != rating {
+			photo.Stars = rating
+			changed = true
+		}
+	}
+
+	if sidecar.Title != "" && (photo.Title == nil || *photo.Title != sidecar.Title) {
+		title := sidecar.Title
+		photo.Title = &title
+		changed = true
+	}
+
+	if sidecar.Caption != "" && (photo.Caption == nil || *photo.Caption != sidecar.Caption) {
+		caption := sidecar.Caption
+		photo.Caption = &caption
+		changed = true
+	}
+
+	if sidecar.Keywords != nil && !slices.Equal(photo.Keywords, sidecar.Keywords) {
+		photo.Keywords = sidecar.Keywords
+		changed = true
+	}
+
+	return changed
+}
+
+func SidecarName(photo *models.Photo) string {
+	name := photo.Filename
+	if photo.OriginalFilename != nil && *photo.OriginalFilename != "" {
+		name = path.Base(*photo.OriginalFilename)
+	}
+	return strings.TrimSuffix(name, path.Ext(name)) + ".xmp"
+}
+
+// sidecarNames names the sidecar of each photo, in order. Photos whose
+// sidecar name is already taken get their ID appended.
+func sidecarNames(photos []*models.Photo) []string {
+	names := make([]string, len(photos))
+	used := make(map[string]bool)
+	for i, photo := range photos {
+		name := SidecarName(photo)
+		if used[strings.ToLower(name)] {
+			name = fmt.Sprintf("%s-%d.xmp", strings.TrimSuffix(name, ".xmp"), photo.ID)
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+func sidecarKey(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	return strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+}
+
+func BuildXMPSidecar(photo *models.Photo) []byte {
+	rating := photo.Stars
+	label := ""
+	switch photo.PickRejectState {
+	case models.PickRejectReject:
+		rating = xmpRejectRating
+	case models.PickRejectPick:
+		label = xmpPickLabel
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xpacket begin="` + "\uFEFF" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>` + "\n")
+	buf.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	buf.WriteString(` <rdf:RDF xmlns:rdf="` + xmpNamespaceRDF + `">` + "\n")
+	buf.WriteString(`  <rdf:Description rdf:about=""` + "\n")
+	buf.WriteString(`    xmlns:xmp="` + xmpNamespaceXMP + `"` + "\n")
+	buf.WriteString(`    xmlns:dc="` + xmpNamespaceDC + `"` + "\n")
+	fmt.Fprintf(&buf, "    xmp:Rating=\"%d\"", rating)
+	if label != "" {
+		fmt.Fprintf(&buf, "\n    xmp:Label=\"%s\"", xmlEscape(label))
+	}
+	buf.WriteString(">\n")
+	if photo.Title != nil && *photo.Title != "" {
+		buf.WriteString("   <dc:title>\n    <rdf:Alt>\n")
+		fmt.Fprintf(&buf, "     <rdf:li xml:lang=\"x-default\">%s</rdf:li>\n", xmlEscape(*photo.Title))
+		buf.WriteString("    </rdf:Alt>\n   </dc:title>\n")
+	}
+	if photo.Caption != nil && *photo.Caption != "" {
+		buf.WriteString("   <dc:description>\n    <rdf:Alt>\n")
+		fmt.Fprintf(&buf, "     <rdf:li xml:lang=\"x-default\">%s</rdf:li>\n", xmlEscape(*photo.Caption))
+		buf.WriteString("    </rdf:Alt>\n   </dc:description>\n")
+	}
+	if len(photo.Keywords) > 0 {
+		buf.WriteString("   <dc:subject>\n    <rdf:Bag>\n")
+		for _, keyword := range photo.Keywords {
+			fmt.Fprintf(&buf, "     <rdf:li>%s</rdf:li>\n", xmlEscape(keyword))
+		}
+		buf.WriteString("    </rdf:Bag>\n   </dc:subject>\n")
+	}
+	buf.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	buf.WriteString(`<?xpacket end="w"?>` + "\n")
+
+	return buf.Bytes()
+}
+
+func ParseXMP(data []byte) (*XMPSidecar, error) {
+	props, err := metadata.ParseXMP(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sidecar := &XMPSidecar{}
+	if value, ok := props["xmp:Rating"].(string); ok {
+		if rating, err := strconv.Atoi(value); err == nil {
+			sidecar.Rating = &rating
+		} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+			rating := int(f)
+			sidecar.Rating = &rating
+		}
+	}
+	sidecar.Label, _ = props["xmp:Label"].(string)
+	sidecar.Title, _ = props["dc:title"].(string)
+	sidecar.Caption, _ = props["dc:description"].(string)
+	switch keywords := props["dc:subject"].(type) {
+	case []string:
+		sidecar.Keywords = keywords
+	case string:
+		sidecar.Keywords = []string{keywords}
+	}
+
+	return sidecar, nil
+}
+
+func xmlEscape(value string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
@@ -0,0 +1,68 @@
+package totp
+
+import (
+	"encoding/base32"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed from RFC 6238 Appendix B, "12345678901234567890".
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// rfc6238Vectors are the SHA-1 rows of RFC 6238 Appendix B. The RFC lists
+// eight digit codes; the six digit codes this package produces are their
+// last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, tc := range rfc6238Vectors {
+		t.Run(fmt.Sprint(tc.unix), func(t *testing.T) {
+			got, err := Code(rfc6238Secret, Step(time.Unix(tc.unix, 0)))
+			if err != nil {
+				t.Fatalf("Code: %v", err)
+			}
+			if want := tc.code[len(tc.code)-Digits:]; got != want {
+				t.Errorf("Code = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, tc := range rfc6238Vectors {
+		now := time.Unix(tc.unix, 0)
+		code := tc.code[len(tc.code)-Digits:]
+
+		step, ok := Validate(rfc6238Secret, code, now, 0)
+		if !ok || step != Step(now) {
+			t.Errorf("Validate(%s) at %d = %d, %v; want %d, true", code, tc.unix, step, ok, Step(now))
+		}
+
+		if _, ok := Validate(rfc6238Secret, code, now.Add(2*Period*time.Second), 1); ok {
+			t.Errorf("Validate(%s) accepted a code two steps old", code)
+		}
+	}
+}
+
+func TestValidateRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870822", "abcdef"} {
+		if _, ok := Validate(rfc6238Secret, code, now, 1); ok {
+			t.Errorf("Validate(%q) = true", code)
+		}
+	}
+	if _, ok := Validate(rfc6238Secret, " 287 082 ", now, 0); !ok {
+		t.Errorf("Validate rejected a code with spaces")
+	}
+}
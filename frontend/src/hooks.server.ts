@@ -1,6 +1,80 @@
-import type { Handle } from '@sveltejs/kit';
+import type { Cookies, Handle } from '@sveltejs/kit';
+
+const API_URL = process.env.VITE_API_URL || 'http://localhost:8080/api';
+
+// Access tokens are short-lived, so renew them a little before they expire.
+const REFRESH_MARGIN_SECONDS = 60;
+
+const tokenExpiresSoon = (token: string) => {
+	try {
+		const payload = JSON.parse(Buffer.from(token.split('.')[1], 'base64url').toString());
+		return typeof payload.exp !== 'number' || payload.exp - REFRESH_MARGIN_SECONDS < Date.now() / 1000;
+	} catch (e) {
+		return true;
+	}
+};
+
+const refreshSession = async (cookies: Cookies, userAgent: string, clientAddress: string) => {
+	const refreshToken = cookies.get('suipic_refresh_token');
+	if (!refreshToken) {
+		return;
+	}
+
+	const response = await fetch(`${API_URL}/auth/refresh`, {
+		method: 'POST',
+		headers: {
+			'Content-Type': 'application/json',
+			'User-Agent': userAgent,
+			'X-Forwarded-For': clientAddress
+		},
+		body: JSON.stringify({ refreshToken })
+	}).catch(() => null);
+
+	if (!response) {
+		return;
+	}
+
+	if (!response.ok) {
+		cookies.delete('suipic_token', { path: '/' });
+		cookies.delete('suipic_user', { path: '/' });
+		cookies.delete('suipic_refresh_token', { path: '/' });
+		return;
+	}
+
+	const result = await response.json();
+
+	cookies.set('suipic_token', result.token, {
+		path: '/',
+		httpOnly: false,
+		sameSite: 'strict',
+		maxAge: 60 * 60 * 24 * 7
+	});
+
+	cookies.set('suipic_user', JSON.stringify(result.user), {
+		path: '/',
+		httpOnly: false,
+		sameSite: 'strict',
+		maxAge: 60 * 60 * 24 * 7
+	});
+
+	cookies.set('suipic_refresh_token', result.refreshToken, {
+		path: '/',
+		httpOnly: true,
+		sameSite: 'strict',
+		maxAge: 60 * 60 * 24 * 30
+	});
+};
 
 export const handle: Handle = async ({ event, resolve }) => {
+	const currentToken = event.cookies.get('suipic_token');
+	if (!currentToken || tokenExpiresSoon(currentToken)) {
+		await refreshSession(
+			event.cookies,
+			event.request.headers.get('user-agent') ?? '',
+			event.getClientAddress()
+		);
+	}
+
 	const token = event.cookies.get('suipic_token');
 	const userStr = event.cookies.get('suipic_user');
 
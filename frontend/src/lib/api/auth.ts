@@ -1,4 +1,14 @@
-import type { TLoginRequest, TRegisterRequest, TAuthResponse, TUser, TRefreshRequest } from '$lib/types';
+import type {
+	TLoginRequest,
+	TLoginResponse,
+	TRegisterRequest,
+	TAuthResponse,
+	TUser,
+	TRefreshRequest,
+	TTOTPEnrollment,
+	TMFAEnrollmentResponse,
+	TOIDCProvider
+} from '$lib/types';
 import { config } from '$lib/config';
 
 const API_URL = config.apiUrl;
@@ -11,7 +21,7 @@ class AuthApiError extends Error {
 }
 
 export const authApi = {
-	async login(data: TLoginRequest): Promise<TAuthResponse> {
+	async login(data: TLoginRequest): Promise<TLoginResponse> {
 		const response = await fetch(`${API_URL}/auth/login`, {
 			method: 'POST',
 			headers: {
@@ -28,6 +38,68 @@ export const authApi = {
 		return response.json();
 	},
 
+	async verifyMfa(mfaToken: string, code: string): Promise<TAuthResponse> {
+		const response = await fetch(`${API_URL}/auth/mfa/verify`, {
+			method: 'POST',
+			headers: {
+				'Content-Type': 'application/json'
+			},
+			body: JSON.stringify({ mfaToken, code })
+		});
+
+		if (!response.ok) {
+			const error = await response.json().catch(() => ({ message: 'Verification failed' }));
+			throw new AuthApiError(error.message || 'Verification failed');
+		}
+
+		return response.json();
+	},
+
+	async beginMfaEnrollment(mfaToken: string): Promise<TTOTPEnrollment> {
+		const response = await fetch(`${API_URL}/auth/mfa/enroll`, {
+			method: 'POST',
+			headers: {
+				'Content-Type': 'application/json'
+			},
+			body: JSON.stringify({ mfaToken })
+		});
+
+		if (!response.ok) {
+			const error = await response.json().catch(() => ({ message: 'Two-factor setup failed' }));
+			throw new AuthApiError(error.message || 'Two-factor setup failed');
+		}
+
+		return response.json();
+	},
+
+	async confirmMfaEnrollment(mfaToken: string, code: string): Promise<TMFAEnrollmentResponse> {
+		const response = await fetch(`${API_URL}/auth/mfa/enroll/confirm`, {
+			method: 'POST',
+			headers: {
+				'Content-Type': 'application/json'
+			},
+			body: JSON.stringify({ mfaToken, code })
+		});
+
+		if (!response.ok) {
+			const error = await response.json().catch(() => ({ message: 'Two-factor setup failed' }));
+			throw new AuthApiError(error.message || 'Two-factor setup failed');
+		}
+
+		return response.json();
+	},
+
+	async oidcProviders(): Promise<TOIDCProvider[]> {
+		const response = await fetch(`${API_URL}/auth/oidc/providers`);
+
+		if (!response.ok) {
+			const error = await response.json().catch(() => ({ message: 'Failed to fetch sign-in providers' }));
+			throw new AuthApiError(error.message || 'Failed to fetch sign-in providers');
+		}
+
+		return response.json();
+	},
+
 	async register(data: TRegisterRequest): Promise<TAuthResponse> {
 		const response = await fetch(`${API_URL}/auth/register`, {
 			method: 'POST',
@@ -76,6 +148,53 @@ export const authApi = {
 		}
 	},
 
+	async forgotPassword(email: string): Promise<void> {
+		const response = await fetch(`${API_URL}/auth/forgot-password`, {
+			method: 'POST',
+			headers: {
+				'Content-Type': 'application/json'
+			},
+			body: JSON.stringify({ email })
+		});
+
+		if (!response.ok) {
+			const error = await response.json().catch(() => ({ message: 'Password reset request failed' }));
+			throw new AuthApiError(error.message || 'Password reset request failed');
+		}
+	},
+
+	async resetPassword(token: string, password: string): Promise<void> {
+		const response = await fetch(`${API_URL}/auth/reset-password`, {
+			method: 'POST',
+			headers: {
+				'Content-Type': 'application/json'
+			},
+			body: JSON.stringify({ token, password })
+		});
+
+		if (!response.ok) {
+			const error = await response.json().catch(() => ({ message: 'Password reset failed' }));
+			throw new AuthApiError(error.message || 'Password reset failed');
+		}
+	},
+
+	async verifyEmail(token: string): Promise<TUser> {
+		const response = await fetch(`${API_URL}/auth/verify-email`, {
+			method: 'POST',
+			headers: {
+				'Content-Type': 'application/json'
+			},
+			body: JSON.stringify({ token })
+		});
+
+		if (!response.ok) {
+			const error = await response.json().catch(() => ({ message: 'Email verification failed' }));
+			throw new AuthApiError(error.message || 'Email verification failed');
+		}
+
+		return response.json();
+	},
+
 	async me(token: string): Promise<TUser> {
 		const response = await fetch(`${API_URL}/auth/me`, {
 			method: 'GET',
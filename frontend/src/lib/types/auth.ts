@@ -10,6 +10,8 @@ export type TUser = {
 	email: string;
 	friendlyName: string;
 	role: EUserRole;
+	avatarId?: string;
+	emailVerifiedAt?: string;
 	createdAt: string;
 	updatedAt: string;
 };
@@ -25,13 +27,39 @@ export type TRegisterRequest = {
 	username: string;
 	password: string;
 	role: EUserRole;
+	avatarId?: string;
+	emailVerifiedAt?: string;
 };
 
 export type TAuthResponse = {
 	user: TUser;
 	token: string;
+	refreshToken: string;
+	expiresIn: number;
+};
+
+export type TMFAChallenge = {
+	mfaRequired: boolean;
+	mfaEnrollmentRequired: boolean;
+	mfaToken: string;
+};
+
+export type TLoginResponse = TAuthResponse | TMFAChallenge;
+
+export type TTOTPEnrollment = {
+	secret: string;
+	provisioningUri: string;
+};
+
+export type TMFAEnrollmentResponse = TAuthResponse & {
+	recoveryCodes: string[];
+};
+
+export type TOIDCProvider = {
+	id: string;
+	name: string;
 };
 
 export type TRefreshRequest = {
-	token: string;
+	refreshToken: string;
 };
@@ -1,7 +1,17 @@
 import type { LayoutServerLoad } from './$types';
 import { redirect } from '@sveltejs/kit';
 
-const publicRoutes = ['/login', '/register', '/about', '/contact', '/privacy', '/terms'];
+const publicRoutes = [
+	'/login',
+	'/register',
+	'/forgot-password',
+	'/reset-password',
+	'/verify-email',
+	'/about',
+	'/contact',
+	'/privacy',
+	'/terms'
+];
 const adminRoutes = ['/admin'];
 const photographerRoutes = ['/albums/new'];
 
@@ -22,7 +32,10 @@ export const load: LayoutServerLoad = async ({ cookies, url }) => {
 	const isAuthenticated = !!(token && user);
 	const pathname = url.pathname;
 
-	if (!isAuthenticated && !publicRoutes.includes(pathname) && pathname !== '/') {
+	const isPublic =
+		publicRoutes.includes(pathname) || pathname === '/' || pathname.startsWith('/login/oidc/');
+
+	if (!isAuthenticated && !isPublic) {
 		throw redirect(303, '/login');
 	}
 
@@ -4,7 +4,7 @@ import { fail, redirect } from '@sveltejs/kit';
 const API_URL = process.env.VITE_API_URL || 'http://localhost:8080/api';
 
 export const actions = {
-	default: async ({ request, cookies }) => {
+	default: async ({ request, cookies, getClientAddress }) => {
 		const data = await request.formData();
 		const username = data.get('username') as string;
 		const email = data.get('email') as string;
@@ -22,7 +22,9 @@ export const actions = {
 			const response = await fetch(`${API_URL}/auth/login`, {
 				method: 'POST',
 				headers: {
-					'Content-Type': 'application/json'
+					'Content-Type': 'application/json',
+					'User-Agent': request.headers.get('user-agent') ?? '',
+					'X-Forwarded-For': getClientAddress()
 				},
 				body: JSON.stringify({
 					username: username || undefined,
@@ -38,6 +40,14 @@ export const actions = {
 
 			const result = await response.json();
 
+			if (result.mfaToken) {
+				return {
+					mfaToken: result.mfaToken,
+					mfaRequired: result.mfaRequired,
+					mfaEnrollmentRequired: result.mfaEnrollmentRequired
+				};
+			}
+
 			cookies.set('suipic_token', result.token, {
 				path: '/',
 				httpOnly: false,
@@ -52,6 +62,13 @@ export const actions = {
 				maxAge: 60 * 60 * 24 * 7
 			});
 
+			cookies.set('suipic_refresh_token', result.refreshToken, {
+				path: '/',
+				httpOnly: true,
+				sameSite: 'strict',
+				maxAge: 60 * 60 * 24 * 30
+			});
+
 			throw redirect(303, '/');
 		} catch (error) {
 			if (error instanceof Response) {
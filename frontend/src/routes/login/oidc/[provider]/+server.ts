@@ -0,0 +1,30 @@
+import type { RequestHandler } from './$types';
+import { error, redirect } from '@sveltejs/kit';
+
+const API_URL = process.env.VITE_API_URL || 'http://localhost:8080/api';
+
+// Starts single sign-on: the backend picks the state and PKCE verifier, and
+// the state is kept in a cookie so the callback can prove it came back to the
+// browser that started it.
+export const GET: RequestHandler = async ({ params, cookies }) => {
+	const response = await fetch(`${API_URL}/auth/oidc/${encodeURIComponent(params.provider)}/start`, {
+		method: 'POST'
+	}).catch(() => null);
+
+	if (!response || !response.ok) {
+		throw error(502, 'Could not reach the identity provider');
+	}
+
+	const result = await response.json();
+
+	// Lax, not strict: the cookie has to come along when the identity
+	// provider sends the browser back.
+	cookies.set('suipic_oidc_state', result.state, {
+		path: '/login/oidc',
+		httpOnly: true,
+		sameSite: 'lax',
+		maxAge: 60 * 10
+	});
+
+	throw redirect(303, result.authorizationUrl);
+};
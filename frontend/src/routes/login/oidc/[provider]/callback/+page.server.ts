@@ -0,0 +1,68 @@
+import type { PageServerLoad } from './$types';
+
+const API_URL = process.env.VITE_API_URL || 'http://localhost:8080/api';
+
+export const load: PageServerLoad = async ({ params, url, cookies, request, getClientAddress }) => {
+	const expectedState = cookies.get('suipic_oidc_state');
+	cookies.delete('suipic_oidc_state', { path: '/login/oidc' });
+
+	const providerError = url.searchParams.get('error_description') || url.searchParams.get('error');
+	if (providerError) {
+		return { error: providerError };
+	}
+
+	const code = url.searchParams.get('code');
+	const state = url.searchParams.get('state');
+	if (!code || !state || state !== expectedState) {
+		return { error: 'Sign-in expired or was started in another browser. Please try again.' };
+	}
+
+	const response = await fetch(`${API_URL}/auth/oidc/${encodeURIComponent(params.provider)}/callback`, {
+		method: 'POST',
+		headers: {
+			'Content-Type': 'application/json',
+			'User-Agent': request.headers.get('user-agent') ?? '',
+			'X-Forwarded-For': getClientAddress()
+		},
+		body: JSON.stringify({ code, state })
+	}).catch(() => null);
+
+	if (!response) {
+		return { error: 'An unexpected error occurred' };
+	}
+
+	const result = await response.json().catch(() => ({ message: 'Sign-in failed' }));
+	if (!response.ok) {
+		return { error: result.message || 'Sign-in failed' };
+	}
+
+	if (result.mfaToken) {
+		return {
+			mfaToken: result.mfaToken as string,
+			mfaEnrollmentRequired: !!result.mfaEnrollmentRequired
+		};
+	}
+
+	cookies.set('suipic_token', result.token, {
+		path: '/',
+		httpOnly: false,
+		sameSite: 'strict',
+		maxAge: 60 * 60 * 24 * 7
+	});
+
+	cookies.set('suipic_user', JSON.stringify(result.user), {
+		path: '/',
+		httpOnly: false,
+		sameSite: 'strict',
+		maxAge: 60 * 60 * 24 * 7
+	});
+
+	cookies.set('suipic_refresh_token', result.refreshToken, {
+		path: '/',
+		httpOnly: true,
+		sameSite: 'strict',
+		maxAge: 60 * 60 * 24 * 30
+	});
+
+	return { user: result.user, token: result.token as string };
+};
@@ -1,13 +1,24 @@
 import type { Actions, PageServerLoad } from './$types';
 import { redirect } from '@sveltejs/kit';
 
+const API_URL = process.env.VITE_API_URL || 'http://localhost:8080/api';
+
 export const load: PageServerLoad = async () => {
 	throw redirect(303, '/login');
 };
 
 export const actions = {
 	default: async ({ cookies }) => {
+		const token = cookies.get('suipic_token');
+		if (token) {
+			await fetch(`${API_URL}/auth/logout`, {
+				method: 'POST',
+				headers: { Authorization: `Bearer ${token}` }
+			}).catch(() => undefined);
+		}
+
 		cookies.delete('suipic_token', { path: '/' });
+		cookies.delete('suipic_refresh_token', { path: '/' });
 		cookies.delete('suipic_user', { path: '/' });
 		throw redirect(303, '/login');
 	}
@@ -4,7 +4,7 @@ import { fail, redirect } from '@sveltejs/kit';
 const API_URL = process.env.VITE_API_URL || 'http://localhost:8080/api';
 
 export const actions = {
-	default: async ({ request, cookies }) => {
+	default: async ({ request, cookies, getClientAddress }) => {
 		const data = await request.formData();
 		const email = data.get('email') as string;
 		const username = data.get('username') as string;
@@ -19,7 +19,9 @@ export const actions = {
 			const response = await fetch(`${API_URL}/auth/register`, {
 				method: 'POST',
 				headers: {
-					'Content-Type': 'application/json'
+					'Content-Type': 'application/json',
+					'User-Agent': request.headers.get('user-agent') ?? '',
+					'X-Forwarded-For': getClientAddress()
 				},
 				body: JSON.stringify({
 					email,
@@ -36,6 +38,10 @@ export const actions = {
 
 			const result = await response.json();
 
+			if (!result.token) {
+				throw redirect(303, '/login?verify=1');
+			}
+
 			cookies.set('suipic_token', result.token, {
 				path: '/',
 				httpOnly: false,
@@ -50,6 +56,13 @@ export const actions = {
 				maxAge: 60 * 60 * 24 * 7
 			});
 
+			cookies.set('suipic_refresh_token', result.refreshToken, {
+				path: '/',
+				httpOnly: true,
+				sameSite: 'strict',
+				maxAge: 60 * 60 * 24 * 30
+			});
+
 			throw redirect(303, '/');
 		} catch (error) {
 			if (error instanceof Response) {